@@ -0,0 +1,157 @@
+// Package analytics computes dashboard reports. The database selects the
+// rows in range and Go groups them into date buckets in the server's time
+// zone (time.Local), so the reports read the same on every SQL dialect and
+// whatever time zone the database session uses. Each report runs on the
+// *gorm.DB it is given, such as one with a request's context.
+package analytics
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"gym-api/models"
+
+	"gorm.io/gorm"
+)
+
+type Granularity string
+
+const (
+	Hour  Granularity = "hour"
+	Day   Granularity = "day"
+	Week  Granularity = "week"
+	Month Granularity = "month"
+)
+
+// maxBuckets bounds the size of a series so a wide range at hourly
+// granularity can't produce an enormous response.
+const maxBuckets = 2000
+
+// maxHeatmapRange bounds the check-ins a heatmap scans, which has a fixed
+// size however wide the range.
+const maxHeatmapRange = 366 * 24 * time.Hour
+
+var (
+	ErrTooManyBuckets = errors.New("date range is too large for this granularity")
+	ErrRangeTooLarge  = errors.New("date range is too large, at most a year")
+)
+
+// Params are the filters shared by every report. BranchID and PackageID are
+// optional.
+type Params struct {
+	From        time.Time
+	To          time.Time
+	Granularity Granularity
+	BranchID    *uint
+	PackageID   *uint
+}
+
+func ParseGranularity(s string) (Granularity, error) {
+	switch g := Granularity(s); g {
+	case Hour, Day, Week, Month:
+		return g, nil
+	case "":
+		return Day, nil
+	}
+	return "", fmt.Errorf("invalid granularity %q, expected hour, day, week or month", s)
+}
+
+// Truncate returns the start of the bucket t falls into. Weeks start on
+// Monday.
+func (g Granularity) Truncate(t time.Time) time.Time {
+	t = t.In(time.Local)
+	switch g {
+	case Hour:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, time.Local)
+	case Week:
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset)
+	case Month:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.Local)
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+	}
+}
+
+func (g Granularity) Next(t time.Time) time.Time {
+	switch g {
+	case Hour:
+		return t.Add(time.Hour)
+	case Week:
+		return t.AddDate(0, 0, 7)
+	case Month:
+		return t.AddDate(0, 1, 0)
+	default:
+		return t.AddDate(0, 0, 1)
+	}
+}
+
+// Label formats a bucket start for display.
+func (g Granularity) Label(t time.Time) string {
+	switch g {
+	case Hour:
+		return t.Format("2006-01-02T15:00")
+	case Month:
+		return t.Format("2006-01")
+	default:
+		return t.Format("2006-01-02")
+	}
+}
+
+// eachRow runs db and calls scan for every row it returns, without
+// holding them all in memory.
+func eachRow(db *gorm.DB, scan func(*sql.Rows) error) error {
+	rows, err := db.Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// buckets lists every bucket start between From and To.
+func (p Params) buckets() ([]time.Time, error) {
+	var out []time.Time
+	for t := p.Granularity.Truncate(p.From); t.Before(p.To); t = p.Granularity.Next(t) {
+		if len(out) >= maxBuckets {
+			return nil, ErrTooManyBuckets
+		}
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+// members scopes a query on users to members matching the filters.
+func (p Params) members(db *gorm.DB) *gorm.DB {
+	db = db.Where("users.role = ?", models.RoleMember)
+	if p.BranchID != nil {
+		db = db.Where("users.branch_id = ?", *p.BranchID)
+	}
+	if p.PackageID != nil {
+		db = db.Where("users.package_id = ?", *p.PackageID)
+	}
+	return db
+}
+
+// visits scopes a query to attendances in the range matching the filters.
+// Branch applies to where the visit happened, package to the visitor.
+func (p Params) visits(db *gorm.DB) *gorm.DB {
+	db = db.Table("attendances").
+		Where("attendances.scan_time >= ? AND attendances.scan_time < ?", p.From, p.To)
+	if p.BranchID != nil {
+		db = db.Where("attendances.branch_id = ?", *p.BranchID)
+	}
+	if p.PackageID != nil {
+		db = db.Joins("JOIN users ON users.id = attendances.trainer_id").
+			Where("users.package_id = ?", *p.PackageID)
+	}
+	return db
+}
@@ -0,0 +1,321 @@
+package analytics
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"time"
+
+	"gym-api/models"
+
+	"gorm.io/gorm"
+)
+
+type SeriesPoint struct {
+	Period string `json:"period"`
+	Count  int64  `json:"count"`
+}
+
+// countBy counts the rows of db by the bucket label of column.
+func (p Params) countBy(db *gorm.DB, column string) (map[string]int64, error) {
+	counts := map[string]int64{}
+	err := eachRow(db.Select(column), func(rows *sql.Rows) error {
+		var t time.Time
+		if err := rows.Scan(&t); err != nil {
+			return err
+		}
+		counts[p.Granularity.Label(p.Granularity.Truncate(t))]++
+		return nil
+	})
+	return counts, err
+}
+
+// Attendance counts check-ins per bucket.
+func Attendance(db *gorm.DB, p Params) ([]SeriesPoint, error) {
+	buckets, err := p.buckets()
+	if err != nil {
+		return nil, err
+	}
+
+	counts, err := p.countBy(p.visits(db), "attendances.scan_time")
+	if err != nil {
+		return nil, err
+	}
+
+	series := make([]SeriesPoint, len(buckets))
+	for i, b := range buckets {
+		label := p.Granularity.Label(b)
+		series[i] = SeriesPoint{Period: label, Count: counts[label]}
+	}
+	return series, nil
+}
+
+// Heatmap counts check-ins by weekday (0 = Sunday) and hour of day, over
+// at most a year.
+func Heatmap(db *gorm.DB, p Params) ([7][24]int64, error) {
+	var grid [7][24]int64
+	if p.To.Sub(p.From) > maxHeatmapRange {
+		return grid, ErrRangeTooLarge
+	}
+
+	err := eachRow(p.visits(db).Select("attendances.scan_time"), func(rows *sql.Rows) error {
+		var t time.Time
+		if err := rows.Scan(&t); err != nil {
+			return err
+		}
+		t = t.In(time.Local)
+		grid[t.Weekday()][t.Hour()]++
+		return nil
+	})
+	return grid, err
+}
+
+type MemberFlow struct {
+	Period  string `json:"period"`
+	New     int64  `json:"new"`
+	Churned int64  `json:"churned"`
+}
+
+// MemberFlows counts members who joined and members whose subscription
+// lapsed without renewal in each bucket. A renewal moves SubEndDate forward,
+// so any member whose latest end date is in the past has churned.
+func MemberFlows(db *gorm.DB, p Params) ([]MemberFlow, error) {
+	buckets, err := p.buckets()
+	if err != nil {
+		return nil, err
+	}
+
+	joined, err := p.countBy(p.members(db.Model(&models.User{})).
+		Where("users.created_at >= ? AND users.created_at < ?", p.From, p.To), "users.created_at")
+	if err != nil {
+		return nil, err
+	}
+
+	end := p.To
+	if now := time.Now(); now.Before(end) {
+		end = now
+	}
+	lapsed, err := p.countBy(p.members(db.Model(&models.User{})).
+		Where("users.sub_end_date >= ? AND users.sub_end_date < ?", p.From, end), "users.sub_end_date")
+	if err != nil {
+		return nil, err
+	}
+
+	flows := make([]MemberFlow, len(buckets))
+	for i, b := range buckets {
+		label := p.Granularity.Label(b)
+		flows[i] = MemberFlow{Period: label, New: joined[label], Churned: lapsed[label]}
+	}
+	return flows, nil
+}
+
+type Cohort struct {
+	Month string `json:"month"`
+	Size  int    `json:"size"`
+	// Retention[k] is the share of the cohort that visited k months after
+	// the join month (Retention[0] is the join month itself).
+	Retention []float64 `json:"retention"`
+}
+
+// RetentionCohorts groups members by join month and measures how many of
+// each cohort visit in the following months. Granularity is ignored.
+func RetentionCohorts(db *gorm.DB, p Params) ([]Cohort, error) {
+	joinedIn := func() *gorm.DB {
+		return p.members(db.Model(&models.User{})).
+			Where("users.created_at >= ? AND users.created_at < ?", p.From, p.To)
+	}
+
+	// 1. Size the cohorts
+	cohort := map[uint]string{} // Join month by member
+	sizes := map[string]int{}
+	err := eachRow(joinedIn().Select("users.id, users.created_at"), func(rows *sql.Rows) error {
+		var id uint
+		var joined time.Time
+		if err := rows.Scan(&id, &joined); err != nil {
+			return err
+		}
+		cohort[id] = Month.Label(Month.Truncate(joined))
+		sizes[cohort[id]]++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(sizes) == 0 {
+		return []Cohort{}, nil
+	}
+
+	// 2. Find the months each member of a cohort visited in, joining the
+	// visits to the cohort's members in the database
+	q := joinedIn().Joins("JOIN attendances ON attendances.trainer_id = users.id").
+		Where("attendances.scan_time >= ?", Month.Truncate(p.From))
+	if p.BranchID != nil {
+		q = q.Where("attendances.branch_id = ?", *p.BranchID)
+	}
+	type key struct {
+		cohort string
+		offset int
+	}
+	type visit struct {
+		member uint
+		offset int
+	}
+	visited := map[visit]bool{}
+	active := map[key]int{}
+	err = eachRow(q.Select("users.id, attendances.scan_time"), func(rows *sql.Rows) error {
+		var id uint
+		var at time.Time
+		if err := rows.Scan(&id, &at); err != nil {
+			return err
+		}
+		offset, ok := monthsBetweenLabels(cohort[id], Month.Label(Month.Truncate(at)))
+		if ok && offset >= 0 && !visited[visit{id, offset}] {
+			visited[visit{id, offset}] = true
+			active[key{cohort[id], offset}]++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 3. Turn the counts into rates, up to the current month
+	current := Month.Label(time.Now())
+	cohorts := make([]Cohort, 0, len(sizes))
+	for month, size := range sizes {
+		c := Cohort{Month: month, Size: size}
+		months, _ := monthsBetweenLabels(month, current)
+		for offset := 0; offset <= months; offset++ {
+			c.Retention = append(c.Retention, float64(active[key{month, offset}])/float64(size))
+		}
+		cohorts = append(cohorts, c)
+	}
+	sort.Slice(cohorts, func(i, j int) bool { return cohorts[i].Month < cohorts[j].Month })
+	return cohorts, nil
+}
+
+// monthsBetweenLabels counts the months from one Month label to another.
+func monthsBetweenLabels(from, to string) (int, bool) {
+	f, err := time.Parse("2006-01", from)
+	if err != nil {
+		return 0, false
+	}
+	t, err := time.Parse("2006-01", to)
+	if err != nil {
+		return 0, false
+	}
+	return (t.Year()-f.Year())*12 + int(t.Month()) - int(f.Month()), true
+}
+
+type PackageRevenue struct {
+	PackageID     uint    `json:"package_id"`
+	PackageName   string  `json:"package_name"`
+	Subscriptions int64   `json:"subscriptions"`
+	Revenue       float64 `json:"revenue"`
+}
+
+// RevenueByPackage totals subscription sales started in the range.
+func RevenueByPackage(db *gorm.DB, p Params) ([]PackageRevenue, error) {
+	db = db.Table("subscriptions").
+		Select("subscriptions.package_id, packages.name as package_name, count(*) as subscriptions, sum(subscriptions.price) as revenue").
+		Joins("LEFT JOIN packages ON packages.id = subscriptions.package_id").
+		Where("subscriptions.created_at >= ? AND subscriptions.created_at < ?", p.From, p.To).
+		Group("subscriptions.package_id, packages.name").
+		Order("revenue desc")
+	if p.BranchID != nil {
+		db = db.Joins("JOIN users ON users.id = subscriptions.member_id").
+			Where("users.branch_id = ?", *p.BranchID)
+	}
+	if p.PackageID != nil {
+		db = db.Where("subscriptions.package_id = ?", *p.PackageID)
+	}
+
+	results := []PackageRevenue{}
+	if err := db.Scan(&results).Error; err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+type VisitFrequency struct {
+	Period   string  `json:"period"`
+	Visits   int64   `json:"visits"`
+	Visitors int64   `json:"visitors"`
+	Average  float64 `json:"average"` // Visits per visiting member
+}
+
+// AverageVisits reports visits per member for each bucket.
+func AverageVisits(db *gorm.DB, p Params) ([]VisitFrequency, error) {
+	buckets, err := p.buckets()
+	if err != nil {
+		return nil, err
+	}
+
+	type visitor struct {
+		period string
+		member uint
+	}
+	seen := map[visitor]bool{}
+	byPeriod := map[string]VisitFrequency{}
+	err = eachRow(p.visits(db).Select("attendances.scan_time, attendances.trainer_id"), func(rows *sql.Rows) error {
+		var at time.Time
+		var member uint
+		if err := rows.Scan(&at, &member); err != nil {
+			return err
+		}
+		period := p.Granularity.Label(p.Granularity.Truncate(at))
+		f := byPeriod[period]
+		f.Visits++
+		if !seen[visitor{period, member}] {
+			seen[visitor{period, member}] = true
+			f.Visitors++
+		}
+		byPeriod[period] = f
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]VisitFrequency, len(buckets))
+	for i, b := range buckets {
+		f := byPeriod[p.Granularity.Label(b)]
+		f.Period = p.Granularity.Label(b)
+		if f.Visitors > 0 {
+			f.Average = float64(f.Visits) / float64(f.Visitors)
+		}
+		out[i] = f
+	}
+	return out, nil
+}
+
+// Summary is the dashboard's headline figures.
+type Summary struct {
+	TotalMembers  int64
+	ActiveMembers int64
+	TotalTrainers int64
+	// EstimatedRevenue sums the prices of the active members' packages; it
+	// is not a record of payments.
+	EstimatedRevenue float64
+	TodayAttendance  int64
+}
+
+// Totals computes the dashboard summary as of now.
+func Totals(db *gorm.DB, now time.Time) (Summary, error) {
+	var s Summary
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	err := errors.Join(
+		db.Model(&models.User{}).Where("role = ?", models.RoleMember).Count(&s.TotalMembers).Error,
+		db.Model(&models.User{}).Where("role = ? AND membership_status = ?", models.RoleMember, "active").Count(&s.ActiveMembers).Error,
+		db.Model(&models.User{}).Where("role = ?", models.RoleTrainer).Count(&s.TotalTrainers).Error,
+		db.Table("users").
+			Select("COALESCE(SUM(packages.price), 0)").
+			Joins("LEFT JOIN packages ON packages.id = users.package_id").
+			Where("users.membership_status = ?", "active").
+			Scan(&s.EstimatedRevenue).Error,
+		db.Model(&models.Attendance{}).
+			Where("scan_time >= ? AND scan_time < ?", dayStart, dayStart.AddDate(0, 0, 1)).
+			Count(&s.TodayAttendance).Error,
+	)
+	return s, err
+}
@@ -0,0 +1,123 @@
+// Package apperror is the API's error model. Handlers return an *Error and
+// the Fiber ErrorHandler turns it into a response:
+//
+//	{"error": "Member not found", "code": "not_found", "request_id": "..."}
+//
+// The message is safe to show to users. The underlying cause is logged
+// with the request ID but never sent to the client.
+package apperror
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Code is a stable, machine-readable error identifier. Clients should branch
+// on codes, not messages.
+type Code string
+
+const (
+	CodeInvalidInput     Code = "invalid_input"
+	CodeValidationFailed Code = "validation_failed"
+	CodeUnauthorized     Code = "unauthorized"
+	CodeForbidden        Code = "forbidden"
+	CodeNotFound         Code = "not_found"
+	CodeConflict         Code = "conflict"
+	CodeKeyReused        Code = "idempotency_key_reused"
+	CodeKeyInProgress    Code = "idempotency_key_in_progress"
+	CodeTooLarge         Code = "payload_too_large"
+	CodeUnsupportedMedia Code = "unsupported_media_type"
+	CodeRateLimited      Code = "rate_limited"
+	CodeTwoFactorNeeded  Code = "two_factor_required"
+	CodeInternal         Code = "internal_error"
+	CodeUnavailable      Code = "service_unavailable"
+	CodeBadGateway       Code = "bad_gateway"
+)
+
+// Error is an API error with its HTTP status.
+type Error struct {
+	Status  int
+	Code    Code
+	Message string
+	Details any   // Extra data for the client, e.g. field errors
+	Err     error // Internal cause; logged, never returned
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// WithCode replaces the generic code with a more specific one.
+func (e *Error) WithCode(code Code) *Error {
+	e.Code = code
+	return e
+}
+
+// WithDetails attaches extra data to the response.
+func (e *Error) WithDetails(details any) *Error {
+	e.Details = details
+	return e
+}
+
+func New(status int, code Code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+func BadRequest(message string) *Error {
+	return New(http.StatusBadRequest, CodeInvalidInput, message)
+}
+
+func Unauthorized(message string) *Error {
+	return New(http.StatusUnauthorized, CodeUnauthorized, message)
+}
+
+func Forbidden(message string) *Error {
+	return New(http.StatusForbidden, CodeForbidden, message)
+}
+
+func NotFound(message string) *Error {
+	return New(http.StatusNotFound, CodeNotFound, message)
+}
+
+func Conflict(message string) *Error {
+	return New(http.StatusConflict, CodeConflict, message)
+}
+
+func TooLarge(message string) *Error {
+	return New(http.StatusRequestEntityTooLarge, CodeTooLarge, message)
+}
+
+func BadGateway(err error, message string) *Error {
+	return &Error{Status: http.StatusBadGateway, Code: CodeBadGateway, Message: message, Err: err}
+}
+
+// Unavailable reports a dependency that is down; err is only logged.
+func Unavailable(err error, message string) *Error {
+	return &Error{Status: http.StatusServiceUnavailable, Code: CodeUnavailable, Message: message, Err: err}
+}
+
+// Internal wraps an unexpected failure. message is what the client sees;
+// err is only logged.
+func Internal(err error, message string) *Error {
+	return &Error{Status: http.StatusInternalServerError, Code: CodeInternal, Message: message, Err: err}
+}
+
+// Validation reports field-level input errors.
+func Validation(fields any) *Error {
+	return New(http.StatusBadRequest, CodeValidationFailed, "Validation failed").WithDetails(fields)
+}
+
+// As returns err as an *Error, if it is one.
+func As(err error) (*Error, bool) {
+	var e *Error
+	ok := errors.As(err, &e)
+	return e, ok
+}
@@ -0,0 +1,47 @@
+package apperror
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+)
+
+// FromDB classifies a database error from a lookup. A missing record
+// becomes 404 with notFound as the message; other failures are mapped by
+// DB.
+func FromDB(err error, notFound string) *Error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return NotFound(notFound)
+	}
+	return DB(err, "Database error")
+}
+
+// DB classifies a database error from a write or query, so clients can tell
+// a conflict or an outage apart from a bug. message is shown for
+// unexpected failures.
+func DB(err error, message string) *Error {
+	switch {
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return &Error{Status: http.StatusConflict, Code: CodeConflict, Message: "A record with the same unique value already exists", Err: err}
+	case errors.Is(err, gorm.ErrForeignKeyViolated):
+		return &Error{Status: http.StatusConflict, Code: CodeConflict, Message: "The record is referenced by or refers to missing data", Err: err}
+	case unavailable(err):
+		return &Error{Status: http.StatusServiceUnavailable, Code: CodeUnavailable, Message: "Database unavailable, try again shortly", Err: err}
+	}
+	return Internal(err, message)
+}
+
+func unavailable(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) ||
+		errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, sql.ErrConnDone) ||
+		errors.Is(err, mysql.ErrInvalidConn) ||
+		errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,85 @@
+package apperror
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
+)
+
+// ErrorResponse is the body of every error reply.
+type ErrorResponse struct {
+	Error     string `json:"error"`
+	Code      Code   `json:"code"`
+	RequestID string `json:"request_id"`
+	Details   any    `json:"details,omitempty"` // e.g. validation.Errors
+}
+
+// handledKey holds the error Handler rendered, for Handled.
+const handledKey = "apperror.handled"
+
+// Handler is the Fiber ErrorHandler. It renders every error returned by a
+// handler in the same shape and logs server-side failures with their
+// request ID so a client report can be matched to the log line.
+func Handler(c *fiber.Ctx, err error) error {
+	e := classify(err)
+	id := RequestID(c)
+	c.Locals(handledKey, err)
+
+	if e.Status >= http.StatusInternalServerError {
+		slog.ErrorContext(c.UserContext(), "Request failed", "method", c.Method(), "path", c.OriginalURL(), "error", err)
+	}
+
+	return c.Status(e.Status).JSON(ErrorResponse{Error: e.Message, Code: e.Code, RequestID: id, Details: e.Details})
+}
+
+// Handled returns the error Handler rendered for this request, if any, for
+// middleware that looks at the outcome after an inner one rendered it.
+func Handled(c *fiber.Ctx) error {
+	err, _ := c.Locals(handledKey).(error)
+	return err
+}
+
+// RequestID returns the ID assigned by the requestid middleware.
+func RequestID(c *fiber.Ctx) string {
+	id, _ := c.Locals(requestid.ConfigDefault.ContextKey).(string)
+	return id
+}
+
+func classify(err error) *Error {
+	if e, ok := As(err); ok {
+		return e
+	}
+
+	// Errors raised by Fiber itself: unknown routes, body limits, ...
+	var fe *fiber.Error
+	if errors.As(err, &fe) {
+		code := CodeInternal
+		switch fe.Code {
+		case http.StatusBadRequest:
+			code = CodeInvalidInput
+		case http.StatusUnauthorized:
+			code = CodeUnauthorized
+		case http.StatusForbidden:
+			code = CodeForbidden
+		case http.StatusNotFound, http.StatusMethodNotAllowed:
+			code = CodeNotFound
+		case http.StatusRequestEntityTooLarge:
+			code = CodeTooLarge
+		case http.StatusUnsupportedMediaType:
+			code = CodeUnsupportedMedia
+		case http.StatusTooManyRequests:
+			code = CodeRateLimited
+		case http.StatusServiceUnavailable:
+			code = CodeUnavailable
+		}
+		if fe.Code >= http.StatusInternalServerError {
+			return &Error{Status: fe.Code, Code: code, Message: http.StatusText(fe.Code), Err: err}
+		}
+		return New(fe.Code, code, fe.Message)
+	}
+
+	return Internal(err, "Internal server error")
+}
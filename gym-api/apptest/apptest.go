@@ -0,0 +1,242 @@
+// Package apptest runs the API on an in-memory SQLite database for
+// handler tests. New registers every route as main does, with fakes for
+// what leaves the process: email is kept in an Outbox, notifications are
+// dropped, photos go to a temporary directory and login limits and gates
+// live in memory.
+//
+// The app points config.DB and storage.Default at its own, so tests that
+// use it must not run in parallel.
+package apptest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"gym-api/apperror"
+	"gym-api/churn"
+	"gym-api/config"
+	"gym-api/controllers"
+	"gym-api/gates"
+	"gym-api/lockout"
+	"gym-api/models"
+	"gym-api/notifications"
+	"gym-api/oidc"
+	"gym-api/repository"
+	"gym-api/routes"
+	"gym-api/search"
+	"gym-api/services"
+	"gym-api/storage"
+	"gym-api/twofactor"
+	"gym-api/utils"
+
+	"github.com/glebarez/sqlite"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// App is the API under test.
+type App struct {
+	*fiber.App
+	DB     *gorm.DB
+	Outbox *Outbox
+	SSO    *oidc.Provider // Off until its Settings are filled in
+}
+
+// New starts the API on a fresh database with every table migrated.
+func New(t testing.TB) *App {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{
+		Logger:         logger.Discard,
+		TranslateError: true,
+	})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	// Each connection to :memory: is a database of its own
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := db.AutoMigrate(models.Tables()...); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	previous, photos := config.DB, storage.Default
+	config.DB, storage.Default = db, storage.NewLocal(t.TempDir(), "/uploads")
+	t.Cleanup(func() { config.DB, storage.Default = previous, photos })
+	t.Cleanup(churn.Wait) // A recompute must not outlive the database
+	search.Invalidate()
+
+	outbox, provider := &Outbox{}, &oidc.Provider{}
+	registry := &gates.Registry{}
+	t.Cleanup(func() { registry.Close(context.Background()) })
+	repos := repository.New(db)
+	twofactor.Default.Use(repos.TwoFactor)
+	t.Cleanup(func() { twofactor.Default.Use(nil) })
+	svc := services.New(repos, services.Deps{
+		Notify:  func(models.User, notifications.Kind, map[string]interface{}) {},
+		Email:   outbox.send,
+		Feed:    services.LiveFeed{},
+		Gates:   registry,
+		Limiter: &lockout.Limiter{Store: lockout.NewMemoryStore(), Limits: config.Login()},
+		Policy:  twofactor.Default,
+		SSO:     provider,
+		Index:   search.Members,
+		Reports: db,
+	})
+
+	app := fiber.New(fiber.Config{ErrorHandler: apperror.Handler})
+	routes.SetupRoutes(app, controllers.NewHandlers(svc))
+	return &App{App: app, DB: db, Outbox: outbox, SSO: provider}
+}
+
+// AddUser stores user as it is, returning it with its ID.
+func (a *App) AddUser(t testing.TB, user models.User) models.User {
+	t.Helper()
+	if err := a.DB.Create(&user).Error; err != nil {
+		t.Fatalf("add user: %v", err)
+	}
+	return user
+}
+
+// Token signs user in, as if with a second factor.
+func (a *App) Token(t testing.TB, user models.User) string {
+	t.Helper()
+	token, err := utils.GenerateToken(user.ID, string(user.Role), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// Request is a request to send with Do.
+type Request struct {
+	Method string
+	Path   string
+	Token  string // Sent as a bearer token
+	Body   any    // Encoded as JSON unless it is a []byte, url.Values or Multipart
+	Header map[string]string
+}
+
+// Multipart is a multipart/form-data body.
+type Multipart struct {
+	Fields url.Values
+	Files  map[string][]byte // File contents by field name
+}
+
+// Response is what the app answered.
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// JSON decodes the body into v, failing the test if it is not JSON.
+func (r Response) JSON(t testing.TB, v any) {
+	t.Helper()
+	if err := json.Unmarshal(r.Body, v); err != nil {
+		t.Fatalf("decode %s: %v", r.Body, err)
+	}
+}
+
+// Do sends req and reads the whole response.
+func (a *App) Do(t testing.TB, req Request) Response {
+	t.Helper()
+
+	var body io.Reader
+	contentType := fiber.MIMEApplicationJSON
+	switch b := req.Body.(type) {
+	case nil:
+	case []byte:
+		body = bytes.NewReader(b)
+	case url.Values:
+		body = bytes.NewBufferString(b.Encode())
+		contentType = fiber.MIMEApplicationForm
+	case Multipart:
+		var buf bytes.Buffer
+		w := multipart.NewWriter(&buf)
+		for name, values := range b.Fields {
+			for _, v := range values {
+				w.WriteField(name, v)
+			}
+		}
+		for name, data := range b.Files {
+			part, err := w.CreateFormFile(name, name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			part.Write(data)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		body, contentType = &buf, w.FormDataContentType()
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	r := httptest.NewRequest(req.Method, req.Path, body)
+	if body != nil {
+		r.Header.Set(fiber.HeaderContentType, contentType)
+	}
+	if req.Token != "" {
+		r.Header.Set(fiber.HeaderAuthorization, "Bearer "+req.Token)
+	}
+	for k, v := range req.Header {
+		r.Header.Set(k, v)
+	}
+
+	resp, err := a.Test(r, -1)
+	if err != nil {
+		t.Fatalf("%s %s: %v", req.Method, req.Path, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Response{Status: resp.StatusCode, Header: resp.Header, Body: data}
+}
+
+// Email is a message the app sent.
+type Email struct {
+	To   models.User
+	Kind notifications.Kind
+	Data map[string]interface{}
+}
+
+// Outbox keeps the emails the app sent.
+type Outbox struct {
+	mu     sync.Mutex
+	emails []Email
+}
+
+func (o *Outbox) send(_ context.Context, user models.User, kind notifications.Kind, _ string, data map[string]interface{}) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.emails = append(o.emails, Email{To: user, Kind: kind, Data: data})
+	return nil
+}
+
+// Sent returns the emails sent so far.
+func (o *Outbox) Sent() []Email {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]Email(nil), o.emails...)
+}
@@ -0,0 +1,16 @@
+package audit
+
+import (
+	"log/slog"
+
+	"gym-api/config"
+	"gym-api/models"
+)
+
+// Record appends an entry to the audit log. Failures are logged, never
+// returned: auditing must not break the action being audited.
+func Record(entry models.AuditLog) {
+	if err := config.DB.Create(&entry).Error; err != nil {
+		slog.Error("Failed to write audit log", "action", entry.Action, "error", err)
+	}
+}
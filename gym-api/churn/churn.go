@@ -0,0 +1,229 @@
+package churn
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gym-api/config"
+	"gym-api/models"
+	"gym-api/notifications"
+
+	"gorm.io/gorm/clause"
+)
+
+const (
+	Low    = "low"
+	Medium = "medium"
+	High   = "high"
+)
+
+// Signals are the inputs to a member's score.
+type Signals struct {
+	RecentVisits       int64 // visits in the last window
+	PriorVisits        int64 // visits in the window before that
+	DaysSinceLastVisit *int
+	DaysSinceJoin      int
+	DaysToExpiry       *int
+}
+
+// Score combines visit decline, inactivity and approaching expiry into a
+// value between 0 and 1.
+func Score(s Signals, cfg config.ChurnSettings) float64 {
+	// Visit frequency decline between the prior and the recent window
+	var decline float64
+	if s.PriorVisits > 0 {
+		decline = clamp(float64(s.PriorVisits-s.RecentVisits) / float64(s.PriorVisits))
+	}
+
+	// Time since the last visit; members who never came count as inactive
+	// once they have been around for the inactivity period
+	var inactivity float64
+	if s.DaysSinceLastVisit != nil {
+		inactivity = clamp(float64(*s.DaysSinceLastVisit) / float64(cfg.InactiveDays))
+	} else {
+		inactivity = clamp(float64(s.DaysSinceJoin) / float64(cfg.InactiveDays))
+	}
+
+	// Closeness to the end of the subscription
+	var expiry float64
+	if s.DaysToExpiry != nil {
+		expiry = clamp(1 - float64(*s.DaysToExpiry)/float64(cfg.ExpiryDays))
+	}
+
+	return math.Round((0.4*decline+0.4*inactivity+0.2*expiry)*100) / 100
+}
+
+func Level(score float64, cfg config.ChurnSettings) string {
+	switch {
+	case score >= cfg.HighScore:
+		return High
+	case score >= cfg.MediumScore:
+		return Medium
+	}
+	return Low
+}
+
+func clamp(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
+
+// Run rescores every active member with a current subscription and stores
+// the results. Members who no longer qualify lose their stored score.
+func Run(ctx context.Context) error {
+	cfg := config.Churn()
+	// Whole seconds, so computed_at reads back exactly what was written
+	// whatever the column's precision, and step 4 keeps this run's rows
+	now := time.Now().Truncate(time.Second)
+	window := time.Duration(cfg.WindowDays) * 24 * time.Hour
+	recentStart := now.Add(-window)
+	priorStart := now.Add(-2 * window)
+
+	// 1. Members to score
+	var members []models.User
+	err := config.DB.WithContext(ctx).
+		Where("role = ? AND is_active = ? AND membership_status = ?", models.RoleMember, true, "active").
+		Where("sub_end_date IS NULL OR sub_end_date > ?", now).
+		Find(&members).Error
+	if err != nil {
+		return err
+	}
+
+	// 2. Visit counts per member
+	type visitStats struct {
+		TrainerID uint
+		Recent    int64
+		Prior     int64
+	}
+	var counts []visitStats
+	err = config.DB.WithContext(ctx).Table("attendances").
+		Select("trainer_id, "+
+			"SUM(CASE WHEN scan_time >= ? THEN 1 ELSE 0 END) AS recent, "+
+			"SUM(CASE WHEN scan_time < ? THEN 1 ELSE 0 END) AS prior", recentStart, recentStart).
+		Where("scan_time >= ?", priorStart).
+		Group("trainer_id").
+		Scan(&counts).Error
+	if err != nil {
+		return err
+	}
+	stats := map[uint]visitStats{}
+	for _, c := range counts {
+		stats[c.TrainerID] = c
+	}
+
+	// Each member's last visit, whenever it was. The column itself is read
+	// rather than MAX(), which some drivers return as text
+	var lastVisits []models.Attendance
+	err = config.DB.WithContext(ctx).Select("trainer_id, scan_time").
+		Where("(trainer_id, scan_time) IN (?)",
+			config.DB.Model(&models.Attendance{}).Select("trainer_id, MAX(scan_time)").Group("trainer_id")).
+		Find(&lastVisits).Error
+	if err != nil {
+		return err
+	}
+	lastVisit := map[uint]time.Time{}
+	for _, a := range lastVisits {
+		lastVisit[a.TrainerID] = a.ScanTime
+	}
+
+	// 3. Score and store
+	for _, member := range members {
+		var daysSinceLast *int
+		if last, ok := lastVisit[member.ID]; ok {
+			d := int(now.Sub(last).Hours() / 24)
+			daysSinceLast = &d
+		}
+
+		var daysToExpiry *int
+		if member.SubEndDate != nil {
+			d := int(member.SubEndDate.Sub(now).Hours() / 24)
+			daysToExpiry = &d
+		}
+
+		signals := Signals{
+			RecentVisits:       stats[member.ID].Recent,
+			PriorVisits:        stats[member.ID].Prior,
+			DaysSinceLastVisit: daysSinceLast,
+			DaysSinceJoin:      int(now.Sub(member.CreatedAt).Hours() / 24),
+			DaysToExpiry:       daysToExpiry,
+		}
+		score := Score(signals, cfg)
+
+		risk := models.ChurnRisk{
+			MemberID:           member.ID,
+			Score:              score,
+			Level:              Level(score, cfg),
+			RecentVisits:       signals.RecentVisits,
+			PriorVisits:        signals.PriorVisits,
+			DaysSinceLastVisit: daysSinceLast,
+			DaysToExpiry:       daysToExpiry,
+			ComputedAt:         now,
+		}
+		err := config.DB.WithContext(ctx).Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "member_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"score", "level", "recent_visits", "prior_visits", "days_since_last_visit", "days_to_expiry", "computed_at"}),
+		}).Create(&risk).Error
+		if err != nil {
+			return err
+		}
+
+		if cfg.Notify && risk.Level == High {
+			if err := notifyHighRisk(ctx, member, now); err != nil {
+				return err
+			}
+		}
+	}
+
+	// 4. Drop scores of members that were not rescored
+	return config.DB.WithContext(ctx).Where("computed_at < ?", now).Delete(&models.ChurnRisk{}).Error
+}
+
+var (
+	running atomic.Bool
+	started sync.WaitGroup
+)
+
+// Start runs Run in the background, for an admin who asked for fresh
+// scores, and reports false if a run started that way is still going.
+func Start() bool {
+	if !running.CompareAndSwap(false, true) {
+		return false
+	}
+	started.Add(1)
+	go func() {
+		defer started.Done()
+		defer running.Store(false)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+		defer cancel()
+		if err := Run(ctx); err != nil {
+			slog.Error("Churn scoring failed", "error", err)
+		}
+	}()
+	return true
+}
+
+// Wait blocks until the run started by Start, if any, has finished.
+func Wait() {
+	started.Wait()
+}
+
+// notifyHighRisk sends the member a "we miss you" message, at most once
+// every 30 days. A failed send is logged and retried on the next run.
+func notifyHighRisk(ctx context.Context, member models.User, now time.Time) error {
+	var risk models.ChurnRisk
+	if err := config.DB.WithContext(ctx).Where("member_id = ?", member.ID).First(&risk).Error; err != nil {
+		return err
+	}
+	if risk.NotifiedAt != nil && now.Sub(*risk.NotifiedAt) < 30*24*time.Hour {
+		return nil
+	}
+
+	if err := notifications.Notify(ctx, member, notifications.WeMissYou, "churn", nil); err != nil {
+		slog.Warn("Churn notification failed", "member_id", member.ID, "error", err)
+		return nil
+	}
+	return config.DB.WithContext(ctx).Model(&models.ChurnRisk{}).Where("member_id = ?", member.ID).Update("notified_at", now).Error
+}
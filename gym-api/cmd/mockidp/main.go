@@ -0,0 +1,45 @@
+// Command mockidp is a minimal OpenID Connect provider for trying single
+// sign-on locally (see oidctest). It signs in whoever you type, or the
+// login_hint without asking. Keys are new on every start.
+//
+//	go run ./cmd/mockidp -groups gym-admins
+//
+// and run the API with
+//
+//	OIDC_ISSUER=http://localhost:9000 OIDC_CLIENT_ID=gym-api \
+//	OIDC_REDIRECT_URL=http://localhost:5173/sso/callback \
+//	OIDC_ALLOWED_DOMAINS=example.com \
+//	OIDC_ROLE_CLAIM=groups OIDC_ROLE_MAP=gym-admins=admin
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"strings"
+
+	"gym-api/oidc/oidctest"
+)
+
+var (
+	addr     = flag.String("addr", ":9000", "listen address")
+	issuer   = flag.String("issuer", "http://localhost:9000", "issuer URL, as the API reaches it")
+	clientID = flag.String("client", "gym-api", "accepted client_id")
+	groups   = flag.String("groups", "", "comma-separated groups claim for everyone who signs in")
+	mfa      = flag.Bool("mfa", false, `report a second factor ("amr": ["pwd", "mfa"])`)
+)
+
+func main() {
+	flag.Parse()
+	idp, err := oidctest.New(*issuer, *clientID)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *groups != "" {
+		idp.Groups = strings.Split(*groups, ",")
+	}
+	idp.MFA = *mfa
+
+	log.Printf("Mock OpenID provider %s listening on %s", *issuer, *addr)
+	log.Fatal(http.ListenAndServe(*addr, idp))
+}
@@ -0,0 +1,23 @@
+package config
+
+import "os"
+
+// ValidateResponses turns on checking every JSON response against the
+// OpenAPI description (VALIDATE_RESPONSES=true). Mismatches are logged, so
+// this is meant for development and staging.
+func ValidateResponses() bool {
+	return os.Getenv("VALIDATE_RESPONSES") == "true"
+}
+
+// MetricsToken must be sent as a bearer token to read /metrics on the API
+// port. Without it the API doesn't serve metrics; use MetricsAddr instead.
+func MetricsToken() string {
+	return os.Getenv("METRICS_TOKEN")
+}
+
+// MetricsAddr is a separate listener for /metrics, without a token, such
+// as 127.0.0.1:9090 or a port only the scraper can reach (METRICS_ADDR).
+// Unset, there is none. Worker processes only expose metrics here.
+func MetricsAddr() string {
+	return os.Getenv("METRICS_ADDR")
+}
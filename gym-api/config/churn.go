@@ -0,0 +1,30 @@
+package config
+
+import (
+	"os"
+	"time"
+)
+
+// ChurnSettings tune the churn risk scoring. Each can be overridden with the
+// environment variable named in its comment.
+type ChurnSettings struct {
+	WindowDays   int           // CHURN_WINDOW_DAYS: length of the recent/prior visit windows compared
+	InactiveDays int           // CHURN_INACTIVE_DAYS: days without a visit that count as fully inactive
+	ExpiryDays   int           // CHURN_EXPIRY_DAYS: days before expiry at which expiry starts adding risk
+	MediumScore  float64       // CHURN_MEDIUM_SCORE: minimum score for "medium"
+	HighScore    float64       // CHURN_HIGH_SCORE: minimum score for "high"
+	Interval     time.Duration // CHURN_INTERVAL_HOURS: how often the job runs
+	Notify       bool          // CHURN_NOTIFY: message members when they become high risk
+}
+
+func Churn() ChurnSettings {
+	return ChurnSettings{
+		WindowDays:   envInt("CHURN_WINDOW_DAYS", 28),
+		InactiveDays: envInt("CHURN_INACTIVE_DAYS", 21),
+		ExpiryDays:   envInt("CHURN_EXPIRY_DAYS", 14),
+		MediumScore:  envFloat("CHURN_MEDIUM_SCORE", 0.4),
+		HighScore:    envFloat("CHURN_HIGH_SCORE", 0.7),
+		Interval:     time.Duration(envInt("CHURN_INTERVAL_HOURS", 24)) * time.Hour,
+		Notify:       os.Getenv("CHURN_NOTIFY") == "true",
+	}
+}
@@ -2,11 +2,13 @@ package config
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
+	"time"
 
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
 )
 
 var DB *gorm.DB
@@ -29,10 +31,30 @@ func ConnectDB() {
 	}
 
 	var err error
-	DB, err = gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	// TranslateError maps driver errors such as duplicate keys to gorm.Err*.
+	// Slow and failed queries go to the application log, with the request's
+	// IDs when the query was given its context.
+	DB, err = gorm.Open(mysql.Open(dsn), &gorm.Config{
+		TranslateError: true,
+		Logger: logger.NewSlogLogger(slog.Default(), logger.Config{
+			SlowThreshold:             200 * time.Millisecond,
+			IgnoreRecordNotFoundError: true,
+			LogLevel:                  logger.Warn,
+		}),
+	})
 	if err != nil {
-		log.Fatal("Failed to connect to database: ", err)
+		slog.Error("Failed to connect to database", "error", err)
+		os.Exit(1)
 	}
 
-	log.Println("Database connection successfully established (MySQL)")
+	slog.Info("Database connection established", "driver", "mysql")
+}
+
+// CloseDB closes the connection pool once nothing uses it any more.
+func CloseDB() error {
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
 }
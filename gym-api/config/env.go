@@ -0,0 +1,25 @@
+package config
+
+import (
+	"os"
+	"strconv"
+)
+
+// envInt reads a positive integer setting, using fallback when the variable
+// is unset, malformed or not above zero.
+func envInt(key string, fallback int) int {
+	v, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || v <= 0 {
+		return fallback
+	}
+	return v
+}
+
+// envFloat is envInt for decimal settings.
+func envFloat(key string, fallback float64) float64 {
+	v, err := strconv.ParseFloat(os.Getenv(key), 64)
+	if err != nil || v <= 0 {
+		return fallback
+	}
+	return v
+}
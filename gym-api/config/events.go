@@ -0,0 +1,13 @@
+package config
+
+import "os"
+
+// LiveFeedStore is LIVE_FEED_STORE: "database" (the default) carries live
+// feed events between processes through the database; "memory" keeps them
+// in the process that published them, which only suits a single process.
+func LiveFeedStore() string {
+	if os.Getenv("LIVE_FEED_STORE") == "memory" {
+		return "memory"
+	}
+	return "database"
+}
@@ -0,0 +1,9 @@
+package config
+
+import "time"
+
+// IdempotencyTTL is how long a response is kept for retries sent with the
+// same Idempotency-Key. Defaults to 24 hours (IDEMPOTENCY_TTL_HOURS).
+func IdempotencyTTL() time.Duration {
+	return time.Duration(envInt("IDEMPOTENCY_TTL_HOURS", 24)) * time.Hour
+}
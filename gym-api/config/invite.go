@@ -0,0 +1,21 @@
+package config
+
+import (
+	"os"
+	"time"
+)
+
+// InviteTTL is how long a staff invite can be accepted. Defaults to 72
+// hours (INVITE_TTL_HOURS).
+func InviteTTL() time.Duration {
+	return time.Duration(envInt("INVITE_TTL_HOURS", 72)) * time.Hour
+}
+
+// InviteURL is the web app page where invitees choose their password
+// (INVITE_URL). The token is appended as ?token=.
+func InviteURL() string {
+	if url := os.Getenv("INVITE_URL"); url != "" {
+		return url
+	}
+	return "http://localhost:5173/invite"
+}
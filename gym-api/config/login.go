@@ -0,0 +1,105 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"strings"
+	"time"
+)
+
+// LoginLimits throttle password guessing. Each can be overridden with the
+// environment variable named in its comment.
+type LoginLimits struct {
+	MaxFailures  int           // LOGIN_MAX_FAILURES: failures within Window that lock an account
+	DelayAfter   int           // LOGIN_DELAY_AFTER: failures after which each retry must wait longer
+	BaseDelay    time.Duration // LOGIN_BASE_DELAY_SECONDS: first wait; doubles with every further failure
+	MaxDelay     time.Duration // LOGIN_MAX_DELAY_SECONDS: cap on the wait
+	Window       time.Duration // LOGIN_WINDOW_MINUTES: how long failures and attempts are counted
+	LockDuration time.Duration // LOGIN_LOCKOUT_MINUTES: how long a locked account stays locked
+	IPAttempts   int           // LOGIN_IP_ATTEMPTS: attempts one address may make within Window
+	Store        string        // LOGIN_LIMIT_STORE: "database" (shared by replicas, default) or "memory"
+}
+
+func Login() LoginLimits {
+	store := os.Getenv("LOGIN_LIMIT_STORE")
+	if store != "memory" {
+		store = "database"
+	}
+	return LoginLimits{
+		MaxFailures:  envInt("LOGIN_MAX_FAILURES", 5),
+		DelayAfter:   envInt("LOGIN_DELAY_AFTER", 2),
+		BaseDelay:    time.Duration(envInt("LOGIN_BASE_DELAY_SECONDS", 1)) * time.Second,
+		MaxDelay:     time.Duration(envInt("LOGIN_MAX_DELAY_SECONDS", 30)) * time.Second,
+		Window:       time.Duration(envInt("LOGIN_WINDOW_MINUTES", 15)) * time.Minute,
+		LockDuration: time.Duration(envInt("LOGIN_LOCKOUT_MINUTES", 15)) * time.Minute,
+		IPAttempts:   envInt("LOGIN_IP_ATTEMPTS", 30),
+		Store:        store,
+	}
+}
+
+// ProxyHeader is the header carrying the client address when the API runs
+// behind a load balancer (PROXY_HEADER, e.g. X-Forwarded-For). Without it
+// every request appears to come from the balancer and per-IP limits would
+// apply to all clients at once.
+func ProxyHeader() string {
+	return os.Getenv("PROXY_HEADER")
+}
+
+// TrustedProxies lists the balancer addresses allowed to set ProxyHeader
+// (TRUSTED_PROXIES, comma-separated IPs or CIDR ranges). Required with
+// PROXY_HEADER: see CheckProxy.
+func TrustedProxies() []string {
+	var proxies []string
+	for _, p := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			proxies = append(proxies, p)
+		}
+	}
+	return proxies
+}
+
+// TrustedProxyRanges parses TrustedProxies, a single IP becoming a range
+// of one address.
+func TrustedProxyRanges() ([]netip.Prefix, error) {
+	var ranges []netip.Prefix
+	for _, p := range TrustedProxies() {
+		if strings.Contains(p, "/") {
+			prefix, err := netip.ParsePrefix(p)
+			if err != nil {
+				return nil, fmt.Errorf("TRUSTED_PROXIES: %w", err)
+			}
+			ranges = append(ranges, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(p)
+		if err != nil {
+			return nil, fmt.Errorf("TRUSTED_PROXIES: %w", err)
+		}
+		addr = addr.Unmap()
+		ranges = append(ranges, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return ranges, nil
+}
+
+// CheckProxy rejects a PROXY_HEADER without TRUSTED_PROXIES, which would
+// let any client name its own address and dodge the per-IP limits.
+func CheckProxy() error {
+	if ProxyHeader() == "" {
+		return nil
+	}
+	if len(TrustedProxies()) == 0 {
+		return errors.New("PROXY_HEADER is set without TRUSTED_PROXIES; list the load balancer addresses")
+	}
+	_, err := TrustedProxyRanges()
+	return err
+}
+
+// TwoFactorIssuer names the service in authenticator apps (TOTP_ISSUER).
+func TwoFactorIssuer() string {
+	if issuer := os.Getenv("TOTP_ISSUER"); issuer != "" {
+		return issuer
+	}
+	return "Gym"
+}
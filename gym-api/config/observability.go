@@ -0,0 +1,39 @@
+package config
+
+import (
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// LogLevel is LOG_LEVEL: debug, info (the default), warn or error.
+func LogLevel() slog.Level {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(os.Getenv("LOG_LEVEL"))); err != nil {
+		return slog.LevelInfo
+	}
+	return level
+}
+
+// LogFormat is LOG_FORMAT: json (the default) or text, which is easier to
+// read in a terminal.
+func LogFormat() string {
+	if strings.ToLower(os.Getenv("LOG_FORMAT")) == "text" {
+		return "text"
+	}
+	return "json"
+}
+
+// TracesExporter is OTEL_TRACES_EXPORTER: otlp, console (spans printed to
+// stdout) or none, the default. The OTLP exporter reads the standard
+// OTEL_EXPORTER_OTLP_* variables, e.g. OTEL_EXPORTER_OTLP_ENDPOINT.
+func TracesExporter() string {
+	exporter := strings.ToLower(os.Getenv("OTEL_TRACES_EXPORTER"))
+	if exporter == "stdout" {
+		return "console"
+	}
+	if exporter == "" {
+		return "none"
+	}
+	return exporter
+}
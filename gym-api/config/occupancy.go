@@ -0,0 +1,23 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// MaxCapacity is the default number of people allowed on site at once,
+// used for branches without their own capacity. 0 disables the limit.
+func MaxCapacity() int {
+	capacity, err := strconv.Atoi(os.Getenv("MAX_CAPACITY"))
+	if err != nil || capacity < 0 {
+		return 0
+	}
+	return capacity
+}
+
+// OccupancyTimeout is how long a check-in counts towards occupancy when the
+// person never checks out. Defaults to 3 hours.
+func OccupancyTimeout() time.Duration {
+	return time.Duration(envInt("OCCUPANCY_TIMEOUT_MINUTES", 180)) * time.Minute
+}
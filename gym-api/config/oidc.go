@@ -0,0 +1,82 @@
+package config
+
+import (
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// OIDCSettings configure single sign-on for staff through an OpenID
+// Connect provider such as Google Workspace. Sign-on is off unless
+// OIDC_ISSUER is set.
+type OIDCSettings struct {
+	Issuer       string   // OIDC_ISSUER, e.g. https://accounts.google.com
+	ClientID     string   // OIDC_CLIENT_ID
+	ClientSecret string   // OIDC_CLIENT_SECRET; empty for a public client relying on PKCE alone
+	RedirectURL  string   // OIDC_REDIRECT_URL: the web app page that receives the code and posts it back
+	Scopes       []string // OIDC_SCOPES, space-separated; default "openid email profile"
+
+	// Just-in-time accounts. OIDC_ALLOWED_DOMAINS (comma-separated) limits
+	// sign-on to those email domains and creates accounts on first sign-on
+	// for people who have none; without it only existing accounts can sign
+	// on and no accounts are created.
+	AllowedDomains []string
+	DefaultRole    string // OIDC_DEFAULT_ROLE: role of new accounts no claim maps; default staff
+
+	// Role mapping. OIDC_ROLE_CLAIM names an ID token claim holding group or
+	// role names, and OIDC_ROLE_MAP maps its values to roles, e.g.
+	// "gym-admins=admin,front-desk=staff". Mapped roles are applied at every
+	// sign-on, so the provider stays the source of truth.
+	RoleClaim string
+	RoleMap   map[string]string
+}
+
+func (s OIDCSettings) Enabled() bool {
+	return s.Issuer != "" && s.ClientID != "" && s.RedirectURL != ""
+}
+
+// ssoRoles are the roles single sign-on may grant. Members sign in with
+// a password.
+var ssoRoles = map[string]bool{"admin": true, "staff": true, "trainer": true}
+
+func OIDC() OIDCSettings {
+	s := OIDCSettings{
+		Issuer:       strings.TrimSuffix(os.Getenv("OIDC_ISSUER"), "/"),
+		ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:  os.Getenv("OIDC_REDIRECT_URL"),
+		Scopes:       strings.Fields(os.Getenv("OIDC_SCOPES")),
+		DefaultRole:  os.Getenv("OIDC_DEFAULT_ROLE"),
+		RoleClaim:    os.Getenv("OIDC_ROLE_CLAIM"),
+		RoleMap:      map[string]string{},
+	}
+	if len(s.Scopes) == 0 {
+		s.Scopes = []string{"openid", "email", "profile"}
+	}
+	if s.DefaultRole == "" {
+		s.DefaultRole = "staff"
+	}
+	if !ssoRoles[s.DefaultRole] {
+		slog.Warn("OIDC_DEFAULT_ROLE cannot be granted by sign-on, using staff", "role", s.DefaultRole)
+		s.DefaultRole = "staff"
+	}
+
+	for _, d := range strings.Split(os.Getenv("OIDC_ALLOWED_DOMAINS"), ",") {
+		if d = strings.ToLower(strings.TrimSpace(d)); d != "" {
+			s.AllowedDomains = append(s.AllowedDomains, d)
+		}
+	}
+	for _, pair := range strings.Split(os.Getenv("OIDC_ROLE_MAP"), ",") {
+		value, role, ok := strings.Cut(pair, "=")
+		value, role = strings.TrimSpace(value), strings.TrimSpace(role)
+		if !ok || value == "" {
+			continue
+		}
+		if !ssoRoles[role] {
+			slog.Warn("OIDC_ROLE_MAP role cannot be granted by sign-on, ignoring it", "role", role, "value", value)
+			continue
+		}
+		s.RoleMap[value] = role
+	}
+	return s
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+// Mode is what a process runs: the HTTP API, the background jobs, or both.
+type Mode string
+
+const (
+	ModeAll    Mode = "all"
+	ModeAPI    Mode = "api"
+	ModeWorker Mode = "worker"
+)
+
+func (m Mode) API() bool    { return m == ModeAll || m == ModeAPI }
+func (m Mode) Worker() bool { return m == ModeAll || m == ModeWorker }
+
+// RunMode is RUN_MODE: all (the default) serves the API and runs the jobs
+// in one process; api and worker split them, so the API can be scaled out
+// while a single worker runs the jobs.
+func RunMode() Mode {
+	switch mode := Mode(strings.ToLower(os.Getenv("RUN_MODE"))); mode {
+	case ModeAll, ModeAPI, ModeWorker:
+		return mode
+	case "":
+		return ModeAll
+	default:
+		slog.Warn("Unknown RUN_MODE, running everything", "mode", mode)
+		return ModeAll
+	}
+}
+
+// ShutdownTimeout bounds a graceful stop: draining requests, waiting for
+// jobs and flushing what is queued. Defaults to 20 seconds
+// (SHUTDOWN_TIMEOUT_SECONDS); keep it under the orchestrator's grace
+// period.
+func ShutdownTimeout() time.Duration {
+	return time.Duration(envInt("SHUTDOWN_TIMEOUT_SECONDS", 20)) * time.Second
+}
+
+// DrainTimeout is the part of ShutdownTimeout given to HTTP requests in
+// flight (HTTP_DRAIN_SECONDS, half of it by default), so queued
+// notifications and spans still go out in the rest.
+func DrainTimeout() time.Duration {
+	total := ShutdownTimeout()
+	if seconds := envInt("HTTP_DRAIN_SECONDS", 0); seconds > 0 {
+		return min(time.Duration(seconds)*time.Second, total)
+	}
+	return total / 2
+}
+
+// ListenAddr is where the API listens (LISTEN_ADDR), :8080 by default.
+func ListenAddr() string {
+	if addr := os.Getenv("LISTEN_ADDR"); addr != "" {
+		return addr
+	}
+	return ":8080"
+}
@@ -0,0 +1,22 @@
+package config
+
+import (
+	"os"
+	"time"
+)
+
+// VerifyEmailTTL is how long the link confirming a self-registered
+// member's email address works. Defaults to 48 hours
+// (VERIFY_EMAIL_TTL_HOURS).
+func VerifyEmailTTL() time.Duration {
+	return time.Duration(envInt("VERIFY_EMAIL_TTL_HOURS", 48)) * time.Hour
+}
+
+// VerifyEmailURL is the web app page that confirms an email address
+// (VERIFY_EMAIL_URL). The token is appended as ?token=.
+func VerifyEmailURL() string {
+	if url := os.Getenv("VERIFY_EMAIL_URL"); url != "" {
+		return url
+	}
+	return "http://localhost:5173/verify-email"
+}
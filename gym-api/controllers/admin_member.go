@@ -4,162 +4,227 @@ import (
 	"strconv"
 	"time"
 
-	"gym-api/config"
+	"gym-api/apperror"
+	"gym-api/audit"
 	"gym-api/models"
+	"gym-api/services"
+	"gym-api/validation"
 
 	"github.com/gofiber/fiber/v2"
 )
 
-func GetAllMembers(c *fiber.Ctx) error {
-	var members []models.User
-	config.DB.Preload("Package").Where("role = ?", models.RoleMember).Find(&members)
-	return c.JSON(fiber.Map{"data": members})
+// MemberHandler serves the member endpoints.
+type MemberHandler struct {
+	membership services.Membership
 }
 
-func GetMemberById(c *fiber.Ctx) error {
+func NewMemberHandler(membership services.Membership) *MemberHandler {
+	return &MemberHandler{membership: membership}
+}
+
+// GetAllMembers lists members. See MemberListSpec for the search, filter
+// and sort parameters.
+func (h *MemberHandler) GetAllMembers(c *fiber.Ctx) error {
+	p, err := listParams(c, MemberListSpec)
+	if err != nil {
+		return err
+	}
+	page, err := h.membership.List(c.UserContext(), MemberListSpec, p)
+	if err != nil {
+		return err
+	}
+	return c.JSON(page)
+}
+
+func (h *MemberHandler) GetMemberById(c *fiber.Ctx) error {
 	idStr := c.Params("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ID"})
+		return apperror.BadRequest("Invalid ID")
 	}
 
-	var member models.User
-	if result := config.DB.Preload("Package").First(&member, id); result.Error != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Member not found"})
+	member, err := h.membership.Get(c.UserContext(), uint(id))
+	if err != nil {
+		return err
 	}
 
-	return c.JSON(fiber.Map{"data": member})
+	return c.JSON(dataResponse("", member))
 }
 
-type AssignTrainerInput struct {
-	MemberID  uint `json:"member_id"`
-	TrainerID uint `json:"trainer_id"`
+// CreateMemberInput is the front desk sign-up form. Unlike RegisterInput it
+// can sell a package straight away and set the end date by hand.
+type CreateMemberInput struct {
+	Name       string `json:"name" form:"name" validate:"required,max=100,name"`
+	Email      string `json:"email" form:"email" validate:"required,email,max=191"`
+	Password   string `json:"password" form:"password" validate:"required,password"`
+	Phone      string `json:"phone" form:"phone" validate:"phone"`
+	PackageID  string `json:"package_id" form:"package_id" validate:"omitempty,number"`
+	SubEndDate string `json:"sub_end_date" form:"sub_end_date" validate:"omitempty,datetime=2006-01-02"`
 }
 
-func AssignTrainer(c *fiber.Ctx) error {
-	var input AssignTrainerInput
-	if err := c.BodyParser(&input); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+func (i *CreateMemberInput) Normalize() {
+	validation.Trim(&i.Name, &i.Phone)
+	i.Email = validation.NormalizeEmail(i.Email)
+}
+
+// CreateMember signs a member up at the front desk. The member is active
+// straight away; staff have checked who they are.
+func (h *MemberHandler) CreateMember(c *fiber.Ctx) error {
+	staffID, _ := c.Locals("user_id").(uint)
+
+	// 1. Parse Form Data (Multipart)
+	input := CreateMemberInput{
+		Name:       c.FormValue("name"),
+		Email:      c.FormValue("email"),
+		Password:   c.FormValue("password"),
+		Phone:      c.FormValue("phone"),
+		PackageID:  c.FormValue("package_id"),
+		SubEndDate: c.FormValue("sub_end_date"),
+	}
+	if err := validation.Struct(&input); err != nil {
+		return inputError(err)
+	}
+	member := services.NewMember{
+		Name:     input.Name,
+		Email:    input.Email,
+		Phone:    input.Phone,
+		Password: input.Password,
+	}
+	if input.PackageID != "" {
+		id, err := strconv.ParseUint(input.PackageID, 10, 0)
+		if err != nil {
+			return apperror.BadRequest("Invalid package_id")
+		}
+		packageID := uint(id)
+		member.PackageID = &packageID
+	}
+	if input.SubEndDate != "" {
+		if end, err := time.Parse("2006-01-02", input.SubEndDate); err == nil {
+			member.SubEndDate = &end
+		}
+	}
+
+	// 2. Handle File Upload
+	file, err := c.FormFile("profile_picture")
+	if err == nil {
+		if member.Photo, err = storeUploadedPhoto(c, file); err != nil {
+			return photoError(c, err)
+		}
+	}
+
+	// 3. Save the member
+	user, err := h.membership.Create(c.UserContext(), member, staffID)
+	if err != nil {
+		if member.Photo != nil {
+			removeUserPhoto(models.User{PhotoKey: member.Photo.Key})
+		}
+		return err
 	}
+	audit.Record(models.AuditLog{Action: "member.created", ActorID: &staffID, Subject: user.Email, IP: c.IP()})
+
+	return c.JSON(UserResponse{Message: "Member registered successfully", User: user})
+}
+
+type AssignTrainerInput struct {
+	MemberID  uint `json:"member_id" validate:"required"`
+	TrainerID uint `json:"trainer_id" validate:"required"`
+}
 
-	var member models.User
-	if result := config.DB.First(&member, input.MemberID); result.Error != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Member not found"})
+// UpdateMemberInput is a partial update: omitted fields are left unchanged.
+type UpdateMemberInput struct {
+	Name             *string `json:"name" validate:"omitnil,min=1,max=100,name"`
+	Email            *string `json:"email" validate:"omitnil,email,max=191"`
+	Phone            *string `json:"phone" validate:"omitnil,phone"`
+	PackageID        *uint   `json:"package_id"`
+	MembershipStatus *string `json:"membership_status" validate:"omitnil,oneof=active inactive suspended"`
+}
+
+func (i *UpdateMemberInput) Normalize() {
+	for _, f := range []*string{i.Name, i.Phone} {
+		if f != nil {
+			validation.Trim(f)
+		}
 	}
+	if i.Email != nil {
+		*i.Email = validation.NormalizeEmail(*i.Email)
+	}
+}
 
-	var trainer models.User
-	if result := config.DB.First(&trainer, input.TrainerID); result.Error != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Trainer not found"})
+func (h *MemberHandler) AssignTrainer(c *fiber.Ctx) error {
+	var input AssignTrainerInput
+	if err := bindInput(c, &input); err != nil {
+		return inputError(err)
 	}
 
-	if trainer.Role != models.RoleTrainer {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Assigned user must be a trainer"})
+	if err := h.membership.AssignTrainer(c.UserContext(), input.MemberID, input.TrainerID); err != nil {
+		return err
 	}
 
-	member.AssignedTrainerID = &input.TrainerID
-	config.DB.Save(&member)
+	return c.JSON(MessageResponse{Message: "Trainer assigned successfully"})
+}
 
-	return c.JSON(fiber.Map{"message": "Trainer assigned successfully"})
+// StatusResponse reports a member's new membership status.
+type StatusResponse struct {
+	Message string `json:"message"`
+	Status  string `json:"status"`
 }
 
-func ToggleMemberStatus(c *fiber.Ctx) error {
+func (h *MemberHandler) ToggleMemberStatus(c *fiber.Ctx) error {
 	idStr := c.Params("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ID"})
-	}
-
-	var member models.User
-	if result := config.DB.First(&member, id); result.Error != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Member not found"})
+		return apperror.BadRequest("Invalid ID")
 	}
 
-	if member.MembershipStatus == "active" {
-		member.MembershipStatus = "inactive"
-	} else {
-		member.MembershipStatus = "active"
+	member, err := h.membership.ToggleStatus(c.UserContext(), uint(id))
+	if err != nil {
+		return err
 	}
-	config.DB.Save(&member)
 
-	return c.JSON(fiber.Map{"message": "Member status updated", "status": member.MembershipStatus})
+	return c.JSON(StatusResponse{Message: "Member status updated", Status: member.MembershipStatus})
 }
 
-func UpdateMember(c *fiber.Ctx) error {
+// UpdateMember edits a member. A different package_id sells that package,
+// starting now; sending the current one leaves the dates alone.
+func (h *MemberHandler) UpdateMember(c *fiber.Ctx) error {
 	idStr := c.Params("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ID"})
-	}
-
-	var input struct {
-		Name             string  `json:"name"`
-		Email            string  `json:"email"`
-		PackageID        *uint   `json:"package_id"`
-		MembershipStatus *string `json:"membership_status"`
-	}
-	if err := c.BodyParser(&input); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
-	}
-
-	var member models.User
-	// Preload package for current context
-	if result := config.DB.First(&member, id); result.Error != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Member not found"})
-	}
-
-	member.Name = input.Name
-	member.Email = input.Email
-
-	// Update Status if provided
-	if input.MembershipStatus != nil && *input.MembershipStatus != "" {
-		member.MembershipStatus = *input.MembershipStatus
-	}
-
-	// Update Package if provided (and different)
-	if input.PackageID != nil {
-		if member.PackageID == nil || *member.PackageID != *input.PackageID {
-			// Logic to update package and dates
-			var pkg models.Package
-			if err := config.DB.First(&pkg, *input.PackageID).Error; err == nil {
-				// Reset subscription dates based on new package
-				now := time.Now()
-				endDate := now.AddDate(0, 0, pkg.DurationDays)
-				member.PackageID = &pkg.ID
-				member.SubStartDate = &now
-				member.SubEndDate = &endDate
-				// Ensure active if assigning a package
-				if member.MembershipStatus != "active" {
-					member.MembershipStatus = "active"
-				}
-			}
-		} else {
-			// Same package, maybe just want to update ID reference?
-			// Logic above handles "different or nil", if same, we can assume NO-OP on dates
-			// typically we wouldn't reach here unless we want to reset dates?
-			// Let's assume sending the same ID does NOT reset dates unless checking a flag.
-			// For simplicity: sending package_id always updates it. If matches, we can skip date reset OR forced reset.
-			// Let's adopt behavior: Providing package_id updates the package.
-			// If user selects same package, maybe they want to RENEW?
-			// Standard Edit behavior: changing fields.
-			// For now, if I switch plan, I get new dates.
-		}
+		return apperror.BadRequest("Invalid ID")
 	}
 
-	config.DB.Save(&member)
+	var input UpdateMemberInput
+	if err := bindInput(c, &input); err != nil {
+		return inputError(err)
+	}
 
-	return c.JSON(fiber.Map{"message": "Member updated", "data": member})
+	adminID, _ := c.Locals("user_id").(uint)
+	member, err := h.membership.Update(c.UserContext(), uint(id), services.MemberChanges{
+		Name:             input.Name,
+		Email:            input.Email,
+		Phone:            input.Phone,
+		PackageID:        input.PackageID,
+		MembershipStatus: input.MembershipStatus,
+	}, adminID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(dataResponse("Member updated", member))
 }
 
-func DeleteMember(c *fiber.Ctx) error {
+func (h *MemberHandler) DeleteMember(c *fiber.Ctx) error {
 	idStr := c.Params("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ID"})
+		return apperror.BadRequest("Invalid ID")
 	}
 
-	if result := config.DB.Delete(&models.User{}, id); result.Error != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete member"})
+	member, err := h.membership.Delete(c.UserContext(), uint(id))
+	if err != nil {
+		return err
 	}
+	removeUserPhoto(member)
 
-	return c.JSON(fiber.Map{"message": "Member deleted"})
+	return c.JSON(MessageResponse{Message: "Member deleted"})
 }
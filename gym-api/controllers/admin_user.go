@@ -3,120 +3,165 @@ package controllers
 import (
 	"strconv"
 
-	"gym-api/config"
+	"gym-api/apperror"
 	"gym-api/models"
-	"gym-api/utils"
+	"gym-api/services"
+	"gym-api/validation"
 
 	"github.com/gofiber/fiber/v2"
 )
 
+// UserHandler serves the staff and trainer account endpoints.
+type UserHandler struct {
+	users services.Users
+}
+
+func NewUserHandler(users services.Users) *UserHandler {
+	return &UserHandler{users: users}
+}
+
 type CreateUserInput struct {
-	Name     string `json:"name"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
-	Role     string `json:"role"` // staff, trainer
+	Name     string `json:"name" validate:"required,max=100,name"`
+	Email    string `json:"email" validate:"required,email,max=191"`
+	Password string `json:"password" validate:"required,password"`
+	Role     string `json:"role" validate:"required,oneof=staff trainer"`
+	BranchID *uint  `json:"branch_id"`
 }
 
-func CreateUser(c *fiber.Ctx) error {
-	var input CreateUserInput
-	if err := c.BodyParser(&input); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
-	}
+func (i *CreateUserInput) Normalize() {
+	validation.Trim(&i.Name)
+	i.Email = validation.NormalizeEmail(i.Email)
+}
 
-	if input.Role != string(models.RoleStaff) && input.Role != string(models.RoleTrainer) {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid role. Only staff or trainer allowed."})
-	}
+// UpdateUserInput is a partial update: omitted fields are left unchanged.
+type UpdateUserInput struct {
+	Name     *string `json:"name" validate:"omitnil,min=1,max=100,name"`
+	Email    *string `json:"email" validate:"omitnil,email,max=191"`
+	Role     *string `json:"role" validate:"omitnil,oneof=staff trainer"`
+	BranchID *uint   `json:"branch_id"`
+}
 
-	hash, err := utils.HashPassword(input.Password)
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not hash password"})
+func (i *UpdateUserInput) Normalize() {
+	if i.Name != nil {
+		validation.Trim(i.Name)
+	}
+	if i.Email != nil {
+		*i.Email = validation.NormalizeEmail(*i.Email)
 	}
+}
 
-	user := models.User{
-		Name:         input.Name,
-		Email:        input.Email,
-		PasswordHash: hash,
-		Role:         models.Role(input.Role),
-		IsActive:     true,
+// CreateUser makes an account with a password chosen by the admin.
+// Deprecated: use InviteUser, which lets the new user choose it.
+func (h *UserHandler) CreateUser(c *fiber.Ctx) error {
+	var input CreateUserInput
+	if err := bindInput(c, &input); err != nil {
+		return inputError(err)
 	}
 
-	if result := config.DB.Create(&user); result.Error != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "User already exists or invalid data"})
+	user, err := h.users.Create(c.UserContext(), services.NewUser{
+		Name:     input.Name,
+		Email:    input.Email,
+		Password: input.Password,
+		Role:     models.Role(input.Role),
+		BranchID: input.BranchID,
+	})
+	if err != nil {
+		return err
 	}
 
-	return c.JSON(fiber.Map{"message": "User created successfully", "user": user})
+	return c.JSON(UserResponse{Message: "User created successfully", User: user})
 }
 
-func GetUsersByRole(c *fiber.Ctx) error {
+func (h *UserHandler) GetUsersByRole(c *fiber.Ctx) error {
 	role := c.Query("role")
 	if role == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Role query param required"})
+		return apperror.BadRequest("Role query param required")
 	}
+	return h.list(c, models.Role(role))
+}
 
-	var users []models.User
-	config.DB.Where("role = ?", role).Find(&users)
-	return c.JSON(fiber.Map{"data": users})
+func (h *UserHandler) GetAllTrainers(c *fiber.Ctx) error {
+	return h.list(c, models.RoleTrainer)
 }
 
-func UpdateUser(c *fiber.Ctx) error {
+func (h *UserHandler) list(c *fiber.Ctx, role models.Role) error {
+	p, err := listParams(c, UserListSpec)
+	if err != nil {
+		return err
+	}
+	page, err := h.users.List(c.UserContext(), role, UserListSpec, p)
+	if err != nil {
+		return err
+	}
+	return c.JSON(page)
+}
+
+func (h *UserHandler) UpdateUser(c *fiber.Ctx) error {
 	idStr := c.Params("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ID"})
+		return apperror.BadRequest("Invalid ID")
 	}
 
-	var input struct {
-		Name  string `json:"name"`
-		Email string `json:"email"`
-		Role  string `json:"role"`
-	}
-	if err := c.BodyParser(&input); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	var input UpdateUserInput
+	if err := bindInput(c, &input); err != nil {
+		return inputError(err)
 	}
 
-	var user models.User
-	if result := config.DB.First(&user, id); result.Error != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+	changes := services.UserChanges{Name: input.Name, Email: input.Email, BranchID: input.BranchID}
+	if input.Role != nil {
+		role := models.Role(*input.Role)
+		changes.Role = &role
 	}
-
-	user.Name = input.Name
-	user.Email = input.Email
-	if input.Role != "" {
-		user.Role = models.Role(input.Role)
+	user, err := h.users.Update(c.UserContext(), uint(id), changes)
+	if err != nil {
+		return err
 	}
-	config.DB.Save(&user)
 
-	return c.JSON(fiber.Map{"message": "User updated", "data": user})
+	return c.JSON(dataResponse("User updated", user))
 }
 
-func DeleteUser(c *fiber.Ctx) error {
+func (h *UserHandler) DeleteUser(c *fiber.Ctx) error {
 	idStr := c.Params("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ID"})
+		return apperror.BadRequest("Invalid ID")
 	}
 
-	if result := config.DB.Delete(&models.User{}, id); result.Error != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete user"})
+	user, err := h.users.Delete(c.UserContext(), uint(id))
+	if err != nil {
+		return err
 	}
+	removeUserPhoto(user)
 
-	return c.JSON(fiber.Map{"message": "User deleted"})
+	return c.JSON(MessageResponse{Message: "User deleted"})
 }
 
-func ToggleUserStatus(c *fiber.Ctx) error {
+// ActiveResponse reports an account's new state after a toggle.
+type ActiveResponse struct {
+	Message  string `json:"message"`
+	IsActive bool   `json:"is_active"`
+}
+
+func (h *UserHandler) ToggleUserStatus(c *fiber.Ctx) error {
+	return h.toggle(c, "User status updated")
+}
+
+func (h *UserHandler) ToggleTrainerStatus(c *fiber.Ctx) error {
+	return h.toggle(c, "Trainer status updated")
+}
+
+func (h *UserHandler) toggle(c *fiber.Ctx, message string) error {
 	idStr := c.Params("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ID"})
+		return apperror.BadRequest("Invalid ID")
 	}
 
-	var user models.User
-	if result := config.DB.First(&user, id); result.Error != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+	user, err := h.users.ToggleActive(c.UserContext(), uint(id))
+	if err != nil {
+		return err
 	}
 
-	user.IsActive = !user.IsActive
-	config.DB.Save(&user)
-
-	return c.JSON(fiber.Map{"message": "User status updated", "is_active": user.IsActive})
+	return c.JSON(ActiveResponse{Message: message, IsActive: user.IsActive})
 }
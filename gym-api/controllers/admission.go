@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"errors"
+	"time"
+
+	"gym-api/apperror"
+	"gym-api/models"
+	"gym-api/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// scanResponse renders the outcome of an admission. On success it includes
+// the member's card so the desk can check the photo.
+func scanResponse(c *fiber.Ctx, attendance models.Attendance, member models.User, err error) error {
+	var denied *services.ScanDenied
+	if errors.As(err, &denied) {
+		return apperror.New(denied.Status, denied.Code, denied.Reason)
+	}
+	if err != nil {
+		return apperror.DB(err, "Could not mark attendance")
+	}
+
+	return c.JSON(ScanResponse{
+		Message: "Attendance marked successfully",
+		Data:    attendance,
+		Member:  verificationCard(member),
+	})
+}
+
+type ScanResponse struct {
+	Message string            `json:"message"`
+	Data    models.Attendance `json:"data"`
+	Member  MemberCard        `json:"member"`
+}
+
+// MemberCard is what the desk sees after a scan to confirm the person in
+// front of them is the member the QR code belongs to.
+type MemberCard struct {
+	ID               uint        `json:"id"`
+	Name             string      `json:"name"`
+	Role             models.Role `json:"role"`
+	ProfilePicture   string      `json:"profile_picture"`
+	ProfileThumbnail string      `json:"profile_thumbnail"`
+	MembershipStatus string      `json:"membership_status"`
+	Package          *string     `json:"package"` // Package name
+	SubEndDate       *time.Time  `json:"sub_end_date"`
+}
+
+func verificationCard(user models.User) MemberCard {
+	card := MemberCard{
+		ID:               user.ID,
+		Name:             user.Name,
+		Role:             user.Role,
+		ProfilePicture:   user.ProfilePicture,
+		ProfileThumbnail: user.ProfileThumbnail,
+		MembershipStatus: user.MembershipStatus,
+		SubEndDate:       user.SubEndDate,
+	}
+	if user.Package != nil {
+		card.Package = &user.Package.Name
+	}
+	return card
+}
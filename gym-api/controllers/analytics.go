@@ -0,0 +1,130 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"gym-api/analytics"
+	"gym-api/apperror"
+	"gym-api/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// parseAnalyticsParams reads the shared report filters:
+// from/to (YYYY-MM-DD or RFC 3339, default last 30 days), granularity,
+// branch_id and package_id. A date-only "to" includes that whole day.
+func parseAnalyticsParams(c *fiber.Ctx) (analytics.Params, error) {
+	var p analytics.Params
+	var err error
+
+	p.To = time.Now()
+	if toStr := c.Query("to"); toStr != "" {
+		if p.To, err = parseReportTime(toStr); err != nil {
+			return p, errors.New("Invalid 'to' date")
+		}
+		if len(toStr) == len("2006-01-02") {
+			p.To = p.To.AddDate(0, 0, 1)
+		}
+	}
+
+	p.From = p.To.AddDate(0, 0, -30)
+	if fromStr := c.Query("from"); fromStr != "" {
+		if p.From, err = parseReportTime(fromStr); err != nil {
+			return p, errors.New("Invalid 'from' date")
+		}
+	}
+	if !p.From.Before(p.To) {
+		return p, errors.New("'from' must be before 'to'")
+	}
+
+	if p.Granularity, err = analytics.ParseGranularity(c.Query("granularity")); err != nil {
+		return p, err
+	}
+
+	for param, dest := range map[string]**uint{"branch_id": &p.BranchID, "package_id": &p.PackageID} {
+		if v := c.Query(param); v != "" {
+			id, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				return p, errors.New("Invalid " + param)
+			}
+			uid := uint(id)
+			*dest = &uid
+		}
+	}
+
+	return p, nil
+}
+
+func parseReportTime(s string) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// AnalyticsResponse is a report with the period it covers.
+type AnalyticsResponse[T any] struct {
+	Data        T                     `json:"data"`
+	From        time.Time             `json:"from"`
+	To          time.Time             `json:"to"`
+	Granularity analytics.Granularity `json:"granularity"`
+}
+
+// AnalyticsHandler serves the management reports.
+type AnalyticsHandler struct {
+	analytics services.Analytics
+}
+
+func NewAnalyticsHandler(analytics services.Analytics) *AnalyticsHandler {
+	return &AnalyticsHandler{analytics: analytics}
+}
+
+// analyticsHandler wraps a report with parameter parsing and the standard
+// response envelope.
+func analyticsHandler[T any](report func(context.Context, analytics.Params) (T, error)) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		p, err := parseAnalyticsParams(c)
+		if err != nil {
+			return apperror.BadRequest(err.Error())
+		}
+
+		data, err := report(c.UserContext(), p)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(AnalyticsResponse[T]{
+			Data:        data,
+			From:        p.From,
+			To:          p.To,
+			Granularity: p.Granularity,
+		})
+	}
+}
+
+func (h *AnalyticsHandler) GetAttendanceAnalytics(c *fiber.Ctx) error {
+	return analyticsHandler(h.analytics.Attendance)(c)
+}
+
+func (h *AnalyticsHandler) GetPeakHourHeatmap(c *fiber.Ctx) error {
+	return analyticsHandler(h.analytics.Heatmap)(c)
+}
+
+func (h *AnalyticsHandler) GetMemberFlows(c *fiber.Ctx) error {
+	return analyticsHandler(h.analytics.MemberFlows)(c)
+}
+
+func (h *AnalyticsHandler) GetRetentionCohorts(c *fiber.Ctx) error {
+	return analyticsHandler(h.analytics.RetentionCohorts)(c)
+}
+
+func (h *AnalyticsHandler) GetRevenueByPackage(c *fiber.Ctx) error {
+	return analyticsHandler(h.analytics.RevenueByPackage)(c)
+}
+
+func (h *AnalyticsHandler) GetAverageVisits(c *fiber.Ctx) error {
+	return analyticsHandler(h.analytics.AverageVisits)(c)
+}
@@ -0,0 +1,123 @@
+package controllers_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"gym-api/analytics"
+	"gym-api/apptest"
+	"gym-api/controllers"
+	"gym-api/models"
+)
+
+// Check-ins are bucketed in the server's time zone, whatever the
+// database makes of the stored times.
+func TestAnalyticsBucketsInLocalTime(t *testing.T) {
+	previous := time.Local
+	time.Local = time.FixedZone("UTC+10", 10*60*60)
+	t.Cleanup(func() { time.Local = previous })
+
+	app := apptest.New(t)
+	token := app.Token(t, app.AddUser(t, models.User{Email: "admin@example.com", Role: models.RoleAdmin}))
+	local := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2026, month, day, hour, minute, 0, 0, time.Local)
+	}
+	// Joined half an hour into February, which is still January in UTC
+	cal := app.AddUser(t, models.User{Email: "cal@example.com", Role: models.RoleMember, CreatedAt: local(time.February, 1, 0, 30)})
+	kim := app.AddUser(t, models.User{Email: "kim@example.com", Role: models.RoleMember, CreatedAt: local(time.February, 1, 0, 30)})
+	for _, visit := range []models.Attendance{
+		{TrainerID: cal.ID, ScanTime: local(time.March, 2, 23, 30)}, // Monday, 13:30 UTC
+		{TrainerID: cal.ID, ScanTime: local(time.March, 3, 0, 15)},  // Tuesday, still Monday in UTC
+		{TrainerID: kim.ID, ScanTime: local(time.March, 3, 9, 0)},
+	} {
+		visit.Date = visit.ScanTime
+		if err := app.DB.Create(&visit).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	get := func(path string, data any) {
+		t.Helper()
+		resp := app.Do(t, apptest.Request{Method: http.MethodGet, Path: path, Token: token})
+		if resp.Status != http.StatusOK {
+			t.Fatalf("%s: %d %s", path, resp.Status, resp.Body)
+		}
+		resp.JSON(t, &struct {
+			Data any `json:"data"`
+		}{data})
+	}
+
+	var days []analytics.SeriesPoint
+	get("/api/admin/analytics/attendance?from=2026-03-02&to=2026-03-03&granularity=day", &days)
+	if len(days) != 2 || days[0] != (analytics.SeriesPoint{Period: "2026-03-02", Count: 1}) || days[1] != (analytics.SeriesPoint{Period: "2026-03-03", Count: 2}) {
+		t.Errorf("daily attendance = %+v", days)
+	}
+
+	var hours []analytics.SeriesPoint
+	get("/api/admin/analytics/attendance?from=2026-03-02&to=2026-03-02&granularity=hour", &hours)
+	if len(hours) != 24 || hours[23] != (analytics.SeriesPoint{Period: "2026-03-02T23:00", Count: 1}) {
+		t.Errorf("hourly attendance = %+v", hours)
+	}
+
+	var weeks []analytics.SeriesPoint
+	get("/api/admin/analytics/attendance?from=2026-03-02&to=2026-03-08&granularity=week", &weeks)
+	if len(weeks) != 1 || weeks[0] != (analytics.SeriesPoint{Period: "2026-03-02", Count: 3}) {
+		t.Errorf("weekly attendance = %+v", weeks)
+	}
+
+	var grid [7][24]int64
+	get("/api/admin/analytics/heatmap?from=2026-03-01&to=2026-03-31", &grid)
+	if grid[time.Monday][23] != 1 || grid[time.Tuesday][0] != 1 || grid[time.Tuesday][9] != 1 {
+		t.Errorf("heatmap Monday 23h = %d, Tuesday 0h = %d, Tuesday 9h = %d; want 1 each",
+			grid[time.Monday][23], grid[time.Tuesday][0], grid[time.Tuesday][9])
+	}
+
+	var visits []analytics.VisitFrequency
+	get("/api/admin/analytics/visits?from=2026-03-02&to=2026-03-03&granularity=day", &visits)
+	if len(visits) != 2 || visits[1].Visits != 2 || visits[1].Visitors != 2 || visits[1].Average != 1 {
+		t.Errorf("visits = %+v", visits)
+	}
+
+	var cohorts []analytics.Cohort
+	get("/api/admin/analytics/retention?from=2026-01-01&to=2026-03-31", &cohorts)
+	if len(cohorts) != 1 || cohorts[0].Month != "2026-02" || cohorts[0].Size != 2 {
+		t.Fatalf("cohorts = %+v, want one February cohort of 2", cohorts)
+	}
+	if r := cohorts[0].Retention; len(r) < 2 || r[0] != 0 || r[1] != 1 {
+		t.Errorf("retention = %v, want nobody in February and everyone in March", r)
+	}
+
+	var flows []analytics.MemberFlow
+	get("/api/admin/analytics/members?from=2026-01-01&to=2026-03-31&granularity=month", &flows)
+	if len(flows) != 3 || flows[0].New != 0 || flows[1].New != 2 {
+		t.Errorf("member flows = %+v, want both members new in February", flows)
+	}
+}
+
+func TestDashboardStats(t *testing.T) {
+	app := apptest.New(t)
+	token := app.Token(t, app.AddUser(t, models.User{Email: "admin@example.com", Role: models.RoleAdmin}))
+	pkg := models.Package{Name: "Monthly", DurationDays: 30, Price: 40}
+	if err := app.DB.Create(&pkg).Error; err != nil {
+		t.Fatal(err)
+	}
+	ana := app.AddUser(t, models.User{Email: "ana@example.com", Role: models.RoleMember, MembershipStatus: "active", PackageID: &pkg.ID})
+	app.AddUser(t, models.User{Email: "bea@example.com", Role: models.RoleMember, MembershipStatus: "inactive", PackageID: &pkg.ID})
+	app.AddUser(t, models.User{Email: "kim@example.com", Role: models.RoleTrainer})
+	now := time.Now()
+	for _, scanned := range []time.Time{now, now.AddDate(0, 0, -1)} {
+		if err := app.DB.Create(&models.Attendance{TrainerID: ana.ID, ScanTime: scanned, Date: scanned}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	resp := app.Do(t, apptest.Request{Method: http.MethodGet, Path: "/api/admin/stats", Token: token})
+	var body struct {
+		Data controllers.DashboardStats `json:"data"`
+	}
+	resp.JSON(t, &body)
+	want := controllers.DashboardStats{TotalMembers: 2, ActiveMembers: 1, TotalTrainers: 1, EstimatedRevenue: 40, TodayAttendance: 1}
+	if resp.Status != http.StatusOK || body.Data != want {
+		t.Errorf("stats: %d %+v, want %+v", resp.Status, body.Data, want)
+	}
+}
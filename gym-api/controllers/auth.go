@@ -1,237 +1,196 @@
 package controllers
 
 import (
+	"errors"
 	"fmt"
-	"time"
+	"math"
+	"strconv"
 
-	"gym-api/config"
+	"gym-api/apperror"
+	"gym-api/lockout"
+	"gym-api/metrics"
 	"gym-api/models"
+	"gym-api/services"
+	"gym-api/twofactor"
 	"gym-api/utils"
+	"gym-api/validation"
 
 	"github.com/gofiber/fiber/v2"
 )
 
-type RegisterInput struct {
-	Name     string `json:"name"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
-}
-
 type LoginInput struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email    string `json:"email" validate:"required"`
+	Password string `json:"password" validate:"required"`
 }
 
-func Register(c *fiber.Ctx) error {
-	// 1. Parse Form Data (Multipart)
-	name := c.FormValue("name")
-	email := c.FormValue("email")
-	password := c.FormValue("password")
-	packageIDStr := c.FormValue("package_id")
+func (i *LoginInput) Normalize() {
+	i.Email = validation.NormalizeEmail(i.Email)
+}
 
-	if name == "" || email == "" || password == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Name, email, and password are required"})
-	}
+// AuthHandler serves password sign-in and the signed-in user's account.
+type AuthHandler struct {
+	auth services.Auth
+}
 
-	// 2. Handle File Upload
-	var profilePicPath string
-	file, err := c.FormFile("profile_picture")
-	if err == nil {
-		// Save file to ./uploads directory
-		// Generate unique filename to avoid collisions
-		filename := fmt.Sprintf("%d_%s", time.Now().Unix(), file.Filename)
-		path := fmt.Sprintf("./uploads/%s", filename)
-
-		if err := c.SaveFile(file, path); err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save profile picture"})
-		}
-		// Store relative path for frontend access
-		profilePicPath = "/uploads/" + filename
-	}
+func NewAuthHandler(auth services.Auth) *AuthHandler {
+	return &AuthHandler{auth: auth}
+}
 
-	// 3. Hash Password
-	hash, err := utils.HashPassword(password)
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not hash password"})
-	}
+func (h *AuthHandler) Login(c *fiber.Ctx) (err error) {
+	defer func() { countLogin("password", err) }()
 
-	// 4. Create User Object
-	user := models.User{
-		Name:           name,
-		Email:          email,
-		PasswordHash:   hash,
-		Role:           models.RoleMember, // Default to Member
-		ProfilePicture: profilePicPath,
+	var input LoginInput
+	if err := bindInput(c, &input); err != nil {
+		return inputError(err)
 	}
 
-	// 5. Handle Package Assignment (if selected)
-	if packageIDStr != "" {
-		var pkg models.Package
-		if err := config.DB.First(&pkg, "id = ?", packageIDStr).Error; err == nil {
-			// Package found, assume subscription starts now
-			now := time.Now()
-			endDate := now.AddDate(0, 0, pkg.DurationDays)
-
-			user.PackageID = &pkg.ID
-			user.SubStartDate = &now
-			user.SubEndDate = &endDate
-			user.MembershipStatus = "active"
+	user, err := h.auth.Login(c.UserContext(), input.Email, input.Password, c.IP())
+	if err != nil {
+		if _, ok := apperror.As(err); ok {
+			return err
 		}
+		return loginBlocked(c, err) // The login limits refused the attempt
 	}
+	return issueLogin(c, user, false)
+}
 
-	// 5b. Handle Manual Sub End Date (Override)
-	subEndDateStr := c.FormValue("sub_end_date")
-	if subEndDateStr != "" {
-		layout := "2006-01-02"
-		if parsedDate, err := time.Parse(layout, subEndDateStr); err == nil {
-			now := time.Now()
-			user.SubStartDate = &now
-			user.SubEndDate = &parsedDate
-			user.MembershipStatus = "active"
+// issueLogin ends a successful first login step. Accounts with 2FA get a
+// challenge for VerifyLogin instead of a token. twoFactor records that the
+// first step already involved a second factor, e.g. at the SSO provider.
+func issueLogin(c *fiber.Ctx, user models.User, twoFactor bool) error {
+	if user.TwoFactorEnabledAt != nil {
+		challenge, err := utils.GenerateChallengeToken(user.ID, string(user.Role))
+		if err != nil {
+			return apperror.Internal(err, "Could not login")
 		}
+		return c.JSON(LoginResponse{TwoFactor: &TwoFactorChallenge{
+			ChallengeToken: challenge,
+			ExpiresIn:      int(utils.ChallengeTTL.Seconds()),
+		}})
 	}
 
-	// 6. Save User to DB
-	if result := config.DB.Create(&user); result.Error != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "User already exists or invalid data"})
+	token, err := utils.GenerateToken(user.ID, string(user.Role), twoFactor)
+	if err != nil {
+		return apperror.Internal(err, "Could not login")
 	}
 
-	return c.JSON(fiber.Map{"message": "User registered successfully", "user": user})
+	profile := profileOf(user)
+	return c.JSON(LoginResponse{
+		Token:                  token,
+		User:                   &profile,
+		TwoFactorSetupRequired: !twoFactor && twofactor.Default.Required(c.UserContext(), user.Role),
+	})
 }
 
-func Login(c *fiber.Ctx) error {
-	var input LoginInput
-	if err := c.BodyParser(&input); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
-	}
+// countLogin records a login attempt in metrics.Logins. A password login
+// that ends in a 2FA challenge counts as a success; the code is counted by
+// VerifyLogin.
+func countLogin(method string, err error) {
+	result := "success"
+	if e, ok := apperror.As(err); ok && e.Status == fiber.StatusTooManyRequests {
+		result = "throttled"
+	} else if err != nil {
+		result = "failure"
+	}
+	metrics.Logins.WithLabelValues(method, result).Inc()
+}
 
-	var user models.User
-	if result := config.DB.Where("email = ?", input.Email).First(&user); result.Error != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid credentials"})
-	}
+// Codes for refused login attempts.
+const (
+	CodeLoginThrottled apperror.Code = "login_throttled"
+	CodeAccountLocked  apperror.Code = "account_locked"
+)
 
-	if !utils.CheckPasswordHash(input.Password, user.PasswordHash) {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid credentials"})
-	}
+// loginBlocked turns a lockout refusal into a 429 with Retry-After.
+func loginBlocked(c *fiber.Ctx, err error) error {
+	var blocked *lockout.Blocked
+	if !errors.As(err, &blocked) {
+		return apperror.DB(err, "Could not login")
+	}
+
+	seconds := int(math.Ceil(blocked.RetryAfter.Seconds()))
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(seconds))
+	switch {
+	case blocked.Kind == lockout.KindIP:
+		return apperror.New(fiber.StatusTooManyRequests, apperror.CodeRateLimited,
+			"Too many login attempts from this address, try again later")
+	case blocked.Locked:
+		return apperror.New(fiber.StatusTooManyRequests, CodeAccountLocked,
+			fmt.Sprintf("Too many failed attempts. Account locked, try again in %d minutes", (seconds+59)/60))
+	}
+	return apperror.New(fiber.StatusTooManyRequests, CodeLoginThrottled,
+		fmt.Sprintf("Too many failed attempts, wait %d seconds", seconds))
+}
 
-	if !user.IsActive {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "User is deactivated"})
-	}
+// UserProfile is the signed-in user as returned by Login and Me.
+type UserProfile struct {
+	ID                uint        `json:"id"`
+	Name              string      `json:"name"`
+	Email             string      `json:"email"`
+	Role              models.Role `json:"role"`
+	MembershipStatus  string      `json:"membership_status"`
+	AssignedTrainerID *uint       `json:"assigned_trainer_id"`
+	ProfilePicture    string      `json:"profile_picture"`
+	ProfileThumbnail  string      `json:"profile_thumbnail"`
+	TwoFactorEnabled  bool        `json:"two_factor_enabled"`
+}
 
-	// Check for active subscription
-	if user.Role == models.RoleMember && user.SubEndDate != nil {
-		if time.Now().After(*user.SubEndDate) {
-			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Subscription expired"})
-		}
+func profileOf(user models.User) UserProfile {
+	return UserProfile{
+		ID:                user.ID,
+		Name:              user.Name,
+		Email:             user.Email,
+		Role:              user.Role,
+		MembershipStatus:  user.MembershipStatus,
+		AssignedTrainerID: user.AssignedTrainerID,
+		ProfilePicture:    user.ProfilePicture,
+		ProfileThumbnail:  user.ProfileThumbnail,
+		TwoFactorEnabled:  user.TwoFactorEnabledAt != nil,
 	}
+}
 
-	token, err := utils.GenerateToken(user.ID, string(user.Role))
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not login"})
-	}
+// LoginResponse holds either a session (Token and User) or, for accounts
+// with 2FA, the TwoFactor challenge to complete with VerifyLogin.
+type LoginResponse struct {
+	Token     string              `json:"token,omitempty"`
+	User      *UserProfile        `json:"user,omitempty"`
+	TwoFactor *TwoFactorChallenge `json:"two_factor,omitempty"`
 
-	return c.JSON(fiber.Map{
-		"token": token,
-		"user": fiber.Map{
-			"id":                  user.ID,
-			"name":                user.Name,
-			"email":               user.Email,
-			"role":                user.Role,
-			"membership_status":   user.MembershipStatus,
-			"assigned_trainer_id": user.AssignedTrainerID,
-			"profile_picture":     user.ProfilePicture,
-			// "package": user.Package, // Include if we preloaded it, currently we don't in Login.
-		},
-	})
+	// The role requires 2FA: the token only reaches the setup routes until
+	// it is enabled
+	TwoFactorSetupRequired bool `json:"two_factor_setup_required,omitempty"`
+}
+
+type ProfileResponse struct {
+	User UserProfile `json:"user"`
 }
 
 // ChangePasswordInput struct
 type ChangePasswordInput struct {
-	OldPassword string `json:"old_password"`
-	NewPassword string `json:"new_password"`
+	OldPassword string `json:"old_password" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required,password,nefield=OldPassword"`
 }
 
-// ChangePassword Controller
-// ChangePassword Controller
-func ChangePassword(c *fiber.Ctx) error {
-	// 1. Get User ID from Context (set by middleware)
-	var uid uint
-	switch v := c.Locals("user_id").(type) {
-	case uint:
-		uid = v
-	case float64:
-		uid = uint(v)
-	case int:
-		uid = uint(v)
-	default:
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
-	}
+func (h *AuthHandler) ChangePassword(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(uint)
 
-	// 2. Parse Input
 	var input ChangePasswordInput
-	if err := c.BodyParser(&input); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	if err := bindInput(c, &input); err != nil {
+		return inputError(err)
 	}
-
-	if len(input.NewPassword) < 6 {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "New password must be at least 6 characters"})
-	}
-
-	// 3. Find User
-	var user models.User
-	if err := config.DB.First(&user, uid).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+	if err := h.auth.ChangePassword(c.UserContext(), userID, input.OldPassword, input.NewPassword); err != nil {
+		return err
 	}
-
-	// 4. Verify Old Password
-	if !utils.CheckPasswordHash(input.OldPassword, user.PasswordHash) {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Incorrect old password"})
-	}
-
-	// 5. Hash New Password
-	newHash, err := utils.HashPassword(input.NewPassword)
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not hash new password"})
-	}
-
-	// 6. Update User
-	user.PasswordHash = newHash
-	config.DB.Save(&user)
-
-	return c.JSON(fiber.Map{"message": "Password updated successfully"})
+	return c.JSON(MessageResponse{Message: "Password updated successfully"})
 }
 
-// Me Controller - Fetch Current User Profile
-func Me(c *fiber.Ctx) error {
-	// 1. Get User ID from Context
-	var uid uint
-	switch v := c.Locals("user_id").(type) {
-	case uint:
-		uid = v
-	case float64:
-		uid = uint(v)
-	case int:
-		uid = uint(v)
-	default:
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
-	}
+// Me returns the signed-in user, as Login does.
+func (h *AuthHandler) Me(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(uint)
 
-	// 2. Find User (Preload necessary relations if needed)
-	var user models.User
-	if err := config.DB.First(&user, uid).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+	user, err := h.auth.Profile(c.UserContext(), userID)
+	if err != nil {
+		return err
 	}
-
-	// 3. Return User Data (matching Login response structure)
-	return c.JSON(fiber.Map{
-		"user": fiber.Map{
-			"id":                  user.ID,
-			"name":                user.Name,
-			"email":               user.Email,
-			"role":                user.Role,
-			"membership_status":   user.MembershipStatus,
-			"assigned_trainer_id": user.AssignedTrainerID,
-			"profile_picture":     user.ProfilePicture,
-		},
-	})
+	return c.JSON(ProfileResponse{User: profileOf(user)})
 }
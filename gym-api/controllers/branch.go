@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	"gym-api/models"
+	"gym-api/services"
+	"gym-api/validation"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// BranchHandler serves the gym locations.
+type BranchHandler struct {
+	branches services.Branches
+}
+
+func NewBranchHandler(branches services.Branches) *BranchHandler {
+	return &BranchHandler{branches: branches}
+}
+
+func (h *BranchHandler) GetBranches(c *fiber.Ctx) error {
+	list, err := h.branches.List(c.UserContext())
+	if err != nil {
+		return err
+	}
+	return c.JSON(dataResponse("", list))
+}
+
+type BranchInput struct {
+	Name     string `json:"name" validate:"required,max=100"`
+	Address  string `json:"address" validate:"max=255"`
+	Capacity int    `json:"capacity" validate:"gte=0"` // 0 falls back to MAX_CAPACITY
+}
+
+func (i *BranchInput) Normalize() {
+	validation.Trim(&i.Name, &i.Address)
+}
+
+func (h *BranchHandler) CreateBranch(c *fiber.Ctx) error {
+	var input BranchInput
+	if err := bindInput(c, &input); err != nil {
+		return inputError(err)
+	}
+
+	branch, err := h.branches.Create(c.UserContext(), models.Branch{Name: input.Name, Address: input.Address, Capacity: input.Capacity})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(dataResponse("Branch created", branch))
+}
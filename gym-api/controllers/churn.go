@@ -0,0 +1,46 @@
+package controllers
+
+import (
+	"gym-api/apperror"
+	"gym-api/churn"
+	"gym-api/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ChurnHandler serves the churn scores.
+type ChurnHandler struct {
+	churn services.Churn
+}
+
+func NewChurnHandler(churn services.Churn) *ChurnHandler {
+	return &ChurnHandler{churn: churn}
+}
+
+// GetAtRiskMembers lists members by churn score so staff can reach out.
+// ?level=high returns only high risk; the default includes medium too.
+func (h *ChurnHandler) GetAtRiskMembers(c *fiber.Ctx) error {
+	levels := []string{churn.Medium, churn.High}
+	switch level := c.Query("level"); level {
+	case "":
+	case churn.Low, churn.Medium, churn.High:
+		levels = []string{level}
+	default:
+		return apperror.BadRequest("Invalid level, expected low, medium or high")
+	}
+
+	risks, err := h.churn.AtRisk(c.UserContext(), levels)
+	if err != nil {
+		return err
+	}
+	return c.JSON(dataResponse("", risks))
+}
+
+// RecomputeChurn starts rescoring members now instead of waiting for the
+// job. Scoring reads every member, so it runs in the background.
+func (h *ChurnHandler) RecomputeChurn(c *fiber.Ctx) error {
+	if err := h.churn.Recompute(); err != nil {
+		return err
+	}
+	return c.JSON(MessageResponse{Message: "Churn scores are being recomputed"})
+}
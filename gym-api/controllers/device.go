@@ -0,0 +1,145 @@
+package controllers
+
+import (
+	"strconv"
+	"time"
+
+	"gym-api/apperror"
+	"gym-api/models"
+	"gym-api/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type CreateDeviceInput struct {
+	Name     string `json:"name" validate:"required,max=100"`
+	BranchID *uint  `json:"branch_id"`
+}
+
+// DeviceHandler serves the kiosk registry and the kiosks' heartbeat.
+type DeviceHandler struct {
+	devices services.Devices
+}
+
+func NewDeviceHandler(devices services.Devices) *DeviceHandler {
+	return &DeviceHandler{devices: devices}
+}
+
+// CreateDevice registers a kiosk and returns its API key. The key is only
+// shown once.
+func (h *DeviceHandler) CreateDevice(c *fiber.Ctx) error {
+	adminID, ok := c.Locals("user_id").(uint)
+	if !ok {
+		return apperror.Unauthorized("Unauthorized")
+	}
+
+	var input CreateDeviceInput
+	if err := bindInput(c, &input); err != nil {
+		return inputError(err)
+	}
+
+	device, key, err := h.devices.Register(c.UserContext(), services.NewDevice{
+		Name:      input.Name,
+		BranchID:  input.BranchID,
+		CreatedBy: adminID,
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(DeviceKeyResponse{
+		Message: "Device registered. Store the API key now; it will not be shown again.",
+		Data:    deviceView(device),
+		APIKey:  key,
+	})
+}
+
+func (h *DeviceHandler) GetDevices(c *fiber.Ctx) error {
+	devices, err := h.devices.List(c.UserContext())
+	if err != nil {
+		return err
+	}
+
+	data := make([]DeviceView, len(devices))
+	for i, d := range devices {
+		data[i] = deviceView(d)
+	}
+	return c.JSON(dataResponse("", data))
+}
+
+// RevokeDevice disables a kiosk's key immediately.
+func (h *DeviceHandler) RevokeDevice(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return apperror.BadRequest("Invalid ID")
+	}
+
+	device, err := h.devices.Revoke(c.UserContext(), uint(id))
+	if err != nil {
+		return err
+	}
+	return c.JSON(dataResponse("Device revoked", deviceView(device)))
+}
+
+// DeviceView is a device as admins and the device itself see it.
+type DeviceView struct {
+	ID         uint       `json:"id"`
+	Name       string     `json:"name"`
+	BranchID   *uint      `json:"branch_id"`
+	KeyPrefix  string     `json:"key_prefix"`
+	LastSeenAt *time.Time `json:"last_seen_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	Online     bool       `json:"online"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func deviceView(d models.Device) DeviceView {
+	return DeviceView{
+		ID:         d.ID,
+		Name:       d.Name,
+		BranchID:   d.BranchID,
+		KeyPrefix:  d.KeyPrefix,
+		LastSeenAt: d.LastSeenAt,
+		RevokedAt:  d.RevokedAt,
+		Online:     d.Online(),
+		CreatedAt:  d.CreatedAt,
+	}
+}
+
+// DeviceKeyResponse is returned once, when the device's key is issued.
+type DeviceKeyResponse struct {
+	Message string     `json:"message"`
+	Data    DeviceView `json:"data"`
+	APIKey  string     `json:"api_key"`
+}
+
+type HeartbeatResponse struct {
+	Data       DeviceView `json:"data"`
+	ServerTime time.Time  `json:"server_time"`
+}
+
+// KioskScan admits a member scanned by a kiosk. The attendance records the
+// device instead of a staff member.
+func (h *AttendanceHandler) KioskScan(c *fiber.Ctx) error {
+	device := c.Locals("device").(*models.Device)
+
+	var input ScanQRInput
+	if err := bindInput(c, &input); err != nil {
+		return inputError(err)
+	}
+
+	attendance, member, err := h.attendance.Admit(c.UserContext(), services.Admission{
+		MemberID:  input.TrainerID,
+		Timestamp: input.Timestamp,
+		Scanner:   services.Scanner{DeviceID: &device.ID, BranchID: device.BranchID},
+		At:        time.Now(),
+	})
+	return scanResponse(c, attendance, member, err)
+}
+
+// KioskHeartbeat lets a kiosk report that it is alive (DeviceAuth records
+// the time) and check its configuration.
+func (h *DeviceHandler) KioskHeartbeat(c *fiber.Ctx) error {
+	device := c.Locals("device").(*models.Device)
+	return c.JSON(HeartbeatResponse{Data: deviceView(*device), ServerTime: time.Now()})
+}
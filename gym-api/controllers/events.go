@@ -0,0 +1,146 @@
+package controllers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gym-api/apperror"
+	"gym-api/events"
+	"gym-api/models"
+	"gym-api/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+)
+
+// roleEventTypes lists the feed events each role may receive. A nil entry
+// means every event type.
+var roleEventTypes = map[string][]events.Type{
+	string(models.RoleAdmin): nil,
+	string(models.RoleStaff): {events.CheckIn, events.CheckOut, events.Denied, events.Expired, events.Occupancy, events.Rejected, events.GateAlert},
+}
+
+// EventHandler serves the live check-in feed.
+type EventHandler struct {
+	auth services.Auth
+}
+
+func NewEventHandler(auth services.Auth) *EventHandler {
+	return &EventHandler{auth: auth}
+}
+
+// StreamEvents pushes the live check-in feed as Server-Sent Events.
+// Clients resume with the Last-Event-ID header (sent automatically by
+// EventSource) or the last_event_id query param.
+func (h *EventHandler) StreamEvents(c *fiber.Ctx) error {
+	role, _ := c.Locals("role").(string)
+	userID, _ := c.Locals("user_id").(uint)
+
+	allowed, ok := roleEventTypes[role]
+	if !ok {
+		return apperror.Forbidden("Live feed not available for this role")
+	}
+
+	// 1. Event types: requested types, limited to what the role may see
+	filter := events.Filter{Types: map[events.Type]bool{}}
+	if typesParam := c.Query("types"); typesParam != "" {
+		for _, t := range strings.Split(typesParam, ",") {
+			filter.Types[events.Type(strings.TrimSpace(t))] = true
+		}
+	}
+	if allowed != nil {
+		if len(filter.Types) == 0 {
+			for _, t := range allowed {
+				filter.Types[t] = true
+			}
+		} else {
+			permitted := map[events.Type]bool{}
+			for _, t := range allowed {
+				if filter.Types[t] {
+					permitted[t] = true
+				}
+			}
+			if len(permitted) == 0 {
+				return apperror.Forbidden("Requested event types are not available for this role")
+			}
+			filter.Types = permitted
+		}
+	}
+
+	// 2. Branch: staff are pinned to their own branch, admins may pick one
+	user, err := h.auth.Profile(c.UserContext(), userID)
+	if err != nil {
+		return apperror.Unauthorized("Unauthorized")
+	}
+	if user.Role == models.RoleStaff && user.BranchID != nil {
+		filter.BranchID = user.BranchID
+	} else if branchParam := c.Query("branch_id"); branchParam != "" {
+		id, err := strconv.Atoi(branchParam)
+		if err != nil {
+			return apperror.BadRequest("Invalid branch_id")
+		}
+		branchID := uint(id)
+		filter.BranchID = &branchID
+	}
+
+	// 3. Subscribe, replaying anything missed since the client's cursor
+	lastEventID := c.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+	sub, missed, inSync := events.Hub.Subscribe(filter, lastEventID)
+
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	c.Context().SetBodyStreamWriter(fasthttp.StreamWriter(func(w *bufio.Writer) {
+		defer events.Hub.Unsubscribe(sub)
+
+		fmt.Fprint(w, "retry: 3000\n\n")
+		if !inSync {
+			// The cursor fell out of the retained history; tell the client
+			// to refetch state before applying the events that follow.
+			fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+		}
+		for _, e := range missed {
+			writeEvent(w, e)
+		}
+		if err := w.Flush(); err != nil {
+			return
+		}
+
+		keepAlive := time.NewTicker(15 * time.Second)
+		defer keepAlive.Stop()
+
+		for {
+			select {
+			case e, open := <-sub.C:
+				if !open {
+					return
+				}
+				writeEvent(w, e)
+			case <-keepAlive.C:
+				fmt.Fprint(w, ": ping\n\n")
+			}
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	}))
+
+	return nil
+}
+
+func writeEvent(w *bufio.Writer, e events.Event) {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", e.ID, e.Type, payload)
+}
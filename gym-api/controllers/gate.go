@@ -0,0 +1,219 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"gym-api/apperror"
+	"gym-api/gates"
+	"gym-api/models"
+	"gym-api/services"
+	"gym-api/validation"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type CreateGateInput struct {
+	Name     string `json:"name" validate:"required,max=100"`
+	Kind     string `json:"kind" validate:"required,oneof=http line simulator"`
+	Address  string `json:"address" validate:"required_unless=Kind simulator,max=255"`
+	Door     string `json:"door" validate:"max=50,door"`
+	BranchID *uint  `json:"branch_id"`
+	DeviceID *uint  `json:"device_id"`
+	Enabled  *bool  `json:"enabled"`
+}
+
+// UpdateGateInput is a partial update: omitted fields are left unchanged.
+// A branch_id or device_id of 0 unbinds the gate from its branch or device.
+type UpdateGateInput struct {
+	Name     *string `json:"name" validate:"omitnil,min=1,max=100"`
+	Kind     *string `json:"kind" validate:"omitnil,oneof=http line simulator"`
+	Address  *string `json:"address" validate:"omitnil,max=255"`
+	Door     *string `json:"door" validate:"omitnil,max=50,door"`
+	BranchID *uint   `json:"branch_id"`
+	DeviceID *uint   `json:"device_id"`
+	Enabled  *bool   `json:"enabled"`
+}
+
+// GateHandler serves the gate endpoints and the relays' webhook.
+type GateHandler struct {
+	gates services.Gates
+}
+
+func NewGateHandler(gates services.Gates) *GateHandler {
+	return &GateHandler{gates: gates}
+}
+
+func (h *GateHandler) GetGates(c *fiber.Ctx) error {
+	list, err := h.gates.List(c.UserContext())
+	if err != nil {
+		return err
+	}
+	return c.JSON(dataResponse("", list))
+}
+
+// GateSecretResponse is returned once, when the gate's secret is issued.
+type GateSecretResponse struct {
+	Message string      `json:"message"`
+	Data    models.Gate `json:"data"`
+	Secret  string      `json:"secret"`
+}
+
+// CreateGate configures a turnstile or door relay. The returned secret signs
+// relay requests and the gate's event webhook; it is only shown once.
+func (h *GateHandler) CreateGate(c *fiber.Ctx) error {
+	var input CreateGateInput
+	if err := bindInput(c, &input); err != nil {
+		return inputError(err)
+	}
+
+	gate, secret, err := h.gates.Create(c.UserContext(), services.NewGate{
+		Name:     input.Name,
+		Kind:     input.Kind,
+		Address:  input.Address,
+		Door:     input.Door,
+		BranchID: input.BranchID,
+		DeviceID: input.DeviceID,
+		Enabled:  input.Enabled == nil || *input.Enabled,
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(GateSecretResponse{
+		Message: "Gate created. Store the secret now; it will not be shown again.",
+		Data:    gate,
+		Secret:  secret,
+	})
+}
+
+func (h *GateHandler) UpdateGate(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return apperror.BadRequest("Invalid ID")
+	}
+
+	var input UpdateGateInput
+	if err := bindInput(c, &input); err != nil {
+		return inputError(err)
+	}
+
+	gate, err := h.gates.Update(c.UserContext(), uint(id), services.GateChanges{
+		Name:     input.Name,
+		Kind:     input.Kind,
+		Address:  input.Address,
+		Door:     input.Door,
+		BranchID: input.BranchID,
+		DeviceID: input.DeviceID,
+		Enabled:  input.Enabled,
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(dataResponse("Gate updated", gate))
+}
+
+func (h *GateHandler) DeleteGate(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return apperror.BadRequest("Invalid ID")
+	}
+
+	if err := h.gates.Delete(c.UserContext(), uint(id)); err != nil {
+		return err
+	}
+
+	return c.JSON(MessageResponse{Message: "Gate deleted"})
+}
+
+// TestGate sends a grant to a gate and reports whether it was accepted, so
+// an installer can check the wiring.
+func (h *GateHandler) TestGate(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return apperror.BadRequest("Invalid ID")
+	}
+
+	ctx, cancel := context.WithTimeout(c.UserContext(), 5*time.Second)
+	defer cancel()
+	if err := h.gates.Test(ctx, uint(id)); err != nil {
+		return err
+	}
+
+	return c.JSON(MessageResponse{Message: "Gate accepted the test signal"})
+}
+
+type GateEventInput struct {
+	Type   string `json:"type" validate:"required,max=40"`
+	Door   string `json:"door" validate:"max=50,door"`
+	Detail string `json:"detail" validate:"max=500"`
+}
+
+// SimulateGateEvent raises an event on a simulator gate, e.g. to try out
+// door-forced alerts without hardware.
+func (h *GateHandler) SimulateGateEvent(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return apperror.BadRequest("Invalid ID")
+	}
+
+	var input GateEventInput
+	if err := bindInput(c, &input); err != nil {
+		return inputError(err)
+	}
+
+	event := gates.Event{Type: input.Type, Door: input.Door, Detail: input.Detail}
+	if err := h.gates.Simulate(uint(id), event); err != nil {
+		return err
+	}
+
+	return c.JSON(MessageResponse{Message: "Event raised"})
+}
+
+// ReceiveGateEvent is the webhook HTTP relays call to report door events.
+// The request must be signed with the gate's secret (see gates.Sign), with
+// a current timestamp and a nonce the gate hasn't used before.
+func (h *GateHandler) ReceiveGateEvent(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return apperror.BadRequest("Invalid ID")
+	}
+
+	gate, err := h.gates.Authenticate(c.UserContext(), uint(id), services.SignedRequest{
+		Timestamp: c.Get(gates.TimestampHeader),
+		Nonce:     c.Get(gates.NonceHeader),
+		Signature: c.Get(gates.SignatureHeader),
+		Body:      c.Body(),
+	})
+	if err != nil {
+		return err
+	}
+
+	var input GateEventInput
+	if err := json.Unmarshal(c.Body(), &input); err != nil {
+		return apperror.BadRequest("Invalid input")
+	}
+	if err := validation.Struct(&input); err != nil {
+		return inputError(err)
+	}
+
+	h.gates.Report(gate, gates.Event{Type: input.Type, Door: input.Door, Detail: input.Detail})
+	return c.JSON(MessageResponse{Message: "Event recorded"})
+}
+
+// GetAuditLogs pages through the audit log. ?action= keeps the entries
+// whose action starts with it: "gate." lists every gate event.
+func (h *GateHandler) GetAuditLogs(c *fiber.Ctx) error {
+	p, err := listParams(c, AuditLogListSpec)
+	if err != nil {
+		return err
+	}
+	page, err := h.gates.AuditLog(c.UserContext(), c.Query("action"), AuditLogListSpec, p)
+	if err != nil {
+		return err
+	}
+	return c.JSON(page)
+}
@@ -0,0 +1,89 @@
+package controllers_test
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"gym-api/apptest"
+	"gym-api/gates"
+	"gym-api/models"
+)
+
+func signedEvent(gate models.Gate, secret, nonce string, body []byte) apptest.Request {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	return apptest.Request{
+		Method: http.MethodPost,
+		Path:   fmt.Sprintf("/api/gates/%d/events", gate.ID),
+		Body:   body,
+		Header: map[string]string{
+			gates.TimestampHeader: timestamp,
+			gates.NonceHeader:     nonce,
+			gates.SignatureHeader: gates.Sign(secret, timestamp, nonce, body),
+		},
+	}
+}
+
+func TestReceiveGateEvent(t *testing.T) {
+	app := apptest.New(t)
+	gate := models.Gate{Name: "Front door", Kind: "http", Address: "http://relay.invalid", Secret: "s3cret", Enabled: true}
+	if err := app.DB.Create(&gate).Error; err != nil {
+		t.Fatal(err)
+	}
+	body := []byte(`{"type":"door_forced","door":"1"}`)
+
+	event := signedEvent(gate, "s3cret", "nonce-1", body)
+	if resp := app.Do(t, event); resp.Status != http.StatusOK {
+		t.Fatalf("signed event: %d %s", resp.Status, resp.Body)
+	}
+	if resp := app.Do(t, event); resp.Status != http.StatusUnauthorized {
+		t.Errorf("replayed event: %d %s, want 401", resp.Status, resp.Body)
+	}
+	if resp := app.Do(t, signedEvent(gate, "wrong", "nonce-2", body)); resp.Status != http.StatusUnauthorized {
+		t.Errorf("wrong secret: %d %s, want 401", resp.Status, resp.Body)
+	}
+
+	forged := signedEvent(gate, "s3cret", "nonce-3", body)
+	forged.Body = []byte(`{"type":"door_closed","door":"1"}`)
+	if resp := app.Do(t, forged); resp.Status != http.StatusUnauthorized {
+		t.Errorf("altered body: %d %s, want 401", resp.Status, resp.Body)
+	}
+}
+
+func TestReceiveGateEventDisabledGate(t *testing.T) {
+	app := apptest.New(t)
+	gate := models.Gate{Name: "Back door", Kind: "http", Secret: "s3cret", Enabled: true}
+	if err := app.DB.Create(&gate).Error; err != nil {
+		t.Fatal(err)
+	}
+	app.DB.Model(&gate).Update("enabled", false)
+
+	resp := app.Do(t, signedEvent(gate, "s3cret", "nonce-1", []byte(`{"type":"door_forced"}`)))
+	if resp.Status != http.StatusNotFound {
+		t.Errorf("disabled gate: %d %s, want 404", resp.Status, resp.Body)
+	}
+}
+
+func TestGateDoorCannotInjectCommands(t *testing.T) {
+	app := apptest.New(t)
+	admin := app.AddUser(t, models.User{Email: "admin@example.com", Role: models.RoleAdmin})
+	token := app.Token(t, admin)
+
+	for _, door := range []string{"1\nGRANT 1 42", "1 2", "door#1"} {
+		resp := app.Do(t, apptest.Request{Method: http.MethodPost, Path: "/api/admin/gates", Token: token, Body: map[string]any{
+			"name": "Front door", "kind": "line", "address": "10.0.0.5:4000", "door": door,
+		}})
+		if resp.Status != http.StatusBadRequest {
+			t.Errorf("door %q: %d %s, want 400", door, resp.Status, resp.Body)
+		}
+	}
+
+	resp := app.Do(t, apptest.Request{Method: http.MethodPost, Path: "/api/admin/gates", Token: token, Body: map[string]any{
+		"name": "Front door", "kind": "simulator", "door": "front_1",
+	}})
+	if resp.Status != http.StatusOK {
+		t.Errorf("door front_1: %d %s, want 200", resp.Status, resp.Body)
+	}
+}
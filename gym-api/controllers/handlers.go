@@ -0,0 +1,53 @@
+package controllers
+
+import "gym-api/services"
+
+// Handlers are every handler that needs a service, each receiving its
+// services through its constructor.
+type Handlers struct {
+	Auth          *AuthHandler
+	Members       *MemberHandler
+	Packages      *PackageHandler
+	Users         *UserHandler
+	Attendance    *AttendanceHandler
+	Gates         *GateHandler
+	Branches      *BranchHandler
+	Devices       *DeviceHandler
+	Incidents     *IncidentHandler
+	Notifications *NotificationHandler
+	Churn         *ChurnHandler
+	Health        *HealthHandler
+	Events        *EventHandler
+	Invites       *InviteHandler
+	Registrations *RegistrationHandler
+	TwoFactor     *TwoFactorHandler
+	SSO           *SSOHandler
+	Lockouts      *LockoutHandler
+	Analytics     *AnalyticsHandler
+	Search        *SearchHandler
+}
+
+func NewHandlers(s services.Services) Handlers {
+	return Handlers{
+		Auth:          NewAuthHandler(s.Auth),
+		Members:       NewMemberHandler(s.Membership),
+		Packages:      NewPackageHandler(s.Packages),
+		Users:         NewUserHandler(s.Users),
+		Attendance:    NewAttendanceHandler(s.Attendance),
+		Gates:         NewGateHandler(s.Gates),
+		Branches:      NewBranchHandler(s.Branches),
+		Devices:       NewDeviceHandler(s.Devices),
+		Incidents:     NewIncidentHandler(s.Incidents),
+		Notifications: NewNotificationHandler(s.Notifications),
+		Churn:         NewChurnHandler(s.Churn),
+		Health:        NewHealthHandler(s.Health),
+		Events:        NewEventHandler(s.Auth),
+		Invites:       NewInviteHandler(s.Invites),
+		Registrations: NewRegistrationHandler(s.Registrations),
+		TwoFactor:     NewTwoFactorHandler(s.TwoFactor),
+		SSO:           NewSSOHandler(s.SSO),
+		Lockouts:      NewLockoutHandler(s.Lockouts),
+		Analytics:     NewAnalyticsHandler(s.Analytics),
+		Search:        NewSearchHandler(s.Search),
+	}
+}
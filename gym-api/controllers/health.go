@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"context"
+	"crypto/subtle"
+	"time"
+
+	"gym-api/apperror"
+	"gym-api/config"
+	"gym-api/metrics"
+	"gym-api/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+)
+
+type HealthResponse struct {
+	Status string `json:"status"`
+}
+
+// HealthHandler serves the probes.
+type HealthHandler struct {
+	health services.Health
+}
+
+func NewHealthHandler(health services.Health) *HealthHandler {
+	return &HealthHandler{health: health}
+}
+
+// Healthz is the liveness probe: the process is up and serving. It checks
+// nothing else, so a database outage doesn't get every replica restarted.
+func (h *HealthHandler) Healthz(c *fiber.Ctx) error {
+	return c.JSON(HealthResponse{Status: "ok"})
+}
+
+// Readyz is the readiness probe: the database answers and its schema has
+// been migrated.
+func (h *HealthHandler) Readyz(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(c.UserContext(), 2*time.Second)
+	defer cancel()
+
+	if err := h.health.Ready(ctx); err != nil {
+		return err
+	}
+	return c.JSON(HealthResponse{Status: "ready"})
+}
+
+var metricsHandler = adaptor.HTTPHandler(metrics.Handler())
+
+// Metrics serves the Prometheus metrics to a scraper sending METRICS_TOKEN
+// as a bearer token. Without a token configured there are none on the API
+// port (see config.MetricsAddr).
+func Metrics(c *fiber.Ctx) error {
+	token := config.MetricsToken()
+	if token == "" {
+		return apperror.NotFound("Metrics are not served on this port")
+	}
+	given := c.Get(fiber.HeaderAuthorization)
+	if subtle.ConstantTimeCompare([]byte(given), []byte("Bearer "+token)) != 1 {
+		return apperror.Unauthorized("Unauthorized")
+	}
+	return metricsHandler(c)
+}
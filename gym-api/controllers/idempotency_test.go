@@ -0,0 +1,118 @@
+package controllers_test
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"gym-api/apperror"
+	"gym-api/apptest"
+	"gym-api/controllers"
+	"gym-api/middleware"
+	"gym-api/models"
+)
+
+func pngPhoto(t *testing.T) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	if err := png.Encode(&b, image.NewRGBA(image.Rect(0, 0, 8, 8))); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestIdempotentRetryReplaysResponse(t *testing.T) {
+	app := apptest.New(t)
+	admin := app.AddUser(t, models.User{Email: "admin@example.com", Role: models.RoleAdmin})
+	token := app.Token(t, admin)
+	create := func(key, email string) apptest.Response {
+		return app.Do(t, apptest.Request{Method: http.MethodPost, Path: "/api/management/members", Token: token,
+			Header: map[string]string{middleware.IdempotencyKeyHeader: key},
+			Body:   url.Values{"name": {"Ana Silva"}, "email": {email}, "password": {"Secret123!"}}})
+	}
+
+	first := create("key-1", "ana@example.com")
+	if first.Status != http.StatusOK {
+		t.Fatalf("create: %d %s", first.Status, first.Body)
+	}
+	retry := create("key-1", "ana@example.com")
+	if retry.Status != http.StatusOK || !bytes.Equal(retry.Body, first.Body) || retry.Header.Get("Idempotent-Replayed") != "true" {
+		t.Errorf("retry: %d %s, want the first response replayed", retry.Status, retry.Body)
+	}
+
+	reused := create("key-1", "bea@example.com")
+	var body struct {
+		Code apperror.Code `json:"code"`
+	}
+	reused.JSON(t, &body)
+	if reused.Status != http.StatusConflict || body.Code != apperror.CodeKeyReused {
+		t.Errorf("different body: %d %s, want 409 %s", reused.Status, reused.Body, apperror.CodeKeyReused)
+	}
+
+	var members int64
+	app.DB.Model(&models.User{}).Where("role = ?", models.RoleMember).Count(&members)
+	if members != 1 {
+		t.Errorf("%d members created, want 1", members)
+	}
+}
+
+func TestIdempotentMultipartIgnoresBoundary(t *testing.T) {
+	app := apptest.New(t)
+	admin := app.AddUser(t, models.User{Email: "admin@example.com", Role: models.RoleAdmin})
+	token := app.Token(t, admin)
+	photo := pngPhoto(t)
+	create := func(fields url.Values, photo []byte) apptest.Response {
+		// Every Do picks a new multipart boundary
+		return app.Do(t, apptest.Request{Method: http.MethodPost, Path: "/api/management/members", Token: token,
+			Header: map[string]string{middleware.IdempotencyKeyHeader: "key-1"},
+			Body:   apptest.Multipart{Fields: fields, Files: map[string][]byte{"profile_picture": photo}}})
+	}
+	fields := url.Values{"name": {"Ana Silva"}, "email": {"ana@example.com"}, "password": {"Secret123!"}}
+
+	first := create(fields, photo)
+	if first.Status != http.StatusOK {
+		t.Fatalf("create: %d %s", first.Status, first.Body)
+	}
+	if retry := create(fields, photo); retry.Header.Get("Idempotent-Replayed") != "true" || !bytes.Equal(retry.Body, first.Body) {
+		t.Errorf("retry: %d %s, want the first response replayed", retry.Status, retry.Body)
+	}
+
+	other := append(bytes.Clone(photo[:len(photo)-1]), photo[len(photo)-1]^1)
+	if resp := create(fields, other); resp.Status != http.StatusConflict {
+		t.Errorf("another photo: %d %s, want 409", resp.Status, resp.Body)
+	}
+	renamed := url.Values{"name": {"Ana Costa"}, "email": {"ana@example.com"}, "password": {"Secret123!"}}
+	if resp := create(renamed, photo); resp.Status != http.StatusConflict {
+		t.Errorf("another field: %d %s, want 409", resp.Status, resp.Body)
+	}
+}
+
+func TestIdempotentKioskScan(t *testing.T) {
+	app := apptest.New(t)
+	key := addDevice(t, app)
+	member := app.AddUser(t, models.User{Email: "cal@example.com", Role: models.RoleMember, MembershipStatus: "active"})
+	scannedAt := time.Now().Unix()
+	scan := func(idempotencyKey string) apptest.Response {
+		return app.Do(t, apptest.Request{Method: http.MethodPost, Path: "/api/kiosk/scan",
+			Header: map[string]string{"X-Device-Key": key, middleware.IdempotencyKeyHeader: idempotencyKey},
+			Body:   controllers.ScanQRInput{TrainerID: member.ID, Timestamp: scannedAt}})
+	}
+
+	first := scan("scan-1")
+	if first.Status != http.StatusOK {
+		t.Fatalf("scan: %d %s", first.Status, first.Body)
+	}
+	// The kiosk timed out waiting and sends the scan again
+	if retry := scan("scan-1"); retry.Status != http.StatusOK || retry.Header.Get("Idempotent-Replayed") != "true" {
+		t.Errorf("retry: %d %s, want the admission replayed", retry.Status, retry.Body)
+	}
+	var visits int64
+	app.DB.Model(&models.Attendance{}).Where("trainer_id = ?", member.ID).Count(&visits)
+	if visits != 1 {
+		t.Errorf("%d check-ins recorded, want 1", visits)
+	}
+}
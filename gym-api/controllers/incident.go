@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"strconv"
+
+	"gym-api/apperror"
+	"gym-api/repository"
+	"gym-api/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type FlagAttendanceInput struct {
+	Reason  string `json:"reason" validate:"max=255"`
+	Notes   string `json:"notes" validate:"max=2000"`
+	Suspend bool   `json:"suspend"` // Suspend the member until an admin reviews the incident
+}
+
+// FlagAttendance rejects a check-in whose holder didn't match the member's
+// photo. The attendance is removed, an incident is opened and the member
+// can optionally be suspended pending review.
+func (h *AttendanceHandler) FlagAttendance(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return apperror.BadRequest("Invalid ID")
+	}
+
+	reporterID, ok := c.Locals("user_id").(uint)
+	if !ok {
+		return apperror.Unauthorized("Unauthorized")
+	}
+
+	var input FlagAttendanceInput
+	if err := bindInput(c, &input); err != nil {
+		return inputError(err)
+	}
+	if input.Reason == "" {
+		input.Reason = "Photo mismatch"
+	}
+
+	incident, err := h.attendance.Reject(c.UserContext(), uint(id), services.Rejection{
+		ReportedBy: reporterID,
+		Reason:     input.Reason,
+		Notes:      input.Notes,
+		Suspend:    input.Suspend,
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(dataResponse("Attendance rejected", incident))
+}
+
+// IncidentHandler serves the admin review of rejected check-ins.
+type IncidentHandler struct {
+	incidents services.Incidents
+}
+
+func NewIncidentHandler(incidents services.Incidents) *IncidentHandler {
+	return &IncidentHandler{incidents: incidents}
+}
+
+func (h *IncidentHandler) GetIncidents(c *fiber.Ctx) error {
+	filter := repository.IncidentFilter{Status: c.Query("status")}
+	if memberID := c.Query("member_id"); memberID != "" {
+		id, err := strconv.ParseUint(memberID, 10, 0)
+		if err != nil {
+			return apperror.BadRequest("Invalid member_id")
+		}
+		filter.MemberID = uint(id)
+	}
+
+	incidents, err := h.incidents.List(c.UserContext(), filter)
+	if err != nil {
+		return err
+	}
+	return c.JSON(dataResponse("", incidents))
+}
+
+type ResolveIncidentInput struct {
+	Resolution string `json:"resolution" validate:"max=2000"`
+	Reinstate  bool   `json:"reinstate"` // Lift the suspension of the member
+}
+
+func (h *IncidentHandler) ResolveIncident(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return apperror.BadRequest("Invalid ID")
+	}
+
+	adminID, ok := c.Locals("user_id").(uint)
+	if !ok {
+		return apperror.Unauthorized("Unauthorized")
+	}
+
+	var input ResolveIncidentInput
+	if err := bindInput(c, &input); err != nil {
+		return inputError(err)
+	}
+
+	incident, err := h.incidents.Resolve(c.UserContext(), uint(id), services.Resolution{
+		ResolvedBy: adminID,
+		Resolution: input.Resolution,
+		Reinstate:  input.Reinstate,
+	})
+	if err != nil {
+		return err
+	}
+	return c.JSON(dataResponse("Incident resolved", incident))
+}
@@ -0,0 +1,32 @@
+package controllers
+
+import (
+	"errors"
+
+	"gym-api/apperror"
+	"gym-api/validation"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// errBadBody marks a body that could not be parsed at all.
+var errBadBody = errors.New("invalid input")
+
+// bindInput parses the request body into input and validates it against its
+// `validate` tags. Pass the error to inputError.
+func bindInput(c *fiber.Ctx, input any) error {
+	if err := c.BodyParser(input); err != nil {
+		return errBadBody
+	}
+	return validation.Struct(input)
+}
+
+// inputError converts a bindInput or validation.Struct failure into an API
+// error. Field errors are listed so clients can show them per field.
+func inputError(err error) error {
+	var fields validation.Errors
+	if errors.As(err, &fields) {
+		return apperror.Validation(fields)
+	}
+	return apperror.BadRequest("Invalid input")
+}
@@ -0,0 +1,172 @@
+package controllers
+
+import (
+	"strconv"
+	"time"
+
+	"gym-api/apperror"
+	"gym-api/audit"
+	"gym-api/models"
+	"gym-api/services"
+	"gym-api/validation"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// InviteHandler serves staff and trainer invites.
+type InviteHandler struct {
+	invites services.Invites
+}
+
+func NewInviteHandler(invites services.Invites) *InviteHandler {
+	return &InviteHandler{invites: invites}
+}
+
+type InviteUserInput struct {
+	Name     string `json:"name" validate:"required,max=100,name"`
+	Email    string `json:"email" validate:"required,email,max=191"`
+	Role     string `json:"role" validate:"required,oneof=staff trainer"`
+	BranchID *uint  `json:"branch_id"`
+}
+
+func (i *InviteUserInput) Normalize() {
+	validation.Trim(&i.Name)
+	i.Email = validation.NormalizeEmail(i.Email)
+}
+
+// InviteResponse reports whether the invite email went out. If it did
+// not, the admin can resend once mail works.
+type InviteResponse struct {
+	Message   string        `json:"message"`
+	Data      models.Invite `json:"data"`
+	EmailSent bool          `json:"email_sent"`
+}
+
+// InviteUser creates an inactive staff or trainer account and emails its
+// owner a link to choose a password.
+func (h *InviteHandler) InviteUser(c *fiber.Ctx) error {
+	adminID, _ := c.Locals("user_id").(uint)
+	var input InviteUserInput
+	if err := bindInput(c, &input); err != nil {
+		return inputError(err)
+	}
+
+	invite, sent, err := h.invites.Invite(c.UserContext(), services.NewInvite{
+		Name:     input.Name,
+		Email:    input.Email,
+		Role:     models.Role(input.Role),
+		BranchID: input.BranchID,
+	}, adminID)
+	if err != nil {
+		return err
+	}
+	audit.Record(models.AuditLog{Action: "user.invited", ActorID: &adminID, Subject: invite.User.Email, Detail: input.Role, IP: c.IP()})
+
+	return c.JSON(InviteResponse{Message: "Invite created", Data: invite, EmailSent: sent})
+}
+
+// GetInvites lists invites not yet accepted, expired ones included.
+func (h *InviteHandler) GetInvites(c *fiber.Ctx) error {
+	invites, err := h.invites.Pending(c.UserContext())
+	if err != nil {
+		return err
+	}
+	return c.JSON(dataResponse("", invites))
+}
+
+// ResendInvite emails a pending invite again with a new link and expiry.
+// Earlier links stop working.
+func (h *InviteHandler) ResendInvite(c *fiber.Ctx) error {
+	adminID, _ := c.Locals("user_id").(uint)
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return apperror.BadRequest("Invalid ID")
+	}
+
+	invite, sent, err := h.invites.Resend(c.UserContext(), uint(id))
+	if err != nil {
+		return err
+	}
+	audit.Record(models.AuditLog{Action: "user.invite_resent", ActorID: &adminID, Subject: invite.User.Email, IP: c.IP()})
+
+	return c.JSON(InviteResponse{Message: "Invite resent", Data: invite, EmailSent: sent})
+}
+
+// RevokeInvite cancels a pending invite and removes the account it was
+// for, freeing the email address.
+func (h *InviteHandler) RevokeInvite(c *fiber.Ctx) error {
+	adminID, _ := c.Locals("user_id").(uint)
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return apperror.BadRequest("Invalid ID")
+	}
+
+	invite, err := h.invites.Revoke(c.UserContext(), uint(id))
+	if err != nil {
+		return err
+	}
+	audit.Record(models.AuditLog{Action: "user.invite_revoked", ActorID: &adminID, Subject: invite.User.Email, IP: c.IP()})
+
+	return c.JSON(MessageResponse{Message: "Invite revoked"})
+}
+
+type InviteTokenInput struct {
+	Token string `json:"token" validate:"required,max=128"`
+}
+
+func (i *InviteTokenInput) Normalize() {
+	validation.Trim(&i.Token)
+}
+
+type AcceptInviteInput struct {
+	Token    string `json:"token" validate:"required,max=128"`
+	Password string `json:"password" validate:"required,password"`
+}
+
+func (i *AcceptInviteInput) Normalize() {
+	validation.Trim(&i.Token)
+}
+
+// InviteDetails tells the invitee who the link is for.
+type InviteDetails struct {
+	Name      string      `json:"name"`
+	Email     string      `json:"email"`
+	Role      models.Role `json:"role"`
+	ExpiresAt time.Time   `json:"expires_at"`
+}
+
+// LookupInvite shows the invite page who it is for. The token is posted
+// rather than put in the URL so it stays out of access logs.
+func (h *InviteHandler) LookupInvite(c *fiber.Ctx) error {
+	var input InviteTokenInput
+	if err := bindInput(c, &input); err != nil {
+		return inputError(err)
+	}
+	invite, err := h.invites.Lookup(c.UserContext(), input.Token)
+	if err != nil {
+		return err
+	}
+	return c.JSON(dataResponse("", InviteDetails{
+		Name:      invite.User.Name,
+		Email:     invite.User.Email,
+		Role:      invite.User.Role,
+		ExpiresAt: invite.ExpiresAt,
+	}))
+}
+
+// AcceptInvite sets the invitee's password, activates the account and
+// signs them in.
+func (h *InviteHandler) AcceptInvite(c *fiber.Ctx) error {
+	var input AcceptInviteInput
+	if err := bindInput(c, &input); err != nil {
+		return inputError(err)
+	}
+
+	user, err := h.invites.Accept(c.UserContext(), input.Token, input.Password)
+	if err != nil {
+		return err
+	}
+	audit.Record(models.AuditLog{Action: "user.invite_accepted", ActorID: &user.ID, Subject: user.Email, IP: c.IP()})
+
+	return issueLogin(c, user, false)
+}
@@ -0,0 +1,86 @@
+package controllers_test
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"gym-api/apptest"
+	"gym-api/controllers"
+	"gym-api/models"
+	"gym-api/notifications"
+)
+
+// inviteToken takes the token from the link in the last invite email.
+func inviteToken(t *testing.T, app *apptest.App) string {
+	t.Helper()
+	sent := app.Outbox.Sent()
+	if len(sent) == 0 || sent[len(sent)-1].Kind != notifications.StaffInvite {
+		t.Fatalf("sent %+v, want an invite", sent)
+	}
+	link, err := url.Parse(sent[len(sent)-1].Data["Link"].(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return link.Query().Get("token")
+}
+
+func TestInviteFlow(t *testing.T) {
+	app := apptest.New(t)
+	admin := app.AddUser(t, models.User{Name: "Admin", Email: "admin@example.com", Role: models.RoleAdmin})
+
+	resp := app.Do(t, apptest.Request{Method: http.MethodPost, Path: "/api/admin/invites", Token: app.Token(t, admin), Body: map[string]any{
+		"name":  "Sam Reyes",
+		"email": "sam@example.com",
+		"role":  "staff",
+	}})
+	if resp.Status != http.StatusOK {
+		t.Fatalf("invite: %d %s", resp.Status, resp.Body)
+	}
+	var invited controllers.InviteResponse
+	resp.JSON(t, &invited)
+	if !invited.EmailSent {
+		t.Error("email_sent = false")
+	}
+	token := inviteToken(t, app)
+
+	resp = app.Do(t, apptest.Request{Method: http.MethodPost, Path: "/api/auth/invite/lookup", Body: map[string]any{"token": token}})
+	var details struct {
+		Data controllers.InviteDetails `json:"data"`
+	}
+	resp.JSON(t, &details)
+	if resp.Status != http.StatusOK || details.Data.Email != "sam@example.com" || details.Data.Role != models.RoleStaff {
+		t.Fatalf("lookup: %d %s", resp.Status, resp.Body)
+	}
+
+	accept := apptest.Request{Method: http.MethodPost, Path: "/api/auth/invite/accept", Body: map[string]any{"token": token, "password": "Secret123!"}}
+	resp = app.Do(t, accept)
+	var login controllers.LoginResponse
+	resp.JSON(t, &login)
+	if resp.Status != http.StatusOK || login.Token == "" {
+		t.Fatalf("accept: %d %s", resp.Status, resp.Body)
+	}
+	if resp := app.Do(t, accept); resp.Status != http.StatusNotFound {
+		t.Errorf("second accept: %d %s, want 404", resp.Status, resp.Body)
+	}
+
+	var user models.User
+	app.DB.Where("email = ?", "sam@example.com").First(&user)
+	if !user.IsActive || user.Role != models.RoleStaff {
+		t.Errorf("invitee = %+v, want an active staff account", user)
+	}
+}
+
+func TestInviteNeedsAdmin(t *testing.T) {
+	app := apptest.New(t)
+	staff := app.AddUser(t, models.User{Email: "staff@example.com", Role: models.RoleStaff})
+
+	resp := app.Do(t, apptest.Request{Method: http.MethodPost, Path: "/api/admin/invites", Token: app.Token(t, staff), Body: map[string]any{
+		"name":  "Sam Reyes",
+		"email": "sam@example.com",
+		"role":  "staff",
+	}})
+	if resp.Status != http.StatusForbidden || len(app.Outbox.Sent()) != 0 {
+		t.Errorf("staff invite: %d %s, want 403 and no email", resp.Status, resp.Body)
+	}
+}
@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"gym-api/apperror"
+	"gym-api/listing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// listParams parses the list parameters for spec, for the service that
+// runs the query.
+func listParams(c *fiber.Ctx, spec listing.Spec) (listing.Params, error) {
+	p, err := listing.Parse(spec, c.Queries())
+	if err != nil {
+		return p, apperror.BadRequest(err.Error())
+	}
+	return p, nil
+}
+
+// The list specs are exported so routes/openapi.go can document their query
+// parameters.
+
+// UserListSpec covers staff and trainer lists.
+var UserListSpec = listing.Spec{
+	Search: []string{"name", "email", "phone"},
+	Filters: map[string]listing.Filter{
+		"branch_id": {Column: "branch_id", Kind: listing.Number},
+		"is_active": {Column: "is_active", Kind: listing.Bool},
+	},
+	Sorts:       map[string]string{"name": "name", "email": "email", "created_at": "created_at", "id": "id"},
+	DefaultSort: "name",
+}
+
+var MemberListSpec = listing.Spec{
+	Search: []string{"name", "email", "phone"},
+	Filters: map[string]listing.Filter{
+		"status":       {Column: "membership_status", Kind: listing.Text, Values: []string{"active", "inactive", "suspended", "pending"}},
+		"package_id":   {Column: "package_id", Kind: listing.Number},
+		"trainer_id":   {Column: "assigned_trainer_id", Kind: listing.Number},
+		"branch_id":    {Column: "branch_id", Kind: listing.Number},
+		"expires_from": {Column: "sub_end_date", Op: ">=", Kind: listing.Date},
+		"expires_to":   {Column: "sub_end_date", Op: "<=", Kind: listing.Date},
+	},
+	Sorts: map[string]string{
+		"name": "name", "email": "email", "created_at": "created_at",
+		"sub_end_date": "sub_end_date", "id": "id",
+	},
+	DefaultSort: "name",
+}
+
+var PackageListSpec = listing.Spec{
+	Search:      []string{"name", "description"},
+	Sorts:       map[string]string{"name": "name", "price": "price", "duration_days": "duration_days", "id": "id"},
+	DefaultSort: "name",
+}
+
+// AttendanceListSpec is shared by the attendance log, report and personal
+// history lists.
+var AttendanceListSpec = listing.Spec{
+	Filters: map[string]listing.Filter{
+		"member_id":  {Column: "trainer_id", Kind: listing.Number},
+		"branch_id":  {Column: "branch_id", Kind: listing.Number},
+		"device_id":  {Column: "device_id", Kind: listing.Number},
+		"scanned_by": {Column: "scanned_by", Kind: listing.Number},
+		"start_date": {Column: "date", Op: ">=", Kind: listing.Date},
+		"end_date":   {Column: "date", Op: "<=", Kind: listing.Date},
+	},
+	Sorts:       map[string]string{"scan_time": "scan_time", "id": "id"},
+	DefaultSort: "-scan_time",
+}
+
+var NotificationLogListSpec = listing.Spec{
+	Filters: map[string]listing.Filter{
+		"user_id": {Column: "user_id", Kind: listing.Number},
+		"status":  {Column: "status", Kind: listing.Text, Values: []string{"sent", "failed", "opted_out"}},
+		"kind":    {Column: "kind", Kind: listing.Text},
+		"channel": {Column: "channel", Kind: listing.Text},
+	},
+	Sorts:       map[string]string{"created_at": "created_at", "id": "id"},
+	DefaultSort: "-created_at",
+}
+
+var AuditLogListSpec = listing.Spec{
+	Filters: map[string]listing.Filter{
+		"gate_id":   {Column: "gate_id", Kind: listing.Number},
+		"device_id": {Column: "device_id", Kind: listing.Number},
+		"actor_id":  {Column: "actor_id", Kind: listing.Number},
+		"from":      {Column: "created_at", Op: ">=", Kind: listing.Date},
+		"to":        {Column: "created_at", Op: "<=", Kind: listing.Date},
+	},
+	Sorts:       map[string]string{"created_at": "created_at", "id": "id"},
+	DefaultSort: "-created_at",
+}
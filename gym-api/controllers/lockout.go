@@ -0,0 +1,68 @@
+package controllers
+
+import (
+	"gym-api/audit"
+	"gym-api/lockout"
+	"gym-api/models"
+	"gym-api/services"
+	"gym-api/validation"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// LockoutHandler serves the login lockout endpoints.
+type LockoutHandler struct {
+	lockouts services.Lockouts
+}
+
+func NewLockoutHandler(lockouts services.Lockouts) *LockoutHandler {
+	return &LockoutHandler{lockouts: lockouts}
+}
+
+// GetLockouts lists the accounts and addresses currently locked out of
+// login.
+func (h *LockoutHandler) GetLockouts(c *fiber.Ctx) error {
+	locked, err := h.lockouts.Locked(c.UserContext())
+	if err != nil {
+		return err
+	}
+	return c.JSON(dataResponse("", locked))
+}
+
+type ClearLockoutInput struct {
+	Email string `json:"email" validate:"required_without=IP,omitempty,email"`
+	IP    string `json:"ip" validate:"required_without=Email,omitempty,ip"`
+}
+
+func (i *ClearLockoutInput) Normalize() {
+	i.Email = validation.NormalizeEmail(i.Email)
+	validation.Trim(&i.IP)
+}
+
+// ClearLockout lifts the lockout of an account, an address, or both, and
+// resets their failure counts.
+func (h *LockoutHandler) ClearLockout(c *fiber.Ctx) error {
+	var input ClearLockoutInput
+	if err := bindInput(c, &input); err != nil {
+		return inputError(err)
+	}
+	adminID, _ := c.Locals("user_id").(uint)
+
+	for _, key := range []lockout.Key{{Kind: lockout.KindAccount, Subject: input.Email}, {Kind: lockout.KindIP, Subject: input.IP}} {
+		if key.Subject == "" {
+			continue
+		}
+		if err := h.lockouts.Clear(c.UserContext(), key); err != nil {
+			return err
+		}
+		audit.Record(models.AuditLog{
+			Action:  "auth.lockout_cleared",
+			ActorID: &adminID,
+			Subject: key.Subject,
+			Detail:  key.Kind,
+			IP:      c.IP(),
+		})
+	}
+
+	return c.JSON(MessageResponse{Message: "Lockout cleared"})
+}
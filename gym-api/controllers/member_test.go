@@ -0,0 +1,101 @@
+package controllers_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"gym-api/apptest"
+	"gym-api/controllers"
+	"gym-api/models"
+)
+
+func TestMemberLifecycle(t *testing.T) {
+	app := apptest.New(t)
+	admin := app.AddUser(t, models.User{Name: "Admin", Email: "admin@example.com", Role: models.RoleAdmin})
+	token := app.Token(t, admin)
+	pkg := models.Package{Name: "Monthly", DurationDays: 30, Price: 40}
+	if err := app.DB.Create(&pkg).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	resp := app.Do(t, apptest.Request{Method: http.MethodPost, Path: "/api/management/members", Token: token, Body: url.Values{
+		"name":       {"Ana Silva"},
+		"email":      {"Ana@Example.com"},
+		"password":   {"Secret123!"},
+		"package_id": {fmt.Sprint(pkg.ID)},
+	}})
+	if resp.Status != http.StatusOK {
+		t.Fatalf("create: %d %s", resp.Status, resp.Body)
+	}
+	var created controllers.UserResponse
+	resp.JSON(t, &created)
+	member := created.User
+	if member.Email != "ana@example.com" || member.PackageID == nil || *member.PackageID != pkg.ID {
+		t.Fatalf("created %+v, want ana@example.com on package %d", member, pkg.ID)
+	}
+
+	path := fmt.Sprintf("/api/admin/members/%d", member.ID)
+	resp = app.Do(t, apptest.Request{Method: http.MethodPut, Path: path, Token: token, Body: map[string]any{"name": "Ana Costa"}})
+	if resp.Status != http.StatusOK {
+		t.Fatalf("update: %d %s", resp.Status, resp.Body)
+	}
+
+	view := fmt.Sprintf("/api/management/members/%d", member.ID)
+	resp = app.Do(t, apptest.Request{Method: http.MethodGet, Path: view, Token: token})
+	var got struct {
+		Data models.User `json:"data"`
+	}
+	resp.JSON(t, &got)
+	if resp.Status != http.StatusOK || got.Data.Name != "Ana Costa" {
+		t.Fatalf("get: %d %s", resp.Status, resp.Body)
+	}
+
+	resp = app.Do(t, apptest.Request{Method: http.MethodDelete, Path: path, Token: token})
+	if resp.Status != http.StatusOK {
+		t.Fatalf("delete: %d %s", resp.Status, resp.Body)
+	}
+	if resp := app.Do(t, apptest.Request{Method: http.MethodGet, Path: view, Token: token}); resp.Status != http.StatusNotFound {
+		t.Errorf("get after delete: %d, want 404", resp.Status)
+	}
+}
+
+func TestMemberEndpointsRefuseStaffAccounts(t *testing.T) {
+	app := apptest.New(t)
+	admin := app.AddUser(t, models.User{Email: "admin@example.com", Role: models.RoleAdmin})
+	staff := app.AddUser(t, models.User{Name: "Sam", Email: "sam@example.com", Role: models.RoleStaff})
+	token := app.Token(t, admin)
+	edit, view := fmt.Sprintf("/api/admin/members/%d", staff.ID), fmt.Sprintf("/api/management/members/%d", staff.ID)
+
+	for _, req := range []apptest.Request{
+		{Method: http.MethodGet, Path: view},
+		{Method: http.MethodPut, Path: edit, Body: map[string]any{"name": "Renamed"}},
+		{Method: http.MethodPost, Path: view + "/toggle"},
+		{Method: http.MethodDelete, Path: edit},
+	} {
+		req.Token = token
+		if resp := app.Do(t, req); resp.Status != http.StatusNotFound {
+			t.Errorf("%s %s: %d %s, want 404", req.Method, req.Path, resp.Status, resp.Body)
+		}
+	}
+
+	var stored models.User
+	app.DB.First(&stored, staff.ID)
+	if stored.Name != "Sam" {
+		t.Errorf("staff account changed to %+v", stored)
+	}
+}
+
+func TestMembersNeedStaff(t *testing.T) {
+	app := apptest.New(t)
+	member := app.AddUser(t, models.User{Email: "ana@example.com", Role: models.RoleMember})
+
+	if resp := app.Do(t, apptest.Request{Method: http.MethodGet, Path: "/api/management/members"}); resp.Status != http.StatusUnauthorized {
+		t.Errorf("anonymous: %d, want 401", resp.Status)
+	}
+	resp := app.Do(t, apptest.Request{Method: http.MethodGet, Path: "/api/management/members", Token: app.Token(t, member)})
+	if resp.Status != http.StatusForbidden {
+		t.Errorf("member: %d, want 403", resp.Status)
+	}
+}
@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	"gym-api/apperror"
+	"gym-api/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// NotificationHandler serves users' notification history and preferences
+// and the admins' delivery log.
+type NotificationHandler struct {
+	notifications services.Notifications
+}
+
+func NewNotificationHandler(notifications services.Notifications) *NotificationHandler {
+	return &NotificationHandler{notifications: notifications}
+}
+
+// GetMyNotifications returns the delivery log of the logged-in user.
+func (h *NotificationHandler) GetMyNotifications(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(uint)
+	if !ok {
+		return apperror.Unauthorized("Unauthorized")
+	}
+
+	logs, err := h.notifications.Recent(c.UserContext(), userID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(dataResponse("", logs))
+}
+
+// GetNotificationLogs lets admins inspect deliveries, optionally for one user.
+func (h *NotificationHandler) GetNotificationLogs(c *fiber.Ctx) error {
+	p, err := listParams(c, NotificationLogListSpec)
+	if err != nil {
+		return err
+	}
+	page, err := h.notifications.Logs(c.UserContext(), NotificationLogListSpec, p)
+	if err != nil {
+		return err
+	}
+	return c.JSON(page)
+}
+
+// NotificationPreferences maps each channel and kind to whether the user
+// wants to receive it.
+type NotificationPreferences struct {
+	Channels map[string]bool `json:"channels"`
+	Kinds    map[string]bool `json:"kinds"`
+}
+
+func (h *NotificationHandler) GetNotificationPreferences(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(uint)
+	if !ok {
+		return apperror.Unauthorized("Unauthorized")
+	}
+
+	prefs, err := h.notifications.Preferences(c.UserContext(), userID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(dataResponse("", NotificationPreferences(prefs)))
+}
+
+func (h *NotificationHandler) UpdateNotificationPreferences(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(uint)
+	if !ok {
+		return apperror.Unauthorized("Unauthorized")
+	}
+
+	var input NotificationPreferences
+	if err := c.BodyParser(&input); err != nil {
+		return apperror.BadRequest("Invalid input")
+	}
+
+	prefs, err := h.notifications.SetPreferences(c.UserContext(), userID, services.Preferences(input))
+	if err != nil {
+		return err
+	}
+	return c.JSON(dataResponse("", NotificationPreferences(prefs)))
+}
+
+type PushTokenInput struct {
+	Token string `json:"token" validate:"max=4096"` // Empty unregisters the device
+}
+
+// UpdatePushToken registers the device token used for push notifications.
+func (h *NotificationHandler) UpdatePushToken(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(uint)
+	if !ok {
+		return apperror.Unauthorized("Unauthorized")
+	}
+
+	var input PushTokenInput
+	if err := bindInput(c, &input); err != nil {
+		return inputError(err)
+	}
+
+	if err := h.notifications.SetPushToken(c.UserContext(), userID, input.Token); err != nil {
+		return err
+	}
+	return c.JSON(MessageResponse{Message: "Push token updated"})
+}
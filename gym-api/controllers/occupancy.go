@@ -0,0 +1,100 @@
+package controllers
+
+import (
+	"strconv"
+	"time"
+
+	"gym-api/apperror"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func queryBranchID(c *fiber.Ctx) (*uint, error) {
+	branchParam := c.Query("branch_id")
+	if branchParam == "" {
+		return nil, nil
+	}
+	id, err := strconv.Atoi(branchParam)
+	if err != nil {
+		return nil, err
+	}
+	branchID := uint(id)
+	return &branchID, nil
+}
+
+type CheckOutInput struct {
+	MemberID uint `json:"member_id" validate:"required"`
+}
+
+func (h *AttendanceHandler) CheckOut(c *fiber.Ctx) error {
+	var input CheckOutInput
+	if err := bindInput(c, &input); err != nil {
+		return inputError(err)
+	}
+
+	attendance, err := h.attendance.CheckOut(c.UserContext(), input.MemberID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(dataResponse("Checked out successfully", attendance))
+}
+
+type Occupancy struct {
+	Occupancy int64  `json:"occupancy"`
+	Capacity  int    `json:"capacity"`            // 0 means unlimited
+	Available *int64 `json:"available,omitempty"` // Only with a capacity
+}
+
+func (h *AttendanceHandler) GetOccupancy(c *fiber.Ctx) error {
+	branchID, err := queryBranchID(c)
+	if err != nil {
+		return apperror.BadRequest("Invalid branch_id")
+	}
+
+	occupancy, capacity, err := h.attendance.Occupancy(c.UserContext(), branchID)
+	if err != nil {
+		return err
+	}
+
+	data := Occupancy{Occupancy: occupancy, Capacity: capacity}
+	if capacity > 0 {
+		available := max(int64(capacity)-occupancy, 0)
+		data.Available = &available
+	}
+	return c.JSON(dataResponse("", data))
+}
+
+type OccupancyHour struct {
+	Hour     time.Time `json:"hour"`
+	CheckIns int64     `json:"check_ins"`
+	Peak     int64     `json:"peak"` // Highest head count during the hour
+}
+
+// GetOccupancyHistory returns hourly check-ins and peak occupancy for a day
+// (defaults to today).
+func (h *AttendanceHandler) GetOccupancyHistory(c *fiber.Ctx) error {
+	branchID, err := queryBranchID(c)
+	if err != nil {
+		return apperror.BadRequest("Invalid branch_id")
+	}
+
+	day := time.Now()
+	if dateStr := c.Query("date"); dateStr != "" {
+		day, err = time.ParseInLocation("2006-01-02", dateStr, time.Local)
+		if err != nil {
+			return apperror.BadRequest("Invalid date, expected YYYY-MM-DD")
+		}
+	}
+
+	history, err := h.attendance.OccupancyHistory(c.UserContext(), branchID, day)
+	if err != nil {
+		return err
+	}
+
+	hours := make([]OccupancyHour, len(history))
+	for i, hour := range history {
+		hours[i] = OccupancyHour(hour)
+	}
+	return c.JSON(dataResponse("", hours))
+}
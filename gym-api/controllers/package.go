@@ -1,103 +1,141 @@
 package controllers
 
 import (
-	"gym-api/config"
+	"strconv"
+
+	"gym-api/apperror"
 	"gym-api/models"
-	"time"
+	"gym-api/services"
+	"gym-api/validation"
 
 	"github.com/gofiber/fiber/v2"
 )
 
-// -- Packages CRUD --
+// PackageHandler serves the package endpoints.
+type PackageHandler struct {
+	packages services.Packages
+}
 
-func GetPackages(c *fiber.Ctx) error {
-	var packages []models.Package
-	config.DB.Find(&packages)
-	return c.JSON(fiber.Map{"data": packages})
+func NewPackageHandler(packages services.Packages) *PackageHandler {
+	return &PackageHandler{packages: packages}
 }
 
-func CreatePackage(c *fiber.Ctx) error {
-	var input models.Package
-	if err := c.BodyParser(&input); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
-	}
+// -- Packages CRUD --
 
-	if result := config.DB.Create(&input); result.Error != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not create package"})
+func (h *PackageHandler) GetPackages(c *fiber.Ctx) error {
+	p, err := listParams(c, PackageListSpec)
+	if err != nil {
+		return err
+	}
+	page, err := h.packages.List(c.UserContext(), PackageListSpec, p)
+	if err != nil {
+		return err
 	}
+	return c.JSON(page)
+}
 
-	return c.JSON(fiber.Map{"message": "Package created", "data": input})
+type PackageInput struct {
+	Name         string  `json:"name" validate:"required,max=100"`
+	DurationDays int     `json:"duration_days" validate:"gt=0,lte=3650"`
+	Price        float64 `json:"price" validate:"gte=0"`
+	Description  string  `json:"description" validate:"max=1000"`
 }
 
-func UpdatePackage(c *fiber.Ctx) error {
-	id := c.Params("id")
-	var pkg models.Package
-	if result := config.DB.First(&pkg, id); result.Error != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Package not found"})
+func (i *PackageInput) Normalize() {
+	validation.Trim(&i.Name, &i.Description)
+}
+
+// UpdatePackageInput is a partial update: omitted fields are left unchanged.
+type UpdatePackageInput struct {
+	Name         *string  `json:"name" validate:"omitnil,min=1,max=100"`
+	DurationDays *int     `json:"duration_days" validate:"omitnil,gt=0,lte=3650"`
+	Price        *float64 `json:"price" validate:"omitnil,gte=0"`
+	Description  *string  `json:"description" validate:"omitnil,max=1000"`
+}
+
+func (h *PackageHandler) CreatePackage(c *fiber.Ctx) error {
+	var input PackageInput
+	if err := bindInput(c, &input); err != nil {
+		return inputError(err)
+	}
+
+	pkg, err := h.packages.Create(c.UserContext(), models.Package{
+		Name:         input.Name,
+		DurationDays: input.DurationDays,
+		Price:        input.Price,
+		Description:  input.Description,
+	})
+	if err != nil {
+		return err
 	}
+	return c.JSON(dataResponse("Package created", pkg))
+}
 
-	var input models.Package
-	if err := c.BodyParser(&input); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+func (h *PackageHandler) UpdatePackage(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return apperror.BadRequest("Invalid ID")
 	}
 
-	pkg.Name = input.Name
-	pkg.DurationDays = input.DurationDays
-	pkg.Price = input.Price
-	pkg.Description = input.Description
+	var input UpdatePackageInput
+	if err := bindInput(c, &input); err != nil {
+		return inputError(err)
+	}
 
-	config.DB.Save(&pkg)
-	return c.JSON(fiber.Map{"message": "Package updated", "data": pkg})
+	pkg, err := h.packages.Update(c.UserContext(), uint(id), services.PackageChanges{
+		Name:         input.Name,
+		DurationDays: input.DurationDays,
+		Price:        input.Price,
+		Description:  input.Description,
+	})
+	if err != nil {
+		return err
+	}
+	return c.JSON(dataResponse("Package updated", pkg))
 }
 
-func DeletePackage(c *fiber.Ctx) error {
-	id := c.Params("id")
-	if result := config.DB.Delete(&models.Package{}, id); result.Error != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not delete package"})
+func (h *PackageHandler) DeletePackage(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return apperror.BadRequest("Invalid ID")
+	}
+	if err := h.packages.Delete(c.UserContext(), uint(id)); err != nil {
+		return err
 	}
-	return c.JSON(fiber.Map{"message": "Package deleted"})
+	return c.JSON(MessageResponse{Message: "Package deleted"})
 }
 
 // -- Subscription Logic --
 
 type SubscribeInput struct {
-	MemberID  uint `json:"member_id"`
-	PackageID uint `json:"package_id"`
+	MemberID  uint `json:"member_id" validate:"required"`
+	PackageID uint `json:"package_id" validate:"required"`
 }
 
-func SubscribeMember(c *fiber.Ctx) error {
-	var input SubscribeInput
-	if err := c.BodyParser(&input); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
-	}
+type SubscriptionResponse struct {
+	Message    string `json:"message"`
+	Package    string `json:"package"`
+	SubEndDate string `json:"sub_end_date"` // YYYY-MM-DD
+	Status     string `json:"status"`
+}
 
-	// 1. Fetch Package
-	var pkg models.Package
-	if result := config.DB.First(&pkg, input.PackageID); result.Error != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Package not found"})
+// SubscribeMember sells or renews a package, starting now.
+func (h *MemberHandler) SubscribeMember(c *fiber.Ctx) error {
+	var input SubscribeInput
+	if err := bindInput(c, &input); err != nil {
+		return inputError(err)
 	}
 
-	// 2. Fetch Member
-	var member models.User
-	if result := config.DB.First(&member, input.MemberID); result.Error != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Member not found"})
+	soldBy, _ := c.Locals("user_id").(uint)
+	member, pkg, err := h.membership.Subscribe(c.UserContext(), input.MemberID, input.PackageID, soldBy)
+	if err != nil {
+		return err
 	}
 
-	// 3. Update Member Subscription
-	now := time.Now()
-	endDate := now.AddDate(0, 0, pkg.DurationDays)
-
-	member.PackageID = &pkg.ID
-	member.SubStartDate = &now
-	member.SubEndDate = &endDate
-	member.MembershipStatus = "active"
-
-	config.DB.Save(&member)
-
-	return c.JSON(fiber.Map{
-		"message":      "Subscription updated successfully",
-		"package":      pkg.Name,
-		"sub_end_date": endDate.Format("2006-01-02"),
-		"status":       "active",
+	return c.JSON(SubscriptionResponse{
+		Message:    "Subscription updated successfully",
+		Package:    pkg.Name,
+		SubEndDate: member.SubEndDate.Format("2006-01-02"),
+		Status:     member.MembershipStatus,
 	})
 }
@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"strconv"
+
+	"gym-api/apperror"
+	"gym-api/models"
+	"gym-api/storage"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// storeUploadedPhoto validates, resizes and stores an uploaded profile picture.
+func storeUploadedPhoto(c *fiber.Ctx, file *multipart.FileHeader) (*storage.StoredPhoto, error) {
+	if file.Size > storage.MaxPhotoBytes {
+		return nil, storage.ErrPhotoTooLarge
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, storage.MaxPhotoBytes+1))
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.SavePhoto(c.UserContext(), storage.Default, data)
+}
+
+// photoError maps photo validation failures to client errors.
+func photoError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, storage.ErrPhotoTooLarge):
+		return apperror.TooLarge(err.Error())
+	case errors.Is(err, storage.ErrUnsupportedType), errors.Is(err, storage.ErrInvalidImage):
+		return apperror.BadRequest(err.Error())
+	}
+	slog.ErrorContext(c.UserContext(), "Failed to store profile picture", "error", err)
+	return apperror.Internal(err, "Failed to save profile picture")
+}
+
+// removeUserPhoto deletes the stored profile picture of a user, if any.
+func removeUserPhoto(user models.User) {
+	if user.PhotoKey == "" {
+		return
+	}
+	if err := storage.DeletePhoto(context.Background(), storage.Default, user.PhotoKey); err != nil {
+		slog.Warn("Failed to delete photo", "user_id", user.ID, "error", err)
+	}
+}
+
+// UploadMemberPhoto replaces a member's profile picture.
+func (h *MemberHandler) UploadMemberPhoto(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return apperror.BadRequest("Invalid ID")
+	}
+
+	// Refuse unknown members before storing anything
+	if _, err := h.membership.Get(c.UserContext(), uint(id)); err != nil {
+		return err
+	}
+
+	file, err := c.FormFile("profile_picture")
+	if err != nil {
+		return apperror.BadRequest("profile_picture file is required")
+	}
+
+	photo, err := storeUploadedPhoto(c, file)
+	if err != nil {
+		return photoError(c, err)
+	}
+
+	member, previous, err := h.membership.SetPhoto(c.UserContext(), uint(id), *photo)
+	if err != nil {
+		removeUserPhoto(models.User{ID: uint(id), PhotoKey: photo.Key})
+		return err
+	}
+	removeUserPhoto(models.User{ID: member.ID, PhotoKey: previous})
+
+	return c.JSON(dataResponse("Profile picture updated", member))
+}
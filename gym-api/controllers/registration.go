@@ -0,0 +1,177 @@
+package controllers
+
+import (
+	"strconv"
+
+	"gym-api/apperror"
+	"gym-api/audit"
+	"gym-api/models"
+	"gym-api/services"
+	"gym-api/storage"
+	"gym-api/validation"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RegistrationHandler serves member self sign-up and its approval.
+type RegistrationHandler struct {
+	registrations services.Registrations
+}
+
+func NewRegistrationHandler(registrations services.Registrations) *RegistrationHandler {
+	return &RegistrationHandler{registrations: registrations}
+}
+
+// RegisterInput is a member's self sign-up. Packages are attached by staff
+// once the registration is approved; see CreateMemberInput for the front
+// desk form.
+type RegisterInput struct {
+	Name     string `json:"name" form:"name" validate:"required,max=100,name"`
+	Email    string `json:"email" form:"email" validate:"required,email,max=191"`
+	Password string `json:"password" form:"password" validate:"required,password"`
+	Phone    string `json:"phone" form:"phone" validate:"phone"`
+}
+
+func (i *RegisterInput) Normalize() {
+	validation.Trim(&i.Name, &i.Phone)
+	i.Email = validation.NormalizeEmail(i.Email)
+}
+
+// UserResponse returns an account that was just created.
+type UserResponse struct {
+	Message string      `json:"message"`
+	User    models.User `json:"user"`
+}
+
+// Register signs a member up. The account stays pending, without a
+// package, until the member confirms their email address and staff
+// approve it (see ApproveRegistration).
+func (h *RegistrationHandler) Register(c *fiber.Ctx) error {
+	// 1. Parse Form Data (Multipart)
+	input := RegisterInput{
+		Name:     c.FormValue("name"),
+		Email:    c.FormValue("email"),
+		Password: c.FormValue("password"),
+		Phone:    c.FormValue("phone"),
+	}
+	if err := validation.Struct(&input); err != nil {
+		return inputError(err)
+	}
+
+	// 2. Handle File Upload
+	var photo *storage.StoredPhoto
+	file, err := c.FormFile("profile_picture")
+	if err == nil {
+		if photo, err = storeUploadedPhoto(c, file); err != nil {
+			return photoError(c, err)
+		}
+	}
+
+	// 3. Create the pending member and ask them to confirm their email address
+	user, err := h.registrations.Register(c.UserContext(), services.NewRegistration{
+		Name:     input.Name,
+		Email:    input.Email,
+		Phone:    input.Phone,
+		Password: input.Password,
+		Photo:    photo,
+	})
+	if err != nil {
+		removeUserPhoto(user)
+		return err
+	}
+
+	return c.JSON(UserResponse{Message: "Registration received. Check your email to confirm your address.", User: user})
+}
+
+type VerifyEmailInput struct {
+	Token string `json:"token" validate:"required,max=128"`
+}
+
+func (i *VerifyEmailInput) Normalize() {
+	validation.Trim(&i.Token)
+}
+
+// VerifyEmail confirms a self-registered member's email address. Opening
+// the link again is harmless.
+func (h *RegistrationHandler) VerifyEmail(c *fiber.Ctx) error {
+	var input VerifyEmailInput
+	if err := bindInput(c, &input); err != nil {
+		return inputError(err)
+	}
+
+	if err := h.registrations.Verify(c.UserContext(), input.Token); err != nil {
+		return err
+	}
+
+	return c.JSON(MessageResponse{Message: "Email address confirmed. We'll let you know once your registration is approved."})
+}
+
+type ResendVerificationInput struct {
+	Email string `json:"email" validate:"required,email,max=191"`
+}
+
+func (i *ResendVerificationInput) Normalize() {
+	i.Email = validation.NormalizeEmail(i.Email)
+}
+
+// ResendVerification emails a new verification link; earlier links stop
+// working. The answer is the same whether or not the email is registered.
+func (h *RegistrationHandler) ResendVerification(c *fiber.Ctx) error {
+	var input ResendVerificationInput
+	if err := bindInput(c, &input); err != nil {
+		return inputError(err)
+	}
+
+	if err := h.registrations.ResendVerification(c.UserContext(), input.Email); err != nil {
+		return err
+	}
+
+	return c.JSON(MessageResponse{Message: "If a registration is waiting for this address to be confirmed, a new link is on its way."})
+}
+
+// GetRegistrations lists self-registrations waiting for approval, oldest
+// first. Those with email_verified_at set can be approved.
+func (h *RegistrationHandler) GetRegistrations(c *fiber.Ctx) error {
+	registrations, err := h.registrations.Pending(c.UserContext())
+	if err != nil {
+		return err
+	}
+	return c.JSON(dataResponse("", registrations))
+}
+
+// ApproveRegistration activates a member whose email address is
+// confirmed. Attach a package afterwards with SubscribeMember.
+func (h *RegistrationHandler) ApproveRegistration(c *fiber.Ctx) error {
+	staffID, _ := c.Locals("user_id").(uint)
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return apperror.BadRequest("Invalid ID")
+	}
+
+	registration, err := h.registrations.Approve(c.UserContext(), uint(id), staffID)
+	if err != nil {
+		return err
+	}
+	audit.Record(models.AuditLog{Action: "member.registration_approved", ActorID: &staffID, Subject: registration.User.Email, IP: c.IP()})
+
+	return c.JSON(dataResponse("Registration approved", registration))
+}
+
+// RejectRegistration removes a pending registration and its account,
+// freeing the email address.
+func (h *RegistrationHandler) RejectRegistration(c *fiber.Ctx) error {
+	staffID, _ := c.Locals("user_id").(uint)
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return apperror.BadRequest("Invalid ID")
+	}
+
+	registration, err := h.registrations.Reject(c.UserContext(), uint(id))
+	if err != nil {
+		return err
+	}
+	removeUserPhoto(registration.User)
+	audit.Record(models.AuditLog{Action: "member.registration_rejected", ActorID: &staffID, Subject: registration.User.Email, IP: c.IP()})
+
+	return c.JSON(MessageResponse{Message: "Registration rejected"})
+}
@@ -3,8 +3,7 @@ package controllers
 import (
 	"time"
 
-	"gym-api/config"
-	"gym-api/models"
+	"gym-api/analytics"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -17,32 +16,12 @@ type DashboardStats struct {
 	TodayAttendance  int64   `json:"today_attendance"`
 }
 
-func GetStats(c *fiber.Ctx) error {
-	var stats DashboardStats
-
-	// 1. Counts
-	config.DB.Model(&models.User{}).Where("role = ?", models.RoleMember).Count(&stats.TotalMembers)
-	config.DB.Model(&models.User{}).Where("role = ? AND membership_status = ?", models.RoleMember, "active").Count(&stats.ActiveMembers)
-	config.DB.Model(&models.User{}).Where("role = ?", models.RoleTrainer).Count(&stats.TotalTrainers)
-
-	// 2. Revenue (Simplified: Sum of prices of currently active packages)
-	// In a real system, you'd use a 'payments' table.
-	type RevenueResult struct {
-		Total float64
+func (h *AnalyticsHandler) GetStats(c *fiber.Ctx) error {
+	summary, err := h.analytics.Summary(c.UserContext())
+	if err != nil {
+		return err
 	}
-	var rev RevenueResult
-	config.DB.Table("users").
-		Select("sum(packages.price) as total").
-		Joins("left join packages on packages.id = users.package_id").
-		Where("users.membership_status = ?", "active").
-		Scan(&rev)
-	stats.EstimatedRevenue = rev.Total
-
-	// 3. Today's Attendance
-	today := time.Now().Format("2006-01-02")
-	config.DB.Model(&models.Attendance{}).Where("date = ?", today).Count(&stats.TodayAttendance)
-
-	return c.JSON(fiber.Map{"data": stats})
+	return c.JSON(dataResponse("", DashboardStats(summary)))
 }
 
 type ChartData struct {
@@ -50,17 +29,22 @@ type ChartData struct {
 	Count int64  `json:"count"`
 }
 
-func GetAttendanceChart(c *fiber.Ctx) error {
-	// Last 7 days
-	var results []ChartData
+func (h *AnalyticsHandler) GetAttendanceChart(c *fiber.Ctx) error {
+	// Last 7 days, one point per day
+	now := time.Now()
+	series, err := h.analytics.Attendance(c.UserContext(), analytics.Params{
+		From:        now.AddDate(0, 0, -6),
+		To:          now,
+		Granularity: analytics.Day,
+	})
+	if err != nil {
+		return err
+	}
 
-	// MySQL specific date truncation
-	config.DB.Table("attendances").
-		Select("DATE_FORMAT(scan_time, '%Y-%m-%d') as date, count(*) as count").
-		Where("scan_time > ?", time.Now().AddDate(0, 0, -7)).
-		Group("DATE_FORMAT(scan_time, '%Y-%m-%d')").
-		Order("date asc").
-		Scan(&results)
+	results := make([]ChartData, len(series))
+	for i, point := range series {
+		results[i] = ChartData{Date: point.Period, Count: point.Count}
+	}
 
-	return c.JSON(fiber.Map{"data": results})
+	return c.JSON(dataResponse("", results))
 }
@@ -0,0 +1,20 @@
+package controllers
+
+// Response bodies shared by many handlers. Handlers return typed bodies
+// rather than fiber.Map so the OpenAPI document (routes/openapi.go) is
+// generated from exactly what clients receive.
+
+// MessageResponse acknowledges an action that returns no data.
+type MessageResponse struct {
+	Message string `json:"message"`
+}
+
+// DataResponse wraps a payload. Writes also say what happened.
+type DataResponse[T any] struct {
+	Message string `json:"message,omitempty"`
+	Data    T      `json:"data"`
+}
+
+func dataResponse[T any](message string, data T) DataResponse[T] {
+	return DataResponse[T]{Message: message, Data: data}
+}
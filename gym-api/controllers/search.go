@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"strconv"
+	"time"
+
+	"gym-api/apperror"
+	"gym-api/search"
+	"gym-api/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// SearchResult is a member card with what the desk needs to pick the
+// right person from several matches.
+type SearchResult struct {
+	MemberCard
+	MemberNumber   string `json:"member_number"`
+	Expired        bool   `json:"expired"`
+	CheckedInToday bool   `json:"checked_in_today"`
+	Score          int    `json:"score"`
+}
+
+// SearchHandler serves the front desk lookup.
+type SearchHandler struct {
+	search services.Search
+}
+
+func NewSearchHandler(search services.Search) *SearchHandler {
+	return &SearchHandler{search: search}
+}
+
+// SearchMembers is the front desk lookup: ?q= matches partial names,
+// emails, phone numbers, member numbers or a scanned QR code, and returns
+// compact cards best match first.
+func (h *SearchHandler) SearchMembers(c *fiber.Ctx) error {
+	q := c.Query("q")
+	if len(q) < 2 {
+		return apperror.BadRequest("Search needs at least 2 characters")
+	}
+	limit, err := strconv.Atoi(c.Query("limit", "10"))
+	if err != nil || limit < 1 {
+		return apperror.BadRequest("limit must be a positive number")
+	}
+	limit = min(limit, 50)
+
+	matches, err := h.search.Members(c.UserContext(), q, limit)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	data := make([]SearchResult, len(matches))
+	for i, m := range matches {
+		data[i] = SearchResult{
+			MemberCard:     verificationCard(m.User),
+			MemberNumber:   search.MemberNumber(m.User.ID),
+			Expired:        m.User.SubEndDate != nil && now.After(*m.User.SubEndDate),
+			CheckedInToday: m.CheckedInToday,
+			Score:          m.Score,
+		}
+	}
+
+	return c.JSON(dataResponse("", data))
+}
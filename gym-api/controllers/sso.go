@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"gym-api/services"
+	"gym-api/validation"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// SSOHandler serves single sign-on.
+type SSOHandler struct {
+	sso services.SSO
+}
+
+func NewSSOHandler(sso services.SSO) *SSOHandler {
+	return &SSOHandler{sso: sso}
+}
+
+// SSOStartResponse sends the browser to the provider. The web app keeps
+// State and checks the provider returns the same one.
+type SSOStartResponse struct {
+	AuthorizationURL string `json:"authorization_url"`
+	State            string `json:"state"`
+}
+
+// StartSSO begins single sign-on with the configured OIDC provider.
+func (h *SSOHandler) StartSSO(c *fiber.Ctx) error {
+	authURL, state, err := h.sso.Start(c.UserContext())
+	if err != nil {
+		return err
+	}
+	return c.JSON(SSOStartResponse{AuthorizationURL: authURL, State: state})
+}
+
+type SSOCallbackInput struct {
+	Code  string `json:"code" validate:"required"`
+	State string `json:"state" validate:"required"`
+}
+
+func (i *SSOCallbackInput) Normalize() {
+	validation.Trim(&i.Code, &i.State)
+}
+
+// FinishSSO signs in with the code the provider returned to the web app.
+// The verified email selects the account; with OIDC_ALLOWED_DOMAINS set,
+// staff without one get an account on first sign-on.
+func (h *SSOHandler) FinishSSO(c *fiber.Ctx) (err error) {
+	defer func() { countLogin("sso", err) }()
+
+	var input SSOCallbackInput
+	if err := bindInput(c, &input); err != nil {
+		return inputError(err)
+	}
+
+	login, err := h.sso.Finish(c.UserContext(), input.Code, input.State, c.IP())
+	if err != nil {
+		return err
+	}
+
+	// Sign in as with a password; the provider may have checked a second factor
+	return issueLogin(c, login.User, login.MFA)
+}
@@ -0,0 +1,114 @@
+package controllers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"gym-api/apptest"
+	"gym-api/config"
+	"gym-api/controllers"
+	"gym-api/models"
+	"gym-api/oidc/oidctest"
+)
+
+// withIdP turns single sign-on on against a mock provider that puts
+// everyone in groups.
+func withIdP(t *testing.T, app *apptest.App, groups ...string) {
+	t.Helper()
+	idp, err := oidctest.New("", "gym-api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp.Groups = groups
+	server := httptest.NewServer(idp)
+	t.Cleanup(server.Close)
+	idp.Issuer = server.URL
+
+	app.SSO.Settings = config.OIDCSettings{
+		Issuer:         server.URL,
+		ClientID:       "gym-api",
+		RedirectURL:    "http://app.invalid/sso/callback",
+		Scopes:         []string{"openid", "email", "profile"},
+		AllowedDomains: []string{"example.com"},
+		DefaultRole:    "staff",
+		RoleClaim:      "groups",
+		RoleMap:        map[string]string{"gym-admins": "admin"},
+	}
+}
+
+// signOn goes through the provider as email and posts the code it returns
+// to the callback.
+func signOn(t *testing.T, app *apptest.App, email string) apptest.Response {
+	t.Helper()
+	resp := app.Do(t, apptest.Request{Method: http.MethodGet, Path: "/api/auth/oidc/start"})
+	var start controllers.SSOStartResponse
+	resp.JSON(t, &start)
+	if resp.Status != http.StatusOK {
+		t.Fatalf("start: %d %s", resp.Status, resp.Body)
+	}
+
+	authURL, err := url.Parse(start.AuthorizationURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := authURL.Query()
+	q.Set("login_hint", email)
+	authURL.RawQuery = q.Encode()
+
+	browser := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	redirect, err := browser.Get(authURL.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	redirect.Body.Close()
+	back, err := url.Parse(redirect.Header.Get("Location"))
+	if err != nil || back.Query().Get("code") == "" {
+		t.Fatalf("provider redirected to %q", redirect.Header.Get("Location"))
+	}
+
+	return app.Do(t, apptest.Request{Method: http.MethodPost, Path: "/api/auth/oidc/callback", Body: map[string]any{
+		"code":  back.Query().Get("code"),
+		"state": back.Query().Get("state"),
+	}})
+}
+
+func TestSSORefusesMembers(t *testing.T) {
+	app := apptest.New(t)
+	// The provider maps everyone to admin
+	withIdP(t, app, "gym-admins")
+	member := app.AddUser(t, models.User{Email: "cal@example.com", Role: models.RoleMember, IsActive: true, MembershipStatus: "active"})
+
+	if resp := signOn(t, app, "cal@example.com"); resp.Status != http.StatusForbidden {
+		t.Errorf("member: %d %s, want 403", resp.Status, resp.Body)
+	}
+	var stored models.User
+	app.DB.First(&stored, member.ID)
+	if stored.Role != models.RoleMember || stored.SSOSubject != "" {
+		t.Errorf("member stored as %s with subject %q, want unchanged", stored.Role, stored.SSOSubject)
+	}
+}
+
+func TestSSOSignsInStaff(t *testing.T) {
+	app := apptest.New(t)
+	withIdP(t, app, "gym-admins")
+	staff := app.AddUser(t, models.User{Email: "sam@example.com", Role: models.RoleStaff, IsActive: true})
+
+	resp := signOn(t, app, "sam@example.com")
+	var login controllers.LoginResponse
+	resp.JSON(t, &login)
+	if resp.Status != http.StatusOK || login.Token == "" {
+		t.Fatalf("staff: %d %s", resp.Status, resp.Body)
+	}
+	var stored models.User
+	app.DB.First(&stored, staff.ID)
+	if stored.Role != models.RoleAdmin || stored.SSOSubject != "mock|sam@example.com" {
+		t.Errorf("staff stored as %s with subject %q, want the mapped admin role and the subject", stored.Role, stored.SSOSubject)
+	}
+
+	// New staff get an account on first sign-on
+	if resp := signOn(t, app, "kim@example.com"); resp.Status != http.StatusOK {
+		t.Errorf("new staff: %d %s", resp.Status, resp.Body)
+	}
+}
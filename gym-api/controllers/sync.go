@@ -0,0 +1,120 @@
+package controllers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"gym-api/apperror"
+	"gym-api/models"
+	"gym-api/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type OfflineScan struct {
+	ClientID    string `json:"client_id" validate:"required,max=64"` // Generated by the device, unique per scan
+	TrainerID   uint   `json:"trainer_id" validate:"required"`       // Member from the QR code
+	Timestamp   int64  `json:"timestamp" validate:"required"`        // QR code timestamp (unix seconds)
+	CapturedAt  int64  `json:"captured_at" validate:"required"`      // When the device scanned it (unix seconds)
+	Attestation string `json:"attestation" validate:"required"`      // Hex HMAC-SHA256, see attestationMessage
+}
+
+type SyncScansInput struct {
+	Scans []OfflineScan `json:"scans" validate:"required,max=500,dive"`
+}
+
+type SyncResult struct {
+	ClientID     string `json:"client_id"`
+	Status       string `json:"status"` // admitted, denied, invalid, error
+	Reason       string `json:"reason,omitempty"`
+	AttendanceID *uint  `json:"attendance_id,omitempty"`
+	Duplicate    bool   `json:"duplicate"` // Already synced earlier; result is the stored one
+}
+
+// attestationMessage is the string a device signs for each scan. The HMAC
+// key is the device's API key itself, which the server only sees in the
+// X-Device-Key header of the sync request: the stored hash is not enough
+// to attest a scan.
+func attestationMessage(s OfflineScan) string {
+	return fmt.Sprintf("%s|%d|%d|%d", s.ClientID, s.TrainerID, s.Timestamp, s.CapturedAt)
+}
+
+func validAttestation(key string, s OfflineScan) bool {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(attestationMessage(s)))
+	expected := mac.Sum(nil)
+
+	given, err := hex.DecodeString(s.Attestation)
+	return err == nil && hmac.Equal(expected, given)
+}
+
+// SyncScans admits scans a device queued while offline. Each scan is judged
+// as of its capture time and the outcome is stored by client ID, so
+// re-sending a batch is safe. Results are returned per item, in input order.
+func (h *AttendanceHandler) SyncScans(c *fiber.Ctx) error {
+	device := c.Locals("device").(*models.Device)
+	key := c.Get("X-Device-Key") // Checked against the device by DeviceAuth
+
+	var input SyncScansInput
+	if err := bindInput(c, &input); err != nil {
+		return inputError(err)
+	}
+
+	// Staff syncing through the scanner app are recorded as the scanner
+	scanner := services.Scanner{DeviceID: &device.ID, BranchID: device.BranchID}
+	if userID, ok := c.Locals("user_id").(uint); ok {
+		scanner.UserID = &userID
+	}
+
+	// Process in capture order so "already scanned today" sees earlier scans
+	order := make([]int, len(input.Scans))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return input.Scans[order[a]].CapturedAt < input.Scans[order[b]].CapturedAt
+	})
+
+	results := make([]SyncResult, len(input.Scans))
+	for _, i := range order {
+		results[i] = h.syncScan(c.UserContext(), key, scanner, input.Scans[i])
+	}
+
+	return c.JSON(dataResponse("", results))
+}
+
+func (h *AttendanceHandler) syncScan(ctx context.Context, key string, scanner services.Scanner, scan OfflineScan) SyncResult {
+	result := SyncResult{ClientID: scan.ClientID}
+	if !validAttestation(key, scan) {
+		result.Status, result.Reason = "invalid", "Invalid attestation"
+		return result
+	}
+
+	synced, duplicate, err := h.attendance.SyncScan(ctx, scan.ClientID, services.Admission{
+		MemberID:  scan.TrainerID,
+		Timestamp: scan.Timestamp,
+		Scanner:   scanner,
+		At:        time.Unix(scan.CapturedAt, 0),
+	})
+	if err != nil {
+		// Not stored, so the device retries it on the next sync
+		result.Status, result.Reason = "error", "Could not sync scan"
+		var appErr *apperror.Error
+		if errors.As(err, &appErr) {
+			result.Reason = appErr.Message
+		}
+		return result
+	}
+
+	result.Status = synced.Status
+	result.Reason = synced.Reason
+	result.AttendanceID = synced.AttendanceID
+	result.Duplicate = duplicate
+	return result
+}
@@ -0,0 +1,141 @@
+package controllers_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"gym-api/apptest"
+	"gym-api/controllers"
+	"gym-api/models"
+	"gym-api/utils"
+)
+
+// addDevice registers a kiosk and returns its API key.
+func addDevice(t *testing.T, app *apptest.App) string {
+	t.Helper()
+	key, err := utils.GenerateAPIKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin := app.AddUser(t, models.User{Email: "admin@example.com", Role: models.RoleAdmin})
+	device := models.Device{Name: "Lobby kiosk", KeyPrefix: key[:12], KeyHash: utils.HashAPIKey(key), CreatedBy: admin.ID}
+	if err := app.DB.Create(&device).Error; err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func attested(hmacKey, clientID string, memberID uint, capturedAt time.Time) controllers.OfflineScan {
+	scan := controllers.OfflineScan{
+		ClientID:   clientID,
+		TrainerID:  memberID,
+		Timestamp:  capturedAt.Unix(),
+		CapturedAt: capturedAt.Unix(),
+	}
+	mac := hmac.New(sha256.New, []byte(hmacKey))
+	fmt.Fprintf(mac, "%s|%d|%d|%d", scan.ClientID, scan.TrainerID, scan.Timestamp, scan.CapturedAt)
+	scan.Attestation = hex.EncodeToString(mac.Sum(nil))
+	return scan
+}
+
+func TestSyncScansAttestation(t *testing.T) {
+	app := apptest.New(t)
+	key := addDevice(t, app)
+	capturedAt := time.Now().Add(-time.Hour)
+
+	resp := app.Do(t, apptest.Request{Method: http.MethodPost, Path: "/api/kiosk/scan/sync", Header: map[string]string{"X-Device-Key": key}, Body: controllers.SyncScansInput{
+		Scans: []controllers.OfflineScan{
+			attested(key, "scan-1", 999, capturedAt),
+			// The stored hash is readable from the database; it must not attest
+			attested(utils.HashAPIKey(key), "scan-2", 999, capturedAt),
+		},
+	}})
+	if resp.Status != http.StatusOK {
+		t.Fatalf("sync: %d %s", resp.Status, resp.Body)
+	}
+	var results struct {
+		Data []controllers.SyncResult `json:"data"`
+	}
+	resp.JSON(t, &results)
+	if len(results.Data) != 2 {
+		t.Fatalf("results = %+v", results.Data)
+	}
+	if got := results.Data[0]; got.Status != "denied" || got.Reason != "Member not found" {
+		t.Errorf("signed with the key: %+v, want denied for the unknown member", got)
+	}
+	if got := results.Data[1]; got.Status != "invalid" {
+		t.Errorf("signed with the key hash: %+v, want invalid", got)
+	}
+}
+
+func TestSyncScansValidatesInput(t *testing.T) {
+	app := apptest.New(t)
+	key := addDevice(t, app)
+	scan := attested(key, "scan-1", 1, time.Now().Add(-time.Hour))
+
+	tooMany := make([]controllers.OfflineScan, 501)
+	for i := range tooMany {
+		tooMany[i] = attested(key, fmt.Sprintf("scan-%d", i), 1, time.Now().Add(-time.Hour))
+	}
+	noClientID := scan
+	noClientID.ClientID = ""
+	longClientID := scan
+	longClientID.ClientID = string(make([]byte, 65))
+
+	tests := []struct {
+		name  string
+		scans []controllers.OfflineScan
+		field string
+	}{
+		{"too many scans", tooMany, "scans"},
+		{"no client ID", []controllers.OfflineScan{scan, noClientID}, "scans[1].client_id"},
+		{"long client ID", []controllers.OfflineScan{longClientID}, "scans[0].client_id"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := app.Do(t, apptest.Request{Method: http.MethodPost, Path: "/api/kiosk/scan/sync", Header: map[string]string{"X-Device-Key": key}, Body: controllers.SyncScansInput{Scans: tt.scans}})
+			var body struct {
+				Details []struct {
+					Field string `json:"field"`
+				} `json:"details"`
+			}
+			resp.JSON(t, &body)
+			if resp.Status != http.StatusBadRequest || len(body.Details) == 0 || body.Details[0].Field != tt.field {
+				t.Errorf("%d %s, want 400 on %s", resp.Status, resp.Body, tt.field)
+			}
+		})
+	}
+}
+
+func TestSyncScansRollsBackCheckInWithoutOutcome(t *testing.T) {
+	app := apptest.New(t)
+	key := addDevice(t, app)
+	member := app.AddUser(t, models.User{Email: "cal@example.com", Role: models.RoleMember, MembershipStatus: "active"})
+	// The outcome can't be stored, as if the disk were full
+	if err := app.DB.Exec(`CREATE TRIGGER no_synced_scans BEFORE INSERT ON synced_scans BEGIN SELECT RAISE(ABORT, 'disk full'); END`).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	resp := app.Do(t, apptest.Request{Method: http.MethodPost, Path: "/api/kiosk/scan/sync", Header: map[string]string{"X-Device-Key": key}, Body: controllers.SyncScansInput{
+		Scans: []controllers.OfflineScan{attested(key, "scan-1", member.ID, time.Now().Add(-time.Hour))},
+	}})
+	var results struct {
+		Data []controllers.SyncResult `json:"data"`
+	}
+	resp.JSON(t, &results)
+	if resp.Status != http.StatusOK || len(results.Data) != 1 || results.Data[0].Status != "error" {
+		t.Fatalf("sync: %d %s, want an error result", resp.Status, resp.Body)
+	}
+
+	// The device retries the scan, so it must not have been admitted
+	var checkIns int64
+	app.DB.Model(&models.Attendance{}).Count(&checkIns)
+	if checkIns != 0 {
+		t.Errorf("%d check-ins stored without their sync outcome", checkIns)
+	}
+}
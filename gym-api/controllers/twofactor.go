@@ -0,0 +1,244 @@
+package controllers
+
+import (
+	"fmt"
+	"strconv"
+
+	"gym-api/apperror"
+	"gym-api/audit"
+	"gym-api/models"
+	"gym-api/services"
+	"gym-api/utils"
+	"gym-api/validation"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// TwoFactorHandler serves the second login step, 2FA enrolment and the
+// per-role policy.
+type TwoFactorHandler struct {
+	twoFactor services.TwoFactor
+}
+
+func NewTwoFactorHandler(twoFactor services.TwoFactor) *TwoFactorHandler {
+	return &TwoFactorHandler{twoFactor: twoFactor}
+}
+
+// TwoFactorChallenge is returned by Login instead of a token when the
+// account uses 2FA. Send ChallengeToken and a code to VerifyLogin.
+type TwoFactorChallenge struct {
+	ChallengeToken string `json:"challenge_token"`
+	ExpiresIn      int    `json:"expires_in"` // Seconds
+}
+
+type VerifyLoginInput struct {
+	ChallengeToken string `json:"challenge_token" validate:"required"`
+	Code           string `json:"code" validate:"required,max=32"` // Authenticator or recovery code
+}
+
+func (i *VerifyLoginInput) Normalize() {
+	validation.Trim(&i.ChallengeToken, &i.Code)
+}
+
+// VerifyLogin is the second login step: it exchanges the challenge from
+// Login and a code for a session token.
+func (h *TwoFactorHandler) VerifyLogin(c *fiber.Ctx) (err error) {
+	defer func() { countLogin("2fa", err) }()
+
+	var input VerifyLoginInput
+	if err := bindInput(c, &input); err != nil {
+		return inputError(err)
+	}
+
+	// 1. The challenge proves the password was right
+	claims, err := utils.ValidateChallengeToken(input.ChallengeToken)
+	if err != nil {
+		return apperror.Unauthorized("Login expired, sign in again")
+	}
+
+	// 2. Check the code
+	user, err := h.twoFactor.VerifyLogin(c.UserContext(), claims.UserID, input.Code, c.IP())
+	if err != nil {
+		if _, ok := apperror.As(err); ok {
+			return err
+		}
+		return loginBlocked(c, err) // The login limits refused the attempt
+	}
+
+	// 3. Issue the session
+	token, err := utils.GenerateToken(user.ID, string(user.Role), true)
+	if err != nil {
+		return apperror.Internal(err, "Could not login")
+	}
+	profile := profileOf(user)
+	return c.JSON(LoginResponse{Token: token, User: &profile})
+}
+
+// TwoFactorSetupResponse starts enrolment. Clients show OTPAuthURI as a QR
+// code for the authenticator app, and Secret for typing in by hand.
+type TwoFactorSetupResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+// SetupTwoFactor generates a new authenticator secret for the current
+// user. It takes effect once EnableTwoFactor confirms a code from it.
+func (h *TwoFactorHandler) SetupTwoFactor(c *fiber.Ctx) error {
+	uid, _ := c.Locals("user_id").(uint)
+	secret, uri, err := h.twoFactor.Setup(c.UserContext(), uid)
+	if err != nil {
+		return err
+	}
+	return c.JSON(TwoFactorSetupResponse{Secret: secret, OTPAuthURI: uri})
+}
+
+type TwoFactorCodeInput struct {
+	Code string `json:"code" validate:"required,max=32"`
+}
+
+func (i *TwoFactorCodeInput) Normalize() {
+	validation.Trim(&i.Code)
+}
+
+// TwoFactorEnabledResponse carries the recovery codes, shown only once,
+// and a session token that counts as signed in with 2FA.
+type TwoFactorEnabledResponse struct {
+	Message       string   `json:"message"`
+	Token         string   `json:"token"`
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// EnableTwoFactor turns 2FA on once the user proves their authenticator
+// produces the right codes.
+func (h *TwoFactorHandler) EnableTwoFactor(c *fiber.Ctx) error {
+	uid, _ := c.Locals("user_id").(uint)
+	var input TwoFactorCodeInput
+	if err := bindInput(c, &input); err != nil {
+		return inputError(err)
+	}
+
+	user, codes, err := h.twoFactor.Enable(c.UserContext(), uid, input.Code)
+	if err != nil {
+		return err
+	}
+	audit.Record(models.AuditLog{Action: "auth.2fa_enabled", ActorID: &user.ID, Subject: user.Email, IP: c.IP()})
+
+	// The current session was a password alone; replace it
+	token, err := utils.GenerateToken(user.ID, string(user.Role), true)
+	if err != nil {
+		return apperror.Internal(err, "Could not issue a new token")
+	}
+
+	return c.JSON(TwoFactorEnabledResponse{
+		Message:       "Two-factor authentication enabled. Store the recovery codes somewhere safe",
+		Token:         token,
+		RecoveryCodes: codes,
+	})
+}
+
+type DisableTwoFactorInput struct {
+	Password string `json:"password" validate:"required"`
+	Code     string `json:"code" validate:"required,max=32"`
+}
+
+func (i *DisableTwoFactorInput) Normalize() {
+	validation.Trim(&i.Code)
+}
+
+// DisableTwoFactor turns 2FA off for the current user, unless their role
+// requires it.
+func (h *TwoFactorHandler) DisableTwoFactor(c *fiber.Ctx) error {
+	uid, _ := c.Locals("user_id").(uint)
+	var input DisableTwoFactorInput
+	if err := bindInput(c, &input); err != nil {
+		return inputError(err)
+	}
+
+	user, err := h.twoFactor.Disable(c.UserContext(), uid, input.Password, input.Code)
+	if err != nil {
+		return err
+	}
+	audit.Record(models.AuditLog{Action: "auth.2fa_disabled", ActorID: &user.ID, Subject: user.Email, IP: c.IP()})
+
+	return c.JSON(MessageResponse{Message: "Two-factor authentication disabled"})
+}
+
+type RecoveryCodesResponse struct {
+	Message       string   `json:"message"`
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// RegenerateRecoveryCodes replaces the current user's recovery codes,
+// invalidating the old ones.
+func (h *TwoFactorHandler) RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	uid, _ := c.Locals("user_id").(uint)
+	var input TwoFactorCodeInput
+	if err := bindInput(c, &input); err != nil {
+		return inputError(err)
+	}
+
+	user, codes, err := h.twoFactor.RegenerateRecoveryCodes(c.UserContext(), uid, input.Code)
+	if err != nil {
+		return err
+	}
+	audit.Record(models.AuditLog{Action: "auth.recovery_codes_replaced", ActorID: &user.ID, Subject: user.Email, IP: c.IP()})
+
+	return c.JSON(RecoveryCodesResponse{Message: "Recovery codes replaced", RecoveryCodes: codes})
+}
+
+// ResetTwoFactor turns 2FA off for a user who lost their authenticator and
+// recovery codes. If their role requires 2FA they must set it up again at
+// their next login.
+func (h *TwoFactorHandler) ResetTwoFactor(c *fiber.Ctx) error {
+	adminID, _ := c.Locals("user_id").(uint)
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return apperror.BadRequest("Invalid ID")
+	}
+
+	user, err := h.twoFactor.Reset(c.UserContext(), uint(id))
+	if err != nil {
+		return err
+	}
+	audit.Record(models.AuditLog{Action: "auth.2fa_reset", ActorID: &adminID, Subject: user.Email, IP: c.IP()})
+
+	return c.JSON(MessageResponse{Message: "Two-factor authentication reset"})
+}
+
+// GetTwoFactorPolicy lists which roles must use 2FA.
+func (h *TwoFactorHandler) GetTwoFactorPolicy(c *fiber.Ctx) error {
+	policies, err := h.twoFactor.Policies(c.UserContext())
+	if err != nil {
+		return err
+	}
+	return c.JSON(dataResponse("", policies))
+}
+
+type TwoFactorPolicyInput struct {
+	Role     string `json:"role" validate:"required,oneof=admin staff trainer member"`
+	Required *bool  `json:"required" validate:"required"`
+}
+
+// UpdateTwoFactorPolicy makes 2FA mandatory, or optional, for a role.
+// Signed-in users of the role without 2FA are limited to setting it up.
+func (h *TwoFactorHandler) UpdateTwoFactorPolicy(c *fiber.Ctx) error {
+	adminID, _ := c.Locals("user_id").(uint)
+	var input TwoFactorPolicyInput
+	if err := bindInput(c, &input); err != nil {
+		return inputError(err)
+	}
+
+	policy, err := h.twoFactor.SetPolicy(c.UserContext(), models.Role(input.Role), *input.Required, adminID)
+	if err != nil {
+		return err
+	}
+	audit.Record(models.AuditLog{
+		Action:  "auth.2fa_policy",
+		ActorID: &adminID,
+		Subject: input.Role,
+		Detail:  fmt.Sprintf("required=%t", policy.Required),
+		IP:      c.IP(),
+	})
+
+	return c.JSON(dataResponse("Two-factor policy updated", policy))
+}
@@ -0,0 +1,135 @@
+package controllers_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"gym-api/apptest"
+	"gym-api/controllers"
+	"gym-api/models"
+	"gym-api/twofactor"
+	"gym-api/utils"
+)
+
+// addTwoFactorUser stores a staff account with 2FA on and the given
+// recovery codes, and returns it with a login challenge.
+func addTwoFactorUser(t *testing.T, app *apptest.App, recoveryCodes ...string) (models.User, string) {
+	t.Helper()
+	secret, err := twofactor.NewSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	user := app.AddUser(t, models.User{Email: "staff@example.com", Role: models.RoleStaff, IsActive: true, TOTPSecret: secret, TwoFactorEnabledAt: &now})
+	for _, code := range recoveryCodes {
+		if err := app.DB.Create(&models.RecoveryCode{UserID: user.ID, CodeHash: twofactor.HashRecoveryCode(code)}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	challenge, err := utils.GenerateChallengeToken(user.ID, string(user.Role))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user, challenge
+}
+
+func verifyLogin(t *testing.T, app *apptest.App, challenge, code string) apptest.Response {
+	t.Helper()
+	return app.Do(t, apptest.Request{Method: http.MethodPost, Path: "/api/auth/login/verify", Body: map[string]any{
+		"challenge_token": challenge,
+		"code":            code,
+	}})
+}
+
+func TestVerifyLoginRefusesReplayedCode(t *testing.T) {
+	app := apptest.New(t)
+	user, challenge := addTwoFactorUser(t, app)
+	code, err := twofactor.Code(user.TOTPSecret, twofactor.Step(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp := verifyLogin(t, app, challenge, code)
+	var login controllers.LoginResponse
+	resp.JSON(t, &login)
+	if resp.Status != http.StatusOK || login.Token == "" {
+		t.Fatalf("first use: %d %s", resp.Status, resp.Body)
+	}
+	if resp := verifyLogin(t, app, challenge, code); resp.Status != http.StatusUnauthorized {
+		t.Errorf("replayed code: %d %s, want 401", resp.Status, resp.Body)
+	}
+
+	// A code from before the one used is refused too
+	earlier, _ := twofactor.Code(user.TOTPSecret, twofactor.Step(time.Now())-1)
+	if resp := verifyLogin(t, app, challenge, earlier); resp.Status != http.StatusUnauthorized {
+		t.Errorf("earlier code: %d %s, want 401", resp.Status, resp.Body)
+	}
+}
+
+func TestVerifyLoginRecoveryCodeWorksOnce(t *testing.T) {
+	app := apptest.New(t)
+	_, challenge := addTwoFactorUser(t, app, "abcde-fghjk", "mnpqr-stuvw")
+
+	if resp := verifyLogin(t, app, challenge, "ABCDE FGHJK"); resp.Status != http.StatusOK {
+		t.Fatalf("recovery code: %d %s", resp.Status, resp.Body)
+	}
+	if resp := verifyLogin(t, app, challenge, "abcde-fghjk"); resp.Status != http.StatusUnauthorized {
+		t.Errorf("reused recovery code: %d %s, want 401", resp.Status, resp.Body)
+	}
+	if resp := verifyLogin(t, app, challenge, "mnpqr-stuvw"); resp.Status != http.StatusOK {
+		t.Errorf("second recovery code: %d %s", resp.Status, resp.Body)
+	}
+
+	var left int64
+	app.DB.Model(&models.RecoveryCode{}).Where("used_at IS NULL").Count(&left)
+	if left != 0 {
+		t.Errorf("%d recovery codes unused, want 0", left)
+	}
+}
+
+func TestTwoFactorRequiredAllowsOnlySetup(t *testing.T) {
+	app := apptest.New(t)
+	admin := app.AddUser(t, models.User{Email: "admin@example.com", Role: models.RoleAdmin})
+	staff := app.AddUser(t, models.User{Email: "staff@example.com", Role: models.RoleStaff, IsActive: true})
+	policy := apptest.Request{Method: http.MethodPut, Path: "/api/admin/2fa/policy", Token: app.Token(t, admin),
+		Body: map[string]any{"role": "staff", "required": true}}
+	if resp := app.Do(t, policy); resp.Status != http.StatusOK {
+		t.Fatalf("policy: %d %s", resp.Status, resp.Body)
+	}
+
+	// Signed in with a password only
+	token, err := utils.GenerateToken(staff.ID, string(staff.Role), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, req := range []apptest.Request{
+		{Method: http.MethodGet, Path: "/api/auth/me", Token: token},
+		{Method: http.MethodPost, Path: "/api/auth/2fa/setup", Token: token},
+	} {
+		if resp := app.Do(t, req); resp.Status != http.StatusOK {
+			t.Errorf("%s: %d %s, want 200", req.Path, resp.Status, resp.Body)
+		}
+	}
+
+	members := apptest.Request{Method: http.MethodGet, Path: "/api/management/members", Token: token}
+	resp := app.Do(t, members)
+	var refused struct {
+		Code string `json:"code"`
+	}
+	resp.JSON(t, &refused)
+	if resp.Status != http.StatusForbidden || refused.Code != "two_factor_required" {
+		t.Errorf("members without 2FA: %d %s, want 403 two_factor_required", resp.Status, resp.Body)
+	}
+
+	members.Token = app.Token(t, staff)
+	if resp := app.Do(t, members); resp.Status != http.StatusOK {
+		t.Errorf("members with 2FA: %d %s, want 200", resp.Status, resp.Body)
+	}
+
+	// Roles the policy doesn't cover are let through
+	adminToken, _ := utils.GenerateToken(admin.ID, string(admin.Role), false)
+	if resp := app.Do(t, apptest.Request{Method: http.MethodGet, Path: "/api/management/members", Token: adminToken}); resp.Status != http.StatusOK {
+		t.Errorf("admin without 2FA: %d %s, want 200", resp.Status, resp.Body)
+	}
+}
@@ -0,0 +1,194 @@
+package events
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Type string
+
+const (
+	CheckIn   Type = "checkin"
+	CheckOut  Type = "checkout"
+	Denied    Type = "denied"
+	Expired   Type = "expired"
+	Occupancy Type = "occupancy"
+	Rejected  Type = "rejected" // Staff rejected a check-in after it was admitted
+	GateAlert Type = "gate"     // Door forced, held open, etc.
+)
+
+// Event is a single message on the live feed. IDs are "<epoch>-<seq>" so a
+// client reconnecting after an API restart can tell its cursor is stale.
+// Events carried through the database have the same ID in every process.
+type Event struct {
+	ID       string      `json:"id"`
+	Seq      uint64      `json:"-"`
+	Type     Type        `json:"type"`
+	BranchID *uint       `json:"branch_id,omitempty"`
+	Data     interface{} `json:"data"`
+	Time     time.Time   `json:"time"`
+}
+
+// Filter narrows a subscription. A nil BranchID matches every branch and an
+// empty Types set matches every type.
+type Filter struct {
+	BranchID *uint
+	Types    map[Type]bool
+}
+
+func (f Filter) Match(e Event) bool {
+	if f.BranchID != nil && (e.BranchID == nil || *e.BranchID != *f.BranchID) {
+		return false
+	}
+	if len(f.Types) > 0 && !f.Types[e.Type] {
+		return false
+	}
+	return true
+}
+
+type Subscription struct {
+	C      chan Event
+	filter Filter
+}
+
+// Broker fans published events out to subscribers and keeps a bounded
+// history so clients can resume from their last seen event ID.
+type Broker struct {
+	mu      sync.RWMutex
+	db      *gorm.DB // Set by UseDB
+	epoch   string
+	seq     uint64 // Of the last event delivered
+	from    uint64 // history holds every event after it
+	history []Event
+	size    int
+	subs    map[*Subscription]struct{}
+	closed  bool
+}
+
+func NewBroker(historySize int) *Broker {
+	return &Broker{
+		epoch: strconv.FormatInt(time.Now().Unix(), 36),
+		size:  historySize,
+		subs:  make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish sends an event to the subscribers. With a database it is stored
+// for Relay to deliver, here and in the other processes.
+func (b *Broker) Publish(t Type, branchID *uint, data interface{}) Event {
+	b.mu.RLock()
+	db := b.db
+	b.mu.RUnlock()
+	if db != nil {
+		return insert(db, t, branchID, data)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	e := Event{
+		ID:       fmt.Sprintf("%s-%d", b.epoch, b.seq+1),
+		Seq:      b.seq + 1,
+		Type:     t,
+		BranchID: branchID,
+		Data:     data,
+		Time:     time.Now(),
+	}
+	b.deliver(e)
+	return e
+}
+
+// deliver adds e to the history and sends it to the subscribers. The
+// caller holds mu.
+func (b *Broker) deliver(e Event) {
+	b.seq = e.Seq
+	b.history = append(b.history, e)
+	if len(b.history) > b.size {
+		dropped := len(b.history) - b.size
+		b.from = b.history[dropped-1].Seq
+		b.history = b.history[dropped:]
+	}
+
+	for s := range b.subs {
+		if !s.filter.Match(e) {
+			continue
+		}
+		select {
+		case s.C <- e:
+		default:
+			// Slow consumer: drop it so it reconnects and resumes from history
+			// instead of blocking every publisher.
+			delete(b.subs, s)
+			close(s.C)
+		}
+	}
+}
+
+// Subscribe registers a subscriber and returns the events it missed since
+// lastEventID. ok is false when the cursor is older than the retained
+// history (or from a previous process) and the client should reload state.
+func (b *Broker) Subscribe(f Filter, lastEventID string) (sub *Subscription, missed []Event, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sub = &Subscription{C: make(chan Event, 64), filter: f}
+	if b.closed {
+		close(sub.C)
+		return sub, nil, true
+	}
+	b.subs[sub] = struct{}{}
+
+	if lastEventID == "" {
+		return sub, nil, true
+	}
+
+	ok = true
+	var since uint64
+	epoch, seqStr, found := strings.Cut(lastEventID, "-")
+	seq, err := strconv.ParseUint(seqStr, 10, 64)
+	if !found || err != nil || epoch != b.epoch || seq > b.seq || seq < b.from {
+		ok = false
+	} else {
+		since = seq
+	}
+
+	for _, e := range b.history {
+		if e.Seq > since && f.Match(e) {
+			missed = append(missed, e)
+		}
+	}
+	return sub, missed, ok
+}
+
+func (b *Broker) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subs[sub]; ok {
+		delete(b.subs, sub)
+		close(sub.C)
+	}
+}
+
+// Close ends every subscription, and any made later, so streams finish
+// and their clients reconnect elsewhere. Call it when shutting down.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for s := range b.subs {
+		delete(b.subs, s)
+		close(s.C)
+	}
+}
+
+// Hub is the process-wide broker used by the HTTP handlers. It keeps
+// events in memory until Setup connects it to the database.
+var Hub = NewBroker(1000)
+
+func Publish(t Type, branchID *uint, data interface{}) Event {
+	return Hub.Publish(t, branchID, data)
+}
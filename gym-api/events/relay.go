@@ -0,0 +1,133 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gym-api/config"
+	"gym-api/models"
+
+	"gorm.io/gorm"
+)
+
+// Events are published by every process, the worker's door alarms
+// included, and each API process serves its own streams. So with a
+// database, Publish only stores the event in live_events, and every API
+// process runs Relay to read the new rows in order and fan them out.
+
+const (
+	relayInterval = 250 * time.Millisecond
+	// gapWait is how long Relay holds back an event after a missing ID: the
+	// insert that took it may not have committed yet.
+	gapWait = time.Second
+	// keepEvents is how long events stay in live_events, for processes that
+	// fell behind.
+	keepEvents = time.Hour
+)
+
+// sharedEpoch starts the IDs of events carried through the database, which
+// stay valid across restarts and replicas.
+const sharedEpoch = "db"
+
+// Setup connects Hub to the database unless LIVE_FEED_STORE is memory. A
+// memory feed only reaches the clients of the process that published the
+// event, so it is refused unless one process runs everything.
+func Setup(mode config.Mode) error {
+	if config.LiveFeedStore() == "memory" {
+		if mode != config.ModeAll {
+			return errors.New("LIVE_FEED_STORE=memory needs RUN_MODE=all, the worker's events would not reach the API")
+		}
+		slog.Warn("The live feed is kept in memory; it is not shared between replicas")
+		return nil
+	}
+	return Hub.UseDB(config.DB)
+}
+
+// UseDB makes the broker publish through the live_events table. Its
+// subscribers get the events published from now on, by any process, once
+// Relay reads them.
+func (b *Broker) UseDB(db *gorm.DB) error {
+	var last uint64
+	if err := db.Model(&models.LiveEvent{}).Select("COALESCE(MAX(id), 0)").Scan(&last).Error; err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.db, b.epoch = db, sharedEpoch
+	b.seq, b.from, b.history = last, last, nil
+	return nil
+}
+
+// insert stores an event for Relay. A failure is logged: the feed is live
+// only and nobody would retry it.
+func insert(db *gorm.DB, t Type, branchID *uint, data interface{}) Event {
+	row := models.LiveEvent{Type: string(t), BranchID: branchID}
+	payload, err := json.Marshal(data)
+	if err == nil {
+		row.Data = payload
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		err = db.WithContext(ctx).Create(&row).Error
+		cancel()
+	}
+	if err != nil {
+		slog.Error("Failed to publish live event", "type", t, "error", err)
+	}
+	return Event{ID: fmt.Sprintf("%s-%d", sharedEpoch, row.ID), Seq: row.ID, Type: t, BranchID: branchID, Data: data, Time: row.CreatedAt}
+}
+
+// Relay delivers the events stored by Publish in any process until ctx is
+// done. Run it in every API process. Without a database it only waits.
+func (b *Broker) Relay(ctx context.Context) error {
+	tick := time.NewTicker(relayInterval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-tick.C:
+		}
+		if err := b.relay(ctx); err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "Failed to read live events", "error", err)
+		}
+	}
+}
+
+// relay delivers the events stored since the last one delivered.
+func (b *Broker) relay(ctx context.Context) error {
+	b.mu.RLock()
+	db, last := b.db, b.seq
+	b.mu.RUnlock()
+	if db == nil {
+		return nil
+	}
+
+	var rows []models.LiveEvent
+	if err := db.WithContext(ctx).Where("id > ?", last).Order("id").Limit(500).Find(&rows).Error; err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, row := range rows {
+		if row.ID != b.seq+1 && time.Since(row.CreatedAt) < gapWait {
+			break
+		}
+		b.deliver(Event{
+			ID:       fmt.Sprintf("%s-%d", sharedEpoch, row.ID),
+			Seq:      row.ID,
+			Type:     Type(row.Type),
+			BranchID: row.BranchID,
+			Data:     json.RawMessage(row.Data),
+			Time:     row.CreatedAt,
+		})
+	}
+	return nil
+}
+
+// Prune drops the events older than keepEvents.
+func Prune(ctx context.Context) error {
+	return config.DB.WithContext(ctx).Where("created_at < ?", time.Now().Add(-keepEvents)).Delete(&models.LiveEvent{}).Error
+}
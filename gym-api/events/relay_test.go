@@ -0,0 +1,195 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"gym-api/config"
+	"gym-api/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// useDB points config.DB at an in-memory database and returns it.
+func useDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard, TranslateError: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(&models.LiveEvent{}); err != nil {
+		t.Fatal(err)
+	}
+	previous := config.DB
+	config.DB = db
+	t.Cleanup(func() {
+		config.DB = previous
+		sqlDB.Close()
+	})
+	return db
+}
+
+// shared returns a broker on db, as a process started now would have.
+func shared(t *testing.T, db *gorm.DB) *Broker {
+	t.Helper()
+	b := NewBroker(10)
+	if err := b.UseDB(db); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func relay(t *testing.T, brokers ...*Broker) {
+	t.Helper()
+	for _, b := range brokers {
+		if err := b.relay(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func receive(t *testing.T, sub *Subscription) Event {
+	t.Helper()
+	select {
+	case e := <-sub.C:
+		return e
+	default:
+		t.Fatal("no event delivered")
+		return Event{}
+	}
+}
+
+func TestRelayReachesEveryProcess(t *testing.T) {
+	db := useDB(t)
+	worker, api := shared(t, db), shared(t, db)
+	sub, _, _ := api.Subscribe(Filter{}, "")
+	branchID := uint(3)
+
+	published := worker.Publish(GateAlert, &branchID, map[string]any{"door": "1"})
+	relay(t, api)
+
+	e := receive(t, sub)
+	if e.ID != published.ID || e.Type != GateAlert || *e.BranchID != 3 {
+		t.Errorf("received %+v, want %s gate alert for branch 3", e, published.ID)
+	}
+	var data map[string]string
+	if err := json.Unmarshal(e.Data.(json.RawMessage), &data); err != nil || data["door"] != "1" {
+		t.Errorf("data = %s, want the published door", e.Data)
+	}
+
+	// Nothing new: nothing delivered twice
+	relay(t, api)
+	select {
+	case e := <-sub.C:
+		t.Errorf("delivered %s again", e.ID)
+	default:
+	}
+}
+
+func TestResumeOnAnotherReplica(t *testing.T) {
+	db := useDB(t)
+	first, second := shared(t, db), shared(t, db)
+	for range 3 {
+		first.Publish(CheckIn, nil, nil)
+	}
+	relay(t, first, second)
+	cursor := first.history[0].ID
+
+	sub, missed, ok := second.Subscribe(Filter{}, cursor)
+	defer second.Unsubscribe(sub)
+	if !ok || len(missed) != 2 {
+		t.Errorf("resume from %s: ok %v, %d missed; want the 2 after it", cursor, ok, len(missed))
+	}
+
+	// A replica started later has no history before its start
+	third := shared(t, db)
+	if _, _, ok := third.Subscribe(Filter{}, cursor); ok {
+		t.Error("resumed from before the replica started")
+	}
+	if _, _, ok := third.Subscribe(Filter{}, first.history[2].ID); !ok {
+		t.Error("could not resume from the latest event")
+	}
+}
+
+func TestRelayWaitsForGaps(t *testing.T) {
+	db := useDB(t)
+	b := shared(t, db)
+	sub, _, _ := b.Subscribe(Filter{}, "")
+
+	// ID 1 was taken by an insert that has not committed yet
+	db.Create(&models.LiveEvent{ID: 2, Type: string(CheckIn), Data: []byte("null")})
+	relay(t, b)
+	select {
+	case e := <-sub.C:
+		t.Fatalf("delivered %s past a gap", e.ID)
+	default:
+	}
+
+	db.Create(&models.LiveEvent{ID: 1, Type: string(CheckIn), Data: []byte("null")})
+	relay(t, b)
+	if e := receive(t, sub); e.Seq != 1 {
+		t.Errorf("delivered %s first, want db-1", e.ID)
+	}
+	if e := receive(t, sub); e.Seq != 2 {
+		t.Errorf("delivered %s second, want db-2", e.ID)
+	}
+
+	// A gap older than gapWait is given up on
+	db.Create(&models.LiveEvent{ID: 4, Type: string(CheckIn), Data: []byte("null"), CreatedAt: time.Now().Add(-2 * gapWait)})
+	relay(t, b)
+	if e := receive(t, sub); e.Seq != 4 {
+		t.Errorf("delivered %s, want db-4", e.ID)
+	}
+}
+
+func TestHistoryLimit(t *testing.T) {
+	b := NewBroker(2)
+	var ids []string
+	for range 4 {
+		ids = append(ids, b.Publish(CheckIn, nil, nil).ID)
+	}
+
+	if _, missed, ok := b.Subscribe(Filter{}, ids[1]); !ok || len(missed) != 2 {
+		t.Errorf("resume from %s: ok %v, %d missed; want the 2 kept", ids[1], ok, len(missed))
+	}
+	if _, _, ok := b.Subscribe(Filter{}, ids[0]); ok {
+		t.Errorf("resumed from %s, which fell out of the history", ids[0])
+	}
+	if _, _, ok := b.Subscribe(Filter{}, "other-1"); ok {
+		t.Error("resumed from another process's epoch")
+	}
+}
+
+func TestSetupRefusesMemoryFeedWhenSplit(t *testing.T) {
+	t.Setenv("LIVE_FEED_STORE", "memory")
+	if err := Setup(config.ModeAPI); err == nil {
+		t.Error("api mode started with a memory feed")
+	}
+	if err := Setup(config.ModeAll); err != nil {
+		t.Errorf("all mode: %v", err)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	db := useDB(t)
+	db.Create(&models.LiveEvent{Type: string(CheckIn), CreatedAt: time.Now().Add(-2 * keepEvents)})
+	db.Create(&models.LiveEvent{Type: string(CheckIn)})
+
+	if err := Prune(context.Background()); err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+	var left int64
+	db.Model(&models.LiveEvent{}).Count(&left)
+	if left != 1 {
+		t.Errorf("%d events left, want 1", left)
+	}
+}
@@ -0,0 +1,66 @@
+// Package gates drives turnstiles and door relays. Admission decisions are
+// sent to the gate serving the scanner, and events reported by the gate
+// (door forced, held open) are handed back to the caller.
+package gates
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// Decision is the outcome of a scan, sent to the gate.
+type Decision struct {
+	Granted  bool      `json:"granted"`
+	MemberID uint      `json:"member_id"`
+	Reason   string    `json:"reason,omitempty"`
+	Door     string    `json:"door"`
+	Time     time.Time `json:"time"`
+}
+
+// Event is something the gate reports on its own.
+type Event struct {
+	Type   string    `json:"type"` // door_forced, door_held_open, ...
+	Door   string    `json:"door"`
+	Detail string    `json:"detail,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+var doorPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+var ErrInvalidDoor = errors.New("door may only contain letters, digits, _ and -")
+
+// ValidDoor reports whether door is safe to send to a controller: line
+// commands are space- and newline-separated, so a door can't contain
+// either.
+func ValidDoor(door string) bool {
+	return doorPattern.MatchString(door)
+}
+
+// Controller is an access-control adapter for one gate.
+type Controller interface {
+	Send(ctx context.Context, d Decision) error
+	Close() error
+}
+
+const (
+	KindHTTP      = "http"
+	KindLine      = "line"
+	KindSimulator = "simulator"
+)
+
+// New builds the controller for a gate kind. onEvent receives events the
+// gate reports over its own connection.
+func New(kind, address, secret string, onEvent func(Event)) (Controller, error) {
+	switch kind {
+	case KindHTTP:
+		return &HTTPRelay{URL: address, Secret: secret}, nil
+	case KindLine:
+		return NewLineController(address, onEvent), nil
+	case KindSimulator:
+		return NewSimulator(onEvent), nil
+	}
+	return nil, fmt.Errorf("unknown gate kind %q", kind)
+}
@@ -0,0 +1,110 @@
+package gates
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var httpClient = &http.Client{Timeout: 5 * time.Second}
+
+// Headers of a signed request. The signature covers the timestamp and the
+// nonce as well as the body, so a captured request can't be sent again:
+// the receiver rejects timestamps more than MaxSkew away and nonces it has
+// already seen.
+const (
+	SignatureHeader = "X-Gate-Signature" // Hex HMAC-SHA256, see Sign
+	TimestampHeader = "X-Gate-Timestamp" // Unix seconds
+	NonceHeader     = "X-Gate-Nonce"     // Unique per request, at most 64 characters
+)
+
+// MaxSkew is how far a signed request's timestamp may be from the
+// receiver's clock.
+const MaxSkew = 5 * time.Minute
+
+var (
+	ErrBadSignature = errors.New("invalid signature")
+	ErrStale        = errors.New("timestamp missing or outside the allowed window")
+	ErrBadNonce     = errors.New("nonce missing or longer than 64 characters")
+)
+
+// HTTPRelay posts each decision as JSON to a relay board or gateway,
+// signed with the gate's secret (see Sign). The relay reports events back
+// by posting to /api/gates/:id/events, signed the same way.
+type HTTPRelay struct {
+	URL    string
+	Secret string
+}
+
+func (r *HTTPRelay) Send(ctx context.Context, d Decision) error {
+	body, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonce := rand.Text()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(NonceHeader, nonce)
+	req.Header.Set(SignatureHeader, Sign(r.Secret, timestamp, nonce, body))
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("relay returned %s", resp.Status)
+	}
+	return nil
+}
+
+func (r *HTTPRelay) Close() error {
+	return nil
+}
+
+// Sign returns the hex HMAC-SHA256 of "<timestamp>.<nonce>." followed by
+// the body.
+func Sign(secret, timestamp, nonce string, body []byte) string {
+	return hex.EncodeToString(signature(secret, timestamp, nonce, body))
+}
+
+func signature(secret, timestamp, nonce string, body []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "." + nonce + "."))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+// Verify checks a signature produced by Sign and that timestamp is within
+// MaxSkew of now. The caller must still reject a nonce the gate has used
+// before.
+func Verify(secret, timestamp, nonce string, body []byte, sig string, now time.Time) error {
+	given, err := hex.DecodeString(sig)
+	if err != nil || !hmac.Equal(signature(secret, timestamp, nonce, body), given) {
+		return ErrBadSignature
+	}
+	sent, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || now.Sub(time.Unix(sent, 0)).Abs() > MaxSkew {
+		return ErrStale
+	}
+	if nonce == "" || len(nonce) > 64 {
+		return ErrBadNonce
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package gates
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHTTPRelaySignsDecisions(t *testing.T) {
+	var got Decision
+	seen := map[string]bool{}
+	relay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		nonce := r.Header.Get(NonceHeader)
+		err := Verify("s3cret", r.Header.Get(TimestampHeader), nonce, body, r.Header.Get(SignatureHeader), time.Now())
+		if err != nil || seen[nonce] {
+			http.Error(w, "bad signature", http.StatusUnauthorized)
+			return
+		}
+		seen[nonce] = true
+		json.Unmarshal(body, &got)
+	}))
+	defer relay.Close()
+
+	d := Decision{Granted: true, MemberID: 42, Door: "1", Reason: "active"}
+	if err := (&HTTPRelay{URL: relay.URL, Secret: "s3cret"}).Send(context.Background(), d); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if !got.Granted || got.MemberID != 42 || got.Door != "1" {
+		t.Errorf("relay got %+v", got)
+	}
+	// A fresh nonce each time
+	if err := (&HTTPRelay{URL: relay.URL, Secret: "s3cret"}).Send(context.Background(), d); err != nil {
+		t.Errorf("second Send: %v", err)
+	}
+
+	if err := (&HTTPRelay{URL: relay.URL, Secret: "wrong"}).Send(context.Background(), d); err == nil {
+		t.Error("Send with the wrong secret succeeded")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"type":"door_forced"}`)
+	sig := Sign("s3cret", "1700000000", "n1", body)
+
+	tests := []struct {
+		name      string
+		timestamp string
+		nonce     string
+		body      []byte
+		sig       string
+		want      error
+	}{
+		{"valid", "1700000000", "n1", body, sig, nil},
+		{"altered body", "1700000000", "n1", []byte(`{}`), sig, ErrBadSignature},
+		{"other nonce", "1700000000", "n2", body, sig, ErrBadSignature},
+		{"not hex", "1700000000", "n1", body, "zz", ErrBadSignature},
+		{"too old", "1699999000", "n1", body, Sign("s3cret", "1699999000", "n1", body), ErrStale},
+		{"no nonce", "1700000000", "", body, Sign("s3cret", "1700000000", "", body), ErrBadNonce},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Verify("s3cret", tt.timestamp, tt.nonce, tt.body, tt.sig, now); !errors.Is(err, tt.want) {
+				t.Errorf("Verify = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package gates
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	errNotConnected = errors.New("gate controller not connected")
+	errNoAck        = errors.New("gate controller did not acknowledge the command")
+)
+
+// ackTimeout is how long Send waits for the controller's ACK.
+const ackTimeout = 2 * time.Second
+
+// LineController talks to Wiegand/RS-485 style access controllers through a
+// TCP bridge (or a serial-to-TCP adapter such as ser2net) using a simple
+// line protocol:
+//
+//	-> GRANT <door> <member_id>
+//	-> DENY <door> <member_id>
+//	<- ACK
+//	<- EVENT <TYPE> <door> [detail...]     e.g. EVENT DOOR_FORCED 1
+//
+// Every command is answered with an ACK. ACKs carry no reference to the
+// command, so one command is sent at a time.
+//
+// The connection is kept open and re-established if it drops. Controllers
+// accept one connection, so only the process that owns the line gates
+// connects (see Registry.OwnsLines).
+type LineController struct {
+	Address string
+
+	onEvent func(Event)
+	sending sync.Mutex    // Held from writing a command until its ACK
+	acks    chan struct{} // ACKs read from the connection
+	mu      sync.Mutex
+	conn    net.Conn
+	done    chan struct{}
+	closing sync.Once
+}
+
+func NewLineController(address string, onEvent func(Event)) *LineController {
+	c := &LineController{Address: address, onEvent: onEvent, acks: make(chan struct{}, 1), done: make(chan struct{})}
+	go c.run()
+	return c
+}
+
+// Send writes the command and waits for the controller to acknowledge it.
+func (c *LineController) Send(ctx context.Context, d Decision) error {
+	if !ValidDoor(d.Door) {
+		return ErrInvalidDoor
+	}
+	verb := "DENY"
+	if d.Granted {
+		verb = "GRANT"
+	}
+
+	c.sending.Lock()
+	defer c.sending.Unlock()
+	c.mu.Lock()
+	conn := c.conn
+	c.mu.Unlock()
+	if conn == nil {
+		return errNotConnected
+	}
+
+	// An ACK that came after an earlier command gave up is not ours
+	select {
+	case <-c.acks:
+	default:
+	}
+	conn.SetWriteDeadline(time.Now().Add(2 * time.Second))
+	if _, err := fmt.Fprintf(conn, "%s %s %d\n", verb, d.Door, d.MemberID); err != nil {
+		return err
+	}
+
+	timeout := time.NewTimer(ackTimeout)
+	defer timeout.Stop()
+	select {
+	case <-c.acks:
+		return nil
+	case <-timeout.C:
+		return errNoAck
+	case <-ctx.Done():
+		return fmt.Errorf("%w: %w", errNoAck, ctx.Err())
+	case <-c.done:
+		return errNotConnected
+	}
+}
+
+func (c *LineController) Close() error {
+	c.closing.Do(func() { close(c.done) })
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn != nil {
+		return c.conn.Close()
+	}
+	return nil
+}
+
+// run keeps a connection open and reads events from it until Close.
+func (c *LineController) run() {
+	for {
+		conn, err := net.DialTimeout("tcp", c.Address, 5*time.Second)
+		if err == nil {
+			c.mu.Lock()
+			select {
+			case <-c.done: // Closed while dialling; Close saw no connection
+				c.mu.Unlock()
+				conn.Close()
+				return
+			default:
+			}
+			c.conn = conn
+			c.mu.Unlock()
+
+			c.read(conn)
+
+			c.mu.Lock()
+			c.conn = nil
+			c.mu.Unlock()
+			conn.Close()
+		}
+
+		select {
+		case <-c.done:
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+func (c *LineController) read(conn net.Conn) {
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 1 && fields[0] == "ACK" {
+			select {
+			case c.acks <- struct{}{}:
+			default: // Nobody waiting; Send drops it
+			}
+			continue
+		}
+		if len(fields) < 3 || fields[0] != "EVENT" {
+			continue // Anything unknown
+		}
+		if c.onEvent != nil {
+			c.onEvent(Event{
+				Type:   strings.ToLower(fields[1]),
+				Door:   fields[2],
+				Detail: strings.Join(fields[3:], " "),
+				Time:   time.Now(),
+			})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		select {
+		case <-c.done:
+		default:
+			slog.Warn("Gate controller disconnected", "address", c.Address, "error", err)
+		}
+	}
+}
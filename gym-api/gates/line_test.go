@@ -0,0 +1,137 @@
+package gates
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// lineRelay accepts the controller's connection on a local port, standing
+// in for the TCP bridge.
+type lineRelay struct {
+	conn   net.Conn
+	lines  *bufio.Scanner
+	events chan Event
+	ctrl   *LineController
+}
+
+func newLineRelay(t *testing.T) *lineRelay {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	r := &lineRelay{events: make(chan Event, 1)}
+	r.ctrl = NewLineController(ln.Addr().String(), func(e Event) { r.events <- e })
+	t.Cleanup(func() { r.ctrl.Close() })
+
+	if r.conn, err = ln.Accept(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { r.conn.Close() })
+	r.lines = bufio.NewScanner(r.conn)
+
+	// The controller stores the connection just after dialling
+	for deadline := time.Now().Add(time.Second); ; {
+		r.ctrl.mu.Lock()
+		connected := r.ctrl.conn != nil
+		r.ctrl.mu.Unlock()
+		if connected {
+			return r
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("controller did not connect")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// answer reads one command and replies to it with reply, if not empty.
+func (r *lineRelay) answer(reply string) <-chan string {
+	got := make(chan string, 1)
+	go func() {
+		if !r.lines.Scan() {
+			close(got)
+			return
+		}
+		got <- r.lines.Text()
+		if reply != "" {
+			r.conn.Write([]byte(reply + "\n"))
+		}
+	}()
+	return got
+}
+
+func TestLineControllerWaitsForAck(t *testing.T) {
+	r := newLineRelay(t)
+
+	got := r.answer("ACK")
+	if err := r.ctrl.Send(context.Background(), Decision{Granted: true, MemberID: 42, Door: "front_1"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if line := <-got; line != "GRANT front_1 42" {
+		t.Errorf("relay read %q, want GRANT front_1 42", line)
+	}
+
+	got = r.answer("ACK")
+	if err := r.ctrl.Send(context.Background(), Decision{MemberID: 7, Door: "2"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if line := <-got; line != "DENY 2 7" {
+		t.Errorf("relay read %q, want DENY 2 7", line)
+	}
+}
+
+func TestLineControllerWithoutAck(t *testing.T) {
+	r := newLineRelay(t)
+
+	got := r.answer("")
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := r.ctrl.Send(ctx, Decision{Granted: true, MemberID: 42, Door: "1"}); !errors.Is(err, errNoAck) {
+		t.Errorf("Send = %v, want errNoAck", err)
+	}
+	<-got
+}
+
+func TestLineControllerRejectsBadDoor(t *testing.T) {
+	r := newLineRelay(t)
+
+	if err := r.ctrl.Send(context.Background(), Decision{Granted: true, MemberID: 42, Door: "1\nGRANT 2"}); !errors.Is(err, ErrInvalidDoor) {
+		t.Errorf("Send = %v, want ErrInvalidDoor", err)
+	}
+}
+
+func TestLineControllerEvents(t *testing.T) {
+	r := newLineRelay(t)
+
+	r.conn.Write([]byte("ACK\nHELLO\nEVENT DOOR_FORCED 1 pried open\n"))
+	select {
+	case e := <-r.events:
+		if e.Type != "door_forced" || e.Door != "1" || e.Detail != "pried open" {
+			t.Errorf("event = %+v", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no event reported")
+	}
+}
+
+func TestLineControllerNotConnected(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	c := NewLineController(addr, nil)
+	defer c.Close()
+	if err := c.Send(context.Background(), Decision{Granted: true, MemberID: 42, Door: "1"}); !errors.Is(err, errNotConnected) {
+		t.Errorf("Send = %v, want errNotConnected", err)
+	}
+}
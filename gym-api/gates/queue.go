@@ -0,0 +1,168 @@
+package gates
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"gym-api/config"
+	"gym-api/models"
+)
+
+// commandTTL is how long a queued decision may wait to be sent. A turnstile
+// opening long after the scan is worse than not opening.
+const commandTTL = 5 * time.Second
+
+// Polling for queued decisions starts at minPoll and slows down to the
+// given maximum while there is nothing to do.
+const (
+	minPoll     = 100 * time.Millisecond
+	maxSendPoll = 500 * time.Millisecond
+	maxIdlePoll = 2 * time.Second
+)
+
+// backoff doubles the wait from minPoll up to max.
+type backoff struct {
+	max  time.Duration
+	wait time.Duration
+}
+
+// next returns how long to wait before polling again.
+func (b *backoff) next() time.Duration {
+	if b.wait == 0 {
+		b.wait = minPoll
+	} else {
+		b.wait = min(2*b.wait, b.max)
+	}
+	return b.wait
+}
+
+func (b *backoff) reset() {
+	b.wait = 0
+}
+
+// queued stands in for a line gate in processes that don't own the line
+// controllers: it queues each decision in gate_commands and waits for the
+// owner to send it.
+type queued struct {
+	gateID uint
+}
+
+func (q *queued) Send(ctx context.Context, d Decision) error {
+	db := config.DB.WithContext(ctx)
+	cmd := models.GateCommand{GateID: q.gateID, Granted: d.Granted, MemberID: d.MemberID, Reason: d.Reason}
+	if err := db.Create(&cmd).Error; err != nil {
+		return err
+	}
+
+	wait := backoff{max: maxSendPoll}
+	poll := time.NewTimer(wait.next())
+	defer poll.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("decision not sent by the gate worker: %w", ctx.Err())
+		case <-poll.C:
+			poll.Reset(wait.next())
+		}
+		if err := db.First(&cmd, cmd.ID).Error; err != nil {
+			return err
+		}
+		if cmd.SentAt != nil {
+			if cmd.Error != "" {
+				return errors.New(cmd.Error)
+			}
+			return nil
+		}
+	}
+}
+
+func (q *queued) Close() error {
+	return nil
+}
+
+// Deliver sends the decisions other processes queued for line gates until
+// ctx is done. Run it in the process that owns the line controllers.
+//
+// It polls every minPoll while decisions are coming in and backs off to
+// maxIdlePoll when there are none.
+func (r *Registry) Deliver(ctx context.Context) error {
+	wait := backoff{max: maxIdlePoll}
+	poll := time.NewTimer(wait.next())
+	defer poll.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-poll.C:
+		}
+		sent, err := r.deliverQueued(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "Failed to deliver queued gate decisions", "error", err)
+		}
+		if sent > 0 {
+			wait.reset()
+		}
+		poll.Reset(wait.next())
+	}
+}
+
+// deliverQueued sends the pending decisions and returns how many there were.
+func (r *Registry) deliverQueued(ctx context.Context) (int, error) {
+	db := config.DB.WithContext(ctx)
+	var commands []models.GateCommand
+	if err := db.Where("sent_at IS NULL").Order("id").Limit(50).Find(&commands).Error; err != nil {
+		return 0, err
+	}
+	// A gate added through an API process since the last reload
+	if slices.ContainsFunc(commands, func(cmd models.GateCommand) bool { return !r.has(cmd.GateID) }) {
+		if err := r.Reload(); err != nil {
+			return 0, err
+		}
+	}
+	for _, cmd := range commands {
+		var err error
+		if time.Since(cmd.CreatedAt) > commandTTL {
+			err = errors.New("decision expired before the gate worker could send it")
+		} else {
+			sendCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+			err = r.Send(sendCtx, cmd.GateID, Decision{Granted: cmd.Granted, MemberID: cmd.MemberID, Reason: cmd.Reason, Time: cmd.CreatedAt})
+			cancel()
+		}
+		outcome := map[string]any{"sent_at": time.Now(), "error": ""}
+		if err != nil {
+			outcome["error"] = truncate(err.Error(), 255)
+		}
+		if err := db.Model(&cmd).Updates(outcome).Error; err != nil {
+			return 0, err
+		}
+	}
+	return len(commands), nil
+}
+
+func (r *Registry) has(gateID uint) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.gates[gateID]
+	return ok
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+// Prune drops sent decisions and the nonces of webhooks too old to be
+// accepted again.
+func Prune(ctx context.Context) error {
+	db := config.DB.WithContext(ctx)
+	return errors.Join(
+		db.Where("created_at < ?", time.Now().Add(-time.Hour)).Delete(&models.GateCommand{}).Error,
+		db.Where("created_at < ?", time.Now().Add(-2*MaxSkew)).Delete(&models.GateNonce{}).Error,
+	)
+}
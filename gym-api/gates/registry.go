@@ -0,0 +1,254 @@
+package gates
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"gym-api/audit"
+	"gym-api/config"
+	"gym-api/events"
+	"gym-api/models"
+)
+
+var ErrNotSimulator = errors.New("gate is not a simulator")
+
+type gate struct {
+	model models.Gate
+	ctrl  Controller
+}
+
+// Registry holds a controller for every enabled gate.
+type Registry struct {
+	// OwnsLines is set in the one process that keeps the connections to
+	// line gates, which accept a single controller at a time. Elsewhere
+	// their decisions are queued for that process (see Deliver).
+	OwnsLines bool
+
+	mu      sync.RWMutex
+	gates   map[uint]*gate
+	sending sync.WaitGroup // Decisions Dispatch has not delivered yet
+}
+
+// Default is the registry used by the scan handlers.
+var Default = &Registry{}
+
+// Setup loads the configured gates into Default.
+func Setup() {
+	if err := Default.Reload(); err != nil {
+		slog.Error("Failed to load gates", "error", err)
+	}
+}
+
+// reloadInterval is how long an edit made through another process takes to
+// reach this one.
+const reloadInterval = 30 * time.Second
+
+// Refresh reloads the gates every reloadInterval until ctx is done, so that
+// every process picks up the edits served by the others.
+func (r *Registry) Refresh(ctx context.Context) error {
+	reload := time.NewTicker(reloadInterval)
+	defer reload.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-reload.C:
+			if err := r.Reload(); err != nil {
+				slog.ErrorContext(ctx, "Failed to reload gates", "error", err)
+			}
+		}
+	}
+}
+
+// Reload rebuilds the controllers from the database. Call it after gates
+// are added, changed or removed; other processes catch up through Refresh.
+func (r *Registry) Reload() error {
+	var rows []models.Gate
+	if err := config.DB.Where("enabled = ?", true).Find(&rows).Error; err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	old := r.gates
+	loaded := make(map[uint]*gate, len(rows))
+	for _, model := range rows {
+		// Keep the connection when nothing it depends on changed
+		if prev, ok := old[model.ID]; ok && prev.model.Kind == model.Kind &&
+			prev.model.Address == model.Address && prev.model.Secret == model.Secret {
+			loaded[model.ID] = &gate{model: model, ctrl: prev.ctrl}
+			delete(old, model.ID)
+			continue
+		}
+		ctrl, err := r.controller(model)
+		if err != nil {
+			slog.Warn("Skipping gate", "gate_id", model.ID, "error", err)
+			continue
+		}
+		loaded[model.ID] = &gate{model: model, ctrl: ctrl}
+	}
+	r.gates = loaded
+	r.mu.Unlock()
+
+	for _, g := range old {
+		g.ctrl.Close()
+	}
+	return nil
+}
+
+func (r *Registry) controller(model models.Gate) (Controller, error) {
+	if model.Kind == KindLine && !r.OwnsLines {
+		return &queued{gateID: model.ID}, nil
+	}
+	return New(model.Kind, model.Address, model.Secret, func(e Event) {
+		r.report(model.ID, e)
+	})
+}
+
+// report hands an event to Report with the gate as it is now, since the
+// controller outlives edits to the gate's name, door or branch.
+func (r *Registry) report(gateID uint, e Event) {
+	r.mu.RLock()
+	g := r.gates[gateID]
+	r.mu.RUnlock()
+	if g != nil {
+		Report(g.model, e)
+	}
+}
+
+// Close waits for dispatched decisions to be delivered, or ctx to be done,
+// and then disconnects every gate.
+func (r *Registry) Close(ctx context.Context) error {
+	delivered := make(chan struct{})
+	go func() {
+		r.sending.Wait()
+		close(delivered)
+	}()
+	var err error
+	select {
+	case <-delivered:
+	case <-ctx.Done():
+		err = fmt.Errorf("gate decisions still sending: %w", ctx.Err())
+	}
+
+	r.mu.Lock()
+	closing := r.gates
+	r.gates = nil
+	r.mu.Unlock()
+	for _, g := range closing {
+		g.ctrl.Close()
+	}
+	return err
+}
+
+// find picks the gate for a scanner: one bound to the device first, then
+// one serving the branch without a device of its own.
+func (r *Registry) find(deviceID, branchID *uint) *gate {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var branchGate *gate
+	for _, g := range r.gates {
+		if deviceID != nil && g.model.DeviceID != nil && *g.model.DeviceID == *deviceID {
+			return g
+		}
+		if g.model.DeviceID == nil && sameBranch(g.model.BranchID, branchID) && branchGate == nil {
+			branchGate = g
+		}
+	}
+	return branchGate
+}
+
+func sameBranch(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// Dispatch sends a decision to the gate serving the scanner, if there is
+// one. It does not block the scan: failures are logged and audited.
+func (r *Registry) Dispatch(deviceID, branchID *uint, d Decision) {
+	g := r.find(deviceID, branchID)
+	if g == nil {
+		return
+	}
+	d.Door = g.model.Door
+
+	r.sending.Add(1)
+	go func() {
+		defer r.sending.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := g.ctrl.Send(ctx, d); err != nil {
+			slog.Warn("Gate did not accept decision", "gate_id", g.model.ID, "gate", g.model.Name, "error", err)
+			audit.Record(models.AuditLog{
+				Action:  "gate.send_failed",
+				GateID:  &g.model.ID,
+				Subject: fmt.Sprintf("member %d", d.MemberID),
+				Detail:  err.Error(),
+			})
+		}
+	}()
+}
+
+// Send delivers a decision to one gate and waits for the result. It is used
+// to test a gate from the admin panel.
+func (r *Registry) Send(ctx context.Context, gateID uint, d Decision) error {
+	r.mu.RLock()
+	g := r.gates[gateID]
+	r.mu.RUnlock()
+	if g == nil {
+		return fmt.Errorf("gate %d is not enabled", gateID)
+	}
+	d.Door = g.model.Door
+	return g.ctrl.Send(ctx, d)
+}
+
+// Simulate injects an event into a simulator gate.
+func (r *Registry) Simulate(gateID uint, e Event) error {
+	r.mu.RLock()
+	g := r.gates[gateID]
+	r.mu.RUnlock()
+	if g == nil {
+		return fmt.Errorf("gate %d is not enabled", gateID)
+	}
+	sim, ok := g.ctrl.(*Simulator)
+	if !ok {
+		return ErrNotSimulator
+	}
+	if e.Door == "" {
+		e.Door = g.model.Door
+	}
+	sim.Trigger(e)
+	return nil
+}
+
+// Report records an event raised by a gate in the audit log and alerts the
+// live feed.
+func Report(g models.Gate, e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	audit.Record(models.AuditLog{
+		Action:    "gate." + e.Type,
+		GateID:    &g.ID,
+		DeviceID:  g.DeviceID,
+		Subject:   "door " + e.Door,
+		Detail:    e.Detail,
+		CreatedAt: e.Time,
+	})
+
+	events.Publish(events.GateAlert, g.BranchID, map[string]any{
+		"gate_id": g.ID,
+		"name":    g.Name,
+		"type":    e.Type,
+		"door":    e.Door,
+		"detail":  e.Detail,
+		"time":    e.Time,
+	})
+}
@@ -0,0 +1,51 @@
+package gates
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Simulator stands in for real hardware. It remembers the decisions it
+// received and lets events be injected with Trigger.
+type Simulator struct {
+	onEvent func(Event)
+
+	mu        sync.Mutex
+	decisions []Decision
+}
+
+func NewSimulator(onEvent func(Event)) *Simulator {
+	return &Simulator{onEvent: onEvent}
+}
+
+func (s *Simulator) Send(_ context.Context, d Decision) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.decisions = append(s.decisions, d)
+	if len(s.decisions) > 100 {
+		s.decisions = s.decisions[1:]
+	}
+	return nil
+}
+
+func (s *Simulator) Close() error {
+	return nil
+}
+
+// Decisions returns the most recent decisions, oldest first.
+func (s *Simulator) Decisions() []Decision {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Decision(nil), s.decisions...)
+}
+
+// Trigger reports an event as if the hardware had raised it.
+func (s *Simulator) Trigger(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	if s.onEvent != nil {
+		s.onEvent(e)
+	}
+}
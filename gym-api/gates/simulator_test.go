@@ -0,0 +1,24 @@
+package gates
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSimulator(t *testing.T) {
+	var events []Event
+	sim := NewSimulator(func(e Event) { events = append(events, e) })
+
+	for id := uint(1); id <= 101; id++ {
+		sim.Send(context.Background(), Decision{Granted: true, MemberID: id})
+	}
+	decisions := sim.Decisions()
+	if len(decisions) != 100 || decisions[0].MemberID != 2 || decisions[99].MemberID != 101 {
+		t.Errorf("kept %d decisions, want the last 100", len(decisions))
+	}
+
+	sim.Trigger(Event{Type: "door_held_open", Door: "1"})
+	if len(events) != 1 || events[0].Type != "door_held_open" || events[0].Time.IsZero() {
+		t.Errorf("events = %+v", events)
+	}
+}
@@ -0,0 +1,112 @@
+// Package idempotency stores the responses to requests sent with an
+// Idempotency-Key, so a client retrying after a timeout gets the first
+// answer back instead of subscribing or checking a member in twice.
+// Keys are per user, or per kiosk device, and kept for
+// config.IdempotencyTTL.
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"gym-api/config"
+	"gym-api/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrReused means the key was sent before with a different request.
+	ErrReused = errors.New("idempotency key reused for a different request")
+	// ErrInProgress means the first request with the key has not finished.
+	ErrInProgress = errors.New("idempotency key in use by a request in progress")
+)
+
+// A request that has held its key this long without finishing is taken to
+// have died with its process, and a retry may run it again.
+const abandonedAfter = time.Minute
+
+// Owner is who sent a key: a signed-in user or a kiosk device. Two owners
+// may use the same key for different requests.
+type Owner struct {
+	UserID   uint
+	DeviceID uint
+}
+
+// Fingerprint identifies a request, so a key cannot be replayed for
+// another one. content is the body, or a canonical form of it where the
+// raw bytes differ between retries.
+func Fingerprint(method, path string, content []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method + " " + path + "\n"))
+	h.Write(content)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Begin claims key for owner. If the key already holds a finished
+// response for the same request, that is returned with replay true and the
+// request must not run again. Otherwise the caller runs the request and
+// then calls Finish, or Release if it failed in a way worth retrying.
+func Begin(ctx context.Context, owner Owner, key, fingerprint string) (entry models.IdempotencyKey, replay bool, err error) {
+	db := config.DB.WithContext(ctx)
+	now := time.Now()
+	// A second try covers a key that had expired, or was abandoned, and was
+	// cleared by the first
+	for attempt := 0; attempt < 2; attempt++ {
+		entry = models.IdempotencyKey{UserID: owner.UserID, DeviceID: owner.DeviceID, Key: key, RequestHash: fingerprint, ExpiresAt: now.Add(config.IdempotencyTTL())}
+		// Not an error when the key is taken, so retries don't log one
+		created := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&entry)
+		if created.Error != nil || created.RowsAffected == 1 {
+			return entry, false, created.Error
+		}
+
+		var existing models.IdempotencyKey
+		err := db.Where(map[string]any{"user_id": owner.UserID, "device_id": owner.DeviceID, "key": key}).First(&existing).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue // Released or pruned meanwhile
+		}
+		if err != nil {
+			return entry, false, err
+		}
+		stale := existing.ExpiresAt.Before(now) ||
+			(existing.Status == 0 && existing.CreatedAt.Before(now.Add(-abandonedAfter)))
+		switch {
+		case stale:
+			if err := db.Delete(&existing).Error; err != nil {
+				return entry, false, err
+			}
+			continue
+		case existing.RequestHash != fingerprint:
+			return existing, false, ErrReused
+		case existing.Status == 0:
+			return existing, false, ErrInProgress
+		default:
+			return existing, true, nil
+		}
+	}
+	return entry, false, ErrInProgress
+}
+
+// Finish stores the response to the request that claimed entry.
+func Finish(ctx context.Context, entry models.IdempotencyKey, status int, contentType string, body []byte) error {
+	return config.DB.WithContext(ctx).Model(&entry).Updates(map[string]any{
+		"status":       status,
+		"content_type": contentType,
+		"body":         body,
+	}).Error
+}
+
+// Release gives the key up without a response, so a retry runs the
+// request again.
+func Release(ctx context.Context, entry models.IdempotencyKey) error {
+	return config.DB.WithContext(ctx).Delete(&entry).Error
+}
+
+// Prune drops expired keys.
+func Prune(ctx context.Context) error {
+	return config.DB.WithContext(ctx).Where("expires_at < ?", time.Now()).Delete(&models.IdempotencyKey{}).Error
+}
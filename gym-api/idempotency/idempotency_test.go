@@ -0,0 +1,176 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gym-api/config"
+	"gym-api/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// useDB points config.DB at an in-memory database and returns it.
+func useDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard, TranslateError: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(&models.IdempotencyKey{}); err != nil {
+		t.Fatal(err)
+	}
+	previous := config.DB
+	config.DB = db
+	t.Cleanup(func() {
+		config.DB = previous
+		sqlDB.Close()
+	})
+	return db
+}
+
+var ana = Owner{UserID: 1}
+
+func TestBeginFinishReplay(t *testing.T) {
+	useDB(t)
+	ctx := context.Background()
+	fingerprint := Fingerprint("POST", "/api/subscriptions", []byte(`{"package_id":1}`))
+
+	entry, replay, err := Begin(ctx, ana, "key-1", fingerprint)
+	if err != nil || replay {
+		t.Fatalf("Begin = %v, %v; want a new claim", replay, err)
+	}
+	if err := Finish(ctx, entry, 201, "application/json", []byte(`{"id":7}`)); err != nil {
+		t.Fatalf("Finish: %v", err)
+	}
+
+	stored, replay, err := Begin(ctx, ana, "key-1", fingerprint)
+	if err != nil || !replay {
+		t.Fatalf("Begin again = %v, %v; want a replay", replay, err)
+	}
+	if stored.Status != 201 || stored.ContentType != "application/json" || string(stored.Body) != `{"id":7}` {
+		t.Errorf("replayed %d %s %s, want the stored response", stored.Status, stored.ContentType, stored.Body)
+	}
+}
+
+func TestBeginRefusesAnotherRequest(t *testing.T) {
+	useDB(t)
+	ctx := context.Background()
+	entry, _, err := Begin(ctx, ana, "key-1", Fingerprint("POST", "/api/subscriptions", []byte(`{"package_id":1}`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = Begin(ctx, ana, "key-1", Fingerprint("POST", "/api/subscriptions", []byte(`{"package_id":1}`)))
+	if !errors.Is(err, ErrInProgress) {
+		t.Errorf("while running: err = %v, want ErrInProgress", err)
+	}
+
+	if err := Finish(ctx, entry, 200, "application/json", []byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+	for _, other := range []string{
+		Fingerprint("POST", "/api/subscriptions", []byte(`{"package_id":2}`)),
+		Fingerprint("POST", "/api/attendance/scan", []byte(`{"package_id":1}`)),
+	} {
+		if _, _, err := Begin(ctx, ana, "key-1", other); !errors.Is(err, ErrReused) {
+			t.Errorf("different request: err = %v, want ErrReused", err)
+		}
+	}
+}
+
+func TestRelease(t *testing.T) {
+	useDB(t)
+	ctx := context.Background()
+	fingerprint := Fingerprint("POST", "/api/subscriptions", nil)
+	entry, _, err := Begin(ctx, ana, "key-1", fingerprint)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Release(ctx, entry); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	if _, replay, err := Begin(ctx, ana, "key-1", fingerprint); err != nil || replay {
+		t.Errorf("Begin after release = %v, %v; want a new claim", replay, err)
+	}
+}
+
+func TestBeginReclaimsStaleKeys(t *testing.T) {
+	db := useDB(t)
+	ctx := context.Background()
+	fingerprint := Fingerprint("POST", "/api/subscriptions", nil)
+
+	// Abandoned by a process that died mid-request
+	abandoned, _, err := Begin(ctx, ana, "abandoned", fingerprint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Model(&abandoned).Update("created_at", time.Now().Add(-2*abandonedAfter))
+	if _, replay, err := Begin(ctx, ana, "abandoned", fingerprint); err != nil || replay {
+		t.Errorf("abandoned key: Begin = %v, %v; want a new claim", replay, err)
+	}
+
+	// Finished, but kept past its TTL
+	expired, _, err := Begin(ctx, ana, "expired", fingerprint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Finish(ctx, expired, 200, "application/json", []byte(`{}`))
+	db.Model(&expired).Update("expires_at", time.Now().Add(-time.Minute))
+	if _, replay, err := Begin(ctx, ana, "expired", Fingerprint("POST", "/api/other", nil)); err != nil || replay {
+		t.Errorf("expired key: Begin = %v, %v; want a new claim", replay, err)
+	}
+}
+
+func TestOwnersHaveTheirOwnKeys(t *testing.T) {
+	useDB(t)
+	ctx := context.Background()
+	owners := []Owner{ana, {UserID: 2}, {DeviceID: 1}, {DeviceID: 2}}
+
+	for i, owner := range owners {
+		body := []byte{byte(i)}
+		entry, replay, err := Begin(ctx, owner, "same-key", Fingerprint("POST", "/api/kiosk/scan", body))
+		if err != nil || replay {
+			t.Fatalf("%+v: Begin = %v, %v; want a new claim", owner, replay, err)
+		}
+		if err := Finish(ctx, entry, 200, "application/json", body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, owner := range owners {
+		entry, replay, err := Begin(ctx, owner, "same-key", Fingerprint("POST", "/api/kiosk/scan", []byte{byte(i)}))
+		if err != nil || !replay || entry.Body[0] != byte(i) {
+			t.Errorf("%+v: Begin = %v, %v; want its own response", owner, replay, err)
+		}
+	}
+}
+
+func TestPrune(t *testing.T) {
+	db := useDB(t)
+	ctx := context.Background()
+	for _, key := range []string{"old", "new"} {
+		if _, _, err := Begin(ctx, ana, key, Fingerprint("POST", "/api/subscriptions", nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Model(&models.IdempotencyKey{}).Where("key = ?", "old").Update("expires_at", time.Now().Add(-time.Minute))
+
+	if err := Prune(ctx); err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+	var keys []string
+	db.Model(&models.IdempotencyKey{}).Pluck("key", &keys)
+	if len(keys) != 1 || keys[0] != "new" {
+		t.Errorf("kept %v, want [new]", keys)
+	}
+}
@@ -0,0 +1,48 @@
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Job is a piece of background work run on a fixed interval.
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) error
+}
+
+// Run runs each job once immediately and then on its interval until ctx
+// is cancelled. A job that is running then sees ctx cancelled; Run returns
+// once every job has returned.
+func Run(ctx context.Context, jobs ...Job) error {
+	var wg sync.WaitGroup
+	for _, job := range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			run(ctx, job)
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+func run(ctx context.Context, job Job) {
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := job.Run(ctx); err != nil {
+			slog.Error("Job failed", "job", job.Name, "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,135 @@
+// Package lifecycle runs the process: long-running workers, such as the
+// HTTP server and the job scheduler, until SIGINT or SIGTERM, then an
+// orderly stop within a deadline.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Worker runs until its context is cancelled and then returns, having
+// finished or abandoned what it was doing. Returning early, with or
+// without an error, stops the process.
+type Worker struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+type hook struct {
+	name string
+	stop func(ctx context.Context) error
+}
+
+// Manager starts the workers and stops everything on a signal.
+type Manager struct {
+	timeout time.Duration
+	workers []Worker
+	hooks   []hook
+}
+
+// New returns a Manager that allows timeout for the whole stop.
+func New(timeout time.Duration) *Manager {
+	return &Manager{timeout: timeout}
+}
+
+// Go adds a worker. Workers start when Run is called.
+func (m *Manager) Go(w Worker) {
+	m.workers = append(m.workers, w)
+}
+
+// OnStop adds a hook run once the workers have returned, such as closing
+// the database. Hooks run in reverse order, so register them as resources
+// are opened.
+func (m *Manager) OnStop(name string, stop func(ctx context.Context) error) {
+	m.hooks = append(m.hooks, hook{name: name, stop: stop})
+}
+
+// Run starts the workers and blocks until a signal arrives or a worker
+// returns, then cancels the workers, waits for them and runs the stop
+// hooks. It returns an error if a worker failed or the stop was not clean.
+func (m *Manager) Run() error {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		failed []error
+		done   = make(map[string]bool)
+	)
+	for _, w := range m.workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := w.Run(ctx)
+			if ctx.Err() == nil {
+				slog.Error("Worker stopped, shutting down", "worker", w.Name, "error", err)
+				err = fmt.Errorf("%s stopped: %w", w.Name, errOrEarly(err))
+			} else if err != nil {
+				slog.Error("Worker did not stop cleanly", "worker", w.Name, "error", err)
+			}
+			mu.Lock()
+			done[w.Name] = true
+			if err != nil {
+				failed = append(failed, err)
+			}
+			mu.Unlock()
+			cancel()
+		}()
+	}
+
+	<-ctx.Done()
+	slog.Info("Shutting down", "timeout", m.timeout.String())
+	stopCtx, stopCancel := context.WithTimeout(context.Background(), m.timeout)
+	defer stopCancel()
+
+	stopped := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-stopCtx.Done():
+		mu.Lock()
+		for _, w := range m.workers {
+			if !done[w.Name] {
+				slog.Error("Worker still running at the shutdown deadline", "worker", w.Name)
+			}
+		}
+		failed = append(failed, errors.New("workers still running at the shutdown deadline"))
+		mu.Unlock()
+	}
+
+	for i := len(m.hooks) - 1; i >= 0; i-- {
+		h := m.hooks[i]
+		if err := h.stop(stopCtx); err != nil {
+			slog.Error("Stop step failed", "step", h.name, "error", err)
+			mu.Lock()
+			failed = append(failed, fmt.Errorf("%s: %w", h.name, err))
+			mu.Unlock()
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(failed) == 0 {
+		slog.Info("Stopped")
+	}
+	return errors.Join(failed...)
+}
+
+func errOrEarly(err error) error {
+	if err == nil {
+		return errors.New("returned before shutdown")
+	}
+	return err
+}
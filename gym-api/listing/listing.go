@@ -0,0 +1,278 @@
+// Package listing is the shared query layer for list endpoints: offset
+// pagination, text search, filters and sorting, validated against a
+// per-endpoint Spec so callers can only touch whitelisted columns.
+package listing
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+type Kind int
+
+const (
+	Number Kind = iota
+	Text
+	Bool
+	Date // YYYY-MM-DD or RFC 3339
+)
+
+// Filter maps a query parameter to a column condition.
+type Filter struct {
+	Column string
+	Op     string // "=" (default), ">=" or "<="
+	Kind   Kind
+	Values []string // Allowed values for Text filters; empty allows any
+}
+
+// Spec describes what a list endpoint accepts.
+type Spec struct {
+	Search      []string          // Columns matched by ?q=
+	Filters     map[string]Filter // Query parameter -> filter
+	Sorts       map[string]string // ?sort= key -> column
+	DefaultSort string            // Sort key, "-" prefix for descending
+}
+
+// Params is a validated list request.
+type Params struct {
+	Page   int
+	Limit  int // 0 for the whole list
+	Search string
+	Sort   string // Column
+	Desc   bool
+
+	conditions []condition
+}
+
+type condition struct {
+	sql   string
+	value any
+}
+
+// Page is the response envelope shared by every list endpoint.
+type Page[T any] struct {
+	Data  []T   `json:"data"`
+	Total int64 `json:"total"`
+	Page  int   `json:"page"`
+	Limit int   `json:"limit"` // 0 when the whole list was returned
+	Pages int64 `json:"pages"`
+}
+
+// Parse validates query parameters against spec. Limits above MaxLimit are
+// capped rather than rejected. A request with neither page nor limit gets
+// the whole list, as these endpoints returned before they were paginated;
+// sending either one pages the list, DefaultLimit rows at a time unless a
+// limit is given.
+func Parse(spec Spec, query map[string]string) (Params, error) {
+	p := Params{Page: 1, Limit: DefaultLimit}
+	if query["page"] == "" && query["limit"] == "" {
+		p.Limit = 0
+	}
+	var err error
+
+	if v := query["page"]; v != "" {
+		if p.Page, err = strconv.Atoi(v); err != nil || p.Page < 1 {
+			return p, errors.New("page must be a positive number")
+		}
+	}
+	if v := query["limit"]; v != "" {
+		if p.Limit, err = strconv.Atoi(v); err != nil || p.Limit < 1 {
+			return p, errors.New("limit must be a positive number")
+		}
+		p.Limit = min(p.Limit, MaxLimit)
+	}
+
+	if p.Search = strings.TrimSpace(query["q"]); p.Search != "" && len(spec.Search) == 0 {
+		return p, errors.New("search is not supported here")
+	}
+
+	sort := query["sort"]
+	if sort == "" {
+		sort = spec.DefaultSort
+	}
+	key := strings.TrimPrefix(sort, "-")
+	column, ok := spec.Sorts[key]
+	if !ok {
+		return p, fmt.Errorf("sort must be one of %s", strings.Join(sortKeys(spec), ", "))
+	}
+	p.Sort, p.Desc = column, strings.HasPrefix(sort, "-")
+
+	for param, f := range spec.Filters {
+		raw := query[param]
+		if raw == "" {
+			continue
+		}
+		value, op, err := f.parse(raw)
+		if err != nil {
+			return p, fmt.Errorf("invalid %s: %w", param, err)
+		}
+		p.conditions = append(p.conditions, condition{sql: f.Column + " " + op + " ?", value: value})
+	}
+
+	return p, nil
+}
+
+func (f Filter) parse(raw string) (any, string, error) {
+	op := f.Op
+	if op == "" {
+		op = "="
+	}
+
+	switch f.Kind {
+	case Number:
+		n, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			return nil, "", errors.New("must be a number")
+		}
+		return n, op, nil
+	case Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, "", errors.New("must be true or false")
+		}
+		return b, op, nil
+	case Date:
+		if t, err := time.ParseInLocation("2006-01-02", raw, time.Local); err == nil {
+			// An upper bound given as a date includes that whole day
+			if op == "<=" {
+				return t.AddDate(0, 0, 1), "<", nil
+			}
+			return t, op, nil
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return nil, "", errors.New("must be a date (YYYY-MM-DD)")
+		}
+		return t, op, nil
+	default:
+		if len(f.Values) > 0 && !slices.Contains(f.Values, raw) {
+			return nil, "", fmt.Errorf("must be one of %s", strings.Join(f.Values, ", "))
+		}
+		return raw, op, nil
+	}
+}
+
+// QueryParam describes a query parameter accepted by Parse, for the API
+// docs.
+type QueryParam struct {
+	Name   string
+	Kind   Kind
+	Values []string // Allowed values, if restricted
+	Doc    string
+}
+
+// QueryParams lists what Parse accepts for spec: paging, sort and search,
+// then the filters by name.
+func (spec Spec) QueryParams() []QueryParam {
+	var sorts []string
+	for _, k := range sortKeys(spec) {
+		sorts = append(sorts, k, "-"+k)
+	}
+	params := []QueryParam{
+		{Name: "page", Kind: Number, Doc: "Page number, from 1. Without page or limit the whole list is returned"},
+		{Name: "limit", Kind: Number, Doc: fmt.Sprintf("Page size, default %d, at most %d", DefaultLimit, MaxLimit)},
+		{Name: "sort", Kind: Text, Values: sorts, Doc: "Sort key, \"-\" prefix for descending. Default " + spec.DefaultSort},
+	}
+	if len(spec.Search) > 0 {
+		params = append(params, QueryParam{Name: "q", Kind: Text, Doc: "Matches " + strings.Join(spec.Search, ", ")})
+	}
+
+	names := make([]string, 0, len(spec.Filters))
+	for name := range spec.Filters {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		f := spec.Filters[name]
+		var doc string
+		switch f.Op {
+		case ">=":
+			doc = "On or after"
+		case "<=":
+			doc = "On or before"
+		}
+		params = append(params, QueryParam{Name: name, Kind: f.Kind, Values: f.Values, Doc: doc})
+	}
+	return params
+}
+
+func sortKeys(spec Spec) []string {
+	keys := make([]string, 0, len(spec.Sorts))
+	for k := range spec.Sorts {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// Apply adds the search and filter conditions to db.
+func (p Params) Apply(db *gorm.DB, spec Spec) *gorm.DB {
+	for _, c := range p.conditions {
+		db = db.Where(c.sql, c.value)
+	}
+
+	if p.Search != "" {
+		pattern := "%" + escapeLike(strings.ToLower(p.Search)) + "%"
+		clauses := make([]string, len(spec.Search))
+		args := make([]any, len(spec.Search))
+		for i, column := range spec.Search {
+			clauses[i] = "LOWER(" + column + ") LIKE ? ESCAPE '!'"
+			args[i] = pattern
+		}
+		db = db.Where("("+strings.Join(clauses, " OR ")+")", args...)
+	}
+
+	return db
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(s)
+}
+
+// Find runs a list query: it applies p to db (which must have its Model
+// set), counts the matches and loads the requested page. Rows are ordered
+// by the sort column with the primary key as a tie-breaker so pages are
+// stable.
+func Find[T any](db *gorm.DB, spec Spec, p Params) (*Page[T], error) {
+	db = p.Apply(db, spec)
+
+	var total int64
+	if err := db.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	dir := " asc"
+	if p.Desc {
+		dir = " desc"
+	}
+	order := p.Sort + dir
+	if p.Sort != "id" {
+		order += ", id" + dir
+	}
+
+	db = db.Order(order)
+	if p.Limit > 0 {
+		db = db.Offset((p.Page - 1) * p.Limit).Limit(p.Limit)
+	}
+	data := []T{}
+	if err := db.Find(&data).Error; err != nil {
+		return nil, err
+	}
+
+	page := &Page[T]{Data: data, Total: total, Page: p.Page, Limit: p.Limit, Pages: 1}
+	if p.Limit > 0 {
+		page.Pages = (total + int64(p.Limit) - 1) / int64(p.Limit)
+	}
+	return page, nil
+}
@@ -0,0 +1,173 @@
+// Package lockout protects the login endpoint from password guessing.
+// Every attempt counts against the client address; every failure counts
+// against the account. Repeated failures first make the account wait
+// progressively longer between attempts and then lock it for a while.
+// Counters live in a Store shared by all replicas.
+package lockout
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gym-api/audit"
+	"gym-api/config"
+	"gym-api/models"
+)
+
+// Blocked is returned by Check while a login may not be attempted.
+type Blocked struct {
+	Kind       string // KindAccount or KindIP
+	Locked     bool   // Locked out, rather than asked to slow down
+	RetryAfter time.Duration
+}
+
+func (b *Blocked) Error() string {
+	if b.Locked {
+		return fmt.Sprintf("%s locked for %s", b.Kind, b.RetryAfter)
+	}
+	return fmt.Sprintf("%s must wait %s", b.Kind, b.RetryAfter)
+}
+
+// Limiter applies the login limits.
+type Limiter struct {
+	Store  Store
+	Limits config.LoginLimits
+}
+
+// Default is the limiter used by the login handler. It counts in memory
+// until Setup connects it to the database.
+var Default = &Limiter{Store: NewMemoryStore(), Limits: config.Login()}
+
+// Setup configures Default from the environment.
+func Setup() {
+	Default.Limits = config.Login()
+	if Default.Limits.Store == "memory" {
+		slog.Warn("Login limits are kept in memory; they are not shared between replicas")
+		return
+	}
+	Default.Store = WithFallback(NewDBStore(config.DB), Default.Store)
+}
+
+// Check counts an attempt from ip and returns a *Blocked error if ip or
+// the account for email may not try now. Call it before verifying the
+// password.
+func (l *Limiter) Check(ctx context.Context, email, ip string) error {
+	now := time.Now()
+
+	// 1. The address: every attempt counts
+	addr, err := l.Store.Hit(ctx, Key{KindIP, ip}, l.Limits.Window, now)
+	if err != nil {
+		return err
+	}
+	if blocked := lockedUntil(KindIP, addr, now); blocked != nil {
+		return blocked
+	}
+	if addr.Count > l.Limits.IPAttempts {
+		until := addr.WindowStart.Add(l.Limits.Window)
+		if err := l.Store.Lock(ctx, Key{KindIP, ip}, until); err != nil {
+			return err
+		}
+		audit.Record(models.AuditLog{
+			Action:  "auth.ip_blocked",
+			Subject: ip,
+			Detail:  fmt.Sprintf("%d login attempts within %s", addr.Count, l.Limits.Window),
+			IP:      ip,
+		})
+		return &Blocked{Kind: KindIP, Locked: true, RetryAfter: until.Sub(now)}
+	}
+
+	// 2. The account: locked, or still inside the wait after its last failure
+	account, found, err := l.Store.Get(ctx, Key{KindAccount, email})
+	if err != nil || !found {
+		return err
+	}
+	if blocked := lockedUntil(KindAccount, account, now); blocked != nil {
+		return blocked
+	}
+	if now.Sub(account.WindowStart) < l.Limits.Window {
+		if next := account.LastAt.Add(l.delay(account.Count)); now.Before(next) {
+			return &Blocked{Kind: KindAccount, RetryAfter: next.Sub(now)}
+		}
+	}
+	return nil
+}
+
+func lockedUntil(kind string, entry models.LoginThrottle, now time.Time) *Blocked {
+	if entry.LockedUntil != nil && entry.LockedUntil.After(now) {
+		return &Blocked{Kind: kind, Locked: true, RetryAfter: entry.LockedUntil.Sub(now)}
+	}
+	return nil
+}
+
+// delay is how long an account must wait after its nth failure: nothing up
+// to DelayAfter, then BaseDelay doubling up to MaxDelay.
+func (l *Limiter) delay(failures int) time.Duration {
+	if failures < l.Limits.DelayAfter {
+		return 0
+	}
+	d := l.Limits.BaseDelay
+	for i := l.Limits.DelayAfter; i < failures && d < l.Limits.MaxDelay; i++ {
+		d *= 2
+	}
+	return min(d, l.Limits.MaxDelay)
+}
+
+// Failed records a wrong password (or unknown email) for email from ip and
+// locks the account once it reaches MaxFailures. userID is the account, if
+// it exists. Unknown emails are counted the same way so responses do not
+// reveal which accounts exist.
+func (l *Limiter) Failed(ctx context.Context, email, ip string, userID *uint) {
+	now := time.Now()
+	entry, err := l.Store.Hit(ctx, Key{KindAccount, email}, l.Limits.Window, now)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to count login failure", "email", email, "error", err)
+		return
+	}
+
+	audit.Record(models.AuditLog{
+		Action:  "auth.login_failed",
+		ActorID: userID,
+		Subject: email,
+		Detail:  fmt.Sprintf("failure %d of %d", entry.Count, l.Limits.MaxFailures),
+		IP:      ip,
+	})
+
+	if entry.Count >= l.Limits.MaxFailures {
+		if err := l.Store.Lock(ctx, Key{KindAccount, email}, now.Add(l.Limits.LockDuration)); err != nil {
+			slog.ErrorContext(ctx, "Failed to lock account", "email", email, "error", err)
+			return
+		}
+		audit.Record(models.AuditLog{
+			Action:  "auth.account_locked",
+			ActorID: userID,
+			Subject: email,
+			Detail:  fmt.Sprintf("locked for %s after %d failures", l.Limits.LockDuration, entry.Count),
+			IP:      ip,
+		})
+	}
+}
+
+// Succeeded forgets the account's failures after a correct password.
+func (l *Limiter) Succeeded(ctx context.Context, email string) {
+	if err := l.Store.Clear(ctx, Key{KindAccount, email}); err != nil {
+		slog.ErrorContext(ctx, "Failed to reset login failures", "email", email, "error", err)
+	}
+}
+
+// Locked lists the accounts and addresses locked out now.
+func (l *Limiter) Locked(ctx context.Context) ([]models.LoginThrottle, error) {
+	return l.Store.Locked(ctx, time.Now())
+}
+
+// Clear lifts a lockout and resets its counter.
+func (l *Limiter) Clear(ctx context.Context, key Key) error {
+	return l.Store.Clear(ctx, key)
+}
+
+// Prune drops counters that have been idle for a day. Run it as a job.
+func Prune(ctx context.Context) error {
+	now := time.Now()
+	return Default.Store.Prune(ctx, now.Add(-24*time.Hour), now)
+}
@@ -0,0 +1,269 @@
+package lockout
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gym-api/config"
+	"gym-api/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// useDB points config.DB, which the audit log writes to, at an in-memory
+// database and returns it.
+func useDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard, TranslateError: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(&models.LoginThrottle{}, &models.AuditLog{}); err != nil {
+		t.Fatal(err)
+	}
+	previous := config.DB
+	config.DB = db
+	t.Cleanup(func() {
+		config.DB = previous
+		sqlDB.Close()
+	})
+	return db
+}
+
+var testLimits = config.LoginLimits{
+	MaxFailures:  5,
+	DelayAfter:   2,
+	BaseDelay:    time.Minute,
+	MaxDelay:     4 * time.Minute,
+	Window:       time.Hour,
+	LockDuration: 15 * time.Minute,
+	IPAttempts:   30,
+}
+
+// stores runs test against the database and the memory store.
+func stores(t *testing.T, test func(t *testing.T, store Store)) {
+	t.Run("database", func(t *testing.T) { test(t, NewDBStore(useDB(t))) })
+	t.Run("memory", func(t *testing.T) {
+		useDB(t)
+		test(t, NewMemoryStore())
+	})
+}
+
+func blocked(t *testing.T, err error) *Blocked {
+	t.Helper()
+	var b *Blocked
+	if !errors.As(err, &b) {
+		t.Fatalf("err = %v, want a *Blocked", err)
+	}
+	return b
+}
+
+func TestDelay(t *testing.T) {
+	l := &Limiter{Limits: testLimits}
+	want := []time.Duration{0, 0, time.Minute, 2 * time.Minute, 4 * time.Minute, 4 * time.Minute}
+	for failures, w := range want {
+		if got := l.delay(failures); got != w {
+			t.Errorf("delay(%d) = %s, want %s", failures, got, w)
+		}
+	}
+}
+
+func TestProgressiveDelay(t *testing.T) {
+	stores(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		l := &Limiter{Store: store, Limits: testLimits}
+
+		// Failures up to DelayAfter may retry at once
+		for range testLimits.DelayAfter - 1 {
+			l.Failed(ctx, "sam@example.com", "10.0.0.1", nil)
+			if err := l.Check(ctx, "sam@example.com", "10.0.0.1"); err != nil {
+				t.Fatalf("Check after %d failure: %v", testLimits.DelayAfter-1, err)
+			}
+		}
+
+		l.Failed(ctx, "sam@example.com", "10.0.0.1", nil)
+		b := blocked(t, l.Check(ctx, "sam@example.com", "10.0.0.1"))
+		if b.Kind != KindAccount || b.Locked || b.RetryAfter <= 0 || b.RetryAfter > testLimits.BaseDelay {
+			t.Errorf("after %d failures: %+v, want a wait of up to %s", testLimits.DelayAfter, b, testLimits.BaseDelay)
+		}
+
+		l.Failed(ctx, "sam@example.com", "10.0.0.1", nil)
+		b = blocked(t, l.Check(ctx, "sam@example.com", "10.0.0.1"))
+		if b.RetryAfter <= testLimits.BaseDelay {
+			t.Errorf("after %d failures: wait %s, want it doubled", testLimits.DelayAfter+1, b.RetryAfter)
+		}
+
+		// Other accounts are not slowed down
+		if err := l.Check(ctx, "kim@example.com", "10.0.0.1"); err != nil {
+			t.Errorf("other account: %v", err)
+		}
+	})
+}
+
+func TestLockAfterMaxFailures(t *testing.T) {
+	stores(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		limits := testLimits
+		limits.DelayAfter = 100
+		l := &Limiter{Store: store, Limits: limits}
+
+		for range limits.MaxFailures - 1 {
+			l.Failed(ctx, "sam@example.com", "10.0.0.1", nil)
+		}
+		if err := l.Check(ctx, "sam@example.com", "10.0.0.1"); err != nil {
+			t.Fatalf("Check before the last failure: %v", err)
+		}
+
+		l.Failed(ctx, "sam@example.com", "10.0.0.1", nil)
+		b := blocked(t, l.Check(ctx, "sam@example.com", "10.0.0.1"))
+		if b.Kind != KindAccount || !b.Locked || b.RetryAfter > limits.LockDuration || b.RetryAfter < limits.LockDuration-time.Minute {
+			t.Errorf("after %d failures: %+v, want locked for %s", limits.MaxFailures, b, limits.LockDuration)
+		}
+
+		locked, err := l.Locked(ctx)
+		if err != nil || len(locked) != 1 || locked[0].Subject != "sam@example.com" {
+			t.Errorf("Locked = %+v, %v", locked, err)
+		}
+
+		// An admin lifts it
+		if err := l.Clear(ctx, Key{KindAccount, "sam@example.com"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := l.Check(ctx, "sam@example.com", "10.0.0.1"); err != nil {
+			t.Errorf("Check after Clear: %v", err)
+		}
+	})
+}
+
+func TestIPLimit(t *testing.T) {
+	stores(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		limits := testLimits
+		limits.IPAttempts = 3
+		l := &Limiter{Store: store, Limits: limits}
+
+		// Every attempt counts, whichever account and whether it failed
+		for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+			if err := l.Check(ctx, email, "10.0.0.1"); err != nil {
+				t.Fatalf("attempt for %s: %v", email, err)
+			}
+		}
+		b := blocked(t, l.Check(ctx, "d@example.com", "10.0.0.1"))
+		if b.Kind != KindIP || !b.Locked {
+			t.Errorf("attempt %d: %+v, want the address locked", limits.IPAttempts+1, b)
+		}
+		if b := blocked(t, l.Check(ctx, "a@example.com", "10.0.0.1")); b.Kind != KindIP {
+			t.Errorf("next attempt: %+v, want the address still locked", b)
+		}
+
+		if err := l.Check(ctx, "a@example.com", "10.0.0.2"); err != nil {
+			t.Errorf("other address: %v", err)
+		}
+	})
+}
+
+func TestSucceededResetsFailures(t *testing.T) {
+	stores(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		l := &Limiter{Store: store, Limits: testLimits}
+
+		for range testLimits.DelayAfter {
+			l.Failed(ctx, "sam@example.com", "10.0.0.1", nil)
+		}
+		l.Succeeded(ctx, "sam@example.com")
+
+		if err := l.Check(ctx, "sam@example.com", "10.0.0.1"); err != nil {
+			t.Errorf("Check after success: %v", err)
+		}
+		if _, found, err := store.Get(ctx, Key{KindAccount, "sam@example.com"}); found || err != nil {
+			t.Errorf("counter kept after success: found = %v, err = %v", found, err)
+		}
+	})
+}
+
+func TestHitStartsNewWindow(t *testing.T) {
+	stores(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		key := Key{KindIP, "10.0.0.1"}
+		start := time.Now().Add(-2 * time.Hour)
+
+		store.Hit(ctx, key, time.Hour, start)
+		entry, err := store.Hit(ctx, key, time.Hour, start.Add(time.Minute))
+		if err != nil || entry.Count != 2 {
+			t.Fatalf("second hit: %+v, %v; want count 2", entry, err)
+		}
+		entry, err = store.Hit(ctx, key, time.Hour, start.Add(time.Hour))
+		if err != nil || entry.Count != 1 || !entry.WindowStart.Equal(start.Add(time.Hour)) {
+			t.Errorf("hit after the window: %+v, %v; want a new window", entry, err)
+		}
+	})
+}
+
+func TestPrune(t *testing.T) {
+	stores(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		now := time.Now()
+		store.Hit(ctx, Key{KindIP, "idle"}, time.Hour, now.Add(-48*time.Hour))
+		store.Hit(ctx, Key{KindIP, "locked"}, time.Hour, now.Add(-48*time.Hour))
+		store.Lock(ctx, Key{KindIP, "locked"}, now.Add(time.Hour))
+		store.Hit(ctx, Key{KindIP, "recent"}, time.Hour, now)
+
+		if err := store.Prune(ctx, now.Add(-24*time.Hour), now); err != nil {
+			t.Fatal(err)
+		}
+		for subject, kept := range map[string]bool{"idle": false, "locked": true, "recent": true} {
+			if _, found, _ := store.Get(ctx, Key{KindIP, subject}); found != kept {
+				t.Errorf("%s: kept = %v, want %v", subject, found, kept)
+			}
+		}
+	})
+}
+
+// brokenStore fails every call, like a database that is down.
+type brokenStore struct{}
+
+var errDown = errors.New("connection refused")
+
+func (brokenStore) Hit(context.Context, Key, time.Duration, time.Time) (models.LoginThrottle, error) {
+	return models.LoginThrottle{}, errDown
+}
+func (brokenStore) Get(context.Context, Key) (models.LoginThrottle, bool, error) {
+	return models.LoginThrottle{}, false, errDown
+}
+func (brokenStore) Lock(context.Context, Key, time.Time) error { return errDown }
+func (brokenStore) Clear(context.Context, Key) error           { return errDown }
+func (brokenStore) Locked(context.Context, time.Time) ([]models.LoginThrottle, error) {
+	return nil, errDown
+}
+func (brokenStore) Prune(context.Context, time.Time, time.Time) error { return errDown }
+
+func TestFallbackToMemory(t *testing.T) {
+	useDB(t)
+	ctx := context.Background()
+	limits := testLimits
+	limits.DelayAfter = 100
+	l := &Limiter{Store: WithFallback(brokenStore{}, NewMemoryStore()), Limits: limits}
+
+	// The limits still hold, per process
+	for range limits.MaxFailures {
+		l.Failed(ctx, "sam@example.com", "10.0.0.1", nil)
+	}
+	if b := blocked(t, l.Check(ctx, "sam@example.com", "10.0.0.1")); b.Kind != KindAccount || !b.Locked {
+		t.Errorf("after %d failures: %+v, want the account locked", limits.MaxFailures, b)
+	}
+
+	// A correct password resets the counter kept in memory
+	l.Succeeded(ctx, "sam@example.com")
+	if err := l.Check(ctx, "sam@example.com", "10.0.0.2"); err != nil {
+		t.Errorf("Check after success: %v", err)
+	}
+}
@@ -0,0 +1,250 @@
+package lockout
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"gym-api/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Key identifies what is being throttled.
+type Key struct {
+	Kind    string // KindAccount or KindIP
+	Subject string // Email or address
+}
+
+const (
+	KindAccount = "account"
+	KindIP      = "ip"
+)
+
+// Store keeps the counters. The database store is shared by every replica;
+// the memory store is per process.
+type Store interface {
+	// Hit counts one event for key, starting a new window when the current
+	// one is older than window, and returns the updated counter.
+	Hit(ctx context.Context, key Key, window time.Duration, now time.Time) (models.LoginThrottle, error)
+	// Get returns the counter for key; found is false if there is none.
+	Get(ctx context.Context, key Key) (entry models.LoginThrottle, found bool, err error)
+	Lock(ctx context.Context, key Key, until time.Time) error
+	Clear(ctx context.Context, key Key) error
+	// Locked lists the counters locked beyond now.
+	Locked(ctx context.Context, now time.Time) ([]models.LoginThrottle, error)
+	// Prune drops counters last hit before cutoff that are not locked.
+	Prune(ctx context.Context, cutoff, now time.Time) error
+}
+
+// -- Database --
+
+type dbStore struct {
+	db *gorm.DB
+}
+
+func NewDBStore(db *gorm.DB) Store {
+	return dbStore{db: db}
+}
+
+func (s dbStore) where(ctx context.Context, key Key) *gorm.DB {
+	return s.db.WithContext(ctx).Where("kind = ? AND subject = ?", key.Kind, key.Subject)
+}
+
+func (s dbStore) Hit(ctx context.Context, key Key, window time.Duration, now time.Time) (models.LoginThrottle, error) {
+	var entry models.LoginThrottle
+	var err error
+	// A second try covers two replicas creating the same row at once
+	for attempt := 0; attempt < 2; attempt++ {
+		err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			entry = models.LoginThrottle{}
+			err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("kind = ? AND subject = ?", key.Kind, key.Subject).
+				First(&entry).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				entry = models.LoginThrottle{Kind: key.Kind, Subject: key.Subject, Count: 1, WindowStart: now, LastAt: now}
+				return tx.Create(&entry).Error
+			}
+			if err != nil {
+				return err
+			}
+
+			if now.Sub(entry.WindowStart) >= window {
+				entry.Count, entry.WindowStart = 0, now
+			}
+			entry.Count++
+			entry.LastAt = now
+			return tx.Save(&entry).Error
+		})
+		if !errors.Is(err, gorm.ErrDuplicatedKey) {
+			break
+		}
+	}
+	return entry, err
+}
+
+func (s dbStore) Get(ctx context.Context, key Key) (models.LoginThrottle, bool, error) {
+	var entry models.LoginThrottle
+	err := s.where(ctx, key).First(&entry).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return entry, false, nil
+	}
+	return entry, err == nil, err
+}
+
+func (s dbStore) Lock(ctx context.Context, key Key, until time.Time) error {
+	return s.where(ctx, key).Model(&models.LoginThrottle{}).Update("locked_until", until).Error
+}
+
+func (s dbStore) Clear(ctx context.Context, key Key) error {
+	return s.where(ctx, key).Delete(&models.LoginThrottle{}).Error
+}
+
+func (s dbStore) Locked(ctx context.Context, now time.Time) ([]models.LoginThrottle, error) {
+	entries := []models.LoginThrottle{}
+	err := s.db.WithContext(ctx).Where("locked_until > ?", now).Order("locked_until desc").Find(&entries).Error
+	return entries, err
+}
+
+func (s dbStore) Prune(ctx context.Context, cutoff, now time.Time) error {
+	return s.db.WithContext(ctx).
+		Where("last_at < ? AND (locked_until IS NULL OR locked_until <= ?)", cutoff, now).
+		Delete(&models.LoginThrottle{}).Error
+}
+
+// -- Memory --
+
+type memoryStore struct {
+	mu      sync.Mutex
+	entries map[Key]models.LoginThrottle
+}
+
+func NewMemoryStore() Store {
+	return &memoryStore{entries: map[Key]models.LoginThrottle{}}
+}
+
+func (s *memoryStore) Hit(_ context.Context, key Key, window time.Duration, now time.Time) (models.LoginThrottle, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[key]
+	if !ok || now.Sub(entry.WindowStart) >= window {
+		entry.Kind, entry.Subject = key.Kind, key.Subject
+		entry.Count, entry.WindowStart = 0, now
+	}
+	entry.Count++
+	entry.LastAt = now
+	s.entries[key] = entry
+	return entry, nil
+}
+
+func (s *memoryStore) Get(_ context.Context, key Key) (models.LoginThrottle, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[key]
+	return entry, ok, nil
+}
+
+func (s *memoryStore) Lock(_ context.Context, key Key, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry, ok := s.entries[key]; ok {
+		entry.LockedUntil = &until
+		s.entries[key] = entry
+	}
+	return nil
+}
+
+func (s *memoryStore) Clear(_ context.Context, key Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
+
+func (s *memoryStore) Locked(_ context.Context, now time.Time) ([]models.LoginThrottle, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries := []models.LoginThrottle{}
+	for _, entry := range s.entries {
+		if entry.LockedUntil != nil && entry.LockedUntil.After(now) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+func (s *memoryStore) Prune(_ context.Context, cutoff, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, entry := range s.entries {
+		if entry.LastAt.Before(cutoff) && (entry.LockedUntil == nil || !entry.LockedUntil.After(now)) {
+			delete(s.entries, key)
+		}
+	}
+	return nil
+}
+
+// -- Fallback --
+
+// fallbackStore uses primary and, for calls that fail (e.g. while the
+// database is unreachable), secondary. Limits then hold per replica
+// instead of not at all.
+type fallbackStore struct {
+	primary, secondary Store
+}
+
+func WithFallback(primary, secondary Store) Store {
+	return fallbackStore{primary: primary, secondary: secondary}
+}
+
+func (s fallbackStore) failed(op string, err error) {
+	slog.Error("Login limit store failed, using the in-memory store", "op", op, "error", err)
+}
+
+func (s fallbackStore) Hit(ctx context.Context, key Key, window time.Duration, now time.Time) (models.LoginThrottle, error) {
+	entry, err := s.primary.Hit(ctx, key, window, now)
+	if err != nil {
+		s.failed("hit", err)
+		return s.secondary.Hit(ctx, key, window, now)
+	}
+	return entry, nil
+}
+
+func (s fallbackStore) Get(ctx context.Context, key Key) (models.LoginThrottle, bool, error) {
+	entry, found, err := s.primary.Get(ctx, key)
+	if err != nil {
+		s.failed("get", err)
+		return s.secondary.Get(ctx, key)
+	}
+	return entry, found, nil
+}
+
+// Lock, Clear and Prune apply to both stores: the counter may have been
+// hit in either.
+func (s fallbackStore) Lock(ctx context.Context, key Key, until time.Time) error {
+	if err := s.primary.Lock(ctx, key, until); err != nil {
+		s.failed("lock", err)
+	}
+	return s.secondary.Lock(ctx, key, until)
+}
+
+func (s fallbackStore) Clear(ctx context.Context, key Key) error {
+	return errors.Join(s.primary.Clear(ctx, key), s.secondary.Clear(ctx, key))
+}
+
+func (s fallbackStore) Locked(ctx context.Context, now time.Time) ([]models.LoginThrottle, error) {
+	entries, err := s.primary.Locked(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+	local, _ := s.secondary.Locked(ctx, now)
+	return append(entries, local...), nil
+}
+
+func (s fallbackStore) Prune(ctx context.Context, cutoff, now time.Time) error {
+	return errors.Join(s.primary.Prune(ctx, cutoff, now), s.secondary.Prune(ctx, cutoff, now))
+}
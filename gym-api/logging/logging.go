@@ -0,0 +1,58 @@
+// Package logging sets up log/slog as the structured logger. Lines logged
+// with a request's context (c.UserContext()) carry its request ID, user and
+// trace IDs, so one request can be followed through the log.
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"slices"
+
+	"gym-api/config"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+type contextKey struct{}
+
+// With returns a copy of ctx whose log lines also carry args, given as
+// key-value pairs like slog.Info's.
+func With(ctx context.Context, args ...any) context.Context {
+	previous, _ := ctx.Value(contextKey{}).([]any)
+	return context.WithValue(ctx, contextKey{}, append(slices.Clip(previous), args...))
+}
+
+// Setup makes slog the default logger, for the log package too: JSON
+// lines on stdout at LOG_LEVEL, or text with LOG_FORMAT=text.
+func Setup() {
+	options := &slog.HandlerOptions{Level: config.LogLevel()}
+	var handler slog.Handler = slog.NewJSONHandler(os.Stdout, options)
+	if config.LogFormat() == "text" {
+		handler = slog.NewTextHandler(os.Stdout, options)
+	}
+	slog.SetDefault(slog.New(contextHandler{handler}))
+}
+
+// contextHandler adds the attributes from With and the current span.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if args, ok := ctx.Value(contextKey{}).([]any); ok {
+		r.Add(args...)
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		r.AddAttrs(slog.String("trace_id", span.TraceID().String()), slog.String("span_id", span.SpanID().String()))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
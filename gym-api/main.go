@@ -1,48 +1,231 @@
 package main
 
 import (
-	"log"
+	"context"
+	"log/slog"
+	"os"
+	"time"
 
+	"gym-api/apperror"
+	"gym-api/churn"
 	"gym-api/config"
+	"gym-api/controllers"
+	"gym-api/events"
+	"gym-api/gates"
+	"gym-api/idempotency"
+	"gym-api/jobs"
+	"gym-api/lifecycle"
+	"gym-api/lockout"
+	"gym-api/logging"
+	"gym-api/metrics"
+	"gym-api/middleware"
+	"gym-api/migrations"
 	"gym-api/models"
+	"gym-api/notifications"
+	"gym-api/oidc"
+	"gym-api/repository"
 	"gym-api/routes"
+	"gym-api/search"
+	"gym-api/services"
+	"gym-api/storage"
+	"gym-api/tracing"
+	"gym-api/twofactor"
 	"gym-api/utils"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
-	"github.com/gofiber/fiber/v2/middleware/logger"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
 )
 
 func main() {
+	// Structured logs first, so everything below is logged the same way
+	logging.Setup()
+	tracing.Setup()
+
 	// 1. Connect to Database
 	config.ConnectDB()
+	if err := config.DB.Use(tracing.GORM{}); err != nil {
+		slog.Error("Failed to trace database queries", "error", err)
+		os.Exit(1)
+	}
 
 	// 2. Auto Migrate
-	err := config.DB.AutoMigrate(&models.User{}, &models.Attendance{}, &models.Package{})
+	err := config.DB.AutoMigrate(models.Tables()...)
 	if err != nil {
-		log.Fatal("Migration failed: ", err)
+		slog.Error("Migration failed", "error", err)
+		os.Exit(1)
+	}
+	if err := migrations.Run(config.DB); err != nil {
+		slog.Error("Data migration failed", "error", err)
+		os.Exit(1)
 	}
 
 	// 3. Seed Data
 	utils.SeedAdmin()
 
-	// 3. Setup Fiber
+	// The process stops on SIGTERM: requests drain, jobs finish, queued
+	// notifications and spans go out and the pool closes, in that order
+	mode := config.RunMode()
+	process := lifecycle.New(config.ShutdownTimeout())
+	process.OnStop("tracing", tracing.Shutdown)
+	process.OnStop("database", func(context.Context) error { return config.CloseDB() })
+
+	// Outbound integrations
+	storage.Setup()
+	notifications.Setup()
+	process.OnStop("notifications", notifications.Wait)
+
+	// Share login limits between replicas
+	lockout.Setup()
+
+	// Staff single sign-on, if an OIDC provider is configured
+	oidc.Setup()
+
+	// The live feed goes through the database, so events published by the
+	// worker or another replica reach every API process's streams
+	if err := events.Setup(mode); err != nil {
+		slog.Error("Refusing to start", "error", err)
+		os.Exit(1)
+	}
+
+	// Database pool stats for /metrics, and a listener of its own for them
+	// if configured
+	metrics.Setup()
+	if addr := config.MetricsAddr(); addr != "" {
+		process.Go(lifecycle.Worker{Name: "metrics", Run: metrics.Serve(addr)})
+	}
+
+	// Door controllers answer the scans the API serves. Line gates take one
+	// connection, which the worker holds; API-only processes queue their
+	// decisions for it. Gates edited through another process are picked up
+	// by the periodic reload
+	gates.Default.OwnsLines = mode.Worker()
+	gates.Setup()
+	process.Go(lifecycle.Worker{Name: "gates-reload", Run: gates.Default.Refresh})
+	process.OnStop("gates", gates.Default.Close)
+
+	if mode.API() {
+		if err := config.CheckProxy(); err != nil {
+			slog.Error("Refusing to start", "error", err)
+			os.Exit(1)
+		}
+		process.Go(lifecycle.Worker{Name: "api", Run: api()})
+		process.Go(lifecycle.Worker{Name: "live-feed", Run: events.Hub.Relay})
+	}
+
+	// Background jobs
+	if mode.Worker() {
+		process.Go(lifecycle.Worker{Name: "gates", Run: gates.Default.Deliver})
+		process.Go(lifecycle.Worker{Name: "jobs", Run: func(ctx context.Context) error {
+			return jobs.Run(ctx,
+				jobs.Job{
+					Name:     "expiry-reminders",
+					Interval: time.Hour,
+					Run:      notifications.SendExpiryReminders,
+				},
+				jobs.Job{
+					Name:     "login-throttle-prune",
+					Interval: time.Hour,
+					Run:      lockout.Prune,
+				},
+				jobs.Job{
+					Name:     "sso-login-prune",
+					Interval: time.Hour,
+					Run:      oidc.Prune,
+				},
+				jobs.Job{
+					Name:     "idempotency-key-prune",
+					Interval: time.Hour,
+					Run:      idempotency.Prune,
+				},
+				jobs.Job{
+					Name:     "live-event-prune",
+					Interval: time.Hour,
+					Run:      events.Prune,
+				},
+				jobs.Job{
+					Name:     "gate-prune",
+					Interval: time.Hour,
+					Run:      gates.Prune,
+				},
+				jobs.Job{
+					Name:     "churn-scoring",
+					Interval: config.Churn().Interval,
+					Run:      churn.Run,
+				},
+			)
+		}})
+	}
+
+	slog.Info("Starting", "mode", mode)
+	if err := process.Run(); err != nil {
+		os.Exit(1)
+	}
+}
+
+// api sets up the HTTP server and returns a worker that serves until its
+// context is cancelled and then drains the requests in flight.
+func api() func(ctx context.Context) error {
+	// Keep the member search index in step with user writes
+	search.Setup()
+
+	// 1. Setup Fiber
 	app := fiber.New(fiber.Config{
-		BodyLimit: 100 * 1024 * 1024, // 100MB
+		BodyLimit:    10 * 1024 * 1024, // 10MB (photos are capped at 5MB by storage.MaxPhotoBytes)
+		ErrorHandler: apperror.Handler,
+		// Client addresses behind a load balancer, for per-IP login limits
+		ProxyHeader:             config.ProxyHeader(),
+		EnableIPValidation:      true,
+		EnableTrustedProxyCheck: len(config.TrustedProxies()) > 0,
+		TrustedProxies:          config.TrustedProxies(),
 	})
-	app.Use(logger.New())
+	// Checked by config.CheckProxy before api runs
+	trusted, _ := config.TrustedProxyRanges()
+	app.Use(middleware.ClientAddress(config.ProxyHeader(), trusted))
+	app.Use(requestid.New())
+	app.Use(middleware.Trace())
+	app.Use(middleware.Metrics())
+	app.Use(middleware.RequestLog())
 	app.Use(cors.New())
 
 	app.Get("/", func(c *fiber.Ctx) error {
 		return c.SendString("Gym Management API is running!")
 	})
 
-	// Serve Static Files (Profile Pictures)
-	app.Static("/uploads", "./uploads")
+	// Serve Static Files (Profile Pictures stored on local disk)
+	if local, ok := storage.Default.(*storage.Local); ok {
+		app.Static(local.BaseURL, local.Dir)
+	}
 
-	// 4. Setup Routes
-	routes.SetupRoutes(app)
+	// 2. Setup Routes. Handlers get their services here; the services get
+	// the database and everything else that leaves the process.
+	repos := repository.New(config.DB)
+	twofactor.Default.Use(repos.TwoFactor)
+	handlers := controllers.NewHandlers(services.New(repos, services.Deps{
+		Notify:  notifications.Dispatch,
+		Email:   notifications.SendEmail,
+		Feed:    services.LiveFeed{},
+		Gates:   gates.Default,
+		Limiter: lockout.Default,
+		Policy:  twofactor.Default,
+		SSO:     oidc.Default,
+		Index:   search.Members,
+		Reports: config.DB,
+	}))
+	routes.SetupRoutes(app, handlers)
 
-	// 5. Start Server
-	log.Fatal(app.Listen(":8080"))
+	// 3. Serve. Live feed streams would hold the drain up until the
+	// deadline, so they are ended first; clients reconnect elsewhere. The
+	// drain gets part of the shutdown budget, the stop hooks the rest.
+	return func(ctx context.Context) error {
+		served := make(chan error, 1)
+		go func() { served <- app.Listen(config.ListenAddr()) }()
+		select {
+		case err := <-served:
+			return err
+		case <-ctx.Done():
+		}
+		events.Hub.Close()
+		return app.ShutdownWithTimeout(config.DrainTimeout())
+	}
 }
@@ -0,0 +1,93 @@
+// Package metrics holds the Prometheus metrics served on /metrics, and the
+// metrics listener for processes that keep them off the API port.
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"gym-api/config"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry holds the metrics this process exposes: the ones below, the Go
+// runtime and process stats, and the database pool once Setup has run.
+var Registry = prometheus.NewRegistry()
+
+var factory = promauto.With(Registry)
+
+// DurationBuckets are the upper bounds, in seconds, used for request
+// latencies.
+var DurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Request and business metrics. Label values must come from a fixed set
+// (route patterns, error codes, package names), never from user input.
+var (
+	RequestDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gym_http_request_duration_seconds",
+		Help:    "Time to serve a request, by route pattern and status.",
+		Buckets: DurationBuckets,
+	}, []string{"method", "route", "status"})
+
+	Scans = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "gym_scans_total",
+		Help: "QR scans by result (admitted or denied); reason is the refusal code.",
+	}, []string{"result", "reason"})
+	Logins = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "gym_logins_total",
+		Help: "Login attempts by method (password, 2fa, sso) and result (success, failure, throttled).",
+	}, []string{"method", "result"})
+	SubscriptionsSold = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "gym_subscriptions_sold_total",
+		Help: "Packages sold, by package name.",
+	}, []string{"package"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Setup registers the database connection pool stats, as go_sql_* with
+// db_name="gym". Call it after config.ConnectDB.
+func Setup() {
+	sqlDB, err := config.DB.DB()
+	if err != nil {
+		slog.Warn("Database pool metrics unavailable", "error", err)
+		return
+	}
+	Registry.MustRegister(collectors.NewDBStatsCollector(sqlDB, "gym"))
+}
+
+// Handler serves Registry in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// Serve returns a worker that serves /metrics on addr, a port meant to be
+// reachable only by the scraper, until its context is cancelled.
+func Serve(addr string) func(ctx context.Context) error {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", Handler())
+	server := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	return func(ctx context.Context) error {
+		failed := make(chan error, 1)
+		go func() { failed <- server.ListenAndServe() }()
+		select {
+		case err := <-failed:
+			return err
+		case <-ctx.Done():
+		}
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		return server.Shutdown(shutdownCtx)
+	}
+}
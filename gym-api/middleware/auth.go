@@ -3,6 +3,10 @@ package middleware
 import (
 	"strings"
 
+	"gym-api/apperror"
+	"gym-api/logging"
+	"gym-api/models"
+	"gym-api/twofactor"
 	"gym-api/utils"
 
 	"github.com/gofiber/fiber/v2"
@@ -11,34 +15,53 @@ import (
 func Protected() fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		authHeader := c.Get("Authorization")
+		// EventSource cannot set headers, so the live feed may pass the token in the query string
+		if authHeader == "" && c.Query("access_token") != "" && strings.Contains(c.Get("Accept"), "text/event-stream") {
+			authHeader = "Bearer " + c.Query("access_token")
+		}
 		if authHeader == "" {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Missing or malformed JWT"})
+			return apperror.Unauthorized("Missing or malformed JWT")
 		}
 
 		parts := strings.Split(authHeader, " ")
 		if len(parts) != 2 || parts[0] != "Bearer" {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid token format"})
+			return apperror.Unauthorized("Invalid token format")
 		}
 
 		tokenString := parts[1]
 		claims, err := utils.ValidateToken(tokenString)
 		if err != nil {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid or expired token"})
+			return apperror.Unauthorized("Invalid or expired token")
 		}
 
 		// Store claims in local context for controllers to use
 		c.Locals("user_id", claims.UserID)
 		c.Locals("role", claims.Role)
+		c.SetUserContext(logging.With(c.UserContext(), "user_id", claims.UserID, "role", claims.Role))
+
+		// Roles that must use 2FA get no further than enrolment with a
+		// password alone
+		if !claims.TwoFactor && twofactor.Default.Required(c.UserContext(), models.Role(claims.Role)) && !twoFactorSetupPaths[c.Path()] {
+			return apperror.New(fiber.StatusForbidden, apperror.CodeTwoFactorNeeded,
+				"Two-factor authentication is required for your role, set it up to continue")
+		}
 
 		return c.Next()
 	}
 }
 
+// Routes a user who must set up 2FA can still reach, to do so.
+var twoFactorSetupPaths = map[string]bool{
+	"/api/auth/me":         true,
+	"/api/auth/2fa/setup":  true,
+	"/api/auth/2fa/enable": true,
+}
+
 func AdminOnly() fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		role := c.Locals("role")
 		if role != "admin" {
-			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Admin access required"})
+			return apperror.Forbidden("Admin access required")
 		}
 		return c.Next()
 	}
@@ -48,7 +71,7 @@ func AdminOrStaffOnly() fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		role := c.Locals("role")
 		if role != "admin" && role != "staff" {
-			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Admin or Staff access required"})
+			return apperror.Forbidden("Admin or Staff access required")
 		}
 		return c.Next()
 	}
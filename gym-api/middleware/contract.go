@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"log/slog"
+	"strings"
+
+	"gym-api/openapi"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Conform checks JSON responses against the API description and logs the
+// ones a handler shaped differently from what clients were promised.
+// Errors are rendered after the chain returns, so only successful
+// responses are seen here.
+func Conform(doc *openapi.Document) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if err := c.Next(); err != nil {
+			return err
+		}
+
+		res := c.Response()
+		if !strings.HasPrefix(string(res.Header.ContentType()), fiber.MIMEApplicationJSON) {
+			return nil
+		}
+		route := c.Route()
+		if err := doc.Conform(c.Method(), route.Path, res.StatusCode(), res.Body()); err != nil {
+			slog.WarnContext(c.UserContext(), "Response does not match the API description", "method", c.Method(), "route", route.Path, "error", err)
+		}
+		return nil
+	}
+}
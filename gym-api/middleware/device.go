@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"log/slog"
+	"time"
+
+	"gym-api/apperror"
+	"gym-api/config"
+	"gym-api/logging"
+	"gym-api/models"
+	"gym-api/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// DeviceAuth authenticates kiosk devices by the X-Device-Key header and
+// records that the device was seen.
+func DeviceAuth() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := c.Get("X-Device-Key")
+		if key == "" {
+			return apperror.Unauthorized("Missing device key")
+		}
+
+		var device models.Device
+		if err := config.DB.WithContext(c.UserContext()).Where("key_hash = ?", utils.HashAPIKey(key)).First(&device).Error; err != nil {
+			return apperror.Unauthorized("Invalid device key")
+		}
+		if device.RevokedAt != nil {
+			return apperror.Unauthorized("Device has been revoked")
+		}
+
+		// Avoid a write on every request; the online window is minutes
+		now := time.Now()
+		if device.LastSeenAt == nil || now.Sub(*device.LastSeenAt) > 15*time.Second {
+			if err := config.DB.WithContext(c.UserContext()).Model(&device).Update("last_seen_at", now).Error; err != nil {
+				slog.WarnContext(c.UserContext(), "Could not record device last seen", "device_id", device.ID, "error", err)
+			}
+			device.LastSeenAt = &now
+		}
+
+		c.Locals("device", &device)
+		c.SetUserContext(logging.With(c.UserContext(), "device_id", device.ID))
+		return c.Next()
+	}
+}
@@ -0,0 +1,159 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"mime/multipart"
+	"slices"
+	"strings"
+
+	"gym-api/apperror"
+	"gym-api/idempotency"
+	"gym-api/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// IdempotencyKeyHeader lets a client retry a POST safely: a repeat with the
+// same key gets the stored response instead of running again.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+const maxIdempotencyKey = 255
+
+const noStoreLocal = "idempotency_no_store"
+
+// NoStore keeps the response to a route out of the idempotency store, for
+// routes that answer with a credential, such as a device API key, which
+// must not sit in the database in plaintext. The key is released after the
+// request, so a retry runs it again and issues a new credential.
+func NoStore() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Locals(noStoreLocal, true)
+		return c.Next()
+	}
+}
+
+// Idempotency replays the stored response to a POST sent again with the
+// same Idempotency-Key by the same user or kiosk. Requests without the
+// header are not affected. Use it after Protected or DeviceAuth.
+//
+// Responses below 500 are stored, errors included, since running the
+// request again would give the same answer; after a 5xx the key is freed
+// so the retry runs. Routes behind NoStore are never stored.
+func Idempotency() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := c.Get(IdempotencyKeyHeader)
+		if c.Method() != fiber.MethodPost || key == "" {
+			return c.Next()
+		}
+		if len(key) > maxIdempotencyKey {
+			return apperror.BadRequest("Idempotency-Key must be at most 255 characters")
+		}
+		owner, ok := idempotencyOwner(c)
+		if !ok {
+			return c.Next()
+		}
+
+		ctx := c.UserContext()
+		key = strings.Clone(key)
+		content, err := canonicalBody(c)
+		if err != nil {
+			return apperror.BadRequest("Invalid multipart form")
+		}
+		fingerprint := idempotency.Fingerprint(c.Method(), c.Path(), content)
+		entry, replay, err := idempotency.Begin(ctx, owner, key, fingerprint)
+		switch {
+		case errors.Is(err, idempotency.ErrReused):
+			return apperror.Conflict("This Idempotency-Key was already used for a different request").
+				WithCode(apperror.CodeKeyReused)
+		case errors.Is(err, idempotency.ErrInProgress):
+			return apperror.Conflict("A request with this Idempotency-Key is still being processed, retry shortly").
+				WithCode(apperror.CodeKeyInProgress)
+		case err != nil:
+			return apperror.DB(err, "Failed to check the Idempotency-Key")
+		case replay:
+			c.Set("Idempotent-Replayed", "true")
+			if entry.ContentType != "" {
+				c.Set(fiber.HeaderContentType, entry.ContentType)
+			}
+			return c.Status(entry.Status).Send(entry.Body)
+		}
+
+		if err := c.Next(); err != nil {
+			renderError(c, err)
+		}
+
+		status := c.Response().StatusCode()
+		if noStore, _ := c.Locals(noStoreLocal).(bool); noStore || status >= fiber.StatusInternalServerError {
+			err = idempotency.Release(ctx, entry)
+		} else {
+			err = idempotency.Finish(ctx, entry, status,
+				string(c.Response().Header.ContentType()), c.Response().Body())
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to store the idempotent response", "key", key, "error", err)
+		}
+		return nil
+	}
+}
+
+// idempotencyOwner is the user or kiosk device the request authenticated
+// as.
+func idempotencyOwner(c *fiber.Ctx) (idempotency.Owner, bool) {
+	if userID, ok := c.Locals("user_id").(uint); ok {
+		return idempotency.Owner{UserID: userID}, true
+	}
+	if device, ok := c.Locals("device").(*models.Device); ok {
+		return idempotency.Owner{DeviceID: device.ID}, true
+	}
+	return idempotency.Owner{}, false
+}
+
+// canonicalBody is what identifies the request body. Multipart boundaries
+// are chosen afresh by browsers and Dart on every send, so a retried form
+// is reduced to its fields and a digest of each file.
+func canonicalBody(c *fiber.Ctx) ([]byte, error) {
+	if !strings.HasPrefix(string(c.Request().Header.ContentType()), fiber.MIMEMultipartForm) {
+		return c.Body(), nil
+	}
+	form, err := c.MultipartForm() // Kept by Fiber for the handler
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	for _, name := range slices.Sorted(maps.Keys(form.Value)) {
+		for _, value := range form.Value[name] {
+			fmt.Fprintf(&b, "field %q %q\n", name, value)
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(form.File)) {
+		for _, header := range form.File[name] {
+			digest, err := fileDigest(header)
+			if err != nil {
+				return nil, err
+			}
+			fmt.Fprintf(&b, "file %q %q %s\n", name, header.Filename, digest)
+		}
+	}
+	return b.Bytes(), nil
+}
+
+func fileDigest(header *multipart.FileHeader) (string, error) {
+	file, err := header.Open()
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"gym-api/metrics"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Metrics times every request into metrics.RequestDuration. Requests are
+// labelled with the route pattern, not the path, so IDs don't each get a
+// series.
+func Metrics() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		if err := c.Next(); err != nil {
+			renderError(c, err)
+		}
+
+		route := c.Route().Path
+		if unmatched(c) {
+			route = "unmatched"
+		}
+		metrics.RequestDuration.WithLabelValues(c.Method(), route, strconv.Itoa(c.Response().StatusCode())).
+			Observe(time.Since(start).Seconds())
+		return nil
+	}
+}
@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"gym-api/apperror"
+	"gym-api/logging"
+	"gym-api/tracing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// renderError renders err with the app's ErrorHandler, as Fiber's logger
+// does, so middleware can see the final status. apperror.Handled returns
+// the error afterwards.
+func renderError(c *fiber.Ctx, err error) {
+	if err := c.App().ErrorHandler(c, err); err != nil {
+		_ = c.SendStatus(fiber.StatusInternalServerError)
+	}
+}
+
+// Trace starts a span for the request, continuing the caller's trace if
+// it sent one, and puts it in c.UserContext() with the request ID. Pass
+// c.UserContext() on so queries and log lines join the request.
+func Trace() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		// Fiber's strings point into buffers it reuses; spans outlive the request
+		method := strings.Clone(c.Method())
+		ctx := otel.GetTextMapPropagator().Extract(c.UserContext(), headerCarrier{&c.Request().Header})
+		ctx, span := tracing.Tracer.Start(ctx, method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(method),
+				semconv.URLPath(strings.Clone(c.Path())),
+				semconv.ClientAddress(strings.Clone(c.IP())),
+				semconv.UserAgentOriginal(strings.Clone(c.Get(fiber.HeaderUserAgent))),
+				attribute.String("request_id", apperror.RequestID(c)),
+			))
+		defer span.End()
+		c.SetUserContext(logging.With(ctx, "request_id", apperror.RequestID(c)))
+
+		if err := c.Next(); err != nil {
+			renderError(c, err)
+		}
+
+		status := c.Response().StatusCode()
+		if !unmatched(c) {
+			span.SetName(method + " " + c.Route().Path)
+			span.SetAttributes(semconv.HTTPRoute(c.Route().Path))
+		}
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if userID, ok := c.Locals("user_id").(uint); ok {
+			span.SetAttributes(attribute.Int("enduser.id", int(userID)))
+		}
+		if status >= fiber.StatusInternalServerError {
+			span.SetStatus(codes.Error, "")
+			if err := apperror.Handled(c); err != nil {
+				span.RecordError(err)
+			}
+		}
+		return nil
+	}
+}
+
+// RequestLog writes one line per request. The request ID, user and trace
+// come from the request context.
+func RequestLog() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		if err := c.Next(); err != nil {
+			renderError(c, err)
+		}
+
+		status := c.Response().StatusCode()
+		attrs := []slog.Attr{
+			slog.String("method", c.Method()),
+			slog.String("path", c.Path()),
+			slog.Int("status", status),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("ip", c.IP()),
+		}
+		if !unmatched(c) {
+			attrs = append(attrs, slog.String("route", c.Route().Path))
+		}
+		if err := apperror.Handled(c); err != nil {
+			attrs = append(attrs, slog.String("error", err.Error()))
+		}
+		level := slog.LevelInfo
+		if status >= fiber.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		slog.LogAttrs(c.UserContext(), level, "Request", attrs...)
+		return nil
+	}
+}
+
+// unmatched reports whether the router found no route, in which case
+// c.Route is whichever middleware ran last.
+func unmatched(c *fiber.Ctx) bool {
+	var fe *fiber.Error
+	return errors.As(apperror.Handled(c), &fe) && fe.Code == fiber.StatusNotFound
+}
+
+// headerCarrier reads trace context from the request headers.
+type headerCarrier struct {
+	header *fasthttp.RequestHeader
+}
+
+func (h headerCarrier) Get(key string) string {
+	return string(h.header.Peek(key))
+}
+
+func (h headerCarrier) Set(key, value string) {
+	h.header.Set(key, value)
+}
+
+func (h headerCarrier) Keys() []string {
+	var keys []string
+	h.header.VisitAll(func(key, _ []byte) {
+		keys = append(keys, string(key))
+	})
+	return keys
+}
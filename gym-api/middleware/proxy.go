@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ClientAddress makes c.IP() the client's address when the request came
+// through the trusted proxies. Each proxy appends the address it received
+// the request from to header (X-Forwarded-For), so entries on the left are
+// whatever the client sent: the client is the rightmost entry that is not
+// one of the proxies. The header is rewritten to just that address, which
+// Fiber then reads. Requests from elsewhere are left to Fiber, which
+// ignores the header from untrusted peers.
+func ClientAddress(header string, trusted []netip.Prefix) fiber.Handler {
+	isTrusted := func(addr netip.Addr) bool {
+		return slices.ContainsFunc(trusted, func(p netip.Prefix) bool { return p.Contains(addr.Unmap()) })
+	}
+	return func(c *fiber.Ctx) error {
+		if header == "" {
+			return c.Next()
+		}
+		peer, ok := netip.AddrFromSlice(c.Context().RemoteIP())
+		if !ok || !isTrusted(peer) {
+			return c.Next()
+		}
+
+		var hops []string
+		for _, value := range c.Request().Header.PeekAll(header) {
+			hops = append(hops, strings.Split(string(value), ",")...)
+		}
+		var client netip.Addr
+		for i := len(hops) - 1; i >= 0; i-- {
+			addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+			if err != nil {
+				break // A proxy would not write this, so it came from the client
+			}
+			client = addr.Unmap()
+			if !isTrusted(client) {
+				break
+			}
+		}
+		if client.IsValid() {
+			c.Request().Header.Set(header, client.String())
+		} else {
+			c.Request().Header.Del(header) // Fiber falls back to the peer
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,85 @@
+// Package migrations changes existing data, and removes what AutoMigrate
+// leaves behind, where AutoMigrate only adds to the schema. Each migration
+// runs once; the ones applied are recorded in schema_migrations. They must
+// be safe to run twice, as replicas starting together may both run one.
+package migrations
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"gym-api/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type migration struct {
+	name string
+	run  func(tx *gorm.DB) error
+}
+
+// all lists the migrations in the order they run. Append only.
+var all = []migration{
+	{name: "lowercase-user-emails", run: lowercaseEmails},
+	{name: "idempotency-keys-per-device", run: dropUserIdempotencyIndex},
+}
+
+// Run applies the migrations not yet recorded. Run it after AutoMigrate.
+func Run(db *gorm.DB) error {
+	var applied []string
+	if err := db.Model(&models.SchemaMigration{}).Pluck("name", &applied).Error; err != nil {
+		return err
+	}
+	for _, m := range all {
+		if slices.Contains(applied, m.name) {
+			continue
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := m.run(tx); err != nil {
+				return err
+			}
+			return tx.Clauses(clause.OnConflict{DoNothing: true}).
+				Create(&models.SchemaMigration{Name: m.name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %w", m.name, err)
+		}
+		slog.Info("Applied data migration", "migration", m.name)
+	}
+	return nil
+}
+
+// lowercaseEmails stores every email the way validation.NormalizeEmail
+// writes new ones, so lookups can compare email = ? on the unique index
+// instead of LOWER(email), which cannot use it. Accounts whose emails
+// differ only in case must be merged by hand first.
+func lowercaseEmails(tx *gorm.DB) error {
+	var clashes []string
+	err := tx.Model(&models.User{}).Group("LOWER(TRIM(email))").Having("COUNT(*) > 1").
+		Pluck("LOWER(TRIM(email))", &clashes).Error
+	if err != nil {
+		return err
+	}
+	if len(clashes) > 0 {
+		return errors.New("several accounts share these emails apart from case, merge or rename them: " +
+			strings.Join(clashes, ", "))
+	}
+	return tx.Model(&models.User{}).Where("email <> LOWER(TRIM(email))").
+		Update("email", gorm.Expr("LOWER(TRIM(email))")).Error
+}
+
+// dropUserIdempotencyIndex drops the unique index on idempotency keys per
+// user, which AutoMigrate replaced with one per user or device. Kept, it
+// would make kiosks share their keys.
+func dropUserIdempotencyIndex(tx *gorm.DB) error {
+	const index = "idx_idempotency_key"
+	if !tx.Migrator().HasIndex(&models.IdempotencyKey{}, index) {
+		return nil
+	}
+	return tx.Migrator().DropIndex(&models.IdempotencyKey{}, index)
+}
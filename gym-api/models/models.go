@@ -21,16 +21,36 @@ type Package struct {
 	Description  string  `json:"description"`
 }
 
+type Branch struct {
+	ID       uint   `gorm:"primaryKey" json:"id"`
+	Name     string `json:"name"`
+	Address  string `json:"address"`
+	Capacity int    `json:"capacity"` // Max people on site; 0 uses MAX_CAPACITY
+}
+
+// OccupancyLock is a row per branch that an admission locks while it
+// counts heads and records the check-in, so concurrent scans on any
+// replica can't both take the last place. BranchID 0 stands for scanners
+// without a branch.
+type OccupancyLock struct {
+	BranchID uint `gorm:"primaryKey;autoIncrement:false" json:"branch_id"`
+}
+
 type User struct {
 	ID                uint   `gorm:"primaryKey" json:"id"`
 	Name              string `json:"name"`
 	Email             string `gorm:"uniqueIndex;type:varchar(191)" json:"email"`
+	Phone             string `gorm:"type:varchar(32)" json:"phone"`
 	PasswordHash      string `json:"-"`
 	ProfilePicture    string `json:"profile_picture"`
+	ProfileThumbnail  string `json:"profile_thumbnail"`
+	PhotoKey          string `json:"-"` // Storage key of the profile picture, see storage.SavePhoto
+	PushToken         string `json:"-"` // FCM registration token of the member's device
 	Role              Role   `gorm:"type:varchar(20);default:'member'" json:"role"`
 	IsActive          bool   `gorm:"default:true" json:"is_active"`
 	AssignedTrainerID *uint  `json:"assigned_trainer_id"`
-	MembershipStatus  string `gorm:"default:'active'" json:"membership_status"`
+	MembershipStatus  string `gorm:"default:'active'" json:"membership_status"` // "pending" until a self-registration is approved
+	BranchID          *uint  `json:"branch_id"`                                 // Home branch (staff are scoped to it)
 
 	// Package & Subscription Info
 	PackageID    *uint      `json:"package_id"`
@@ -38,16 +58,298 @@ type User struct {
 	SubStartDate *time.Time `json:"sub_start_date"`
 	SubEndDate   *time.Time `json:"sub_end_date"`
 
+	// Two-factor authentication (see package twofactor)
+	TOTPSecret         string     `gorm:"type:varchar(64)" json:"-"` // Set at enrolment, in use once TwoFactorEnabledAt is set
+	TOTPLastStep       int64      `json:"-"`                         // Last time step accepted, so each code works once
+	TwoFactorEnabledAt *time.Time `json:"two_factor_enabled_at"`
+
+	// Single sign-on: the provider's subject for this account, recorded at
+	// the first sign-on
+	SSOSubject string `gorm:"type:varchar(191);index" json:"-"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Device is an unattended check-in kiosk. It authenticates with an API key
+// sent in the X-Device-Key header; only a hash of the key is stored.
+type Device struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	Name       string     `json:"name"`
+	BranchID   *uint      `json:"branch_id"`
+	KeyPrefix  string     `gorm:"type:varchar(16)" json:"key_prefix"` // Shown to admins to tell keys apart
+	KeyHash    string     `gorm:"uniqueIndex;type:varchar(64)" json:"-"`
+	LastSeenAt *time.Time `json:"last_seen_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedBy  uint       `json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// DeviceOnlineWindow is how recently a device must have been heard from to
+// count as online.
+const DeviceOnlineWindow = 2 * time.Minute
+
+func (d Device) Online() bool {
+	return d.RevokedAt == nil && d.LastSeenAt != nil && time.Since(*d.LastSeenAt) < DeviceOnlineWindow
+}
+
+// Gate is a turnstile or door relay that opens on successful check-ins.
+// A gate bound to a device serves that kiosk; otherwise it serves every
+// scanner in its branch.
+type Gate struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Name      string    `json:"name"`
+	Kind      string    `gorm:"type:varchar(20)" json:"kind"` // http, line, simulator
+	Address   string    `json:"address"`                      // Relay URL or host:port of the controller
+	Secret    string    `json:"-"`                            // Signs relay requests and event webhooks
+	Door      string    `gorm:"type:varchar(50)" json:"door"` // Door/reader number on the controller
+	BranchID  *uint     `json:"branch_id"`
+	DeviceID  *uint     `json:"device_id"`
+	Enabled   bool      `gorm:"default:true" json:"enabled"`
 	CreatedAt time.Time `json:"created_at"`
+}
+
+// GateCommand is a decision for a line gate, queued by an API process for
+// the process that holds the controller connections (gates.Deliver). SentAt
+// is set once it was sent or given up on, with Error saying why.
+type GateCommand struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	GateID    uint       `json:"gate_id"`
+	Granted   bool       `json:"granted"`
+	MemberID  uint       `json:"member_id"`
+	Reason    string     `gorm:"type:varchar(255)" json:"reason"`
+	CreatedAt time.Time  `json:"created_at"`
+	SentAt    *time.Time `gorm:"index" json:"sent_at"`
+	Error     string     `gorm:"type:varchar(255)" json:"error"`
+}
+
+// LiveEvent is an event on the live feed, kept for every API process to
+// read and send to its own subscribers (see events.Broker.Relay). The ID is
+// the event's place in the feed.
+type LiveEvent struct {
+	ID        uint64    `gorm:"primaryKey" json:"id"`
+	Type      string    `gorm:"type:varchar(32)" json:"type"`
+	BranchID  *uint     `json:"branch_id"`
+	Data      []byte    `json:"data"` // JSON
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
+// GateNonce is a nonce a gate has signed a webhook with, kept until its
+// timestamp could no longer be accepted so the request can't be replayed.
+type GateNonce struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	GateID    uint      `gorm:"uniqueIndex:idx_gate_nonce" json:"gate_id"`
+	Nonce     string    `gorm:"uniqueIndex:idx_gate_nonce;type:varchar(64)" json:"nonce"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
+// AuditLog is an append-only record of security-relevant events.
+type AuditLog struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Action    string    `gorm:"type:varchar(50);index" json:"action"` // e.g. gate.door_forced
+	ActorID   *uint     `json:"actor_id"`                             // User who acted, if any
+	DeviceID  *uint     `json:"device_id"`
+	GateID    *uint     `json:"gate_id"`
+	Subject   string    `json:"subject"` // What the action was about, e.g. an email or door
+	Detail    string    `gorm:"type:text" json:"detail"`
+	IP        string    `gorm:"type:varchar(64)" json:"ip"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
+// LoginThrottle counts login attempts for an account (Kind "account",
+// Subject the email) or a client address (Kind "ip"). It lives in the
+// database so every API replica enforces the same limits.
+type LoginThrottle struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	Kind        string     `gorm:"type:varchar(10);uniqueIndex:idx_login_throttle_subject" json:"kind"`
+	Subject     string     `gorm:"type:varchar(191);uniqueIndex:idx_login_throttle_subject" json:"subject"`
+	Count       int        `json:"count"` // Failures (account) or attempts (ip) in the current window
+	WindowStart time.Time  `json:"window_start"`
+	LastAt      time.Time  `gorm:"index" json:"last_at"`
+	LockedUntil *time.Time `gorm:"index" json:"locked_until"`
+}
+
+// Invite is a staff or trainer account waiting for its owner to choose a
+// password. The user exists but is inactive until the invite is accepted.
+// Only a hash of the token is stored.
+type Invite struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	UserID     uint       `gorm:"uniqueIndex" json:"user_id"`
+	User       User       `gorm:"foreignKey:UserID" json:"user"`
+	TokenHash  string     `gorm:"uniqueIndex;type:varchar(64)" json:"-"`
+	InvitedBy  uint       `json:"invited_by"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	SentAt     *time.Time `json:"sent_at"` // Last time the email went out
+	AcceptedAt *time.Time `gorm:"index" json:"accepted_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Registration is a member's self sign-up. The member is "pending" until
+// they confirm their email address and staff approve them. Only a hash of
+// the verification token is stored.
+type Registration struct {
+	ID              uint       `gorm:"primaryKey" json:"id"`
+	UserID          uint       `gorm:"uniqueIndex" json:"user_id"`
+	User            User       `gorm:"foreignKey:UserID" json:"user"`
+	TokenHash       string     `gorm:"uniqueIndex;type:varchar(64)" json:"-"`
+	ExpiresAt       time.Time  `json:"expires_at"` // Of the verification link
+	SentAt          *time.Time `json:"sent_at"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	ApprovedBy      *uint      `json:"approved_by"`
+	ApprovedAt      *time.Time `gorm:"index" json:"approved_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// RecoveryCode is a one-time code that replaces the authenticator app when
+// it is lost. Only a hash is stored.
+type RecoveryCode struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"index" json:"user_id"`
+	CodeHash  string     `gorm:"uniqueIndex;type:varchar(64)" json:"-"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// TwoFactorPolicy records whether a role must sign in with a second factor.
+// Roles without a row do not.
+type TwoFactorPolicy struct {
+	Role      Role      `gorm:"primaryKey;type:varchar(20)" json:"role"`
+	Required  bool      `json:"required"`
+	UpdatedBy *uint     `json:"updated_by"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+// OIDCLogin is a single sign-on in progress: what is needed to finish it
+// when the provider sends the user back.
+type OIDCLogin struct {
+	State     string    `gorm:"primaryKey;type:varchar(64)"`
+	Verifier  string    `gorm:"type:varchar(128)"` // PKCE code verifier
+	Nonce     string    `gorm:"type:varchar(64)"`
+	ExpiresAt time.Time `gorm:"index"`
+}
+
+// SyncedScan remembers the outcome of a scan uploaded from a device's
+// offline queue, so retried uploads get the same answer.
+type SyncedScan struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	DeviceID     uint      `gorm:"uniqueIndex:idx_synced_scan_client" json:"device_id"`
+	ClientID     string    `gorm:"uniqueIndex:idx_synced_scan_client;type:varchar(64)" json:"client_id"`
+	MemberID     uint      `json:"member_id"`
+	CapturedAt   time.Time `json:"captured_at"`
+	Status       string    `gorm:"type:varchar(20)" json:"status"` // admitted, denied
+	Reason       string    `json:"reason"`
+	AttendanceID *uint     `json:"attendance_id"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// IdempotencyKey holds the response to a POST sent with an Idempotency-Key
+// header, so a retry with the same key gets it again instead of repeating
+// the operation. Status is 0 while the first request is still running.
+// Keys belong to a signed-in user or to a kiosk device; the other ID is 0.
+type IdempotencyKey struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	UserID      uint      `gorm:"uniqueIndex:idx_idempotency_owner_key" json:"user_id"`
+	DeviceID    uint      `gorm:"uniqueIndex:idx_idempotency_owner_key;not null;default:0" json:"device_id"`
+	Key         string    `gorm:"uniqueIndex:idx_idempotency_owner_key;type:varchar(255)" json:"key"`
+	RequestHash string    `gorm:"type:varchar(64)" json:"-"` // SHA-256 of method, path and body
+	Status      int       `json:"status"`
+	ContentType string    `gorm:"type:varchar(100)" json:"content_type"`
+	Body        []byte    `json:"-"`
+	CreatedAt   time.Time `json:"created_at"`
+	ExpiresAt   time.Time `gorm:"index" json:"expires_at"`
+}
+
+// SchemaMigration records a data migration that has run, see package
+// migrations.
+type SchemaMigration struct {
+	Name      string    `gorm:"primaryKey;type:varchar(100)" json:"name"`
+	AppliedAt time.Time `json:"applied_at"`
+}
+
 type Attendance struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	TrainerID    uint       `json:"trainer_id"`
+	Trainer      User       `gorm:"foreignKey:TrainerID" json:"trainer"`
+	ScannedBy    *uint      `json:"scanned_by"` // Admin who scanned (nil for kiosk check-ins)
+	Admin        *User      `gorm:"foreignKey:ScannedBy" json:"admin"`
+	DeviceID     *uint      `json:"device_id"` // Kiosk that admitted the user
+	Device       *Device    `gorm:"foreignKey:DeviceID" json:"device,omitempty"`
+	BranchID     *uint      `json:"branch_id"` // Branch of the scanner that admitted the user
+	ScanTime     time.Time  `json:"scan_time"`
+	CheckOutTime *time.Time `json:"check_out_time"`        // nil until the user checks out
+	Date         time.Time  `gorm:"type:date" json:"date"` // stored as YYYY-MM-DD
+}
+
+// Subscription records every package sale or renewal. User keeps only the
+// current subscription; this table is the history used for revenue.
+type Subscription struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	MemberID  uint      `gorm:"index" json:"member_id"`
+	PackageID uint      `gorm:"index" json:"package_id"`
+	Package   Package   `gorm:"foreignKey:PackageID" json:"package"`
+	Price     float64   `json:"price"` // Price at the time of sale
+	StartDate time.Time `json:"start_date"`
+	EndDate   time.Time `json:"end_date"`
+	SoldBy    *uint     `json:"sold_by"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
+// Incident records a check-in that staff rejected because the person at the
+// desk did not match the member's photo. The attendance itself is removed;
+// the scan details are kept here.
+type Incident struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	MemberID     uint       `gorm:"index" json:"member_id"`
+	Member       User       `gorm:"foreignKey:MemberID" json:"member"`
+	AttendanceID uint       `json:"attendance_id"` // ID of the removed attendance
+	ScanTime     time.Time  `json:"scan_time"`
+	ScannedBy    *uint      `json:"scanned_by"`
+	DeviceID     *uint      `json:"device_id"`
+	ReportedBy   uint       `json:"reported_by"`
+	Reason       string     `json:"reason"`
+	Notes        string     `gorm:"type:text" json:"notes"`
+	Suspended    bool       `json:"suspended"`                                           // Member was suspended pending review
+	Status       string     `gorm:"type:varchar(20);default:'open';index" json:"status"` // open, resolved
+	Resolution   string     `json:"resolution"`
+	ResolvedBy   *uint      `json:"resolved_by"`
+	ResolvedAt   *time.Time `json:"resolved_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// ChurnRisk is the latest churn score of a member, refreshed by the churn job.
+type ChurnRisk struct {
+	ID                 uint       `gorm:"primaryKey" json:"id"`
+	MemberID           uint       `gorm:"uniqueIndex" json:"member_id"`
+	Member             User       `gorm:"foreignKey:MemberID" json:"member"`
+	Score              float64    `json:"score"`                               // 0 (safe) to 1 (likely to leave)
+	Level              string     `gorm:"type:varchar(10);index" json:"level"` // low, medium, high
+	RecentVisits       int64      `json:"recent_visits"`
+	PriorVisits        int64      `json:"prior_visits"`
+	DaysSinceLastVisit *int       `json:"days_since_last_visit"` // nil if never visited
+	DaysToExpiry       *int       `json:"days_to_expiry"`        // nil if no end date
+	ComputedAt         time.Time  `json:"computed_at"`
+	NotifiedAt         *time.Time `json:"notified_at"`
+}
+
+type NotificationLog struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
-	TrainerID uint      `json:"trainer_id"`
-	Trainer   User      `gorm:"foreignKey:TrainerID" json:"trainer"`
-	ScannedBy uint      `json:"scanned_by"` // Admin who scanned
-	Admin     User      `gorm:"foreignKey:ScannedBy" json:"admin"`
-	ScanTime  time.Time `json:"scan_time"`
-	Date      time.Time `gorm:"type:date" json:"date"` // stored as YYYY-MM-DD
+	UserID    uint      `gorm:"index" json:"user_id"`
+	Kind      string    `gorm:"type:varchar(50);index" json:"kind"`
+	Channel   string    `gorm:"type:varchar(20)" json:"channel"`
+	Recipient string    `json:"recipient"`
+	Subject   string    `json:"subject"`
+	Body      string    `gorm:"type:text" json:"body"`
+	Status    string    `gorm:"type:varchar(20)" json:"status"` // sent, failed, opted_out
+	Error     string    `json:"error,omitempty"`
+	Reference string    `gorm:"type:varchar(100);index" json:"reference"` // De-duplicates scheduled sends
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NotificationOptOut stops a kind of notification on a channel for a user.
+// An empty Channel or Kind matches all of them.
+type NotificationOptOut struct {
+	ID      uint   `gorm:"primaryKey" json:"id"`
+	UserID  uint   `gorm:"index" json:"user_id"`
+	Channel string `gorm:"type:varchar(20)" json:"channel"`
+	Kind    string `gorm:"type:varchar(50)" json:"kind"`
 }
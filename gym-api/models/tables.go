@@ -0,0 +1,33 @@
+package models
+
+// Tables lists every model with a table, in migration order. main migrates
+// them and the readiness probe checks that they exist.
+func Tables() []any {
+	return []any{
+		&User{},
+		&Attendance{},
+		&Package{},
+		&Branch{},
+		&OccupancyLock{},
+		&NotificationLog{},
+		&NotificationOptOut{},
+		&Subscription{},
+		&ChurnRisk{},
+		&Incident{},
+		&Device{},
+		&SyncedScan{},
+		&Gate{},
+		&GateCommand{},
+		&GateNonce{},
+		&LiveEvent{},
+		&AuditLog{},
+		&LoginThrottle{},
+		&RecoveryCode{},
+		&TwoFactorPolicy{},
+		&OIDCLogin{},
+		&Invite{},
+		&Registration{},
+		&IdempotencyKey{},
+		&SchemaMigration{},
+	}
+}
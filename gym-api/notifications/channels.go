@@ -0,0 +1,289 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+type Channel string
+
+const (
+	Email Channel = "email"
+	SMS   Channel = "sms"
+	Push  Channel = "push"
+)
+
+var AllChannels = []Channel{Email, SMS, Push}
+
+// Message is a rendered notification addressed to one recipient. To is an
+// email address, phone number or push token depending on the channel.
+type Message struct {
+	To      string            `json:"to"`
+	Subject string            `json:"subject"`
+	Body    string            `json:"body"`
+	Data    map[string]string `json:"data,omitempty"`
+}
+
+// Sender delivers messages over one channel.
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// SMTPSender sends plain-text email through an SMTP relay.
+type SMTPSender struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (s *SMTPSender) Send(ctx context.Context, msg Message) error {
+	var body strings.Builder
+	fmt.Fprintf(&body, "From: %s\r\n", s.From)
+	fmt.Fprintf(&body, "To: %s\r\n", msg.To)
+	// Subjects carry names; encoding keeps line breaks in them out of the headers
+	fmt.Fprintf(&body, "Subject: %s\r\n", mime.QEncoding.Encode("UTF-8", msg.Subject))
+	body.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n")
+	body.WriteString(msg.Body)
+
+	// smtp.SendMail takes no context, so the dial and the deadline are
+	// ours: a stalled relay gives up when the caller's ctx does
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(s.Host, s.Port))
+	if err != nil {
+		return err
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(time.Minute)
+	}
+	conn.SetDeadline(deadline)
+
+	client, err := smtp.NewClient(conn, s.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: s.Host}); err != nil {
+			return err
+		}
+	}
+	if s.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.Username, s.Password, s.Host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(s.From); err != nil {
+		return err
+	}
+	if err := client.Rcpt(msg.To); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte(body.String())); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// HTTPSMSSender is a provider adapter for SMS gateways that accept a JSON
+// POST of {from, to, body} with a bearer API key.
+type HTTPSMSSender struct {
+	URL    string
+	APIKey string
+	From   string
+}
+
+func (s *HTTPSMSSender) Send(ctx context.Context, msg Message) error {
+	return postJSON(ctx, s.URL, "Bearer "+s.APIKey, map[string]string{
+		"from": s.From,
+		"to":   msg.To,
+		"body": msg.Subject,
+	})
+}
+
+// FCMSender delivers push notifications through the Firebase Cloud
+// Messaging HTTP v1 API, authenticated as a service account.
+type FCMSender struct {
+	Endpoint string // https://fcm.googleapis.com/v1/projects/<id>/messages:send
+	Tokens   oauth2.TokenSource
+}
+
+// NewFCMSender reads a service account key file, as downloaded from the
+// Firebase console. projectID defaults to the key's project.
+func NewFCMSender(credentialsFile, projectID, baseURL string) (*FCMSender, error) {
+	key, err := os.ReadFile(credentialsFile)
+	if err != nil {
+		return nil, err
+	}
+	creds, err := google.CredentialsFromJSON(context.Background(), key, "https://www.googleapis.com/auth/firebase.messaging")
+	if err != nil {
+		return nil, err
+	}
+	if projectID == "" {
+		projectID = creds.ProjectID
+	}
+	if projectID == "" {
+		return nil, errors.New("no project ID in the credentials; set FCM_PROJECT_ID")
+	}
+	return &FCMSender{
+		Endpoint: strings.TrimRight(baseURL, "/") + "/v1/projects/" + projectID + "/messages:send",
+		Tokens:   creds.TokenSource, // Caches the access token until it expires
+	}, nil
+}
+
+func (s *FCMSender) Send(ctx context.Context, msg Message) error {
+	token, err := s.Tokens.Token()
+	if err != nil {
+		return fmt.Errorf("fcm access token: %w", err)
+	}
+	return postJSON(ctx, s.Endpoint, "Bearer "+token.AccessToken, map[string]interface{}{
+		"message": map[string]interface{}{
+			"token": msg.To,
+			"notification": map[string]string{
+				"title": msg.Subject,
+				"body":  msg.Body,
+			},
+			"data": msg.Data,
+		},
+	})
+}
+
+// FileSender appends every message as a JSON line to a local file. It is
+// meant for development and testing in place of the real channels.
+type FileSender struct {
+	Path    string
+	Channel Channel
+
+	mu sync.Mutex
+}
+
+func (s *FileSender) Send(_ context.Context, msg Message) error {
+	line, err := json.Marshal(struct {
+		Channel Channel   `json:"channel"`
+		SentAt  time.Time `json:"sent_at"`
+		Message
+	}{s.Channel, time.Now(), msg})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+func postJSON(ctx context.Context, url, authorization string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", authorization)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("provider returned %s", resp.Status)
+	}
+	return nil
+}
+
+// SendersFromEnv builds the configured channels. NOTIFY_SINK=file routes
+// every channel to NOTIFY_FILE_PATH instead of the real providers.
+func SendersFromEnv() map[Channel]Sender {
+	senders := map[Channel]Sender{}
+
+	if os.Getenv("NOTIFY_SINK") == "file" {
+		path := os.Getenv("NOTIFY_FILE_PATH")
+		if path == "" {
+			path = "./notifications.log"
+		}
+		for _, ch := range AllChannels {
+			senders[ch] = &FileSender{Path: path, Channel: ch}
+		}
+		return senders
+	}
+
+	if host := os.Getenv("SMTP_HOST"); host != "" {
+		port := os.Getenv("SMTP_PORT")
+		if port == "" {
+			port = "587"
+		}
+		senders[Email] = &SMTPSender{
+			Host:     host,
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("SMTP_FROM"),
+		}
+	}
+
+	if url := os.Getenv("SMS_API_URL"); url != "" {
+		senders[SMS] = &HTTPSMSSender{
+			URL:    url,
+			APIKey: os.Getenv("SMS_API_KEY"),
+			From:   os.Getenv("SMS_FROM"),
+		}
+	}
+
+	if file := os.Getenv("FCM_CREDENTIALS_FILE"); file != "" {
+		baseURL := os.Getenv("FCM_ENDPOINT")
+		if baseURL == "" {
+			baseURL = "https://fcm.googleapis.com"
+		}
+		sender, err := NewFCMSender(file, os.Getenv("FCM_PROJECT_ID"), baseURL)
+		if err != nil {
+			slog.Error("Push notifications are off: FCM credentials unusable", "file", file, "error", err)
+		} else {
+			senders[Push] = sender
+		}
+	} else if os.Getenv("FCM_SERVER_KEY") != "" {
+		slog.Warn("FCM_SERVER_KEY is for the retired legacy FCM API; set FCM_CREDENTIALS_FILE to a service account key instead")
+	}
+
+	return senders
+}
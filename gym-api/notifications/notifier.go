@@ -0,0 +1,183 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"gym-api/config"
+	"gym-api/models"
+)
+
+var errUnknownKind = errors.New("unknown notification kind")
+
+// ErrNoEmail is returned by SendEmail when no email channel is configured.
+var ErrNoEmail = errors.New("no email channel configured")
+
+// senders holds the configured channels; see Setup.
+var senders = map[Channel]Sender{}
+
+// Setup loads the channel configuration from the environment.
+func Setup() {
+	senders = SendersFromEnv()
+	for ch := range senders {
+		slog.Info("Notification channel enabled", "channel", ch)
+	}
+}
+
+func recipient(user models.User, ch Channel) string {
+	switch ch {
+	case Email:
+		return user.Email
+	case SMS:
+		return user.Phone
+	case Push:
+		return user.PushToken
+	}
+	return ""
+}
+
+func optedOut(userID uint, ch Channel, kind Kind) bool {
+	var count int64
+	config.DB.Model(&models.NotificationOptOut{}).
+		Where("user_id = ? AND (channel = ? OR channel = '') AND (kind = ? OR kind = '')", userID, ch, kind).
+		Count(&count)
+	return count > 0
+}
+
+// Notify renders kind for the user and delivers it on every configured
+// channel the user can be reached on. Every attempt, including ones skipped
+// because of an opt-out, is written to the delivery log. reference is stored
+// with the log entries so scheduled senders can avoid repeats.
+func Notify(ctx context.Context, user models.User, kind Kind, reference string, data map[string]interface{}) error {
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	data["Name"] = user.Name
+
+	subject, body, err := render(kind, data)
+	if err != nil {
+		return err
+	}
+
+	for _, ch := range AllChannels {
+		sender, ok := senders[ch]
+		to := recipient(user, ch)
+		if !ok || to == "" {
+			continue
+		}
+
+		entry := models.NotificationLog{
+			UserID:    user.ID,
+			Kind:      string(kind),
+			Channel:   string(ch),
+			Recipient: to,
+			Subject:   subject,
+			Body:      body,
+			Reference: reference,
+		}
+
+		if optedOut(user.ID, ch, kind) {
+			entry.Status = "opted_out"
+		} else if err := sender.Send(ctx, Message{To: to, Subject: subject, Body: body, Data: map[string]string{"kind": string(kind)}}); err != nil {
+			entry.Status = "failed"
+			entry.Error = err.Error()
+			slog.Warn("Notification failed", "kind", kind, "channel", ch, "user_id", user.ID, "error", err)
+		} else {
+			entry.Status = "sent"
+		}
+
+		if err := config.DB.Create(&entry).Error; err != nil {
+			slog.Error("Failed to record notification", "error", err)
+		}
+	}
+	return nil
+}
+
+// NotifyOnce is Notify unless a notification with the same kind and
+// reference has already been delivered to the user.
+func NotifyOnce(ctx context.Context, user models.User, kind Kind, reference string, data map[string]interface{}) error {
+	var count int64
+	config.DB.Model(&models.NotificationLog{}).
+		Where("user_id = ? AND kind = ? AND reference = ? AND status IN ?", user.ID, kind, reference, []string{"sent", "opted_out"}).
+		Count(&count)
+	if count > 0 {
+		return nil
+	}
+	return Notify(ctx, user, kind, reference, data)
+}
+
+// Notifications sent by Dispatch and not yet done, for Wait.
+var pending sync.WaitGroup
+
+// Dispatch sends a notification in the background so request handlers don't
+// wait on mail servers or push providers.
+func Dispatch(user models.User, kind Kind, data map[string]interface{}) {
+	pending.Add(1)
+	go func() {
+		defer pending.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		if err := Notify(ctx, user, kind, "", data); err != nil {
+			slog.Warn("Notification failed", "kind", kind, "user_id", user.ID, "error", err)
+		}
+	}()
+}
+
+// Wait blocks until the notifications handed to Dispatch are sent, or ctx
+// is done. Call it on shutdown, after requests have drained.
+func Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("notifications still sending: %w", ctx.Err())
+	}
+}
+
+// SendEmail delivers a transactional email, such as an invite, straight
+// away and returns the outcome to the caller. Opt-outs do not apply. The
+// delivery is logged without its body, which carries a secret link.
+func SendEmail(ctx context.Context, user models.User, kind Kind, reference string, data map[string]interface{}) error {
+	sender, ok := senders[Email]
+	if !ok {
+		return ErrNoEmail
+	}
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	data["Name"] = user.Name
+
+	subject, body, err := render(kind, data)
+	if err != nil {
+		return err
+	}
+
+	entry := models.NotificationLog{
+		UserID:    user.ID,
+		Kind:      string(kind),
+		Channel:   string(Email),
+		Recipient: user.Email,
+		Subject:   subject,
+		Body:      "(withheld: contains a secret link)",
+		Reference: reference,
+		Status:    "sent",
+	}
+	sendErr := sender.Send(ctx, Message{To: user.Email, Subject: subject, Body: body, Data: map[string]string{"kind": string(kind)}})
+	if sendErr != nil {
+		entry.Status = "failed"
+		entry.Error = sendErr.Error()
+	}
+	if err := config.DB.Create(&entry).Error; err != nil {
+		slog.Error("Failed to record notification", "error", err)
+	}
+	return sendErr
+}
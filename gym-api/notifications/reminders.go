@@ -0,0 +1,89 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"gym-api/config"
+	"gym-api/models"
+)
+
+// ReminderDays are the days before expiry on which members are reminded.
+var ReminderDays = []int{7, 3, 1}
+
+// SendExpiryReminders notifies members whose subscription ends within the
+// reminder windows, and members whose subscription ended in the last week.
+// It is safe to run repeatedly: each reminder is sent once per window.
+func SendExpiryReminders(ctx context.Context) error {
+	now := time.Now()
+	longest := 0
+	for _, d := range ReminderDays {
+		longest = max(longest, d)
+	}
+
+	// 1. Upcoming expiries
+	var expiring []models.User
+	err := config.DB.Preload("Package").
+		Where("role = ? AND is_active = ? AND sub_end_date > ? AND sub_end_date <= ?",
+			models.RoleMember, true, now, now.AddDate(0, 0, longest)).
+		Find(&expiring).Error
+	if err != nil {
+		return err
+	}
+
+	for _, member := range expiring {
+		daysLeft := int(math.Ceil(member.SubEndDate.Sub(now).Hours() / 24))
+
+		// Use the tightest window the member falls into, so someone first
+		// seen at 2 days left gets the 3-day reminder and later the 1-day one.
+		window := 0
+		for _, d := range ReminderDays {
+			if daysLeft <= d && (window == 0 || d < window) {
+				window = d
+			}
+		}
+		if window == 0 {
+			continue
+		}
+
+		ref := fmt.Sprintf("%s:%dd", member.SubEndDate.Format("2006-01-02"), window)
+		if err := NotifyOnce(ctx, member, ExpiryReminder, ref, memberData(member, map[string]interface{}{"DaysLeft": daysLeft})); err != nil {
+			return err
+		}
+	}
+
+	// 2. Recent expiries
+	var expired []models.User
+	err = config.DB.Preload("Package").
+		Where("role = ? AND is_active = ? AND sub_end_date <= ? AND sub_end_date > ?",
+			models.RoleMember, true, now, now.AddDate(0, 0, -7)).
+		Find(&expired).Error
+	if err != nil {
+		return err
+	}
+
+	for _, member := range expired {
+		ref := member.SubEndDate.Format("2006-01-02")
+		if err := NotifyOnce(ctx, member, SubscriptionExpired, ref, memberData(member, nil)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// memberData fills the package fields shared by subscription templates.
+func memberData(member models.User, data map[string]interface{}) map[string]interface{} {
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	if member.Package != nil {
+		data["Package"] = member.Package.Name
+	}
+	if member.SubEndDate != nil {
+		data["EndDate"] = member.SubEndDate.Format("2006-01-02")
+	}
+	return data
+}
@@ -0,0 +1,112 @@
+package notifications
+
+import (
+	"strings"
+	"text/template"
+)
+
+type Kind string
+
+const (
+	Welcome             Kind = "welcome"
+	ExpiryReminder      Kind = "expiry_reminder"
+	SubscriptionExpired Kind = "subscription_expired"
+	RenewalReceipt      Kind = "renewal_receipt"
+	ClassBookingChanged Kind = "class_booking_changed"
+	WeMissYou           Kind = "we_miss_you"
+
+	// Sent with SendEmail only; users cannot opt out
+	StaffInvite Kind = "staff_invite"
+	VerifyEmail Kind = "verify_email"
+)
+
+// AllKinds are the kinds users can switch off.
+var AllKinds = []Kind{Welcome, ExpiryReminder, SubscriptionExpired, RenewalReceipt, ClassBookingChanged, WeMissYou}
+
+// Templates are rendered with the data passed to Notify plus "Name". The
+// subject doubles as the SMS text and push title, so keep it short.
+var templates = map[Kind]*template.Template{
+	Welcome: mustParse(Welcome,
+		`Welcome to the gym, {{.Name}}!`,
+		`Hi {{.Name}},
+
+Your account is ready. Show the QR code in the app at the front desk to check in.
+`),
+	ExpiryReminder: mustParse(ExpiryReminder,
+		`Your membership expires in {{.DaysLeft}} day(s)`,
+		`Hi {{.Name}},
+
+Your {{.Package}} membership ends on {{.EndDate}}. Renew at the front desk to keep your access.
+`),
+	SubscriptionExpired: mustParse(SubscriptionExpired,
+		`Your membership has expired`,
+		`Hi {{.Name}},
+
+Your {{.Package}} membership ended on {{.EndDate}}. Visit the front desk to renew.
+`),
+	RenewalReceipt: mustParse(RenewalReceipt,
+		`Receipt: {{.Package}} until {{.EndDate}}`,
+		`Hi {{.Name}},
+
+Thanks for renewing. Here is your receipt:
+
+  Package:  {{.Package}}
+  Amount:   {{.Price}}
+  Valid:    {{.StartDate}} to {{.EndDate}}
+`),
+	ClassBookingChanged: mustParse(ClassBookingChanged,
+		`Class update: {{.ClassName}}`,
+		`Hi {{.Name}},
+
+Your booking for {{.ClassName}} on {{.ClassTime}} is now {{.Status}}.
+`),
+	WeMissYou: mustParse(WeMissYou,
+		`We miss you at the gym, {{.Name}}`,
+		`Hi {{.Name}},
+
+We haven't seen you in a while. Drop by the front desk if there's anything we can do to help you get back into your routine.
+`),
+	StaffInvite: mustParse(StaffInvite,
+		`You're invited to join the gym team`,
+		`Hi {{.Name}},
+
+{{.InvitedBy}} has invited you to join the gym as {{.Role}}. Choose your password to activate your account:
+
+{{.Link}}
+
+The link works once and expires on {{.ExpiresAt}}. If you weren't expecting this, ignore this email.
+`),
+	VerifyEmail: mustParse(VerifyEmail,
+		`Confirm your email address`,
+		`Hi {{.Name}},
+
+Thanks for registering. Confirm your email address to continue:
+
+{{.Link}}
+
+The link expires on {{.ExpiresAt}}. Once confirmed, our staff will review your registration and let you know when your membership is ready.
+If you didn't register, ignore this email.
+`),
+}
+
+func mustParse(kind Kind, subject, body string) *template.Template {
+	t := template.Must(template.New(string(kind)).Option("missingkey=zero").Parse(body))
+	template.Must(t.New("subject").Parse(subject))
+	return t
+}
+
+func render(kind Kind, data map[string]interface{}) (subject, body string, err error) {
+	t, ok := templates[kind]
+	if !ok {
+		return "", "", errUnknownKind
+	}
+
+	var sb, bb strings.Builder
+	if err := t.ExecuteTemplate(&sb, "subject", data); err != nil {
+		return "", "", err
+	}
+	if err := t.Execute(&bb, data); err != nil {
+		return "", "", err
+	}
+	return sb.String(), bb.String(), nil
+}
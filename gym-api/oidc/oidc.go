@@ -0,0 +1,310 @@
+// Package oidc signs staff in through an OpenID Connect provider using the
+// authorization code flow with PKCE. The web app asks Start for the
+// provider's login page, the provider sends the browser back to the web app
+// with a code, and the web app posts the code to Finish, which returns the
+// verified ID token claims. Pending logins are kept in the database so any
+// replica can finish them.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"gym-api/config"
+	"gym-api/models"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrDisabled is returned when no provider is configured.
+	ErrDisabled = errors.New("single sign-on is not configured")
+	// ErrUnknownState is returned for a state that was never issued, has
+	// expired or was already used.
+	ErrUnknownState = errors.New("unknown or expired sign-on state")
+	// ErrRefused wraps every reason the provider's answer is refused: a
+	// code it rejected or an ID token that does not verify.
+	ErrRefused = errors.New("sign-on refused")
+)
+
+// LoginTTL is how long the user has to finish signing in at the provider.
+const LoginTTL = 10 * time.Minute
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// Claims are the ID token claims used to find or create the account.
+type Claims struct {
+	Subject       string   `json:"sub"`
+	Email         string   `json:"email"`
+	EmailVerified bool     `json:"email_verified"`
+	Name          string   `json:"name"`
+	Nonce         string   `json:"nonce"`
+	AMR           []string `json:"amr"` // Authentication methods, e.g. ["pwd", "mfa"]
+
+	// Every claim, for the configured role claim
+	Raw jwt.MapClaims `json:"-"`
+}
+
+// Provider is a configured OpenID Connect provider. Its discovery document
+// and signing keys are fetched on first use and cached.
+type Provider struct {
+	Settings config.OIDCSettings
+
+	mu          sync.Mutex
+	meta        *metadata
+	keys        map[string]any // Public keys by kid
+	keysFetched time.Time
+}
+
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Default is the provider used by the sign-on handlers.
+var Default = &Provider{Settings: config.OIDC()}
+
+// Setup configures Default from the environment.
+func Setup() {
+	Default = &Provider{Settings: config.OIDC()}
+	switch s := Default.Settings; {
+	case s.Enabled():
+		slog.Info("Single sign-on enabled", "issuer", s.Issuer)
+	case s.Issuer != "":
+		slog.Warn("OIDC_ISSUER is set but OIDC_CLIENT_ID or OIDC_REDIRECT_URL is not; single sign-on is off")
+	}
+}
+
+func (p *Provider) Enabled() bool {
+	return p.Settings.Enabled()
+}
+
+// Start begins a sign-on. It returns the provider URL to send the browser
+// to and the state the web app must post back with the code.
+func (p *Provider) Start(ctx context.Context) (authURL, state string, err error) {
+	if !p.Enabled() {
+		return "", "", ErrDisabled
+	}
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	login := models.OIDCLogin{ExpiresAt: time.Now().Add(LoginTTL)}
+	for _, v := range []*string{&login.State, &login.Verifier, &login.Nonce} {
+		if *v, err = randomString(); err != nil {
+			return "", "", err
+		}
+	}
+	if err := config.DB.WithContext(ctx).Create(&login).Error; err != nil {
+		return "", "", err
+	}
+
+	challenge := sha256.Sum256([]byte(login.Verifier))
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", p.Settings.ClientID)
+	q.Set("redirect_uri", p.Settings.RedirectURL)
+	q.Set("scope", strings.Join(p.Settings.Scopes, " "))
+	q.Set("state", login.State)
+	q.Set("nonce", login.Nonce)
+	q.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	q.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(meta.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return meta.AuthorizationEndpoint + sep + q.Encode(), login.State, nil
+}
+
+// Finish redeems the code the provider returned for state and returns the
+// verified ID token claims. Each state can be finished once.
+func (p *Provider) Finish(ctx context.Context, code, state string) (*Claims, error) {
+	if !p.Enabled() {
+		return nil, ErrDisabled
+	}
+
+	// 1. Claim the pending login; deleting it makes the state single use
+	var login models.OIDCLogin
+	err := config.DB.WithContext(ctx).Where("state = ?", state).First(&login).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrUnknownState
+	}
+	if err != nil {
+		return nil, err
+	}
+	result := config.DB.WithContext(ctx).Where("state = ?", state).Delete(&models.OIDCLogin{})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 || time.Now().After(login.ExpiresAt) {
+		return nil, ErrUnknownState
+	}
+
+	// 2. Exchange the code, proving we started the flow with the verifier
+	rawIDToken, err := p.exchange(ctx, code, login.Verifier)
+	if err != nil {
+		return nil, err
+	}
+
+	// 3. Verify the ID token
+	claims, err := p.verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrRefused, err)
+	}
+	if claims.Nonce != login.Nonce {
+		return nil, fmt.Errorf("%w: nonce does not match", ErrRefused)
+	}
+	return claims, nil
+}
+
+func (p *Provider) exchange(ctx context.Context, code, verifier string) (string, error) {
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.Settings.RedirectURL)
+	form.Set("client_id", p.Settings.ClientID)
+	form.Set("code_verifier", verifier)
+	if p.Settings.ClientSecret != "" {
+		form.Set("client_secret", p.Settings.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil {
+		return "", fmt.Errorf("token endpoint returned %s", resp.Status)
+	}
+	if body.Error != "" {
+		return "", fmt.Errorf("%w: %s %s", ErrRefused, body.Error, body.ErrorDescription)
+	}
+	if resp.StatusCode >= 300 {
+		return "", fmt.Errorf("token endpoint returned %s", resp.Status)
+	}
+	if body.IDToken == "" {
+		return "", errors.New("token endpoint returned no id_token")
+	}
+	return body.IDToken, nil
+}
+
+func (p *Provider) verify(ctx context.Context, raw string) (*Claims, error) {
+	var mapClaims jwt.MapClaims
+	_, err := jwt.ParseWithClaims(raw, &mapClaims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.key(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384"}),
+		jwt.WithIssuer(p.Settings.Issuer),
+		jwt.WithAudience(p.Settings.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Decode the registered shape from the verified claims
+	b, err := json.Marshal(mapClaims)
+	if err != nil {
+		return nil, err
+	}
+	claims := &Claims{Raw: mapClaims}
+	if err := json.Unmarshal(b, claims); err != nil {
+		return nil, err
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("no subject")
+	}
+	return claims, nil
+}
+
+// metadata fetches the discovery document once.
+func (p *Provider) metadata(ctx context.Context) (*metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.meta != nil {
+		return p.meta, nil
+	}
+
+	var meta metadata
+	if err := getJSON(ctx, p.Settings.Issuer+"/.well-known/openid-configuration", &meta); err != nil {
+		return nil, fmt.Errorf("discovery: %w", err)
+	}
+	if meta.Issuer != p.Settings.Issuer {
+		return nil, fmt.Errorf("discovery: issuer %q does not match OIDC_ISSUER", meta.Issuer)
+	}
+	if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+		return nil, errors.New("discovery: endpoints missing")
+	}
+	p.meta = &meta
+	return p.meta, nil
+}
+
+func getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
+
+// randomString returns 32 random bytes, base64url encoded: 43 characters,
+// as RFC 7636 recommends for the code verifier.
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Prune drops sign-ons that were started but never finished. Run it as a
+// job.
+func Prune(ctx context.Context) error {
+	return config.DB.WithContext(ctx).Where("expires_at < ?", time.Now()).Delete(&models.OIDCLogin{}).Error
+}
@@ -0,0 +1,232 @@
+// Package oidctest is a minimal OpenID Connect provider, for tests and for
+// trying single sign-on locally (see cmd/mockidp). It serves discovery,
+// signing keys, an authorize page that signs in whoever is typed (or the
+// login_hint, without asking) and a token endpoint that checks PKCE.
+// Nothing is persisted; keys are new for every Provider.
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const keyID = "mock"
+
+// Provider is the identity provider. Set Issuer to the URL it is served
+// at before the first request.
+type Provider struct {
+	Issuer   string
+	ClientID string   // The only client_id accepted
+	Groups   []string // groups claim for everyone who signs in
+	MFA      bool     // Report a second factor ("amr": ["pwd", "mfa"])
+
+	key    *rsa.PrivateKey
+	mux    *http.ServeMux
+	mu     sync.Mutex
+	grants map[string]grant
+}
+
+// grant is an issued authorization code waiting to be redeemed.
+type grant struct {
+	Email, Name, Nonce, Challenge, RedirectURI string
+	Expires                                    time.Time
+}
+
+// New returns a provider for clientID with a fresh signing key.
+func New(issuer, clientID string) (*Provider, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	p := &Provider{Issuer: issuer, ClientID: clientID, key: key, mux: http.NewServeMux(), grants: map[string]grant{}}
+	p.mux.HandleFunc("/.well-known/openid-configuration", p.discovery)
+	p.mux.HandleFunc("/jwks", p.jwks)
+	p.mux.HandleFunc("/authorize", p.authorize)
+	p.mux.HandleFunc("/token", p.token)
+	return p, nil
+}
+
+func (p *Provider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mux.ServeHTTP(w, r)
+}
+
+func (p *Provider) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                p.Issuer,
+		"authorization_endpoint":                p.Issuer + "/authorize",
+		"token_endpoint":                        p.Issuer + "/token",
+		"jwks_uri":                              p.Issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (p *Provider) jwks(w http.ResponseWriter, r *http.Request) {
+	e := big.NewInt(int64(p.key.E)).Bytes()
+	writeJSON(w, http.StatusOK, map[string]any{"keys": []map[string]string{{
+		"kid": keyID,
+		"kty": "RSA",
+		"use": "sig",
+		"alg": "RS256",
+		"n":   base64.RawURLEncoding.EncodeToString(p.key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(e),
+	}}})
+}
+
+var loginPage = template.Must(template.New("login").Parse(`<!doctype html>
+<title>Mock sign-on</title>
+<form method="post">
+<p><label>Email <input name="email" type="email" required autofocus></label></p>
+<p><label>Name <input name="name"></label></p>
+<p><button>Sign in</button></p>
+</form>`))
+
+// authorize signs in login_hint straight away, or asks for an email.
+func (p *Provider) authorize(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	q := r.URL.Query()
+	switch {
+	case q.Get("client_id") != p.ClientID:
+		http.Error(w, "unknown client_id", http.StatusBadRequest)
+		return
+	case q.Get("response_type") != "code" || q.Get("redirect_uri") == "":
+		http.Error(w, "response_type=code and redirect_uri are required", http.StatusBadRequest)
+		return
+	case q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "":
+		http.Error(w, "PKCE with S256 is required", http.StatusBadRequest)
+		return
+	}
+
+	email := q.Get("login_hint")
+	if r.Method == http.MethodPost {
+		email = r.PostForm.Get("email")
+	}
+	if email == "" {
+		loginPage.Execute(w, nil)
+		return
+	}
+
+	code := randomString()
+	p.mu.Lock()
+	p.grants[code] = grant{
+		Email:       strings.ToLower(email),
+		Name:        r.PostForm.Get("name"),
+		Nonce:       q.Get("nonce"),
+		Challenge:   q.Get("code_challenge"),
+		RedirectURI: q.Get("redirect_uri"),
+		Expires:     time.Now().Add(time.Minute),
+	}
+	p.mu.Unlock()
+
+	back, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	params := back.Query()
+	params.Set("code", code)
+	params.Set("state", q.Get("state"))
+	back.RawQuery = params.Encode()
+	http.Redirect(w, r, back.String(), http.StatusFound)
+}
+
+func (p *Provider) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.Method != http.MethodPost {
+		oauthError(w, "invalid_request", "POST a form")
+		return
+	}
+	code := r.PostForm.Get("code")
+	p.mu.Lock()
+	g, ok := p.grants[code]
+	delete(p.grants, code)
+	p.mu.Unlock()
+
+	verifier := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	switch {
+	case r.PostForm.Get("grant_type") != "authorization_code":
+		oauthError(w, "unsupported_grant_type", "")
+		return
+	case !ok || time.Now().After(g.Expires):
+		oauthError(w, "invalid_grant", "unknown or expired code")
+		return
+	case r.PostForm.Get("redirect_uri") != g.RedirectURI:
+		oauthError(w, "invalid_grant", "redirect_uri does not match")
+		return
+	case base64.RawURLEncoding.EncodeToString(verifier[:]) != g.Challenge:
+		oauthError(w, "invalid_grant", "code_verifier does not match")
+		return
+	case r.PostForm.Get("client_id") != p.ClientID:
+		oauthError(w, "invalid_client", "")
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":            p.Issuer,
+		"aud":            p.ClientID,
+		"sub":            "mock|" + g.Email,
+		"email":          g.Email,
+		"email_verified": true,
+		"name":           g.Name,
+		"nonce":          g.Nonce,
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+		"amr":            []string{"pwd"},
+	}
+	if len(p.Groups) > 0 {
+		claims["groups"] = p.Groups
+	}
+	if p.MFA {
+		claims["amr"] = []string{"pwd", "mfa"}
+	}
+	t := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	t.Header["kid"] = keyID
+	idToken, err := t.SignedString(p.key)
+	if err != nil {
+		oauthError(w, "server_error", err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+func oauthError(w http.ResponseWriter, code, description string) {
+	writeJSON(w, http.StatusBadRequest, map[string]string{"error": code, "error_description": description})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func randomString() string {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprint("random: ", err))
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
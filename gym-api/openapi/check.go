@@ -0,0 +1,143 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Route is a route as registered with the router.
+type Route struct {
+	Method string
+	Path   string // Fiber syntax
+}
+
+// Check compares the registered routes with the documented ones and
+// returns a line for every route that is only on one side.
+func (d *Document) Check(routes []Route) []string {
+	var problems []string
+	registered := map[string]bool{}
+	for _, r := range routes {
+		key := strings.ToUpper(r.Method) + " " + r.Path
+		if registered[key] {
+			continue
+		}
+		registered[key] = true
+		if d.routes[key] == nil {
+			problems = append(problems, key+": registered but not documented")
+		}
+	}
+	for key := range d.routes {
+		if !registered[key] {
+			problems = append(problems, key+": documented but not registered")
+		}
+	}
+	sort.Strings(problems)
+	return problems
+}
+
+// Conform checks a JSON response body of a documented route against its
+// schema: missing fields, undocumented fields and wrong types are errors.
+// Error statuses are checked against the error schema. Routes that are
+// not documented or do not return JSON are skipped.
+func (d *Document) Conform(method, path string, status int, body []byte) error {
+	op := d.routes[strings.ToUpper(method)+" "+path]
+	if op == nil {
+		return nil
+	}
+
+	res := op.Responses["200"]
+	if status >= 400 {
+		res = op.Responses["default"]
+	} else if !op.jsonResponse {
+		return nil
+	}
+	if res == nil || res.Content["application/json"] == nil {
+		return nil
+	}
+
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		return fmt.Errorf("response is not JSON: %w", err)
+	}
+	return d.validate(res.Content["application/json"].Schema, v, "$")
+}
+
+func (d *Document) validate(s *Schema, v any, at string) error {
+	if s.Ref != "" {
+		return d.validate(d.Components.Schemas[strings.TrimPrefix(s.Ref, refPrefix)], v, at)
+	}
+	if v == nil {
+		if s.Nullable || s.Type == "" && len(s.AllOf) == 0 {
+			return nil
+		}
+		return fmt.Errorf("%s: is null", at)
+	}
+	for _, sub := range s.AllOf {
+		if err := d.validate(sub, v, at); err != nil {
+			return err
+		}
+	}
+
+	switch s.Type {
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: expected an object", at)
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				return fmt.Errorf("%s: missing %q", at, name)
+			}
+		}
+		for name, fv := range obj {
+			fs, ok := s.Properties[name]
+			switch {
+			case ok:
+			case s.AdditionalProperties != nil:
+				fs = s.AdditionalProperties
+			case s.Properties != nil:
+				return fmt.Errorf("%s: undocumented field %q", at, name)
+			default:
+				continue
+			}
+			if err := d.validate(fs, fv, at+"."+name); err != nil {
+				return err
+			}
+		}
+	case "array":
+		arr, ok := v.([]any)
+		if !ok {
+			return fmt.Errorf("%s: expected an array", at)
+		}
+		for i, item := range arr {
+			if err := d.validate(s.Items, item, fmt.Sprintf("%s[%d]", at, i)); err != nil {
+				return err
+			}
+		}
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("%s: expected a string", at)
+		}
+		if len(s.Enum) > 0 && !slices.Contains(s.Enum, str) {
+			return fmt.Errorf("%s: %q is not one of %s", at, str, strings.Join(s.Enum, ", "))
+		}
+	case "integer":
+		if n, ok := v.(float64); !ok || n != math.Trunc(n) {
+			return fmt.Errorf("%s: expected an integer", at)
+		}
+	case "number":
+		if _, ok := v.(float64); !ok {
+			return fmt.Errorf("%s: expected a number", at)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%s: expected a boolean", at)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,255 @@
+// Package openapi builds the OpenAPI 3 description of the API. Routes are
+// described with Operation values; request and response schemas are
+// derived from the Go input and response types by reflection, so the
+// document follows the types instead of being maintained by hand.
+package openapi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+const Version = "3.0.3"
+
+// Operation documents one route.
+type Operation struct {
+	ID         string // Unique operationId, used as the method name by client generators
+	Method     string // GET, POST, ...
+	Path       string // Fiber syntax: /api/admin/users/:id
+	Tag        string
+	Summary    string
+	Deprecated bool
+	Security   []string // Security schemes a request must satisfy, all of them; empty means public
+	Roles      []string // Roles allowed in, published as x-roles
+	Query      []Param
+	Headers    []Param
+
+	Body  any      // JSON request body: a zero value of the input type
+	Form  any      // multipart/form-data request body
+	Files []string // File fields of a multipart body
+
+	Response    any    // 200 body: a zero value of the response type
+	ContentType string // Response content type; defaults to application/json
+}
+
+// Param is a query or path parameter.
+type Param struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// Query is an optional query parameter.
+func Query(name string, schema *Schema, description string) Param {
+	return Param{Name: name, In: "query", Schema: schema, Description: description}
+}
+
+// Header is an optional request header.
+func Header(name string, schema *Schema, description string) Param {
+	return Param{Name: name, In: "header", Schema: schema, Description: description}
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"` // http or apiKey
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	In           string `json:"in,omitempty"`
+	Name         string `json:"name,omitempty"`
+	Description  string `json:"description,omitempty"`
+}
+
+// Config holds the document-wide settings.
+type Config struct {
+	Title           string
+	Version         string
+	Description     string
+	SecuritySchemes map[string]SecurityScheme
+	Error           any // Body of every error response
+}
+
+// Document is a generated OpenAPI document. It marshals to the JSON form
+// served to clients.
+type Document struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       Info                             `json:"info"`
+	Paths      map[string]map[string]*operation `json:"paths"`
+	Components Components                       `json:"components"`
+
+	// Documented routes by "METHOD /fiber/path", for Check and Conform
+	routes map[string]*operation
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type operation struct {
+	OperationID string                `json:"operationId"`
+	Tags        []string              `json:"tags,omitempty"`
+	Summary     string                `json:"summary,omitempty"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+	Parameters  []Param               `json:"parameters,omitempty"`
+	RequestBody *requestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+	Roles       []string              `json:"x-roles,omitempty"`
+
+	jsonResponse bool
+}
+
+type requestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*mediaType `json:"content"`
+}
+
+type response struct {
+	Description string                `json:"description"`
+	Content     map[string]*mediaType `json:"content,omitempty"`
+}
+
+type mediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+var pathParam = regexp.MustCompile(`:([A-Za-z_][A-Za-z0-9_]*)`)
+
+// Build documents ops. It fails on duplicate routes or IDs, unknown
+// security schemes and operations without a response type.
+func Build(cfg Config, ops []Operation) (*Document, error) {
+	g := newGenerator()
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    Info{Title: cfg.Title, Version: cfg.Version, Description: cfg.Description},
+		Paths:   map[string]map[string]*operation{},
+		Components: Components{
+			Schemas:         g.schemas,
+			SecuritySchemes: cfg.SecuritySchemes,
+		},
+		routes: map[string]*operation{},
+	}
+
+	var errorSchema *Schema
+	if cfg.Error != nil {
+		errorSchema = g.response(reflect.TypeOf(cfg.Error))
+	}
+
+	var errs []error
+	ids := map[string]bool{}
+	for _, op := range ops {
+		method := strings.ToUpper(op.Method)
+		key := method + " " + op.Path
+		switch {
+		case op.ID == "":
+			errs = append(errs, fmt.Errorf("%s: missing ID", key))
+			continue
+		case ids[op.ID]:
+			errs = append(errs, fmt.Errorf("%s: duplicate ID %q", key, op.ID))
+			continue
+		case doc.routes[key] != nil:
+			errs = append(errs, fmt.Errorf("%s: documented twice", key))
+			continue
+		case op.Response == nil && op.ContentType == "":
+			errs = append(errs, fmt.Errorf("%s: no response type", key))
+			continue
+		}
+		ids[op.ID] = true
+
+		o := &operation{
+			OperationID: op.ID,
+			Summary:     op.Summary,
+			Deprecated:  op.Deprecated,
+			Parameters:  pathParams(op.Path),
+			Roles:       op.Roles,
+			Responses:   map[string]*response{},
+		}
+		if op.Tag != "" {
+			o.Tags = []string{op.Tag}
+		}
+		o.Parameters = append(o.Parameters, op.Query...)
+		o.Parameters = append(o.Parameters, op.Headers...)
+
+		if len(op.Security) > 0 {
+			requirement := map[string][]string{}
+			for _, name := range op.Security {
+				if _, ok := cfg.SecuritySchemes[name]; !ok {
+					errs = append(errs, fmt.Errorf("%s: unknown security scheme %q", key, name))
+				}
+				requirement[name] = []string{}
+			}
+			o.Security = []map[string][]string{requirement}
+		}
+
+		switch {
+		case op.Body != nil:
+			o.RequestBody = &requestBody{Required: true, Content: map[string]*mediaType{
+				"application/json": {Schema: g.request(reflect.TypeOf(op.Body), "json")},
+			}}
+		case op.Form != nil || len(op.Files) > 0:
+			o.RequestBody = &requestBody{Required: true, Content: map[string]*mediaType{
+				"multipart/form-data": {Schema: g.form(op.Form, op.Files)},
+			}}
+		}
+
+		contentType := op.ContentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		var body *Schema
+		if op.Response != nil {
+			body = g.response(reflect.TypeOf(op.Response))
+		} else {
+			body = &Schema{Type: "string"}
+		}
+		o.jsonResponse = contentType == "application/json"
+		o.Responses["200"] = &response{
+			Description: http.StatusText(http.StatusOK),
+			Content:     map[string]*mediaType{contentType: {Schema: body}},
+		}
+		if errorSchema != nil {
+			o.Responses["default"] = &response{
+				Description: "Error",
+				Content:     map[string]*mediaType{"application/json": {Schema: errorSchema}},
+			}
+		}
+
+		path := pathParam.ReplaceAllString(op.Path, "{$1}")
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = map[string]*operation{}
+		}
+		doc.Paths[path][strings.ToLower(method)] = o
+		doc.routes[key] = o
+	}
+
+	errs = append(errs, g.errs...)
+	return doc, errors.Join(errs...)
+}
+
+// pathParams declares the :params of a Fiber path. IDs are integers.
+func pathParams(path string) []Param {
+	var params []Param
+	for _, m := range pathParam.FindAllStringSubmatch(path, -1) {
+		schema := &Schema{Type: "string"}
+		if m[1] == "id" || strings.HasSuffix(m[1], "_id") {
+			schema = &Schema{Type: "integer", Minimum: ptr(1.0)}
+		}
+		params = append(params, Param{Name: m[1], In: "path", Required: true, Schema: schema})
+	}
+	return params
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,266 @@
+package openapi
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Schema is the subset of the OpenAPI schema object the generator emits.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	ExclusiveMinimum     bool               `json:"exclusiveMinimum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// Shorthands for parameter schemas.
+func String() *Schema  { return &Schema{Type: "string"} }
+func Integer() *Schema { return &Schema{Type: "integer"} }
+func Boolean() *Schema { return &Schema{Type: "boolean"} }
+func Date() *Schema    { return &Schema{Type: "string", Format: "date"} }
+
+func Enum(values ...string) *Schema {
+	return &Schema{Type: "string", Enum: values}
+}
+
+const refPrefix = "#/components/schemas/"
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// generator turns Go types into schemas. Named structs become components
+// referenced by name; generic instances such as envelopes are inlined.
+type generator struct {
+	schemas map[string]*Schema
+	types   map[reflect.Type]string // Component name of each struct
+	errs    []error
+}
+
+func newGenerator() *generator {
+	return &generator{schemas: map[string]*Schema{}, types: map[reflect.Type]string{}}
+}
+
+// mode says how struct fields are read. Responses take required from the
+// json omitempty option; requests from the validate tag, together with
+// its constraints.
+type mode struct {
+	request bool
+	tag     string // json or form
+}
+
+func (g *generator) response(t reflect.Type) *Schema {
+	return g.schema(t, mode{tag: "json"})
+}
+
+func (g *generator) request(t reflect.Type, tag string) *Schema {
+	return g.schema(t, mode{request: true, tag: tag})
+}
+
+// form is a multipart body: the form fields of input plus the file fields.
+func (g *generator) form(input any, files []string) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	if input != nil {
+		s = g.object(reflect.TypeOf(input), mode{request: true, tag: "form"})
+	}
+	for _, name := range files {
+		s.Properties[name] = &Schema{Type: "string", Format: "binary"}
+	}
+	return s
+}
+
+func (g *generator) schema(t reflect.Type, m mode) *Schema {
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t.Kind() == reflect.Pointer:
+		s := g.schema(t.Elem(), m)
+		if s.Ref != "" {
+			// $ref siblings are ignored in 3.0, so wrap it
+			return &Schema{AllOf: []*Schema{s}, Nullable: true}
+		}
+		s.Nullable = true
+		return s
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		return &Schema{Type: "integer"}
+	case reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer", Minimum: ptr(0.0)}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schema(t.Elem(), m)}
+	case reflect.Array:
+		return &Schema{Type: "array", Items: g.schema(t.Elem(), m), MinItems: ptr(t.Len()), MaxItems: ptr(t.Len())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem(), m)}
+	case reflect.Interface:
+		return &Schema{} // Any value
+	case reflect.Struct:
+		if t.Name() == "" || strings.Contains(t.Name(), "[") {
+			return g.object(t, m)
+		}
+		return g.component(t, m)
+	}
+
+	g.errs = append(g.errs, fmt.Errorf("openapi: unsupported type %s", t))
+	return &Schema{}
+}
+
+// component registers t under its name and returns a reference to it.
+func (g *generator) component(t reflect.Type, m mode) *Schema {
+	if name, ok := g.types[t]; ok {
+		return &Schema{Ref: refPrefix + name}
+	}
+
+	name := t.Name()
+	if _, taken := g.schemas[name]; taken {
+		// Same name in another package: prefix the package name
+		pkg := t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]
+		name = string(unicode.ToUpper(rune(pkg[0]))) + pkg[1:] + name
+	}
+	g.types[t] = name
+	g.schemas[name] = &Schema{} // Placeholder so recursive types terminate
+	*g.schemas[name] = *g.object(t, m)
+	return &Schema{Ref: refPrefix + name}
+}
+
+func (g *generator) object(t reflect.Type, m mode) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	g.fields(s, t, m)
+	return s
+}
+
+// fields adds the fields of struct t to s the way encoding/json sees them,
+// promoting the fields of embedded structs.
+func (g *generator) fields(s *Schema, t reflect.Type, m mode) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get(m.tag)
+		if tag == "" && m.tag != "json" {
+			tag = f.Tag.Get("json")
+		}
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.fields(s, ft, m)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		fs := g.schema(f.Type, m)
+		required := !strings.Contains(opts, "omitempty")
+		if m.request {
+			required = constrain(fs, f.Tag.Get("validate"))
+		}
+		s.Properties[name] = fs
+		if required {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+// constrain applies validate rules to the field schema s and reports
+// whether the field is required. Rules after "dive" apply to elements and
+// are left out.
+func constrain(s *Schema, rules string) bool {
+	if rules == "" {
+		return false
+	}
+	required := false
+	target := s
+	if len(s.AllOf) == 1 {
+		target = s.AllOf[0]
+	}
+	for _, rule := range strings.Split(rules, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		n, numErr := strconv.ParseFloat(param, 64)
+		switch name {
+		case "dive":
+			return required
+		case "required":
+			required = true
+		case "email":
+			target.Format = "email"
+		case "url":
+			target.Format = "uri"
+		case "datetime":
+			if param == "2006-01-02" {
+				target.Format = "date"
+			}
+		case "oneof":
+			target.Enum = strings.Fields(param)
+		case "min", "max", "gte", "lte", "gt":
+			if numErr != nil {
+				continue
+			}
+			bound(target, name, n)
+		}
+	}
+	return required
+}
+
+func bound(s *Schema, rule string, n float64) {
+	lower := rule == "min" || rule == "gte" || rule == "gt"
+	switch s.Type {
+	case "string":
+		if lower {
+			s.MinLength = ptr(int(n))
+		} else {
+			s.MaxLength = ptr(int(n))
+		}
+	case "array":
+		if lower {
+			s.MinItems = ptr(int(n))
+		} else {
+			s.MaxItems = ptr(int(n))
+		}
+	case "integer", "number":
+		if lower {
+			s.Minimum = ptr(n)
+			s.ExclusiveMinimum = rule == "gt"
+		} else {
+			s.Maximum = ptr(n)
+		}
+	}
+}
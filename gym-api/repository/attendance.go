@@ -0,0 +1,226 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"gym-api/listing"
+	"gym-api/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Attendance stores check-ins and the outcomes of synced offline scans.
+type Attendance interface {
+	ByID(ctx context.Context, id uint) (models.Attendance, error)
+	// List pages through all check-ins with the attendee, scanner and device.
+	List(ctx context.Context, spec listing.Spec, p listing.Params) (*listing.Page[models.Attendance], error)
+	// ForMember pages through one person's check-ins.
+	ForMember(ctx context.Context, memberID uint, spec listing.Spec, p listing.Params) (*listing.Page[models.Attendance], error)
+	// CheckedInOn reports whether the member has a check-in on day's date.
+	CheckedInOn(ctx context.Context, memberID uint, day time.Time) (bool, error)
+	// CheckedInSince returns which of memberIDs have checked in since since.
+	CheckedInSince(ctx context.Context, memberIDs []uint, since time.Time) ([]uint, error)
+	// CountOpen counts check-ins since since without a check-out; a nil
+	// branchID counts every branch.
+	CountOpen(ctx context.Context, branchID *uint, since time.Time) (int64, error)
+	// LockOccupancy locks the branch's occupancy row, or the one for
+	// scanners without a branch, until the transaction ends. Call it
+	// through Repos.Transaction.
+	LockOccupancy(ctx context.Context, branchID *uint) error
+	// LatestOpen finds the member's most recent check-in since since
+	// without a check-out.
+	LatestOpen(ctx context.Context, memberID uint, since time.Time) (models.Attendance, error)
+	// Visits returns the check-ins of a branch, or of every branch when
+	// branchID is nil, scanned from from until to, with only their scan and
+	// check-out times.
+	Visits(ctx context.Context, branchID *uint, from, to time.Time) ([]models.Attendance, error)
+	Create(ctx context.Context, attendance *models.Attendance) error
+	Save(ctx context.Context, attendance *models.Attendance) error
+	Delete(ctx context.Context, attendance *models.Attendance) error
+
+	// SyncedScan finds the stored outcome of an offline scan.
+	SyncedScan(ctx context.Context, deviceID uint, clientID string) (models.SyncedScan, error)
+	CreateSyncedScan(ctx context.Context, scan *models.SyncedScan) error
+}
+
+type attendance struct {
+	db *gorm.DB
+}
+
+func (r *attendance) ByID(ctx context.Context, id uint) (models.Attendance, error) {
+	var a models.Attendance
+	err := r.db.WithContext(ctx).First(&a, id).Error
+	return a, err
+}
+
+func (r *attendance) List(ctx context.Context, spec listing.Spec, p listing.Params) (*listing.Page[models.Attendance], error) {
+	// Attendance.TrainerID is the person who checked in (member or trainer),
+	// so 'Trainer' is the attendee and 'Admin' (ScannedBy) the staff who
+	// scanned.
+	db := r.db.WithContext(ctx).Model(&models.Attendance{}).Preload("Trainer").Preload("Admin").Preload("Device")
+	return listing.Find[models.Attendance](db, spec, p)
+}
+
+func (r *attendance) ForMember(ctx context.Context, memberID uint, spec listing.Spec, p listing.Params) (*listing.Page[models.Attendance], error) {
+	db := r.db.WithContext(ctx).Model(&models.Attendance{}).Where("trainer_id = ?", memberID)
+	return listing.Find[models.Attendance](db, spec, p)
+}
+
+func (r *attendance) CheckedInOn(ctx context.Context, memberID uint, day time.Time) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.Attendance{}).
+		Where("trainer_id = ? AND date = ?", memberID, day.Format("2006-01-02")).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *attendance) CheckedInSince(ctx context.Context, memberIDs []uint, since time.Time) ([]uint, error) {
+	var ids []uint
+	err := r.db.WithContext(ctx).Model(&models.Attendance{}).
+		Where("trainer_id IN ? AND scan_time >= ?", memberIDs, since).
+		Distinct().Pluck("trainer_id", &ids).Error
+	return ids, err
+}
+
+func (r *attendance) CountOpen(ctx context.Context, branchID *uint, since time.Time) (int64, error) {
+	var count int64
+	db := r.db.WithContext(ctx).Model(&models.Attendance{}).
+		Where("check_out_time IS NULL AND scan_time > ?", since)
+	if branchID != nil {
+		db = db.Where("branch_id = ?", *branchID)
+	}
+	err := db.Count(&count).Error
+	return count, err
+}
+
+func (r *attendance) LockOccupancy(ctx context.Context, branchID *uint) error {
+	var lock models.OccupancyLock
+	if branchID != nil {
+		lock.BranchID = *branchID
+	}
+	db := r.db.WithContext(ctx)
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&lock).Error; err != nil {
+		return err
+	}
+	return db.Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate}).
+		Where("branch_id = ?", lock.BranchID).First(&lock).Error
+}
+
+func (r *attendance) LatestOpen(ctx context.Context, memberID uint, since time.Time) (models.Attendance, error) {
+	var a models.Attendance
+	err := r.db.WithContext(ctx).
+		Where("trainer_id = ? AND check_out_time IS NULL AND scan_time > ?", memberID, since).
+		Order("scan_time desc").
+		First(&a).Error
+	return a, err
+}
+
+func (r *attendance) Visits(ctx context.Context, branchID *uint, from, to time.Time) ([]models.Attendance, error) {
+	var visits []models.Attendance
+	db := r.db.WithContext(ctx).Select("scan_time", "check_out_time").
+		Where("scan_time >= ? AND scan_time < ?", from, to)
+	if branchID != nil {
+		db = db.Where("branch_id = ?", *branchID)
+	}
+	err := db.Find(&visits).Error
+	return visits, err
+}
+
+func (r *attendance) Create(ctx context.Context, a *models.Attendance) error {
+	return r.db.WithContext(ctx).Create(a).Error
+}
+
+func (r *attendance) Save(ctx context.Context, a *models.Attendance) error {
+	return r.db.WithContext(ctx).Save(a).Error
+}
+
+func (r *attendance) Delete(ctx context.Context, a *models.Attendance) error {
+	return r.db.WithContext(ctx).Delete(a).Error
+}
+
+func (r *attendance) SyncedScan(ctx context.Context, deviceID uint, clientID string) (models.SyncedScan, error) {
+	var scan models.SyncedScan
+	err := r.db.WithContext(ctx).Where("device_id = ? AND client_id = ?", deviceID, clientID).First(&scan).Error
+	return scan, err
+}
+
+func (r *attendance) CreateSyncedScan(ctx context.Context, scan *models.SyncedScan) error {
+	return r.db.WithContext(ctx).Create(scan).Error
+}
+
+// Branches stores gym locations.
+type Branches interface {
+	// List returns every branch by name.
+	List(ctx context.Context) ([]models.Branch, error)
+	ByID(ctx context.Context, id uint) (models.Branch, error)
+	Create(ctx context.Context, branch *models.Branch) error
+}
+
+type branches struct {
+	db *gorm.DB
+}
+
+func (r *branches) List(ctx context.Context) ([]models.Branch, error) {
+	var list []models.Branch
+	err := r.db.WithContext(ctx).Order("name asc").Find(&list).Error
+	return list, err
+}
+
+func (r *branches) ByID(ctx context.Context, id uint) (models.Branch, error) {
+	var branch models.Branch
+	err := r.db.WithContext(ctx).First(&branch, id).Error
+	return branch, err
+}
+
+func (r *branches) Create(ctx context.Context, branch *models.Branch) error {
+	return r.db.WithContext(ctx).Create(branch).Error
+}
+
+// Incidents stores rejected check-ins for admin review.
+type Incidents interface {
+	// List returns the incidents matching filter with their member, newest
+	// first.
+	List(ctx context.Context, filter IncidentFilter) ([]models.Incident, error)
+	ByID(ctx context.Context, id uint) (models.Incident, error)
+	Create(ctx context.Context, incident *models.Incident) error
+	Save(ctx context.Context, incident *models.Incident) error
+}
+
+// IncidentFilter narrows an incident list. Zero fields match everything.
+type IncidentFilter struct {
+	Status   string
+	MemberID uint
+}
+
+type incidents struct {
+	db *gorm.DB
+}
+
+func (r *incidents) List(ctx context.Context, filter IncidentFilter) ([]models.Incident, error) {
+	list := []models.Incident{}
+	db := r.db.WithContext(ctx).Preload("Member")
+	if filter.Status != "" {
+		db = db.Where("status = ?", filter.Status)
+	}
+	if filter.MemberID != 0 {
+		db = db.Where("member_id = ?", filter.MemberID)
+	}
+	err := db.Order("created_at desc").Find(&list).Error
+	return list, err
+}
+
+func (r *incidents) ByID(ctx context.Context, id uint) (models.Incident, error) {
+	var incident models.Incident
+	err := r.db.WithContext(ctx).First(&incident, id).Error
+	return incident, err
+}
+
+func (r *incidents) Create(ctx context.Context, incident *models.Incident) error {
+	return r.db.WithContext(ctx).Create(incident).Error
+}
+
+func (r *incidents) Save(ctx context.Context, incident *models.Incident) error {
+	return r.db.WithContext(ctx).Save(incident).Error
+}
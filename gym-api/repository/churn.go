@@ -0,0 +1,30 @@
+package repository
+
+import (
+	"context"
+
+	"gym-api/models"
+
+	"gorm.io/gorm"
+)
+
+// ChurnRisks stores the members' churn scores. Package churn computes
+// them.
+type ChurnRisks interface {
+	// AtLevels returns the scores at any of levels with their member and
+	// package, highest first.
+	AtLevels(ctx context.Context, levels []string) ([]models.ChurnRisk, error)
+}
+
+type churnRisks struct {
+	db *gorm.DB
+}
+
+func (r *churnRisks) AtLevels(ctx context.Context, levels []string) ([]models.ChurnRisk, error) {
+	risks := []models.ChurnRisk{}
+	err := r.db.WithContext(ctx).Preload("Member").Preload("Member.Package").
+		Where("level IN ?", levels).
+		Order("score desc").
+		Find(&risks).Error
+	return risks, err
+}
@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"context"
+
+	"gym-api/models"
+
+	"gorm.io/gorm"
+)
+
+// Database reports on the database itself, for the readiness probe.
+type Database interface {
+	// Ping checks that the database answers.
+	Ping(ctx context.Context) error
+	// Migrated reports whether every table in models.Tables exists.
+	Migrated(ctx context.Context) bool
+}
+
+type database struct {
+	db *gorm.DB
+}
+
+func (r *database) Ping(ctx context.Context) error {
+	sqlDB, err := r.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+func (r *database) Migrated(ctx context.Context) bool {
+	migrator := r.db.WithContext(ctx).Migrator()
+	for _, table := range models.Tables() {
+		if !migrator.HasTable(table) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"context"
+
+	"gym-api/models"
+
+	"gorm.io/gorm"
+)
+
+// Devices stores the kiosks and scanner apps that sign in with an API key.
+type Devices interface {
+	// List returns every device, revoked or not, by name.
+	List(ctx context.Context) ([]models.Device, error)
+	ByID(ctx context.Context, id uint) (models.Device, error)
+	Create(ctx context.Context, device *models.Device) error
+	Save(ctx context.Context, device *models.Device) error
+}
+
+type devices struct {
+	db *gorm.DB
+}
+
+func (r *devices) List(ctx context.Context) ([]models.Device, error) {
+	var list []models.Device
+	err := r.db.WithContext(ctx).Order("name asc").Find(&list).Error
+	return list, err
+}
+
+func (r *devices) ByID(ctx context.Context, id uint) (models.Device, error) {
+	var device models.Device
+	err := r.db.WithContext(ctx).First(&device, id).Error
+	return device, err
+}
+
+func (r *devices) Create(ctx context.Context, device *models.Device) error {
+	return r.db.WithContext(ctx).Create(device).Error
+}
+
+func (r *devices) Save(ctx context.Context, device *models.Device) error {
+	return r.db.WithContext(ctx).Save(device).Error
+}
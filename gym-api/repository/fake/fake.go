@@ -0,0 +1,806 @@
+// Package fake keeps the repositories in memory, so the business rules in
+// package services can be tested without a database. Gates, AuditLogs,
+// Invites and TwoFactor are not faked and stay nil. Lookups return
+// gorm.ErrRecordNotFound and duplicate emails and device keys
+// gorm.ErrDuplicatedKey, as the GORM implementations do.
+//
+// Lists ignore the search, filter and sort parameters and return every
+// row in one page.
+package fake
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"gym-api/listing"
+	"gym-api/models"
+	"gym-api/repository"
+
+	"gorm.io/gorm"
+)
+
+// Store holds the rows. Tests seed it through the Add methods and inspect
+// the exported slices afterwards.
+type Store struct {
+	mu sync.Mutex
+
+	Users         []models.User
+	Packages      []models.Package
+	Subscriptions []models.Subscription
+	Attendance    []models.Attendance
+	Branches      []models.Branch
+	Incidents     []models.Incident
+	Devices       []models.Device
+	Notifications []models.NotificationLog
+	OptOuts       []models.NotificationOptOut
+	ChurnRisks    []models.ChurnRisk
+	SyncedScans   []models.SyncedScan
+	Registrations []models.Registration
+
+	nextID uint
+}
+
+func New() *Store {
+	return &Store{}
+}
+
+// Repos returns repositories over s. Transactions run their function
+// directly; nothing is rolled back.
+func (s *Store) Repos() repository.Repos {
+	return repository.Repos{
+		Users:         users{s},
+		Packages:      packages{s},
+		Subscriptions: subscriptions{s},
+		Attendance:    attendance{s},
+		Branches:      branches{s},
+		Incidents:     incidents{s},
+		Devices:       devices{s},
+		Notifications: notifications{s},
+		ChurnRisks:    churnRisks{s},
+		Database:      database{},
+		Registrations: registrations{s},
+	}
+}
+
+func (s *Store) id() uint {
+	s.nextID++
+	return s.nextID
+}
+
+// AddUser stores user, giving it an ID and an email address if it has
+// none, and returns it.
+func (s *Store) AddUser(user models.User) models.User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if user.ID == 0 {
+		user.ID = s.id()
+	}
+	if user.Email == "" {
+		user.Email = fmt.Sprintf("user%d@example.com", user.ID)
+	}
+	s.Users = append(s.Users, user)
+	return user
+}
+
+func (s *Store) AddPackage(pkg models.Package) models.Package {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if pkg.ID == 0 {
+		pkg.ID = s.id()
+	}
+	s.Packages = append(s.Packages, pkg)
+	return pkg
+}
+
+func (s *Store) AddBranch(branch models.Branch) models.Branch {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if branch.ID == 0 {
+		branch.ID = s.id()
+	}
+	s.Branches = append(s.Branches, branch)
+	return branch
+}
+
+func (s *Store) AddAttendance(a models.Attendance) models.Attendance {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if a.ID == 0 {
+		a.ID = s.id()
+	}
+	s.Attendance = append(s.Attendance, a)
+	return a
+}
+
+// User returns the stored user with id, or the zero User.
+func (s *Store) User(id uint) models.User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i := s.userIndex(id); i >= 0 {
+		return s.Users[i]
+	}
+	return models.User{}
+}
+
+func (s *Store) userIndex(id uint) int {
+	return slices.IndexFunc(s.Users, func(u models.User) bool { return u.ID == id })
+}
+
+// withPackage loads user's package, as ByID preloads it.
+func (s *Store) withPackage(user models.User) models.User {
+	user.Package = nil
+	if user.PackageID != nil {
+		if i := slices.IndexFunc(s.Packages, func(p models.Package) bool { return p.ID == *user.PackageID }); i >= 0 {
+			pkg := s.Packages[i]
+			user.Package = &pkg
+		}
+	}
+	return user
+}
+
+func page[T any](rows []T) *listing.Page[T] {
+	return &listing.Page[T]{Data: slices.Clone(rows), Total: int64(len(rows)), Page: 1, Pages: 1}
+}
+
+type users struct{ s *Store }
+
+func (r users) ByID(_ context.Context, id uint) (models.User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	i := r.s.userIndex(id)
+	if i < 0 {
+		return models.User{}, gorm.ErrRecordNotFound
+	}
+	return r.s.withPackage(r.s.Users[i]), nil
+}
+
+func (r users) ByIDs(_ context.Context, ids []uint) ([]models.User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var list []models.User
+	for _, u := range r.s.Users {
+		if slices.Contains(ids, u.ID) {
+			list = append(list, r.s.withPackage(u))
+		}
+	}
+	return list, nil
+}
+
+func (r users) ByEmail(_ context.Context, email string) (models.User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, u := range r.s.Users {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+	return models.User{}, gorm.ErrRecordNotFound
+}
+
+func (r users) List(_ context.Context, role models.Role, _ listing.Spec, _ listing.Params) (*listing.Page[models.User], error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var list []models.User
+	for _, u := range r.s.Users {
+		if u.Role == role {
+			list = append(list, u)
+		}
+	}
+	return page(list), nil
+}
+
+func (r users) taken(user *models.User) bool {
+	return slices.ContainsFunc(r.s.Users, func(u models.User) bool { return u.Email == user.Email && u.ID != user.ID })
+}
+
+func (r users) Create(_ context.Context, user *models.User) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if r.taken(user) {
+		return gorm.ErrDuplicatedKey
+	}
+	if user.ID == 0 {
+		user.ID = r.s.id()
+	}
+	if user.Role == "" {
+		user.Role = models.RoleMember
+	}
+	if user.MembershipStatus == "" {
+		user.MembershipStatus = "active"
+	}
+	user.IsActive = true // The schema default; callers that want false update it after
+	user.CreatedAt, user.UpdatedAt = time.Now(), time.Now()
+	r.s.Users = append(r.s.Users, *user)
+	return nil
+}
+
+func (r users) Save(_ context.Context, user *models.User) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if r.taken(user) {
+		return gorm.ErrDuplicatedKey
+	}
+	saved := *user
+	saved.Package = nil
+	saved.UpdatedAt = time.Now()
+	if i := r.s.userIndex(user.ID); i >= 0 {
+		r.s.Users[i] = saved
+	} else {
+		r.s.Users = append(r.s.Users, saved)
+	}
+	return nil
+}
+
+func (r users) SetMembershipStatus(_ context.Context, id uint, status string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if i := r.s.userIndex(id); i >= 0 {
+		r.s.Users[i].MembershipStatus = status
+	}
+	return nil
+}
+
+func (r users) SetPushToken(_ context.Context, id uint, token string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if i := r.s.userIndex(id); i >= 0 {
+		r.s.Users[i].PushToken = token
+	}
+	return nil
+}
+
+func (r users) Delete(_ context.Context, user *models.User) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	r.s.Users = slices.DeleteFunc(r.s.Users, func(u models.User) bool { return u.ID == user.ID })
+	return nil
+}
+
+func (r users) PendingInvite(context.Context, uint) (bool, error) {
+	return false, nil
+}
+
+type packages struct{ s *Store }
+
+func (r packages) ByID(_ context.Context, id uint) (models.Package, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, p := range r.s.Packages {
+		if p.ID == id {
+			return p, nil
+		}
+	}
+	return models.Package{}, gorm.ErrRecordNotFound
+}
+
+func (r packages) List(context.Context, listing.Spec, listing.Params) (*listing.Page[models.Package], error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return page(r.s.Packages), nil
+}
+
+func (r packages) Create(_ context.Context, pkg *models.Package) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	pkg.ID = r.s.id()
+	r.s.Packages = append(r.s.Packages, *pkg)
+	return nil
+}
+
+func (r packages) Save(_ context.Context, pkg *models.Package) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for i, p := range r.s.Packages {
+		if p.ID == pkg.ID {
+			r.s.Packages[i] = *pkg
+			return nil
+		}
+	}
+	r.s.Packages = append(r.s.Packages, *pkg)
+	return nil
+}
+
+func (r packages) Delete(_ context.Context, id uint) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	n := len(r.s.Packages)
+	r.s.Packages = slices.DeleteFunc(r.s.Packages, func(p models.Package) bool { return p.ID == id })
+	if len(r.s.Packages) == n {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+type subscriptions struct{ s *Store }
+
+func (r subscriptions) Create(_ context.Context, sub *models.Subscription) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	sub.ID = r.s.id()
+	sub.CreatedAt = time.Now()
+	r.s.Subscriptions = append(r.s.Subscriptions, *sub)
+	return nil
+}
+
+type attendance struct{ s *Store }
+
+func (r attendance) index(id uint) int {
+	return slices.IndexFunc(r.s.Attendance, func(a models.Attendance) bool { return a.ID == id })
+}
+
+func (r attendance) ByID(_ context.Context, id uint) (models.Attendance, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if i := r.index(id); i >= 0 {
+		return r.s.Attendance[i], nil
+	}
+	return models.Attendance{}, gorm.ErrRecordNotFound
+}
+
+func (r attendance) List(context.Context, listing.Spec, listing.Params) (*listing.Page[models.Attendance], error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return page(r.s.Attendance), nil
+}
+
+func (r attendance) ForMember(_ context.Context, memberID uint, _ listing.Spec, _ listing.Params) (*listing.Page[models.Attendance], error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var list []models.Attendance
+	for _, a := range r.s.Attendance {
+		if a.TrainerID == memberID {
+			list = append(list, a)
+		}
+	}
+	return page(list), nil
+}
+
+func sameDay(a, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd
+}
+
+func (r attendance) CheckedInOn(_ context.Context, memberID uint, day time.Time) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return slices.ContainsFunc(r.s.Attendance, func(a models.Attendance) bool {
+		return a.TrainerID == memberID && sameDay(a.Date, day)
+	}), nil
+}
+
+func (r attendance) CheckedInSince(_ context.Context, memberIDs []uint, since time.Time) ([]uint, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var ids []uint
+	for _, a := range r.s.Attendance {
+		if slices.Contains(memberIDs, a.TrainerID) && !a.ScanTime.Before(since) {
+			ids = append(ids, a.TrainerID)
+		}
+	}
+	return ids, nil
+}
+
+// open reports whether a is a check-in since since without a check-out,
+// at branchID or, when branchID is nil, anywhere.
+func open(a models.Attendance, branchID *uint, since time.Time) bool {
+	if a.CheckOutTime != nil || a.ScanTime.Before(since) {
+		return false
+	}
+	return branchID == nil || (a.BranchID != nil && *a.BranchID == *branchID)
+}
+
+func (r attendance) CountOpen(_ context.Context, branchID *uint, since time.Time) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var n int64
+	for _, a := range r.s.Attendance {
+		if open(a, branchID, since) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// LockOccupancy does nothing: the fakes are not shared between processes.
+func (r attendance) LockOccupancy(context.Context, *uint) error {
+	return nil
+}
+
+func (r attendance) LatestOpen(_ context.Context, memberID uint, since time.Time) (models.Attendance, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var latest *models.Attendance
+	for i, a := range r.s.Attendance {
+		if a.TrainerID == memberID && open(a, nil, since) && (latest == nil || a.ScanTime.After(latest.ScanTime)) {
+			latest = &r.s.Attendance[i]
+		}
+	}
+	if latest == nil {
+		return models.Attendance{}, gorm.ErrRecordNotFound
+	}
+	return *latest, nil
+}
+
+func (r attendance) Visits(_ context.Context, branchID *uint, from, to time.Time) ([]models.Attendance, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var visits []models.Attendance
+	for _, a := range r.s.Attendance {
+		if !a.ScanTime.Before(from) && a.ScanTime.Before(to) && (branchID == nil || a.BranchID != nil && *a.BranchID == *branchID) {
+			visits = append(visits, models.Attendance{ScanTime: a.ScanTime, CheckOutTime: a.CheckOutTime})
+		}
+	}
+	return visits, nil
+}
+
+func (r attendance) Create(_ context.Context, a *models.Attendance) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	a.ID = r.s.id()
+	r.s.Attendance = append(r.s.Attendance, *a)
+	return nil
+}
+
+func (r attendance) Save(_ context.Context, a *models.Attendance) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if i := r.index(a.ID); i >= 0 {
+		r.s.Attendance[i] = *a
+		return nil
+	}
+	r.s.Attendance = append(r.s.Attendance, *a)
+	return nil
+}
+
+func (r attendance) Delete(_ context.Context, a *models.Attendance) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	r.s.Attendance = slices.DeleteFunc(r.s.Attendance, func(row models.Attendance) bool { return row.ID == a.ID })
+	return nil
+}
+
+func (r attendance) SyncedScan(_ context.Context, deviceID uint, clientID string) (models.SyncedScan, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, scan := range r.s.SyncedScans {
+		if scan.DeviceID == deviceID && scan.ClientID == clientID {
+			return scan, nil
+		}
+	}
+	return models.SyncedScan{}, gorm.ErrRecordNotFound
+}
+
+func (r attendance) CreateSyncedScan(_ context.Context, scan *models.SyncedScan) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, s := range r.s.SyncedScans {
+		if s.DeviceID == scan.DeviceID && s.ClientID == scan.ClientID {
+			return gorm.ErrDuplicatedKey
+		}
+	}
+	scan.ID = r.s.id()
+	scan.CreatedAt = time.Now()
+	r.s.SyncedScans = append(r.s.SyncedScans, *scan)
+	return nil
+}
+
+type branches struct{ s *Store }
+
+func (r branches) List(context.Context) ([]models.Branch, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return slices.Clone(r.s.Branches), nil
+}
+
+func (r branches) ByID(_ context.Context, id uint) (models.Branch, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, b := range r.s.Branches {
+		if b.ID == id {
+			return b, nil
+		}
+	}
+	return models.Branch{}, gorm.ErrRecordNotFound
+}
+
+func (r branches) Create(_ context.Context, branch *models.Branch) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	branch.ID = r.s.id()
+	r.s.Branches = append(r.s.Branches, *branch)
+	return nil
+}
+
+type incidents struct{ s *Store }
+
+func (r incidents) index(id uint) int {
+	return slices.IndexFunc(r.s.Incidents, func(i models.Incident) bool { return i.ID == id })
+}
+
+func (r incidents) List(_ context.Context, filter repository.IncidentFilter) ([]models.Incident, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	list := []models.Incident{}
+	for _, incident := range slices.Backward(r.s.Incidents) {
+		if (filter.Status == "" || incident.Status == filter.Status) &&
+			(filter.MemberID == 0 || incident.MemberID == filter.MemberID) {
+			if i := r.s.userIndex(incident.MemberID); i >= 0 {
+				incident.Member = r.s.Users[i]
+			}
+			list = append(list, incident)
+		}
+	}
+	return list, nil
+}
+
+func (r incidents) ByID(_ context.Context, id uint) (models.Incident, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if i := r.index(id); i >= 0 {
+		return r.s.Incidents[i], nil
+	}
+	return models.Incident{}, gorm.ErrRecordNotFound
+}
+
+func (r incidents) Create(_ context.Context, incident *models.Incident) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	incident.ID = r.s.id()
+	incident.CreatedAt = time.Now()
+	if incident.Status == "" {
+		incident.Status = "open"
+	}
+	r.s.Incidents = append(r.s.Incidents, *incident)
+	return nil
+}
+
+func (r incidents) Save(_ context.Context, incident *models.Incident) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	i := r.index(incident.ID)
+	if i < 0 {
+		return gorm.ErrRecordNotFound
+	}
+	r.s.Incidents[i] = *incident
+	return nil
+}
+
+type devices struct{ s *Store }
+
+func (r devices) index(id uint) int {
+	return slices.IndexFunc(r.s.Devices, func(d models.Device) bool { return d.ID == id })
+}
+
+func (r devices) List(context.Context) ([]models.Device, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return slices.Clone(r.s.Devices), nil
+}
+
+func (r devices) ByID(_ context.Context, id uint) (models.Device, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if i := r.index(id); i >= 0 {
+		return r.s.Devices[i], nil
+	}
+	return models.Device{}, gorm.ErrRecordNotFound
+}
+
+func (r devices) Create(_ context.Context, device *models.Device) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if slices.ContainsFunc(r.s.Devices, func(d models.Device) bool { return d.KeyHash == device.KeyHash }) {
+		return gorm.ErrDuplicatedKey
+	}
+	device.ID = r.s.id()
+	device.CreatedAt = time.Now()
+	r.s.Devices = append(r.s.Devices, *device)
+	return nil
+}
+
+func (r devices) Save(_ context.Context, device *models.Device) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	i := r.index(device.ID)
+	if i < 0 {
+		return gorm.ErrRecordNotFound
+	}
+	r.s.Devices[i] = *device
+	return nil
+}
+
+type notifications struct{ s *Store }
+
+func (r notifications) Recent(_ context.Context, userID uint, limit int) ([]models.NotificationLog, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	logs := []models.NotificationLog{}
+	for _, entry := range slices.Backward(r.s.Notifications) {
+		if entry.UserID == userID && len(logs) < limit {
+			logs = append(logs, entry)
+		}
+	}
+	return logs, nil
+}
+
+func (r notifications) List(context.Context, listing.Spec, listing.Params) (*listing.Page[models.NotificationLog], error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return page(r.s.Notifications), nil
+}
+
+func (r notifications) OptOuts(_ context.Context, userID uint) ([]models.NotificationOptOut, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var optOuts []models.NotificationOptOut
+	for _, o := range r.s.OptOuts {
+		if o.UserID == userID {
+			optOuts = append(optOuts, o)
+		}
+	}
+	return optOuts, nil
+}
+
+func (r notifications) SetOptOuts(_ context.Context, userID uint, optOuts []models.NotificationOptOut) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	r.s.OptOuts = slices.DeleteFunc(r.s.OptOuts, func(o models.NotificationOptOut) bool { return o.UserID == userID })
+	for _, o := range optOuts {
+		o.ID = r.s.id()
+		r.s.OptOuts = append(r.s.OptOuts, o)
+	}
+	return nil
+}
+
+type churnRisks struct{ s *Store }
+
+func (r churnRisks) AtLevels(_ context.Context, levels []string) ([]models.ChurnRisk, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	risks := []models.ChurnRisk{}
+	for _, risk := range r.s.ChurnRisks {
+		if slices.Contains(levels, risk.Level) {
+			if i := r.s.userIndex(risk.MemberID); i >= 0 {
+				risk.Member = r.s.withPackage(r.s.Users[i])
+			}
+			risks = append(risks, risk)
+		}
+	}
+	slices.SortStableFunc(risks, func(a, b models.ChurnRisk) int { return cmp.Compare(b.Score, a.Score) })
+	return risks, nil
+}
+
+// database is always up and migrated.
+type database struct{}
+
+func (database) Ping(context.Context) error    { return nil }
+func (database) Migrated(context.Context) bool { return true }
+
+type registrations struct{ s *Store }
+
+// find returns the registration matching keep with its user, as the GORM
+// implementation preloads it.
+func (r registrations) find(keep func(models.Registration) bool) (models.Registration, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, reg := range r.s.Registrations {
+		if keep(reg) {
+			if i := r.s.userIndex(reg.UserID); i >= 0 {
+				reg.User = r.s.Users[i]
+			}
+			return reg, nil
+		}
+	}
+	return models.Registration{}, gorm.ErrRecordNotFound
+}
+
+func (r registrations) update(id uint, change func(*models.Registration)) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if i := slices.IndexFunc(r.s.Registrations, func(reg models.Registration) bool { return reg.ID == id }); i >= 0 {
+		change(&r.s.Registrations[i])
+	}
+}
+
+func (r registrations) Pending(context.Context) ([]models.Registration, error) {
+	r.s.mu.Lock()
+	ids := []uint{}
+	for _, reg := range r.s.Registrations {
+		if reg.ApprovedAt == nil {
+			ids = append(ids, reg.ID)
+		}
+	}
+	r.s.mu.Unlock()
+	list := []models.Registration{}
+	for _, id := range ids {
+		reg, err := r.find(func(reg models.Registration) bool { return reg.ID == id })
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, reg)
+	}
+	return list, nil
+}
+
+func (r registrations) PendingByID(_ context.Context, id uint) (models.Registration, error) {
+	return r.find(func(reg models.Registration) bool { return reg.ID == id && reg.ApprovedAt == nil })
+}
+
+func (r registrations) ByTokenHash(_ context.Context, hash string) (models.Registration, error) {
+	return r.find(func(reg models.Registration) bool { return reg.TokenHash == hash })
+}
+
+func (r registrations) ForUser(_ context.Context, userID uint) (models.Registration, error) {
+	return r.find(func(reg models.Registration) bool { return reg.UserID == userID })
+}
+
+func (r registrations) Create(ctx context.Context, registration *models.Registration) error {
+	if err := (users{r.s}).Create(ctx, &registration.User); err != nil {
+		return err
+	}
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	registration.ID = r.s.id()
+	registration.UserID = registration.User.ID
+	registration.CreatedAt = time.Now()
+	stored := *registration
+	stored.User = models.User{}
+	r.s.Registrations = append(r.s.Registrations, stored)
+	return nil
+}
+
+func (r registrations) UnverifiedByEmail(_ context.Context, email string) (models.Registration, error) {
+	user, err := (users{r.s}).ByEmail(context.Background(), email)
+	if err != nil {
+		return models.Registration{}, err
+	}
+	return r.find(func(reg models.Registration) bool { return reg.UserID == user.ID && reg.EmailVerifiedAt == nil })
+}
+
+func (r registrations) SetToken(_ context.Context, registration *models.Registration) error {
+	r.update(registration.ID, func(reg *models.Registration) {
+		reg.TokenHash, reg.ExpiresAt = registration.TokenHash, registration.ExpiresAt
+	})
+	return nil
+}
+
+func (r registrations) MarkSent(_ context.Context, registration *models.Registration, at time.Time) error {
+	r.update(registration.ID, func(reg *models.Registration) { reg.SentAt = &at })
+	return nil
+}
+
+func (r registrations) MarkVerified(_ context.Context, id uint, at time.Time) error {
+	r.update(id, func(reg *models.Registration) {
+		if reg.EmailVerifiedAt == nil {
+			reg.EmailVerifiedAt = &at
+		}
+	})
+	return nil
+}
+
+func (r registrations) Approve(_ context.Context, registration models.Registration, staffID uint, at time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	i := slices.IndexFunc(r.s.Registrations, func(reg models.Registration) bool { return reg.ID == registration.ID && reg.ApprovedAt == nil })
+	if i < 0 {
+		return gorm.ErrRecordNotFound
+	}
+	r.s.Registrations[i].ApprovedAt, r.s.Registrations[i].ApprovedBy = &at, &staffID
+	if j := r.s.userIndex(registration.UserID); j >= 0 && r.s.Users[j].MembershipStatus == "pending" {
+		r.s.Users[j].MembershipStatus = "active"
+	}
+	return nil
+}
+
+func (r registrations) Delete(_ context.Context, registration models.Registration) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	r.s.Registrations = slices.DeleteFunc(r.s.Registrations, func(reg models.Registration) bool { return reg.ID == registration.ID })
+	r.s.Users = slices.DeleteFunc(r.s.Users, func(u models.User) bool { return u.ID == registration.UserID })
+	return nil
+}
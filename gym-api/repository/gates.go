@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+
+	"gym-api/listing"
+	"gym-api/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Gates stores the turnstiles and door relays.
+type Gates interface {
+	// List returns every gate, enabled or not, by name.
+	List(ctx context.Context) ([]models.Gate, error)
+	ByID(ctx context.Context, id uint) (models.Gate, error)
+	Create(ctx context.Context, gate *models.Gate) error
+	Save(ctx context.Context, gate *models.Gate) error
+	Delete(ctx context.Context, id uint) error
+	// UseNonce records a nonce the gate signed a webhook with. It reports
+	// false if the gate has used it before.
+	UseNonce(ctx context.Context, gateID uint, nonce string) (bool, error)
+}
+
+type gates struct {
+	db *gorm.DB
+}
+
+func (r *gates) List(ctx context.Context) ([]models.Gate, error) {
+	var list []models.Gate
+	err := r.db.WithContext(ctx).Order("name asc").Find(&list).Error
+	return list, err
+}
+
+func (r *gates) ByID(ctx context.Context, id uint) (models.Gate, error) {
+	var gate models.Gate
+	err := r.db.WithContext(ctx).First(&gate, id).Error
+	return gate, err
+}
+
+func (r *gates) Create(ctx context.Context, gate *models.Gate) error {
+	return r.db.WithContext(ctx).Create(gate).Error
+}
+
+func (r *gates) Save(ctx context.Context, gate *models.Gate) error {
+	return r.db.WithContext(ctx).Save(gate).Error
+}
+
+func (r *gates) Delete(ctx context.Context, id uint) error {
+	result := r.db.WithContext(ctx).Delete(&models.Gate{}, id)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
+
+func (r *gates) UseNonce(ctx context.Context, gateID uint, nonce string) (bool, error) {
+	used := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.GateNonce{GateID: gateID, Nonce: nonce})
+	return used.RowsAffected == 1, used.Error
+}
+
+// AuditLogs is the record of security-relevant events. Entries are written
+// by package audit.
+type AuditLogs interface {
+	// List pages through the entries whose action starts with prefix.
+	List(ctx context.Context, prefix string, spec listing.Spec, p listing.Params) (*listing.Page[models.AuditLog], error)
+}
+
+type auditLogs struct {
+	db *gorm.DB
+}
+
+func (r *auditLogs) List(ctx context.Context, prefix string, spec listing.Spec, p listing.Params) (*listing.Page[models.AuditLog], error) {
+	db := r.db.WithContext(ctx).Model(&models.AuditLog{})
+	if prefix != "" {
+		db = db.Where("action LIKE ?", prefix+"%")
+	}
+	return listing.Find[models.AuditLog](db, spec, p)
+}
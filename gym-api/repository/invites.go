@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"gym-api/models"
+
+	"gorm.io/gorm"
+)
+
+// Invites stores the invitations of staff and trainers, each with the
+// inactive account it is for.
+type Invites interface {
+	// Pending lists the invites not yet accepted, newest first, expired
+	// ones included.
+	Pending(ctx context.Context) ([]models.Invite, error)
+	PendingByID(ctx context.Context, id uint) (models.Invite, error)
+	PendingByTokenHash(ctx context.Context, hash string) (models.Invite, error)
+	// Create stores invite.User as an inactive account, then the invite.
+	Create(ctx context.Context, invite *models.Invite) error
+	// SetToken writes the invite's token hash and expiry.
+	SetToken(ctx context.Context, invite *models.Invite) error
+	MarkSent(ctx context.Context, invite *models.Invite, at time.Time) error
+	// Accept marks the invite accepted and activates its account with
+	// passwordHash. It returns gorm.ErrRecordNotFound if the invite was
+	// accepted already, so a link opened twice is used once.
+	Accept(ctx context.Context, invite models.Invite, passwordHash string, at time.Time) error
+	// Delete removes the invite and its account.
+	Delete(ctx context.Context, invite models.Invite) error
+}
+
+type invites struct {
+	db *gorm.DB
+}
+
+func (r *invites) pending(ctx context.Context) *gorm.DB {
+	return r.db.WithContext(ctx).Preload("User").Where("accepted_at IS NULL")
+}
+
+func (r *invites) Pending(ctx context.Context) ([]models.Invite, error) {
+	list := []models.Invite{}
+	err := r.pending(ctx).Order("created_at desc").Find(&list).Error
+	return list, err
+}
+
+func (r *invites) PendingByID(ctx context.Context, id uint) (models.Invite, error) {
+	var invite models.Invite
+	err := r.pending(ctx).First(&invite, id).Error
+	return invite, err
+}
+
+func (r *invites) PendingByTokenHash(ctx context.Context, hash string) (models.Invite, error) {
+	var invite models.Invite
+	err := r.pending(ctx).Where("token_hash = ?", hash).First(&invite).Error
+	return invite, err
+}
+
+func (r *invites) Create(ctx context.Context, invite *models.Invite) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// IsActive defaults to true in the schema, so it is set explicitly
+		if err := tx.Create(&invite.User).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&invite.User).Update("is_active", false).Error; err != nil {
+			return err
+		}
+		invite.UserID = invite.User.ID
+		return tx.Omit("User").Create(invite).Error
+	})
+}
+
+func (r *invites) SetToken(ctx context.Context, invite *models.Invite) error {
+	return r.db.WithContext(ctx).Model(invite).
+		Updates(map[string]any{"token_hash": invite.TokenHash, "expires_at": invite.ExpiresAt}).Error
+}
+
+func (r *invites) MarkSent(ctx context.Context, invite *models.Invite, at time.Time) error {
+	return r.db.WithContext(ctx).Model(invite).Update("sent_at", at).Error
+}
+
+func (r *invites) Accept(ctx context.Context, invite models.Invite, passwordHash string, at time.Time) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Invite{}).
+			Where("id = ? AND accepted_at IS NULL", invite.ID).
+			Update("accepted_at", at)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Model(&models.User{}).Where("id = ?", invite.UserID).
+			Updates(map[string]any{"password_hash": passwordHash, "is_active": true}).Error
+	})
+}
+
+func (r *invites) Delete(ctx context.Context, invite models.Invite) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&invite).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.User{}, invite.UserID).Error
+	})
+}
@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"context"
+
+	"gym-api/listing"
+	"gym-api/models"
+
+	"gorm.io/gorm"
+)
+
+// Notifications stores the delivery log and the users' opt-outs. Entries
+// are logged by package notifications.
+type Notifications interface {
+	// Recent returns the latest limit deliveries to userID, newest first.
+	Recent(ctx context.Context, userID uint, limit int) ([]models.NotificationLog, error)
+	List(ctx context.Context, spec listing.Spec, p listing.Params) (*listing.Page[models.NotificationLog], error)
+	OptOuts(ctx context.Context, userID uint) ([]models.NotificationOptOut, error)
+	// SetOptOuts replaces the opt-outs of userID.
+	SetOptOuts(ctx context.Context, userID uint, optOuts []models.NotificationOptOut) error
+}
+
+type notifications struct {
+	db *gorm.DB
+}
+
+func (r *notifications) Recent(ctx context.Context, userID uint, limit int) ([]models.NotificationLog, error) {
+	logs := []models.NotificationLog{}
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at desc").Limit(limit).Find(&logs).Error
+	return logs, err
+}
+
+func (r *notifications) List(ctx context.Context, spec listing.Spec, p listing.Params) (*listing.Page[models.NotificationLog], error) {
+	return listing.Find[models.NotificationLog](r.db.WithContext(ctx).Model(&models.NotificationLog{}), spec, p)
+}
+
+func (r *notifications) OptOuts(ctx context.Context, userID uint) ([]models.NotificationOptOut, error) {
+	var optOuts []models.NotificationOptOut
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Find(&optOuts).Error
+	return optOuts, err
+}
+
+func (r *notifications) SetOptOuts(ctx context.Context, userID uint, optOuts []models.NotificationOptOut) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.NotificationOptOut{}).Error; err != nil {
+			return err
+		}
+		if len(optOuts) > 0 {
+			return tx.Create(&optOuts).Error
+		}
+		return nil
+	})
+}
@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+
+	"gym-api/listing"
+	"gym-api/models"
+
+	"gorm.io/gorm"
+)
+
+// Packages stores the membership packages on sale.
+type Packages interface {
+	ByID(ctx context.Context, id uint) (models.Package, error)
+	List(ctx context.Context, spec listing.Spec, p listing.Params) (*listing.Page[models.Package], error)
+	Create(ctx context.Context, pkg *models.Package) error
+	Save(ctx context.Context, pkg *models.Package) error
+	Delete(ctx context.Context, id uint) error
+}
+
+type packages struct {
+	db *gorm.DB
+}
+
+func (r *packages) ByID(ctx context.Context, id uint) (models.Package, error) {
+	var pkg models.Package
+	err := r.db.WithContext(ctx).First(&pkg, id).Error
+	return pkg, err
+}
+
+func (r *packages) List(ctx context.Context, spec listing.Spec, p listing.Params) (*listing.Page[models.Package], error) {
+	return listing.Find[models.Package](r.db.WithContext(ctx).Model(&models.Package{}), spec, p)
+}
+
+func (r *packages) Create(ctx context.Context, pkg *models.Package) error {
+	return r.db.WithContext(ctx).Create(pkg).Error
+}
+
+func (r *packages) Save(ctx context.Context, pkg *models.Package) error {
+	return r.db.WithContext(ctx).Save(pkg).Error
+}
+
+func (r *packages) Delete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Delete(&models.Package{}, id).Error
+}
+
+// Subscriptions is the history of package sales.
+type Subscriptions interface {
+	Create(ctx context.Context, sub *models.Subscription) error
+}
+
+type subscriptions struct {
+	db *gorm.DB
+}
+
+func (r *subscriptions) Create(ctx context.Context, sub *models.Subscription) error {
+	return r.db.WithContext(ctx).Create(sub).Error
+}
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"gym-api/models"
+
+	"gorm.io/gorm"
+)
+
+// Registrations stores members' self sign-ups until staff approve them.
+type Registrations interface {
+	// Pending lists the registrations not yet approved, oldest first.
+	Pending(ctx context.Context) ([]models.Registration, error)
+	PendingByID(ctx context.Context, id uint) (models.Registration, error)
+	ByTokenHash(ctx context.Context, hash string) (models.Registration, error)
+	// ForUser finds the registration of an account, approved or not.
+	ForUser(ctx context.Context, userID uint) (models.Registration, error)
+	// Create stores registration.User, then the registration.
+	Create(ctx context.Context, registration *models.Registration) error
+	// UnverifiedByEmail finds the registration of the account with email
+	// whose address is not confirmed yet.
+	UnverifiedByEmail(ctx context.Context, email string) (models.Registration, error)
+	// SetToken writes the registration's token hash and expiry.
+	SetToken(ctx context.Context, registration *models.Registration) error
+	MarkSent(ctx context.Context, registration *models.Registration, at time.Time) error
+	// MarkVerified records the email address as confirmed, unless it was
+	// already.
+	MarkVerified(ctx context.Context, id uint, at time.Time) error
+	// Approve marks the registration approved by staffID and activates the
+	// member. It returns gorm.ErrRecordNotFound if it was approved already,
+	// so two people approving at once do it once.
+	Approve(ctx context.Context, registration models.Registration, staffID uint, at time.Time) error
+	// Delete removes the registration and its account.
+	Delete(ctx context.Context, registration models.Registration) error
+}
+
+type registrations struct {
+	db *gorm.DB
+}
+
+func (r *registrations) pending(ctx context.Context) *gorm.DB {
+	return r.db.WithContext(ctx).Preload("User").Where("approved_at IS NULL")
+}
+
+func (r *registrations) Pending(ctx context.Context) ([]models.Registration, error) {
+	list := []models.Registration{}
+	err := r.pending(ctx).Order("created_at").Find(&list).Error
+	return list, err
+}
+
+func (r *registrations) PendingByID(ctx context.Context, id uint) (models.Registration, error) {
+	var registration models.Registration
+	err := r.pending(ctx).First(&registration, id).Error
+	return registration, err
+}
+
+func (r *registrations) ByTokenHash(ctx context.Context, hash string) (models.Registration, error) {
+	var registration models.Registration
+	err := r.db.WithContext(ctx).Where("token_hash = ?", hash).First(&registration).Error
+	return registration, err
+}
+
+func (r *registrations) ForUser(ctx context.Context, userID uint) (models.Registration, error) {
+	var registration models.Registration
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).First(&registration).Error
+	return registration, err
+}
+
+func (r *registrations) Create(ctx context.Context, registration *models.Registration) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&registration.User).Error; err != nil {
+			return err
+		}
+		registration.UserID = registration.User.ID
+		return tx.Omit("User").Create(registration).Error
+	})
+}
+
+func (r *registrations) UnverifiedByEmail(ctx context.Context, email string) (models.Registration, error) {
+	db := r.db.WithContext(ctx)
+	users := db.Model(&models.User{}).Select("id").Where("email = ?", email)
+	var registration models.Registration
+	err := db.Preload("User").
+		Where("user_id IN (?) AND email_verified_at IS NULL", users).
+		First(&registration).Error
+	return registration, err
+}
+
+func (r *registrations) SetToken(ctx context.Context, registration *models.Registration) error {
+	return r.db.WithContext(ctx).Model(registration).
+		Updates(map[string]any{"token_hash": registration.TokenHash, "expires_at": registration.ExpiresAt}).Error
+}
+
+func (r *registrations) MarkSent(ctx context.Context, registration *models.Registration, at time.Time) error {
+	return r.db.WithContext(ctx).Model(registration).Update("sent_at", at).Error
+}
+
+func (r *registrations) MarkVerified(ctx context.Context, id uint, at time.Time) error {
+	return r.db.WithContext(ctx).Model(&models.Registration{}).
+		Where("id = ? AND email_verified_at IS NULL", id).
+		Update("email_verified_at", at).Error
+}
+
+func (r *registrations) Approve(ctx context.Context, registration models.Registration, staffID uint, at time.Time) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Registration{}).
+			Where("id = ? AND approved_at IS NULL", registration.ID).
+			Updates(map[string]any{"approved_at": at, "approved_by": staffID})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Model(&models.User{}).
+			Where("id = ? AND membership_status = ?", registration.UserID, "pending").
+			Update("membership_status", "active").Error
+	})
+}
+
+func (r *registrations) Delete(ctx context.Context, registration models.Registration) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&registration).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.User{}, registration.UserID).Error
+	})
+}
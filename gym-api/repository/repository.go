@@ -0,0 +1,76 @@
+// Package repository is the storage layer behind package services. Each
+// repository is an interface over the GORM queries for one model, so
+// services can be tested with fakes; New returns the GORM implementations.
+//
+// Lookups return gorm.ErrRecordNotFound when nothing matches, and writes
+// return gorm.ErrDuplicatedKey on unique conflicts, whatever the
+// implementation.
+package repository
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// Repos is the set of repositories a service works with.
+type Repos struct {
+	Users         Users
+	Packages      Packages
+	Subscriptions Subscriptions
+	Attendance    Attendance
+	Branches      Branches
+	Incidents     Incidents
+	Devices       Devices
+	Gates         Gates
+	AuditLogs     AuditLogs
+	Notifications Notifications
+	ChurnRisks    ChurnRisks
+	Invites       Invites
+	Registrations Registrations
+	TwoFactor     TwoFactor
+	Database      Database
+
+	tx func(ctx context.Context, fn func(Repos) error) error
+}
+
+// New returns repositories reading and writing through db.
+func New(db *gorm.DB) Repos {
+	r := bind(db)
+	r.tx = func(ctx context.Context, fn func(Repos) error) error {
+		return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			return fn(bind(tx))
+		})
+	}
+	return r
+}
+
+func bind(db *gorm.DB) Repos {
+	return Repos{
+		Users:         &users{db},
+		Packages:      &packages{db},
+		Subscriptions: &subscriptions{db},
+		Attendance:    &attendance{db},
+		Branches:      &branches{db},
+		Incidents:     &incidents{db},
+		Devices:       &devices{db},
+		Gates:         &gates{db},
+		AuditLogs:     &auditLogs{db},
+		Notifications: &notifications{db},
+		ChurnRisks:    &churnRisks{db},
+		Invites:       &invites{db},
+		Registrations: &registrations{db},
+		TwoFactor:     &twoFactor{db},
+		Database:      &database{db},
+	}
+}
+
+// Transaction runs fn with repositories that share one transaction. It
+// commits when fn returns nil. Repos built by hand, such as fakes, run fn
+// directly.
+func (r Repos) Transaction(ctx context.Context, fn func(Repos) error) error {
+	if r.tx == nil {
+		return fn(r)
+	}
+	return r.tx(ctx, fn)
+}
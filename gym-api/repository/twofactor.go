@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"gym-api/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TwoFactor stores users' authenticator secrets and recovery codes.
+type TwoFactor interface {
+	// SetSecret stores the secret of an authenticator being set up.
+	SetSecret(ctx context.Context, userID uint, secret string) error
+	// ClaimStep records step as the last authenticator code the user
+	// signed in with. It reports false if that step or a later one was
+	// already used, even by a concurrent request.
+	ClaimStep(ctx context.Context, userID uint, step int64) (bool, error)
+	// UseRecoveryCode marks an unused recovery code as used. It reports
+	// false if the user has no unused code with that hash.
+	UseRecoveryCode(ctx context.Context, userID uint, hash string, at time.Time) (bool, error)
+	CountRecoveryCodes(ctx context.Context, userID uint) (int64, error)
+	// Enable turns two-factor authentication on.
+	Enable(ctx context.Context, userID uint, at time.Time) error
+	// ReplaceRecoveryCodes drops the user's recovery codes and stores
+	// hashes instead.
+	ReplaceRecoveryCodes(ctx context.Context, userID uint, hashes []string) error
+	// Clear turns two-factor authentication off and drops the secret and
+	// the recovery codes.
+	Clear(ctx context.Context, userID uint) error
+	// Policies lists the roles whose policy was ever set.
+	Policies(ctx context.Context) ([]models.TwoFactorPolicy, error)
+	// SetPolicy creates or replaces the policy for policy.Role.
+	SetPolicy(ctx context.Context, policy *models.TwoFactorPolicy) error
+}
+
+type twoFactor struct {
+	db *gorm.DB
+}
+
+func (r *twoFactor) SetSecret(ctx context.Context, userID uint, secret string) error {
+	return r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", userID).Update("totp_secret", secret).Error
+}
+
+func (r *twoFactor) ClaimStep(ctx context.Context, userID uint, step int64) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.User{}).
+		Where("id = ? AND totp_last_step < ?", userID, step).
+		Update("totp_last_step", step)
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *twoFactor) UseRecoveryCode(ctx context.Context, userID uint, hash string, at time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hash).
+		Update("used_at", at)
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *twoFactor) CountRecoveryCodes(ctx context.Context, userID uint) (int64, error) {
+	var left int64
+	err := r.db.WithContext(ctx).Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Count(&left).Error
+	return left, err
+}
+
+func (r *twoFactor) Enable(ctx context.Context, userID uint, at time.Time) error {
+	return r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", userID).Update("two_factor_enabled_at", at).Error
+}
+
+func (r *twoFactor) ReplaceRecoveryCodes(ctx context.Context, userID uint, hashes []string) error {
+	db := r.db.WithContext(ctx)
+	if err := db.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return err
+	}
+	rows := make([]models.RecoveryCode, len(hashes))
+	for i, hash := range hashes {
+		rows[i] = models.RecoveryCode{UserID: userID, CodeHash: hash}
+	}
+	return db.Create(&rows).Error
+}
+
+func (r *twoFactor) Clear(ctx context.Context, userID uint) error {
+	db := r.db.WithContext(ctx)
+	err := db.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]any{
+		"totp_secret":           "",
+		"totp_last_step":        0,
+		"two_factor_enabled_at": nil,
+	}).Error
+	if err != nil {
+		return err
+	}
+	return db.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error
+}
+
+func (r *twoFactor) Policies(ctx context.Context) ([]models.TwoFactorPolicy, error) {
+	var rows []models.TwoFactorPolicy
+	err := r.db.WithContext(ctx).Find(&rows).Error
+	return rows, err
+}
+
+func (r *twoFactor) SetPolicy(ctx context.Context, policy *models.TwoFactorPolicy) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "role"}},
+		DoUpdates: clause.AssignmentColumns([]string{"required", "updated_by", "updated_at"}),
+	}).Create(policy).Error
+}
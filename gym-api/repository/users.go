@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+
+	"gym-api/listing"
+	"gym-api/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Users stores accounts of every role.
+type Users interface {
+	// ByID loads a user with their package, if any.
+	ByID(ctx context.Context, id uint) (models.User, error)
+	// ByIDs loads the users with ids that still exist, with their packages.
+	ByIDs(ctx context.Context, ids []uint) ([]models.User, error)
+	// ByEmail finds an account by its (lower-case) email address.
+	ByEmail(ctx context.Context, email string) (models.User, error)
+	// List pages through the users with role.
+	List(ctx context.Context, role models.Role, spec listing.Spec, p listing.Params) (*listing.Page[models.User], error)
+	Create(ctx context.Context, user *models.User) error
+	// Save writes every column of user but none of its associations.
+	Save(ctx context.Context, user *models.User) error
+	// SetMembershipStatus changes only the membership status.
+	SetMembershipStatus(ctx context.Context, id uint, status string) error
+	// SetPushToken changes only the push notification token.
+	SetPushToken(ctx context.Context, id uint, token string) error
+	// Delete removes user together with their invite or registration.
+	Delete(ctx context.Context, user *models.User) error
+	// PendingInvite reports whether the user has an invite not yet accepted.
+	PendingInvite(ctx context.Context, userID uint) (bool, error)
+}
+
+type users struct {
+	db *gorm.DB
+}
+
+func (r *users) ByID(ctx context.Context, id uint) (models.User, error) {
+	var user models.User
+	err := r.db.WithContext(ctx).Preload("Package").First(&user, id).Error
+	return user, err
+}
+
+func (r *users) ByIDs(ctx context.Context, ids []uint) ([]models.User, error) {
+	var list []models.User
+	err := r.db.WithContext(ctx).Preload("Package").Where("id IN ?", ids).Find(&list).Error
+	return list, err
+}
+
+func (r *users) ByEmail(ctx context.Context, email string) (models.User, error) {
+	var user models.User
+	err := r.db.WithContext(ctx).Where("email = ?", email).First(&user).Error
+	return user, err
+}
+
+func (r *users) List(ctx context.Context, role models.Role, spec listing.Spec, p listing.Params) (*listing.Page[models.User], error) {
+	db := r.db.WithContext(ctx).Model(&models.User{}).Preload("Package").Where("role = ?", role)
+	return listing.Find[models.User](db, spec, p)
+}
+
+func (r *users) Create(ctx context.Context, user *models.User) error {
+	return r.db.WithContext(ctx).Create(user).Error
+}
+
+func (r *users) Save(ctx context.Context, user *models.User) error {
+	return r.db.WithContext(ctx).Omit(clause.Associations).Save(user).Error
+}
+
+func (r *users) SetMembershipStatus(ctx context.Context, id uint, status string) error {
+	return r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", id).Update("membership_status", status).Error
+}
+
+func (r *users) SetPushToken(ctx context.Context, id uint, token string) error {
+	return r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", id).Update("push_token", token).Error
+}
+
+func (r *users) Delete(ctx context.Context, user *models.User) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.Invite{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.Registration{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(user).Error
+	})
+}
+
+func (r *users) PendingInvite(ctx context.Context, userID uint) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.Invite{}).
+		Where("user_id = ? AND accepted_at IS NULL", userID).
+		Count(&count).Error
+	return count > 0, err
+}
@@ -0,0 +1,482 @@
+package routes
+
+import (
+	"slices"
+	"strings"
+	"sync"
+
+	"gym-api/analytics"
+	"gym-api/apperror"
+	"gym-api/controllers"
+	"gym-api/gates"
+	"gym-api/listing"
+	"gym-api/middleware"
+	"gym-api/models"
+	"gym-api/openapi"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Spec returns the OpenAPI document for the routes in SetupRoutes. Every
+// route must have an entry in operations, and its handler must answer
+// with the Response type; `go test ./routes` fails when they disagree and
+// `go test ./routes -update` regenerates openapi.json.
+var Spec = sync.OnceValues(func() (*openapi.Document, error) {
+	return openapi.Build(openapi.Config{
+		Title:       "Gym Management API",
+		Version:     "1.0.0",
+		Description: "Errors share one body; branch on its code, not the message.",
+		SecuritySchemes: map[string]openapi.SecurityScheme{
+			"bearer": {Type: "http", Scheme: "bearer", BearerFormat: "JWT",
+				Description: "Token from /api/auth/login"},
+			"deviceKey": {Type: "apiKey", In: "header", Name: "X-Device-Key",
+				Description: "API key issued when the device was registered"},
+			"gateSignature": {Type: "apiKey", In: "header", Name: "X-Gate-Signature",
+				Description: "Hex HMAC-SHA256 of \"<timestamp>.<nonce>.\" and the body with the gate's secret, " +
+					"sent with X-Gate-Timestamp and X-Gate-Nonce"},
+		},
+		Error: apperror.ErrorResponse{},
+	}, idempotent(operations()))
+})
+
+// gateSignatureHeaders are the headers signed along with a gate's webhook
+// body, besides the signature itself.
+func gateSignatureHeaders() []openapi.Param {
+	timestamp := openapi.Header(gates.TimestampHeader, &openapi.Schema{Type: "string"},
+		"Unix seconds when the request was signed; rejected more than 5 minutes away from the server's clock")
+	nonce := openapi.Header(gates.NonceHeader, &openapi.Schema{Type: "string", MaxLength: ptr(64)},
+		"A value the gate never sends twice")
+	timestamp.Required, nonce.Required = true, true
+	return []openapi.Param{timestamp, nonce}
+}
+
+func serveSpec(c *fiber.Ctx) error {
+	doc, err := Spec()
+	if err != nil {
+		return apperror.Internal(err, "API description unavailable")
+	}
+	return c.JSON(doc)
+}
+
+var (
+	bearer     = []string{"bearer"}
+	adminOnly  = []string{"admin"}
+	management = []string{"admin", "staff"}
+)
+
+// Shorthands for bodies used by many routes
+type (
+	message = controllers.MessageResponse
+	users   = listing.Page[models.User]
+	visits  = listing.Page[models.Attendance]
+)
+
+// issuesSecret lists the operations behind middleware.NoStore: their
+// responses carry a credential and are never replayed.
+var issuesSecret = []string{"createDevice", "createGate"}
+
+// idempotent documents the Idempotency-Key header on the POST routes behind
+// middleware.Idempotency: the signed-in routes outside /api/auth, except
+// those in issuesSecret, and the kiosk routes.
+func idempotent(ops []openapi.Operation) []openapi.Operation {
+	header := openapi.Header(middleware.IdempotencyKeyHeader, &openapi.Schema{Type: "string", MaxLength: ptr(255)},
+		"Send a unique value to retry safely: a repeat with the same key returns the first response, marked Idempotent-Replayed")
+	for i, op := range ops {
+		signedIn := slices.Equal(op.Security, bearer) && !strings.HasPrefix(op.Path, "/api/auth/") &&
+			!slices.Contains(issuesSecret, op.ID)
+		if op.Method == "POST" && (signedIn || slices.Equal(op.Security, []string{"deviceKey"})) {
+			ops[i].Headers = append(op.Headers, header)
+		}
+	}
+	return ops
+}
+
+func operations() []openapi.Operation {
+	return []openapi.Operation{
+		// Operations
+		{ID: "healthz", Method: "GET", Path: "/healthz", Tag: "operations",
+			Summary: "Liveness probe: the process is serving", Response: controllers.HealthResponse{}},
+		{ID: "readyz", Method: "GET", Path: "/readyz", Tag: "operations",
+			Summary: "Readiness probe: the database answers and is migrated; 503 otherwise", Response: controllers.HealthResponse{}},
+		{ID: "metrics", Method: "GET", Path: "/metrics", Tag: "operations",
+			Summary:     "Prometheus metrics for a scraper sending METRICS_TOKEN as a bearer token; not found when no token is set",
+			ContentType: "text/plain"},
+
+		// Auth
+		{ID: "register", Method: "POST", Path: "/api/auth/register", Tag: "auth",
+			Summary: "Sign up as a member; the account waits for email confirmation and staff approval",
+			Form:    controllers.RegisterInput{}, Files: []string{"profile_picture"},
+			Response: controllers.UserResponse{}},
+		{ID: "verifyEmail", Method: "POST", Path: "/api/auth/verify-email", Tag: "auth",
+			Summary: "Confirm a new member's email address with the emailed token",
+			Body:    controllers.VerifyEmailInput{}, Response: message{}},
+		{ID: "resendVerification", Method: "POST", Path: "/api/auth/verify-email/resend", Tag: "auth",
+			Summary: "Email a new confirmation link",
+			Body:    controllers.ResendVerificationInput{}, Response: message{}},
+		{ID: "login", Method: "POST", Path: "/api/auth/login", Tag: "auth",
+			Summary: "Sign in with email and password; accounts with 2FA get a challenge instead of a token",
+			Body:    controllers.LoginInput{}, Response: controllers.LoginResponse{}},
+		{ID: "verifyLogin", Method: "POST", Path: "/api/auth/login/verify", Tag: "auth",
+			Summary: "Complete a 2FA login with an authenticator or recovery code",
+			Body:    controllers.VerifyLoginInput{}, Response: controllers.LoginResponse{}},
+		{ID: "startSSO", Method: "GET", Path: "/api/auth/oidc/start", Tag: "auth",
+			Summary:  "Begin staff single sign-on: the provider URL to send the browser to",
+			Response: controllers.SSOStartResponse{}},
+		{ID: "finishSSO", Method: "POST", Path: "/api/auth/oidc/callback", Tag: "auth",
+			Summary: "Sign in with the code the provider returned",
+			Body:    controllers.SSOCallbackInput{}, Response: controllers.LoginResponse{}},
+		{ID: "lookupInvite", Method: "POST", Path: "/api/auth/invite/lookup", Tag: "auth",
+			Summary: "Who an invite link is for",
+			Body:    controllers.InviteTokenInput{}, Response: controllers.DataResponse[controllers.InviteDetails]{}},
+		{ID: "acceptInvite", Method: "POST", Path: "/api/auth/invite/accept", Tag: "auth",
+			Summary: "Choose a password for an invited account and sign in",
+			Body:    controllers.AcceptInviteInput{}, Response: controllers.LoginResponse{}},
+		{ID: "changePassword", Method: "POST", Path: "/api/auth/change-password", Tag: "auth",
+			Summary: "Change the signed-in user's password", Security: bearer,
+			Body: controllers.ChangePasswordInput{}, Response: message{}},
+		{ID: "me", Method: "GET", Path: "/api/auth/me", Tag: "auth",
+			Summary: "Profile of the signed-in user", Security: bearer,
+			Response: controllers.ProfileResponse{}},
+		{ID: "setupTwoFactor", Method: "POST", Path: "/api/auth/2fa/setup", Tag: "auth",
+			Summary: "Generate an authenticator secret to enrol", Security: bearer,
+			Response: controllers.TwoFactorSetupResponse{}},
+		{ID: "enableTwoFactor", Method: "POST", Path: "/api/auth/2fa/enable", Tag: "auth",
+			Summary: "Confirm a code and turn 2FA on", Security: bearer,
+			Body: controllers.TwoFactorCodeInput{}, Response: controllers.TwoFactorEnabledResponse{}},
+		{ID: "disableTwoFactor", Method: "POST", Path: "/api/auth/2fa/disable", Tag: "auth",
+			Summary: "Turn 2FA off, unless the role requires it", Security: bearer,
+			Body: controllers.DisableTwoFactorInput{}, Response: message{}},
+		{ID: "regenerateRecoveryCodes", Method: "POST", Path: "/api/auth/2fa/recovery-codes", Tag: "auth",
+			Summary: "Replace the recovery codes", Security: bearer,
+			Body: controllers.TwoFactorCodeInput{}, Response: controllers.RecoveryCodesResponse{}},
+
+		// Kiosk devices
+		{ID: "kioskScan", Method: "POST", Path: "/api/kiosk/scan", Tag: "kiosk",
+			Summary: "Admit a member at an unattended kiosk", Security: []string{"deviceKey"},
+			Body: controllers.ScanQRInput{}, Response: controllers.ScanResponse{}},
+		{ID: "kioskSyncScans", Method: "POST", Path: "/api/kiosk/scan/sync", Tag: "kiosk",
+			Summary: "Upload the kiosk's offline scan queue", Security: []string{"deviceKey"},
+			Body: controllers.SyncScansInput{}, Response: controllers.DataResponse[[]controllers.SyncResult]{}},
+		{ID: "kioskHeartbeat", Method: "POST", Path: "/api/kiosk/heartbeat", Tag: "kiosk",
+			Summary: "Report that the kiosk is alive", Security: []string{"deviceKey"},
+			Response: controllers.HeartbeatResponse{}},
+
+		// Gate webhook
+		{ID: "receiveGateEvent", Method: "POST", Path: "/api/gates/:id/events", Tag: "gates",
+			Summary: "Door event reported by a gate relay", Security: []string{"gateSignature"},
+			Headers: gateSignatureHeaders(),
+			Body:    controllers.GateEventInput{}, Response: message{}},
+
+		// API description
+		{ID: "openAPI", Method: "GET", Path: "/api/openapi.json", Tag: "docs",
+			Summary: "This document", Response: map[string]any{}},
+
+		// Any signed-in user
+		{ID: "getHistory", Method: "GET", Path: "/api/history", Tag: "attendance",
+			Summary: "The signed-in user's check-ins", Security: bearer,
+			Query: listQuery(controllers.AttendanceListSpec), Response: visits{}},
+		{ID: "getMyNotifications", Method: "GET", Path: "/api/notifications", Tag: "notifications",
+			Summary: "Notifications sent to the signed-in user", Security: bearer,
+			Response: controllers.DataResponse[[]models.NotificationLog]{}},
+		{ID: "getNotificationPreferences", Method: "GET", Path: "/api/notifications/preferences", Tag: "notifications",
+			Summary: "Channels and kinds the user receives", Security: bearer,
+			Response: controllers.DataResponse[controllers.NotificationPreferences]{}},
+		{ID: "updateNotificationPreferences", Method: "PUT", Path: "/api/notifications/preferences", Tag: "notifications",
+			Summary: "Switch channels or kinds on or off", Security: bearer,
+			Body:     controllers.NotificationPreferences{},
+			Response: controllers.DataResponse[controllers.NotificationPreferences]{}},
+		{ID: "updatePushToken", Method: "PUT", Path: "/api/notifications/push-token", Tag: "notifications",
+			Summary: "Register the device's push token", Security: bearer,
+			Body: controllers.PushTokenInput{}, Response: message{}},
+
+		// Admin
+		{ID: "getReports", Method: "GET", Path: "/api/admin/reports", Tag: "attendance",
+			Summary: "Attendance report", Security: bearer, Roles: adminOnly,
+			Query: listQuery(controllers.AttendanceListSpec), Response: visits{}},
+		{ID: "getAllTrainers", Method: "GET", Path: "/api/admin/trainers", Tag: "users",
+			Summary: "List trainers", Security: bearer, Roles: adminOnly,
+			Query: listQuery(controllers.UserListSpec), Response: users{}},
+		{ID: "toggleTrainerStatus", Method: "POST", Path: "/api/admin/trainers/:id/toggle", Tag: "users",
+			Summary: "Activate or deactivate a trainer", Security: bearer, Roles: adminOnly,
+			Response: controllers.ActiveResponse{}},
+		{ID: "resetTwoFactor", Method: "POST", Path: "/api/admin/users/:id/2fa/reset", Tag: "users",
+			Summary: "Turn 2FA off for a user who lost their authenticator", Security: bearer, Roles: adminOnly,
+			Response: message{}},
+		{ID: "updateMember", Method: "PUT", Path: "/api/admin/members/:id", Tag: "members",
+			Summary: "Edit a member", Security: bearer, Roles: adminOnly,
+			Body: controllers.UpdateMemberInput{}, Response: controllers.DataResponse[models.User]{}},
+		{ID: "deleteMember", Method: "DELETE", Path: "/api/admin/members/:id", Tag: "members",
+			Summary: "Delete a member", Security: bearer, Roles: adminOnly,
+			Response: message{}},
+
+		// Staff and admin
+		{ID: "scanQR", Method: "POST", Path: "/api/management/scan", Tag: "attendance",
+			Summary: "Admit a member from the scanner app", Security: bearer, Roles: management,
+			Body: controllers.ScanQRInput{}, Response: controllers.ScanResponse{}},
+		{ID: "syncScans", Method: "POST", Path: "/api/management/scan/sync", Tag: "attendance",
+			Summary: "Upload the scanner app's offline scan queue", Security: []string{"bearer", "deviceKey"}, Roles: management,
+			Body: controllers.SyncScansInput{}, Response: controllers.DataResponse[[]controllers.SyncResult]{}},
+		{ID: "getAllMembers", Method: "GET", Path: "/api/management/members", Tag: "members",
+			Summary: "List members", Security: bearer, Roles: management,
+			Query: listQuery(controllers.MemberListSpec), Response: users{}},
+		{ID: "getAtRiskMembers", Method: "GET", Path: "/api/management/members/at-risk", Tag: "churn",
+			Summary: "Members likely to leave, highest risk first", Security: bearer, Roles: management,
+			Query: []openapi.Param{
+				openapi.Query("level", openapi.Enum("low", "medium", "high"), "Default: medium and high"),
+			},
+			Response: controllers.DataResponse[[]models.ChurnRisk]{}},
+		{ID: "searchMembers", Method: "GET", Path: "/api/management/members/search", Tag: "members",
+			Summary: "Front desk lookup by name, email, phone, member number or QR code", Security: bearer, Roles: management,
+			Query: []openapi.Param{
+				{Name: "q", In: "query", Required: true, Schema: &openapi.Schema{Type: "string", MinLength: ptr(2)}},
+				openapi.Query("limit", openapi.Integer(), "Default 10, at most 50"),
+			},
+			Response: controllers.DataResponse[[]controllers.SearchResult]{}},
+		{ID: "createMember", Method: "POST", Path: "/api/management/members", Tag: "members",
+			Summary: "Register a member at the front desk, optionally with a package", Security: bearer, Roles: management,
+			Form: controllers.CreateMemberInput{}, Files: []string{"profile_picture"},
+			Response: controllers.UserResponse{}},
+		{ID: "getRegistrations", Method: "GET", Path: "/api/management/registrations", Tag: "members",
+			Summary: "Self-registrations waiting for approval", Security: bearer, Roles: management,
+			Response: controllers.DataResponse[[]models.Registration]{}},
+		{ID: "approveRegistration", Method: "POST", Path: "/api/management/registrations/:id/approve", Tag: "members",
+			Summary: "Activate a member whose email address is confirmed", Security: bearer, Roles: management,
+			Response: controllers.DataResponse[models.Registration]{}},
+		{ID: "rejectRegistration", Method: "POST", Path: "/api/management/registrations/:id/reject", Tag: "members",
+			Summary: "Turn down a registration and remove its account", Security: bearer, Roles: management,
+			Response: message{}},
+		{ID: "getMemberById", Method: "GET", Path: "/api/management/members/:id", Tag: "members",
+			Summary: "Fetch a member", Security: bearer, Roles: management,
+			Response: controllers.DataResponse[models.User]{}},
+		{ID: "assignTrainer", Method: "POST", Path: "/api/management/members/assign", Tag: "members",
+			Summary: "Assign a trainer to a member", Security: bearer, Roles: management,
+			Body: controllers.AssignTrainerInput{}, Response: message{}},
+		{ID: "subscribeMember", Method: "POST", Path: "/api/management/members/subscribe", Tag: "members",
+			Summary: "Sell or renew a package", Security: bearer, Roles: management,
+			Body: controllers.SubscribeInput{}, Response: controllers.SubscriptionResponse{}},
+		{ID: "getPackages", Method: "GET", Path: "/api/management/packages", Tag: "packages",
+			Summary: "List packages", Security: bearer, Roles: management,
+			Query: listQuery(controllers.PackageListSpec), Response: listing.Page[models.Package]{}},
+		{ID: "toggleMemberStatus", Method: "POST", Path: "/api/management/members/:id/toggle", Tag: "members",
+			Summary: "Activate or deactivate a member", Security: bearer, Roles: management,
+			Response: controllers.StatusResponse{}},
+		{ID: "uploadMemberPhoto", Method: "PUT", Path: "/api/management/members/:id/photo", Tag: "members",
+			Summary: "Replace a member's photo", Security: bearer, Roles: management,
+			Files: []string{"profile_picture"}, Response: controllers.DataResponse[models.User]{}},
+		{ID: "getAttendanceLogs", Method: "GET", Path: "/api/management/attendance", Tag: "attendance",
+			Summary: "Attendance log", Security: bearer, Roles: management,
+			Query: listQuery(controllers.AttendanceListSpec), Response: visits{}},
+		{ID: "flagAttendance", Method: "POST", Path: "/api/management/attendance/:id/flag", Tag: "incidents",
+			Summary: "Reject a check-in after a photo mismatch", Security: bearer, Roles: management,
+			Body: controllers.FlagAttendanceInput{}, Response: controllers.DataResponse[models.Incident]{}},
+		{ID: "streamEvents", Method: "GET", Path: "/api/management/events", Tag: "attendance",
+			Summary: "Live check-in feed (server-sent events)", Security: bearer, Roles: management,
+			Query: []openapi.Param{
+				openapi.Query("types", openapi.String(), "Comma-separated event types"),
+				openapi.Query("branch_id", openapi.Integer(), "Admins only; staff see their own branch"),
+				openapi.Query("last_event_id", openapi.String(), "Resume after this event, like the Last-Event-ID header"),
+				openapi.Query("access_token", openapi.String(), "JWT for EventSource clients, which cannot set headers"),
+			},
+			ContentType: "text/event-stream"},
+		{ID: "checkOut", Method: "POST", Path: "/api/management/checkout", Tag: "occupancy",
+			Summary: "Check a member out", Security: bearer, Roles: management,
+			Body: controllers.CheckOutInput{}, Response: controllers.DataResponse[models.Attendance]{}},
+		{ID: "getOccupancy", Method: "GET", Path: "/api/management/occupancy", Tag: "occupancy",
+			Summary: "People on site now", Security: bearer, Roles: management,
+			Query:    []openapi.Param{openapi.Query("branch_id", openapi.Integer(), "")},
+			Response: controllers.DataResponse[controllers.Occupancy]{}},
+
+		// Admin: staff and trainer accounts
+		{ID: "createUser", Method: "POST", Path: "/api/admin/users", Tag: "users",
+			Summary: "Create a staff or trainer account with a password; use inviteUser instead", Deprecated: true,
+			Security: bearer, Roles: adminOnly,
+			Body: controllers.CreateUserInput{}, Response: controllers.UserResponse{}},
+		{ID: "getInvites", Method: "GET", Path: "/api/admin/invites", Tag: "users",
+			Summary: "Invites not yet accepted", Security: bearer, Roles: adminOnly,
+			Response: controllers.DataResponse[[]models.Invite]{}},
+		{ID: "inviteUser", Method: "POST", Path: "/api/admin/invites", Tag: "users",
+			Summary: "Invite a staff member or trainer to choose their own password", Security: bearer, Roles: adminOnly,
+			Body: controllers.InviteUserInput{}, Response: controllers.InviteResponse{}},
+		{ID: "resendInvite", Method: "POST", Path: "/api/admin/invites/:id/resend", Tag: "users",
+			Summary: "Email a pending invite again with a new link", Security: bearer, Roles: adminOnly,
+			Response: controllers.InviteResponse{}},
+		{ID: "revokeInvite", Method: "DELETE", Path: "/api/admin/invites/:id", Tag: "users",
+			Summary: "Cancel a pending invite and remove its account", Security: bearer, Roles: adminOnly,
+			Response: message{}},
+		{ID: "getUsersByRole", Method: "GET", Path: "/api/admin/users", Tag: "users",
+			Summary: "List accounts with a role", Security: bearer, Roles: adminOnly,
+			Query: append([]openapi.Param{
+				{Name: "role", In: "query", Required: true, Schema: openapi.Enum("admin", "staff", "trainer", "member")},
+			}, listQuery(controllers.UserListSpec)...),
+			Response: users{}},
+		{ID: "updateUser", Method: "PUT", Path: "/api/admin/users/:id", Tag: "users",
+			Summary: "Edit an account", Security: bearer, Roles: adminOnly,
+			Body: controllers.UpdateUserInput{}, Response: controllers.DataResponse[models.User]{}},
+		{ID: "deleteUser", Method: "DELETE", Path: "/api/admin/users/:id", Tag: "users",
+			Summary: "Delete an account", Security: bearer, Roles: adminOnly,
+			Response: message{}},
+		{ID: "toggleUserStatus", Method: "POST", Path: "/api/admin/users/:id/toggle", Tag: "users",
+			Summary: "Activate or deactivate an account", Security: bearer, Roles: adminOnly,
+			Response: controllers.ActiveResponse{}},
+
+		// Admin: packages
+		{ID: "createPackage", Method: "POST", Path: "/api/admin/packages", Tag: "packages",
+			Summary: "Create a package", Security: bearer, Roles: adminOnly,
+			Body: controllers.PackageInput{}, Response: controllers.DataResponse[models.Package]{}},
+		{ID: "updatePackage", Method: "PUT", Path: "/api/admin/packages/:id", Tag: "packages",
+			Summary: "Edit a package", Security: bearer, Roles: adminOnly,
+			Body: controllers.UpdatePackageInput{}, Response: controllers.DataResponse[models.Package]{}},
+		{ID: "deletePackage", Method: "DELETE", Path: "/api/admin/packages/:id", Tag: "packages",
+			Summary: "Delete a package", Security: bearer, Roles: adminOnly,
+			Response: message{}},
+
+		// Admin: branches
+		{ID: "getBranches", Method: "GET", Path: "/api/admin/branches", Tag: "branches",
+			Summary: "List branches", Security: bearer, Roles: adminOnly,
+			Response: controllers.DataResponse[[]models.Branch]{}},
+		{ID: "createBranch", Method: "POST", Path: "/api/admin/branches", Tag: "branches",
+			Summary: "Create a branch", Security: bearer, Roles: adminOnly,
+			Body: controllers.BranchInput{}, Response: controllers.DataResponse[models.Branch]{}},
+
+		// Admin: kiosk devices
+		{ID: "getDevices", Method: "GET", Path: "/api/admin/devices", Tag: "devices",
+			Summary: "List kiosk devices", Security: bearer, Roles: adminOnly,
+			Response: controllers.DataResponse[[]controllers.DeviceView]{}},
+		{ID: "createDevice", Method: "POST", Path: "/api/admin/devices", Tag: "devices",
+			Summary: "Register a kiosk and issue its API key", Security: bearer, Roles: adminOnly,
+			Body: controllers.CreateDeviceInput{}, Response: controllers.DeviceKeyResponse{}},
+		{ID: "revokeDevice", Method: "POST", Path: "/api/admin/devices/:id/revoke", Tag: "devices",
+			Summary: "Disable a kiosk's key", Security: bearer, Roles: adminOnly,
+			Response: controllers.DataResponse[controllers.DeviceView]{}},
+
+		// Admin: gates
+		{ID: "getGates", Method: "GET", Path: "/api/admin/gates", Tag: "gates",
+			Summary: "List gates", Security: bearer, Roles: adminOnly,
+			Response: controllers.DataResponse[[]models.Gate]{}},
+		{ID: "createGate", Method: "POST", Path: "/api/admin/gates", Tag: "gates",
+			Summary: "Configure a gate and issue its secret", Security: bearer, Roles: adminOnly,
+			Body: controllers.CreateGateInput{}, Response: controllers.GateSecretResponse{}},
+		{ID: "updateGate", Method: "PUT", Path: "/api/admin/gates/:id", Tag: "gates",
+			Summary: "Edit a gate", Security: bearer, Roles: adminOnly,
+			Body: controllers.UpdateGateInput{}, Response: controllers.DataResponse[models.Gate]{}},
+		{ID: "deleteGate", Method: "DELETE", Path: "/api/admin/gates/:id", Tag: "gates",
+			Summary: "Remove a gate", Security: bearer, Roles: adminOnly,
+			Response: message{}},
+		{ID: "testGate", Method: "POST", Path: "/api/admin/gates/:id/test", Tag: "gates",
+			Summary: "Send a test grant to a gate", Security: bearer, Roles: adminOnly,
+			Response: message{}},
+		{ID: "simulateGateEvent", Method: "POST", Path: "/api/admin/gates/:id/simulate", Tag: "gates",
+			Summary: "Raise a door event on a simulated gate", Security: bearer, Roles: adminOnly,
+			Body: controllers.GateEventInput{}, Response: message{}},
+		{ID: "getAuditLogs", Method: "GET", Path: "/api/admin/audit", Tag: "audit",
+			Summary: "Security audit trail", Security: bearer, Roles: adminOnly,
+			Query: append(listQuery(controllers.AuditLogListSpec),
+				openapi.Query("action", openapi.String(), "Action or action prefix, e.g. gate.")),
+			Response: listing.Page[models.AuditLog]{}},
+
+		// Admin: login lockouts
+		{ID: "getLockouts", Method: "GET", Path: "/api/admin/lockouts", Tag: "auth",
+			Summary: "Accounts and addresses locked out of login", Security: bearer, Roles: adminOnly,
+			Response: controllers.DataResponse[[]models.LoginThrottle]{}},
+		{ID: "clearLockout", Method: "POST", Path: "/api/admin/lockouts/clear", Tag: "auth",
+			Summary: "Lift a lockout and reset its failure count", Security: bearer, Roles: adminOnly,
+			Body: controllers.ClearLockoutInput{}, Response: message{}},
+
+		// Admin: two-factor policy
+		{ID: "getTwoFactorPolicy", Method: "GET", Path: "/api/admin/2fa/policy", Tag: "auth",
+			Summary: "Which roles must sign in with 2FA", Security: bearer, Roles: adminOnly,
+			Response: controllers.DataResponse[[]models.TwoFactorPolicy]{}},
+		{ID: "updateTwoFactorPolicy", Method: "PUT", Path: "/api/admin/2fa/policy", Tag: "auth",
+			Summary: "Require 2FA for a role, or make it optional", Security: bearer, Roles: adminOnly,
+			Body: controllers.TwoFactorPolicyInput{}, Response: controllers.DataResponse[models.TwoFactorPolicy]{}},
+
+		// Admin: incidents
+		{ID: "getIncidents", Method: "GET", Path: "/api/admin/incidents", Tag: "incidents",
+			Summary: "Rejected check-ins", Security: bearer, Roles: adminOnly,
+			Query: []openapi.Param{
+				openapi.Query("status", openapi.Enum("open", "resolved"), ""),
+				openapi.Query("member_id", openapi.Integer(), ""),
+			},
+			Response: controllers.DataResponse[[]models.Incident]{}},
+		{ID: "resolveIncident", Method: "POST", Path: "/api/admin/incidents/:id/resolve", Tag: "incidents",
+			Summary: "Close an incident", Security: bearer, Roles: adminOnly,
+			Body: controllers.ResolveIncidentInput{}, Response: controllers.DataResponse[models.Incident]{}},
+
+		// Admin: notifications
+		{ID: "getNotificationLogs", Method: "GET", Path: "/api/admin/notifications/logs", Tag: "notifications",
+			Summary: "Notification deliveries", Security: bearer, Roles: adminOnly,
+			Query: listQuery(controllers.NotificationLogListSpec), Response: listing.Page[models.NotificationLog]{}},
+
+		// Admin: analytics
+		{ID: "getStats", Method: "GET", Path: "/api/admin/stats", Tag: "analytics",
+			Summary: "Dashboard counters", Security: bearer, Roles: adminOnly,
+			Response: controllers.DataResponse[controllers.DashboardStats]{}},
+		{ID: "getAttendanceChart", Method: "GET", Path: "/api/admin/attendance/chart", Tag: "analytics",
+			Summary: "Check-ins over the last 7 days", Security: bearer, Roles: adminOnly,
+			Response: controllers.DataResponse[[]controllers.ChartData]{}},
+		{ID: "getOccupancyHistory", Method: "GET", Path: "/api/admin/occupancy/history", Tag: "occupancy",
+			Summary: "Hourly check-ins and peak occupancy for a day", Security: bearer, Roles: adminOnly,
+			Query: []openapi.Param{
+				openapi.Query("branch_id", openapi.Integer(), ""),
+				openapi.Query("date", openapi.Date(), "Default today"),
+			},
+			Response: controllers.DataResponse[[]controllers.OccupancyHour]{}},
+		{ID: "getAttendanceAnalytics", Method: "GET", Path: "/api/admin/analytics/attendance", Tag: "analytics",
+			Summary: "Check-ins per period", Security: bearer, Roles: adminOnly,
+			Query: analyticsQuery, Response: controllers.AnalyticsResponse[[]analytics.SeriesPoint]{}},
+		{ID: "getPeakHourHeatmap", Method: "GET", Path: "/api/admin/analytics/heatmap", Tag: "analytics",
+			Summary: "Check-ins by weekday and hour, over at most a year", Security: bearer, Roles: adminOnly,
+			Query: analyticsQuery, Response: controllers.AnalyticsResponse[[7][24]int64]{}},
+		{ID: "getMemberFlows", Method: "GET", Path: "/api/admin/analytics/members", Tag: "analytics",
+			Summary: "New, renewed and lapsed members per period", Security: bearer, Roles: adminOnly,
+			Query: analyticsQuery, Response: controllers.AnalyticsResponse[[]analytics.MemberFlow]{}},
+		{ID: "getRetentionCohorts", Method: "GET", Path: "/api/admin/analytics/retention", Tag: "analytics",
+			Summary: "Retention by sign-up cohort", Security: bearer, Roles: adminOnly,
+			Query: analyticsQuery, Response: controllers.AnalyticsResponse[[]analytics.Cohort]{}},
+		{ID: "getRevenueByPackage", Method: "GET", Path: "/api/admin/analytics/revenue", Tag: "analytics",
+			Summary: "Revenue by package", Security: bearer, Roles: adminOnly,
+			Query: analyticsQuery, Response: controllers.AnalyticsResponse[[]analytics.PackageRevenue]{}},
+		{ID: "getAverageVisits", Method: "GET", Path: "/api/admin/analytics/visits", Tag: "analytics",
+			Summary: "Average visits per member", Security: bearer, Roles: adminOnly,
+			Query: analyticsQuery, Response: controllers.AnalyticsResponse[[]analytics.VisitFrequency]{}},
+		{ID: "recomputeChurn", Method: "POST", Path: "/api/admin/churn/recompute", Tag: "churn",
+			Summary: "Start rescoring churn risk now; the at-risk list updates when it finishes", Security: bearer, Roles: adminOnly,
+			Response: message{}},
+	}
+}
+
+// analyticsQuery are the filters read by parseAnalyticsParams.
+var analyticsQuery = []openapi.Param{
+	openapi.Query("from", openapi.String(), "YYYY-MM-DD or RFC 3339, default 30 days before to"),
+	openapi.Query("to", openapi.String(), "YYYY-MM-DD (whole day) or RFC 3339, default now"),
+	openapi.Query("granularity", openapi.Enum("hour", "day", "week", "month"), "Default day"),
+	openapi.Query("branch_id", openapi.Integer(), ""),
+	openapi.Query("package_id", openapi.Integer(), ""),
+}
+
+// listQuery documents the parameters of a list endpoint.
+func listQuery(spec listing.Spec) []openapi.Param {
+	var params []openapi.Param
+	for _, p := range spec.QueryParams() {
+		var schema *openapi.Schema
+		switch {
+		case len(p.Values) > 0:
+			schema = openapi.Enum(p.Values...)
+		case p.Kind == listing.Number:
+			schema = openapi.Integer()
+		case p.Kind == listing.Bool:
+			schema = openapi.Boolean()
+		case p.Kind == listing.Date:
+			schema = openapi.String()
+			p.Doc = strings.TrimSpace(p.Doc + " (YYYY-MM-DD or RFC 3339)")
+		default:
+			schema = openapi.String()
+		}
+		params = append(params, openapi.Query(p.Name, schema, p.Doc))
+	}
+	return params
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,475 @@
+package routes_test
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"image"
+	"image/png"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"gym-api/apptest"
+	"gym-api/controllers"
+	"gym-api/gates"
+	"gym-api/models"
+	"gym-api/notifications"
+	"gym-api/openapi"
+	"gym-api/routes"
+	"gym-api/services"
+	"gym-api/twofactor"
+	"gym-api/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// The web and mobile clients generate their API code from openapi.json.
+var update = flag.Bool("update", false, "regenerate openapi.json")
+
+const specFile = "../openapi.json"
+
+func spec(t *testing.T) *openapi.Document {
+	t.Helper()
+	doc, err := routes.Spec()
+	if err != nil {
+		t.Fatalf("API description: %v", err)
+	}
+	return doc
+}
+
+func TestRoutesMatchSpec(t *testing.T) {
+	doc := spec(t)
+
+	app := fiber.New()
+	routes.SetupRoutes(app, controllers.NewHandlers(services.Services{})) // Only registered, never called
+	var registered []openapi.Route
+	for _, r := range app.GetRoutes(true) {
+		if r.Method == fiber.MethodHead {
+			continue // Added by Fiber for every GET
+		}
+		registered = append(registered, openapi.Route{Method: r.Method, Path: r.Path})
+	}
+
+	for _, problem := range doc.Check(registered) {
+		t.Error(problem)
+	}
+}
+
+func TestSpecFileIsCurrent(t *testing.T) {
+	generated, err := json.MarshalIndent(spec(t), "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	generated = append(generated, '\n')
+
+	if *update {
+		if err := os.WriteFile(specFile, generated, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	if committed, err := os.ReadFile(specFile); err != nil || !bytes.Equal(committed, generated) {
+		t.Error("openapi.json is out of date; run go test ./routes -update")
+	}
+}
+
+// route is where an operation is registered.
+type route struct {
+	method string
+	path   string // Fiber syntax
+}
+
+var pathParam = regexp.MustCompile(`\{(\w+)\}`)
+
+// documented maps the operation IDs in doc to their routes.
+func documented(doc *openapi.Document) map[string]route {
+	ops := map[string]route{}
+	for path, methods := range doc.Paths {
+		for method, op := range methods {
+			ops[op.OperationID] = route{strings.ToUpper(method), pathParam.ReplaceAllString(path, ":$1")}
+		}
+	}
+	return ops
+}
+
+// call sends one request to a documented operation. Requests are built
+// when the call is made, so they can use what earlier calls returned.
+type call struct {
+	op     string
+	status int // Expected; 0 means 200
+	req    func() apptest.Request
+	then   func(apptest.Response)
+}
+
+// notCalled lists the operations TestResponsesMatchSpec cannot call, with
+// the reason.
+var notCalled = map[string]string{
+	"streamEvents": "server-sent events never end",
+}
+
+// TestResponsesMatchSpec calls every documented operation and checks the
+// body against the response schema the description promises clients: a
+// handler answering with a different shape than its Response type fails
+// here. Error responses are checked against the error schema.
+func TestResponsesMatchSpec(t *testing.T) {
+	doc := spec(t)
+	app := apptest.New(t)
+	const password = "Secret123!"
+	hash, err := utils.HashPassword(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 1. The gym
+	branch := models.Branch{Name: "Downtown", Capacity: 50}
+	pkg := models.Package{Name: "Monthly", DurationDays: 30, Price: 40}
+	for _, row := range []any{&branch, &pkg} {
+		if err := app.DB.Create(row).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	admin := app.AddUser(t, models.User{Name: "Ada Admin", Email: "admin@example.com", PasswordHash: hash, Role: models.RoleAdmin})
+	staff := app.AddUser(t, models.User{Name: "Sam Staff", Email: "staff@example.com", PasswordHash: hash, Role: models.RoleStaff, BranchID: &branch.ID})
+	enrolled := app.AddUser(t, models.User{Name: "Eve Enrolled", Email: "eve@example.com", PasswordHash: hash, Role: models.RoleStaff})
+	trainer := app.AddUser(t, models.User{Name: "Tina Trainer", Email: "tina@example.com", Role: models.RoleTrainer})
+	start, end := time.Now().AddDate(0, -1, 0), time.Now().AddDate(0, 1, 0)
+	member := func(name, email string) models.User {
+		return app.AddUser(t, models.User{Name: name, Email: email, Role: models.RoleMember, MembershipStatus: "active",
+			PackageID: &pkg.ID, SubStartDate: &start, SubEndDate: &end})
+	}
+	ana := member("Ana Silva", "ana@example.com")  // Scanned at the desk and checked out
+	ben := member("Ben Costa", "ben@example.com")  // Scanned at a kiosk, then flagged
+	cal := member("Cal Reyes", "cal@example.com")  // Scanned offline
+	dan := member("Dan Moreau", "dan@example.com") // Edited and deleted
+
+	token := app.Token(t, admin)
+	staffToken := app.Token(t, staff)
+	as := func(token, method, path string, body any) func() apptest.Request {
+		return func() apptest.Request {
+			return apptest.Request{Method: method, Path: path, Token: token, Body: body}
+		}
+	}
+	get := func(path string) func() apptest.Request { return as(token, http.MethodGet, path, nil) }
+	post := func(path string, body any) func() apptest.Request { return as(token, http.MethodPost, path, body) }
+	put := func(path string, body any) func() apptest.Request { return as(token, http.MethodPut, path, body) }
+	del := func(path string) func() apptest.Request { return as(token, http.MethodDelete, path, nil) }
+	decode := func(v any) func(apptest.Response) {
+		return func(r apptest.Response) { r.JSON(t, v) }
+	}
+	photo := pngPhoto(t)
+
+	// Filled in by the calls that create them
+	var (
+		deviceKey       string
+		device          controllers.DeviceKeyResponse
+		gate            controllers.GateSecretResponse
+		scan            controllers.ScanResponse
+		incident        controllers.DataResponse[models.Incident]
+		created         controllers.UserResponse
+		registered      controllers.UserResponse
+		declined        controllers.UserResponse
+		invited         controllers.InviteResponse
+		revoked         controllers.InviteResponse
+		newPackage      controllers.DataResponse[models.Package]
+		setup           controllers.TwoFactorSetupResponse
+		enabled         controllers.TwoFactorEnabledResponse
+		regenerated     controllers.RecoveryCodesResponse
+		challenge       controllers.LoginResponse
+		enrolledToken   = app.Token(t, enrolled)
+		registrationFor = func(user models.User) uint {
+			var r models.Registration
+			if err := app.DB.Where("user_id = ?", user.ID).First(&r).Error; err != nil {
+				t.Fatalf("registration of %s: %v", user.Email, err)
+			}
+			return r.ID
+		}
+	)
+	emailed := func(kind notifications.Kind) string {
+		sent := app.Outbox.Sent()
+		for i := len(sent) - 1; i >= 0; i-- {
+			if sent[i].Kind == kind {
+				link, err := url.Parse(sent[i].Data["Link"].(string))
+				if err != nil {
+					t.Fatal(err)
+				}
+				return link.Query().Get("token")
+			}
+		}
+		t.Fatalf("no %s email sent", kind)
+		return ""
+	}
+	kiosk := func(path string, body any) func() apptest.Request {
+		return func() apptest.Request {
+			return apptest.Request{Method: http.MethodPost, Path: path, Body: body, Header: map[string]string{"X-Device-Key": deviceKey}}
+		}
+	}
+	id := func(format string, id func() uint) func() string {
+		return func() string { return fmt.Sprintf(format, id()) }
+	}
+	at := func(method string, path func() string, body any) func() apptest.Request {
+		return func() apptest.Request {
+			return apptest.Request{Method: method, Path: path(), Token: token, Body: body}
+		}
+	}
+
+	calls := []call{
+		// Operations
+		{op: "healthz", req: get("/healthz")},
+		{op: "readyz", req: get("/readyz")},
+		{op: "metrics", status: http.StatusNotFound, req: get("/metrics")}, // No METRICS_TOKEN
+		{op: "openAPI", req: get("/api/openapi.json")},
+
+		// Sign-in
+		{op: "login", status: http.StatusUnauthorized, req: post("/api/auth/login", map[string]any{"email": staff.Email, "password": "wrong"})},
+		{op: "login", req: post("/api/auth/login", map[string]any{"email": staff.Email, "password": password})},
+		{op: "getLockouts", req: get("/api/admin/lockouts")},
+		{op: "clearLockout", req: post("/api/admin/lockouts/clear", map[string]any{"email": staff.Email})},
+		{op: "me", req: as(staffToken, http.MethodGet, "/api/auth/me", nil)},
+		{op: "changePassword", req: as(staffToken, http.MethodPost, "/api/auth/change-password",
+			map[string]any{"old_password": password, "new_password": "Changed123!"})},
+		{op: "startSSO", status: http.StatusNotFound, req: get("/api/auth/oidc/start")}, // Not configured
+		{op: "finishSSO", status: http.StatusNotFound, req: post("/api/auth/oidc/callback", map[string]any{"code": "c", "state": "s"})},
+
+		// Two-factor authentication
+		{op: "getTwoFactorPolicy", req: get("/api/admin/2fa/policy")},
+		{op: "updateTwoFactorPolicy", req: put("/api/admin/2fa/policy", map[string]any{"role": "trainer", "required": false})},
+		{op: "setupTwoFactor", req: as(enrolledToken, http.MethodPost, "/api/auth/2fa/setup", nil), then: decode(&setup)},
+		{op: "enableTwoFactor", req: func() apptest.Request {
+			code, err := twofactor.Code(setup.Secret, twofactor.Step(time.Now()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return as(enrolledToken, http.MethodPost, "/api/auth/2fa/enable", map[string]any{"code": code})()
+		}, then: decode(&enabled)},
+		{op: "regenerateRecoveryCodes", req: func() apptest.Request {
+			return as(enabled.Token, http.MethodPost, "/api/auth/2fa/recovery-codes", map[string]any{"code": enabled.RecoveryCodes[0]})()
+		}, then: decode(&regenerated)},
+		{op: "login", req: post("/api/auth/login", map[string]any{"email": enrolled.Email, "password": password}), then: decode(&challenge)},
+		{op: "verifyLogin", req: func() apptest.Request {
+			return post("/api/auth/login/verify", map[string]any{
+				"challenge_token": challenge.TwoFactor.ChallengeToken,
+				"code":            regenerated.RecoveryCodes[0],
+			})()
+		}},
+		{op: "disableTwoFactor", req: func() apptest.Request {
+			return as(enabled.Token, http.MethodPost, "/api/auth/2fa/disable",
+				map[string]any{"password": password, "code": regenerated.RecoveryCodes[1]})()
+		}},
+		{op: "resetTwoFactor", req: post(fmt.Sprintf("/api/admin/users/%d/2fa/reset", enrolled.ID), nil)},
+
+		// Self-registration
+		{op: "register", req: as("", http.MethodPost, "/api/auth/register", apptest.Multipart{
+			Fields: url.Values{"name": {"Fay Newman"}, "email": {"fay@example.com"}, "password": {password}},
+			Files:  map[string][]byte{"profile_picture": photo},
+		}), then: decode(&registered)},
+		{op: "resendVerification", req: post("/api/auth/verify-email/resend", map[string]any{"email": "fay@example.com"})},
+		{op: "verifyEmail", req: func() apptest.Request {
+			return post("/api/auth/verify-email", map[string]any{"token": emailed(notifications.VerifyEmail)})()
+		}},
+		{op: "register", req: as("", http.MethodPost, "/api/auth/register", apptest.Multipart{
+			Fields: url.Values{"name": {"Gus Oldman"}, "email": {"gus@example.com"}, "password": {password}},
+		}), then: decode(&declined)},
+		{op: "getRegistrations", req: get("/api/management/registrations")},
+		{op: "approveRegistration", req: at(http.MethodPost, id("/api/management/registrations/%d/approve", func() uint {
+			return registrationFor(registered.User)
+		}), nil)},
+		{op: "rejectRegistration", req: at(http.MethodPost, id("/api/management/registrations/%d/reject", func() uint {
+			return registrationFor(declined.User)
+		}), nil)},
+
+		// Staff accounts
+		{op: "inviteUser", req: post("/api/admin/invites", map[string]any{"name": "Hal Hughes", "email": "hal@example.com", "role": "staff"}),
+			then: decode(&invited)},
+		{op: "inviteUser", req: post("/api/admin/invites", map[string]any{"name": "Ivy Irwin", "email": "ivy@example.com", "role": "trainer"}),
+			then: decode(&revoked)},
+		{op: "getInvites", req: get("/api/admin/invites")},
+		{op: "resendInvite", req: at(http.MethodPost, id("/api/admin/invites/%d/resend", func() uint { return invited.Data.ID }), nil)},
+		{op: "lookupInvite", req: func() apptest.Request {
+			return post("/api/auth/invite/lookup", map[string]any{"token": emailed(notifications.StaffInvite)})()
+		}},
+		{op: "acceptInvite", req: func() apptest.Request {
+			return post("/api/auth/invite/accept", map[string]any{"token": emailed(notifications.StaffInvite), "password": password})()
+		}},
+		{op: "revokeInvite", req: at(http.MethodDelete, id("/api/admin/invites/%d", func() uint { return revoked.Data.ID }), nil)},
+		{op: "createUser", req: post("/api/admin/users", map[string]any{"name": "Jo Jones", "email": "jo@example.com", "password": password, "role": "staff"}),
+			then: decode(&created)},
+		{op: "getUsersByRole", req: get("/api/admin/users?role=staff")},
+		{op: "updateUser", req: at(http.MethodPut, id("/api/admin/users/%d", func() uint { return created.User.ID }), map[string]any{"name": "Jo Jonas"})},
+		{op: "toggleUserStatus", req: at(http.MethodPost, id("/api/admin/users/%d/toggle", func() uint { return created.User.ID }), nil)},
+		{op: "deleteUser", req: at(http.MethodDelete, id("/api/admin/users/%d", func() uint { return created.User.ID }), nil)},
+		{op: "getAllTrainers", req: get("/api/admin/trainers")},
+		{op: "toggleTrainerStatus", req: post(fmt.Sprintf("/api/admin/trainers/%d/toggle", trainer.ID), nil)},
+		{op: "toggleTrainerStatus", req: post(fmt.Sprintf("/api/admin/trainers/%d/toggle", trainer.ID), nil)},
+
+		// Packages and branches
+		{op: "getPackages", req: get("/api/management/packages")},
+		{op: "createPackage", req: post("/api/admin/packages", map[string]any{"name": "Yearly", "duration_days": 365, "price": 400}),
+			then: decode(&newPackage)},
+		{op: "updatePackage", req: at(http.MethodPut, id("/api/admin/packages/%d", func() uint { return newPackage.Data.ID }), map[string]any{"price": 380})},
+		{op: "deletePackage", req: at(http.MethodDelete, id("/api/admin/packages/%d", func() uint { return newPackage.Data.ID }), nil)},
+		{op: "getBranches", req: get("/api/admin/branches")},
+		{op: "createBranch", req: post("/api/admin/branches", map[string]any{"name": "Uptown", "capacity": 20})},
+
+		// Members
+		{op: "createMember", req: post("/api/management/members", url.Values{
+			"name": {"Kim Kaur"}, "email": {"kim@example.com"}, "password": {password}, "package_id": {strconv.Itoa(int(pkg.ID))},
+		})},
+		{op: "getAllMembers", req: get("/api/management/members")},
+		{op: "searchMembers", req: get("/api/management/members/search?q=ana")},
+		{op: "getMemberById", req: get(fmt.Sprintf("/api/management/members/%d", ana.ID))},
+		{op: "assignTrainer", req: post("/api/management/members/assign", map[string]any{"member_id": dan.ID, "trainer_id": trainer.ID})},
+		{op: "subscribeMember", req: post("/api/management/members/subscribe", map[string]any{"member_id": dan.ID, "package_id": pkg.ID})},
+		{op: "toggleMemberStatus", req: post(fmt.Sprintf("/api/management/members/%d/toggle", dan.ID), nil)},
+		{op: "uploadMemberPhoto", req: put(fmt.Sprintf("/api/management/members/%d/photo", dan.ID), apptest.Multipart{
+			Files: map[string][]byte{"profile_picture": photo},
+		})},
+		{op: "updateMember", req: put(fmt.Sprintf("/api/admin/members/%d", dan.ID), map[string]any{"name": "Dan Moreno", "membership_status": "active"})},
+		{op: "deleteMember", req: del(fmt.Sprintf("/api/admin/members/%d", dan.ID))},
+
+		// Devices and check-ins
+		{op: "createDevice", req: post("/api/admin/devices", map[string]any{"name": "Lobby kiosk", "branch_id": branch.ID}),
+			then: func(r apptest.Response) { r.JSON(t, &device); deviceKey = device.APIKey }},
+		{op: "getDevices", req: get("/api/admin/devices")},
+		{op: "kioskHeartbeat", req: kiosk("/api/kiosk/heartbeat", nil)},
+		{op: "kioskScan", req: func() apptest.Request {
+			return kiosk("/api/kiosk/scan", map[string]any{"trainer_id": ben.ID, "timestamp": time.Now().Unix()})()
+		}, then: decode(&scan)},
+		{op: "kioskSyncScans", req: func() apptest.Request {
+			return kiosk("/api/kiosk/scan/sync", map[string]any{"scans": []controllers.OfflineScan{
+				offlineScan(deviceKey, "scan-1", cal.ID, time.Now().Add(-time.Hour)),
+			}})()
+		}},
+		{op: "syncScans", req: func() apptest.Request {
+			req := post("/api/management/scan/sync", map[string]any{"scans": []controllers.OfflineScan{
+				offlineScan(deviceKey, "scan-1", cal.ID, time.Now().Add(-time.Hour)), // Replays the kiosk's result
+			}})()
+			req.Token, req.Header = staffToken, map[string]string{"X-Device-Key": deviceKey}
+			return req
+		}},
+		{op: "scanQR", req: func() apptest.Request {
+			return as(staffToken, http.MethodPost, "/api/management/scan", map[string]any{"trainer_id": ana.ID, "timestamp": time.Now().Unix()})()
+		}},
+		{op: "getOccupancy", req: get(fmt.Sprintf("/api/management/occupancy?branch_id=%d", branch.ID))},
+		{op: "checkOut", req: as(staffToken, http.MethodPost, "/api/management/checkout", map[string]any{"member_id": ana.ID})},
+		{op: "getHistory", req: as(app.Token(t, ana), http.MethodGet, "/api/history", nil)},
+		{op: "getAttendanceLogs", req: get("/api/management/attendance")},
+		{op: "getReports", req: get("/api/admin/reports")},
+		{op: "flagAttendance", req: at(http.MethodPost, id("/api/management/attendance/%d/flag", func() uint { return scan.Data.ID }),
+			map[string]any{"reason": "Photo mismatch", "suspend": true}), then: decode(&incident)},
+		{op: "getIncidents", req: get("/api/admin/incidents")},
+		{op: "resolveIncident", req: at(http.MethodPost, id("/api/admin/incidents/%d/resolve", func() uint { return incident.Data.ID }),
+			map[string]any{"resolution": "Twin brother", "reinstate": true})},
+		{op: "revokeDevice", req: at(http.MethodPost, id("/api/admin/devices/%d/revoke", func() uint { return device.Data.ID }), nil)},
+
+		// Gates
+		{op: "createGate", req: post("/api/admin/gates", map[string]any{"name": "Turnstile", "kind": "simulator", "branch_id": branch.ID}),
+			then: decode(&gate)},
+		{op: "getGates", req: get("/api/admin/gates")},
+		{op: "updateGate", req: at(http.MethodPut, id("/api/admin/gates/%d", func() uint { return gate.Data.ID }), map[string]any{"door": "2"})},
+		{op: "testGate", req: at(http.MethodPost, id("/api/admin/gates/%d/test", func() uint { return gate.Data.ID }), nil)},
+		{op: "simulateGateEvent", req: at(http.MethodPost, id("/api/admin/gates/%d/simulate", func() uint { return gate.Data.ID }),
+			map[string]any{"type": "door_forced"})},
+		{op: "receiveGateEvent", req: func() apptest.Request {
+			body := []byte(`{"type":"door_held"}`)
+			timestamp, nonce := strconv.FormatInt(time.Now().Unix(), 10), "nonce-1"
+			return apptest.Request{Method: http.MethodPost, Path: fmt.Sprintf("/api/gates/%d/events", gate.Data.ID), Body: body,
+				Header: map[string]string{
+					gates.TimestampHeader: timestamp,
+					gates.NonceHeader:     nonce,
+					gates.SignatureHeader: gates.Sign(gate.Secret, timestamp, nonce, body),
+				}}
+		}},
+		{op: "getAuditLogs", req: get("/api/admin/audit?action=gate.")},
+		{op: "deleteGate", req: at(http.MethodDelete, id("/api/admin/gates/%d", func() uint { return gate.Data.ID }), nil)},
+
+		// Notifications
+		{op: "getMyNotifications", req: get("/api/notifications")},
+		{op: "getNotificationPreferences", req: get("/api/notifications/preferences")},
+		{op: "updateNotificationPreferences", req: put("/api/notifications/preferences", map[string]any{"email": false})},
+		{op: "updatePushToken", req: put("/api/notifications/push-token", map[string]any{"token": "device-token"})},
+		{op: "getNotificationLogs", req: get("/api/admin/notifications/logs")},
+
+		// Reports
+		{op: "getStats", req: get("/api/admin/stats")},
+		{op: "getAttendanceChart", req: get("/api/admin/attendance/chart")},
+		{op: "getOccupancyHistory", req: get("/api/admin/occupancy/history")},
+		{op: "getAttendanceAnalytics", req: get("/api/admin/analytics/attendance")},
+		{op: "getPeakHourHeatmap", req: get("/api/admin/analytics/heatmap")},
+		{op: "getMemberFlows", req: get("/api/admin/analytics/members?granularity=week")},
+		{op: "getRetentionCohorts", req: get("/api/admin/analytics/retention")},
+		{op: "getRevenueByPackage", req: get("/api/admin/analytics/revenue")},
+		{op: "getAverageVisits", req: get("/api/admin/analytics/visits")},
+		{op: "recomputeChurn", req: post("/api/admin/churn/recompute", nil)},
+		{op: "getAtRiskMembers", req: get("/api/management/members/at-risk?level=low")},
+	}
+
+	ops := documented(doc)
+	seen := map[string]bool{}
+	for _, c := range calls {
+		r, ok := ops[c.op]
+		if !ok {
+			t.Errorf("%s: not documented", c.op)
+			continue
+		}
+		seen[c.op] = true
+
+		req := c.req()
+		if req.Method != r.method {
+			t.Fatalf("%s: sent %s, documented as %s", c.op, req.Method, r.method)
+		}
+		resp := app.Do(t, req)
+		want := c.status
+		if want == 0 {
+			want = http.StatusOK
+		}
+		if resp.Status != want {
+			t.Fatalf("%s %s: status %d, want %d: %s", req.Method, req.Path, resp.Status, want, resp.Body)
+		}
+		if err := doc.Conform(r.method, r.path, resp.Status, resp.Body); err != nil {
+			t.Errorf("%s %s: %v\n%s", req.Method, r.path, err, resp.Body)
+		}
+		if c.then != nil {
+			c.then(resp)
+		}
+	}
+
+	for op := range ops {
+		if !seen[op] && notCalled[op] == "" {
+			t.Errorf("%s: never called; add it to the calls", op)
+		}
+	}
+}
+
+// offlineScan is a scan queued by the device with key, attested as the
+// device would.
+func offlineScan(key, clientID string, memberID uint, capturedAt time.Time) controllers.OfflineScan {
+	scan := controllers.OfflineScan{
+		ClientID:   clientID,
+		TrainerID:  memberID,
+		Timestamp:  capturedAt.Unix(),
+		CapturedAt: capturedAt.Unix(),
+	}
+	mac := hmac.New(sha256.New, []byte(key))
+	fmt.Fprintf(mac, "%s|%d|%d|%d", scan.ClientID, scan.TrainerID, scan.Timestamp, scan.CapturedAt)
+	scan.Attestation = hex.EncodeToString(mac.Sum(nil))
+	return scan
+}
+
+func pngPhoto(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 8, 8))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
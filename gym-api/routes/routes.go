@@ -1,59 +1,174 @@
 package routes
 
 import (
+	"log/slog"
+	"os"
+
+	"gym-api/config"
 	"gym-api/controllers"
 	"gym-api/middleware"
 
 	"github.com/gofiber/fiber/v2"
 )
 
-func SetupRoutes(app *fiber.App) {
+// SetupRoutes registers every route. Handlers holds the handlers that are
+// built with their services; the rest are plain functions.
+func SetupRoutes(app *fiber.App, h controllers.Handlers) {
+	doc, err := Spec()
+	if err != nil {
+		slog.Error("API description is invalid", "error", err)
+		os.Exit(1)
+	}
+	// Development aid: log responses that stray from the API description
+	if config.ValidateResponses() {
+		app.Use(middleware.Conform(doc))
+	}
+
+	// Probes and scraping for the orchestrator, outside the versioned API
+	app.Get("/healthz", h.Health.Healthz)
+	app.Get("/readyz", h.Health.Readyz)
+	app.Get("/metrics", controllers.Metrics)
+
 	api := app.Group("/api")
 	auth := api.Group("/auth")
 
-	auth.Post("/register", controllers.Register)
-	auth.Post("/login", controllers.Login)
+	auth.Post("/register", h.Registrations.Register) // Member self sign-up, pending until approved
+	auth.Post("/verify-email", h.Registrations.VerifyEmail)
+	auth.Post("/verify-email/resend", h.Registrations.ResendVerification)
+	auth.Post("/login", h.Auth.Login)
+	auth.Post("/login/verify", h.TwoFactor.VerifyLogin) // Second step for accounts with 2FA
+	auth.Get("/oidc/start", h.SSO.StartSSO)             // Staff single sign-on
+	auth.Post("/oidc/callback", h.SSO.FinishSSO)
+	auth.Post("/invite/lookup", h.Invites.LookupInvite) // Invite page: who the link is for
+	auth.Post("/invite/accept", h.Invites.AcceptInvite)
 	// Protected Auth Routes (Requires Middleware for Context)
-	auth.Post("/change-password", middleware.Protected(), controllers.ChangePassword)
-	auth.Get("/me", middleware.Protected(), controllers.Me)
+	auth.Post("/change-password", middleware.Protected(), h.Auth.ChangePassword)
+	auth.Get("/me", middleware.Protected(), h.Auth.Me)
+
+	// Two-factor enrolment
+	twoFactor := auth.Group("/2fa", middleware.Protected())
+	twoFactor.Post("/setup", h.TwoFactor.SetupTwoFactor)
+	twoFactor.Post("/enable", h.TwoFactor.EnableTwoFactor)
+	twoFactor.Post("/disable", h.TwoFactor.DisableTwoFactor)
+	twoFactor.Post("/recovery-codes", h.TwoFactor.RegenerateRecoveryCodes)
+
+	// Kiosk Routes (device API key instead of a user JWT)
+	kiosk := api.Group("/kiosk", middleware.DeviceAuth(), middleware.Idempotency())
+	kiosk.Post("/scan", h.Attendance.KioskScan)
+	kiosk.Post("/scan/sync", h.Attendance.SyncScans) // Upload the offline scan queue
+	kiosk.Post("/heartbeat", h.Devices.KioskHeartbeat)
+
+	// Gate relays report door events here, signed with the gate secret
+	api.Post("/gates/:id/events", h.Gates.ReceiveGateEvent)
+
+	// API description for client generators
+	api.Get("/openapi.json", serveSpec)
 
 	// Protected Routes
 	api.Use(middleware.Protected())
+	api.Use(middleware.Idempotency()) // Safe retries of POSTs sent with an Idempotency-Key
 
 	// Trainer Routes
-	api.Get("/history", controllers.GetHistory)
+	api.Get("/history", h.Attendance.GetHistory)
+
+	// Notification Routes (any logged-in user)
+	api.Get("/notifications", h.Notifications.GetMyNotifications)
+	api.Get("/notifications/preferences", h.Notifications.GetNotificationPreferences)
+	api.Put("/notifications/preferences", h.Notifications.UpdateNotificationPreferences)
+	api.Put("/notifications/push-token", h.Notifications.UpdatePushToken)
 
 	// Admin Routes (Strict Admin Only)
 	admin := api.Group("/admin", middleware.AdminOnly())
-	admin.Get("/reports", controllers.GetReports)
-	admin.Get("/trainers", controllers.GetAllTrainers)
-	admin.Post("/trainers/:id/toggle", controllers.ToggleTrainerStatus)
-	admin.Put("/members/:id", controllers.UpdateMember) // Only admin can edit/delete for now
-	admin.Delete("/members/:id", controllers.DeleteMember)
+	admin.Get("/reports", h.Attendance.GetReports)
+	admin.Get("/trainers", h.Users.GetAllTrainers)
+	admin.Post("/trainers/:id/toggle", h.Users.ToggleTrainerStatus)
+	admin.Put("/members/:id", h.Members.UpdateMember) // Only admin can edit/delete for now
+	admin.Delete("/members/:id", h.Members.DeleteMember)
 	// Staff & Admin Routes (Shared Management)
 	management := api.Group("/management", middleware.AdminOrStaffOnly())
-	management.Post("/scan", controllers.ScanQR)
-	management.Get("/members", controllers.GetAllMembers)     // Staff needs to see members
-	management.Get("/members/:id", controllers.GetMemberById) // Fetch single member for scan verification
-	management.Post("/members/assign", controllers.AssignTrainer)
-	management.Post("/members/subscribe", controllers.SubscribeMember)
-	management.Get("/packages", controllers.GetPackages)                   // Staff needs to see packages for reference
-	management.Post("/members/:id/toggle", controllers.ToggleMemberStatus) // Allow staff to toggle member status
-	management.Get("/attendance", controllers.GetAttendanceLogs)           // Shared Attendance View
+	management.Post("/scan", h.Attendance.ScanQR)
+	// Offline scan queue; the scanner app must also be a registered device to sign its scans
+	management.Post("/scan/sync", middleware.DeviceAuth(), h.Attendance.SyncScans)
+	management.Get("/members", h.Members.GetAllMembers)          // Staff needs to see members
+	management.Post("/members", h.Members.CreateMember)          // Front desk sign-up
+	management.Get("/members/at-risk", h.Churn.GetAtRiskMembers) // Churn risk call list
+	management.Get("/members/search", h.Search.SearchMembers)    // Front desk lookup
+	management.Get("/members/:id", h.Members.GetMemberById)      // Fetch single member for scan verification
+	management.Post("/members/assign", h.Members.AssignTrainer)
+	management.Post("/members/subscribe", h.Members.SubscribeMember)
+	management.Get("/registrations", h.Registrations.GetRegistrations) // Self sign-ups to approve
+	management.Post("/registrations/:id/approve", h.Registrations.ApproveRegistration)
+	management.Post("/registrations/:id/reject", h.Registrations.RejectRegistration)
+	management.Get("/packages", h.Packages.GetPackages)                  // Staff needs to see packages for reference
+	management.Post("/members/:id/toggle", h.Members.ToggleMemberStatus) // Allow staff to toggle member status
+	management.Put("/members/:id/photo", h.Members.UploadMemberPhoto)    // Re-upload a member's photo
+	management.Get("/attendance", h.Attendance.GetAttendanceLogs)        // Shared Attendance View
+	management.Post("/attendance/:id/flag", h.Attendance.FlagAttendance) // Reject a check-in after a photo mismatch
+	management.Get("/events", h.Events.StreamEvents)                     // Live check-in feed (SSE)
+	management.Post("/checkout", h.Attendance.CheckOut)
+	management.Get("/occupancy", h.Attendance.GetOccupancy)
 
 	// Admin User Routes (Staff & Trainers)
-	admin.Post("/users", controllers.CreateUser)
-	admin.Get("/users", controllers.GetUsersByRole)
-	admin.Put("/users/:id", controllers.UpdateUser)
-	admin.Delete("/users/:id", controllers.DeleteUser)
-	admin.Post("/users/:id/toggle", controllers.ToggleUserStatus)
+	admin.Post("/users", h.Users.CreateUser)
+	admin.Get("/users", h.Users.GetUsersByRole)
+	admin.Put("/users/:id", h.Users.UpdateUser)
+	admin.Delete("/users/:id", h.Users.DeleteUser)
+	admin.Post("/users/:id/toggle", h.Users.ToggleUserStatus)
+	admin.Post("/users/:id/2fa/reset", h.TwoFactor.ResetTwoFactor) // Lost authenticator
+
+	// Admin Staff Invites
+	admin.Get("/invites", h.Invites.GetInvites)
+	admin.Post("/invites", h.Invites.InviteUser)
+	admin.Post("/invites/:id/resend", h.Invites.ResendInvite)
+	admin.Delete("/invites/:id", h.Invites.RevokeInvite)
 
 	// Admin Package Routes
-	admin.Post("/packages", controllers.CreatePackage)
-	admin.Put("/packages/:id", controllers.UpdatePackage)
-	admin.Delete("/packages/:id", controllers.DeletePackage)
+	admin.Post("/packages", h.Packages.CreatePackage)
+	admin.Put("/packages/:id", h.Packages.UpdatePackage)
+	admin.Delete("/packages/:id", h.Packages.DeletePackage)
+
+	// Admin Branch Routes
+	admin.Get("/branches", h.Branches.GetBranches)
+	admin.Post("/branches", h.Branches.CreateBranch)
+
+	// Admin Kiosk Devices
+	admin.Get("/devices", h.Devices.GetDevices)
+	admin.Post("/devices", middleware.NoStore(), h.Devices.CreateDevice)
+	admin.Post("/devices/:id/revoke", h.Devices.RevokeDevice)
+
+	// Admin Gate Routes
+	admin.Get("/gates", h.Gates.GetGates)
+	admin.Post("/gates", middleware.NoStore(), h.Gates.CreateGate)
+	admin.Put("/gates/:id", h.Gates.UpdateGate)
+	admin.Delete("/gates/:id", h.Gates.DeleteGate)
+	admin.Post("/gates/:id/test", h.Gates.TestGate)
+	admin.Post("/gates/:id/simulate", h.Gates.SimulateGateEvent)
+	admin.Get("/audit", h.Gates.GetAuditLogs)
+
+	// Admin Login Lockouts
+	admin.Get("/lockouts", h.Lockouts.GetLockouts)
+	admin.Post("/lockouts/clear", h.Lockouts.ClearLockout)
+
+	// Admin Two-Factor Policy
+	admin.Get("/2fa/policy", h.TwoFactor.GetTwoFactorPolicy)
+	admin.Put("/2fa/policy", h.TwoFactor.UpdateTwoFactorPolicy)
+
+	// Admin Incident Review
+	admin.Get("/incidents", h.Incidents.GetIncidents)
+	admin.Post("/incidents/:id/resolve", h.Incidents.ResolveIncident)
+
+	// Admin Notification Routes
+	admin.Get("/notifications/logs", h.Notifications.GetNotificationLogs)
 
 	// Admin Analytics
-	admin.Get("/stats", controllers.GetStats)
-	admin.Get("/attendance/chart", controllers.GetAttendanceChart)
+	admin.Get("/stats", h.Analytics.GetStats)
+	admin.Get("/attendance/chart", h.Analytics.GetAttendanceChart)
+	admin.Get("/occupancy/history", h.Attendance.GetOccupancyHistory)
+	admin.Get("/analytics/attendance", h.Analytics.GetAttendanceAnalytics)
+	admin.Get("/analytics/heatmap", h.Analytics.GetPeakHourHeatmap)
+	admin.Get("/analytics/members", h.Analytics.GetMemberFlows)
+	admin.Get("/analytics/retention", h.Analytics.GetRetentionCohorts)
+	admin.Get("/analytics/revenue", h.Analytics.GetRevenueByPackage)
+	admin.Get("/analytics/visits", h.Analytics.GetAverageVisits)
+	admin.Post("/churn/recompute", h.Churn.RecomputeChurn)
 }
@@ -0,0 +1,370 @@
+// Package search keeps an in-memory index of members and trainers for
+// as-you-type lookups at the front desk.
+package search
+
+import (
+	"encoding/json"
+	"maps"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"gym-api/config"
+	"gym-api/models"
+
+	"gorm.io/gorm"
+)
+
+// indexTTL bounds how stale the index can get when users are changed by
+// another instance, or read back before their transaction committed.
+// Writes through this instance are picked up by the next search.
+const indexTTL = time.Minute
+
+type entry struct {
+	id     uint
+	name   string   // Lowercased
+	words  []string // Name split into words
+	email  string   // Lowercased
+	phone  string   // Digits only
+	number string   // Member number, see MemberNumber
+}
+
+// Hit is a ranked search result.
+type Hit struct {
+	ID    uint
+	Score int
+}
+
+type index struct {
+	mu      sync.Mutex
+	entries []entry
+	builtAt time.Time
+	dirty   bool
+	changed map[uint]bool // Users written since the last search
+}
+
+var members = &index{dirty: true}
+
+// Setup tracks the users written through config.DB, so the next search
+// reloads just those rows. A write that can't be tied to rows, such as an
+// update by condition, rebuilds the whole index instead.
+func Setup() {
+	track := func(db *gorm.DB) {
+		if db.Statement.Table != "users" || db.Error != nil {
+			return
+		}
+		ids, ok := writtenIDs(db)
+		if !ok {
+			Invalidate()
+			return
+		}
+		members.mu.Lock()
+		if members.changed == nil {
+			members.changed = make(map[uint]bool)
+		}
+		for _, id := range ids {
+			members.changed[id] = true
+		}
+		members.mu.Unlock()
+	}
+	cb := config.DB.Callback()
+	cb.Create().After("gorm:create").Register("search:track_create", track)
+	cb.Update().After("gorm:update").Register("search:track_update", track)
+	cb.Delete().After("gorm:delete").Register("search:track_delete", track)
+}
+
+// writtenIDs returns the IDs of the users a statement wrote, from the
+// models it was given, or false if any of them has no ID.
+func writtenIDs(db *gorm.DB) ([]uint, bool) {
+	if db.Statement.Schema == nil || db.Statement.Schema.PrioritizedPrimaryField == nil {
+		return nil, false
+	}
+	field := db.Statement.Schema.PrioritizedPrimaryField
+	id := func(v reflect.Value) (uint, bool) {
+		value, zero := field.ValueOf(db.Statement.Context, reflect.Indirect(v))
+		id, ok := value.(uint)
+		return id, ok && !zero
+	}
+
+	rv := db.Statement.ReflectValue
+	switch rv.Kind() {
+	case reflect.Struct:
+		if id, ok := id(rv); ok {
+			return []uint{id}, true
+		}
+	case reflect.Slice, reflect.Array:
+		ids := make([]uint, 0, rv.Len())
+		for i := range rv.Len() {
+			id, ok := id(rv.Index(i))
+			if !ok {
+				return nil, false
+			}
+			ids = append(ids, id)
+		}
+		return ids, len(ids) > 0
+	}
+	return nil, false
+}
+
+// Invalidate makes the next search rebuild the index.
+func Invalidate() {
+	members.mu.Lock()
+	members.dirty = true
+	members.mu.Unlock()
+}
+
+// MemberNumber is the number printed on membership cards.
+func MemberNumber(id uint) string {
+	return "M" + leftPad(strconv.FormatUint(uint64(id), 10), 6)
+}
+
+func leftPad(s string, n int) string {
+	if len(s) >= n {
+		return s
+	}
+	return strings.Repeat("0", n-len(s)) + s
+}
+
+func (ix *index) snapshot() ([]entry, error) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	if ix.dirty || time.Since(ix.builtAt) >= indexTTL {
+		entries, err := load(config.DB)
+		if err != nil {
+			return nil, err
+		}
+		ix.entries, ix.builtAt, ix.dirty, ix.changed = entries, time.Now(), false, nil
+		return entries, nil
+	}
+
+	if len(ix.changed) > 0 {
+		rows, err := load(config.DB.Where("id IN ?", slices.Collect(maps.Keys(ix.changed))))
+		if err != nil {
+			return nil, err
+		}
+		// A new slice, as searches may still be reading the old one. Rows
+		// no longer found were deleted or stopped being members.
+		entries := make([]entry, 0, len(ix.entries)+len(rows))
+		for _, e := range ix.entries {
+			if !ix.changed[e.id] {
+				entries = append(entries, e)
+			}
+		}
+		ix.entries, ix.changed = append(entries, rows...), nil
+	}
+	return ix.entries, nil
+}
+
+// load indexes the members and trainers db selects.
+func load(db *gorm.DB) ([]entry, error) {
+	var rows []struct {
+		ID    uint
+		Name  string
+		Email string
+		Phone string
+	}
+	err := db.Model(&models.User{}).
+		Select("id, name, email, phone").
+		Where("role IN ?", []models.Role{models.RoleMember, models.RoleTrainer}).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]entry, len(rows))
+	for i, r := range rows {
+		name := strings.ToLower(r.Name)
+		entries[i] = entry{
+			id:     r.ID,
+			name:   name,
+			words:  strings.FieldsFunc(name, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }),
+			email:  strings.ToLower(r.Email),
+			phone:  digits(r.Phone),
+			number: strings.ToLower(MemberNumber(r.ID)),
+		}
+	}
+	return entries, nil
+}
+
+// Members returns up to limit members and trainers matching query, best
+// first. Every word of the query must match: as a prefix of a name word,
+// email or member number, inside the phone number, or within a small edit
+// distance of a name word. A scanned QR payload or "#" and an ID ("#42")
+// matches that member exactly and ranks first.
+func Members(query string, limit int) ([]Hit, error) {
+	entries, err := members.snapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	exact, hasExact := exactID(query)
+	terms := strings.Fields(strings.ToLower(query))
+	if len(terms) == 0 {
+		return []Hit{}, nil
+	}
+
+	var hits []Hit
+	for i := range entries {
+		e := &entries[i]
+		if hasExact && e.id == exact {
+			hits = append(hits, Hit{ID: e.id, Score: 1000})
+			continue
+		}
+
+		total := 0
+		for _, t := range terms {
+			s := e.score(t)
+			if s == 0 {
+				total = 0
+				break
+			}
+			total += s
+		}
+		if total > 0 {
+			hits = append(hits, Hit{ID: e.id, Score: total})
+		}
+	}
+
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].Score > hits[j].Score })
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits, nil
+}
+
+// exactID recognises the JSON payload of a member's QR code or "#" and an
+// ID. Bare numbers are not IDs: they are more likely part of a phone or
+// member number.
+func exactID(query string) (uint, bool) {
+	query = strings.TrimSpace(query)
+
+	if strings.HasPrefix(query, "{") {
+		var qr struct {
+			TrainerID uint `json:"trainer_id"`
+		}
+		if json.Unmarshal([]byte(query), &qr) == nil && qr.TrainerID != 0 {
+			return qr.TrainerID, true
+		}
+		return 0, false
+	}
+
+	rest, ok := strings.CutPrefix(query, "#")
+	if !ok {
+		return 0, false
+	}
+	id, err := strconv.ParseUint(rest, 10, 64)
+	if err != nil || id == 0 {
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func (e *entry) score(term string) int {
+	switch {
+	case strings.HasPrefix(e.name, term):
+		return 100
+	case anyPrefix(e.words, term):
+		return 80
+	case strings.HasPrefix(e.email, term):
+		return 70
+	case strings.HasPrefix(e.number, term):
+		return 65
+	}
+
+	if d := digits(term); len(d) >= 3 && len(d) == len(term) && strings.Contains(e.phone, d) {
+		if strings.HasSuffix(e.phone, d) {
+			return 65 // People often give the last digits
+		}
+		return 60
+	}
+
+	if strings.Contains(e.name, term) {
+		return 50
+	}
+	if strings.Contains(e.email, term) {
+		return 40
+	}
+
+	// Typo tolerance: whole words from 3 letters ("jon" finds "john"),
+	// word prefixes from 4 so half-typed names still match
+	if len(term) >= 3 {
+		maxDist := 1
+		if len(term) >= 7 {
+			maxDist = 2
+		}
+		for _, w := range e.words {
+			d := distance(term, w, maxDist)
+			if len(term) >= 4 && len(w) > len(term) {
+				d = min(d, distance(term, w[:len(term)], maxDist))
+			}
+			if d <= maxDist {
+				return 30 - 10*d
+			}
+		}
+	}
+	return 0
+}
+
+func anyPrefix(words []string, term string) bool {
+	for _, w := range words {
+		if strings.HasPrefix(w, term) {
+			return true
+		}
+	}
+	return false
+}
+
+func digits(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// distance is the Levenshtein distance between a and b, or bound+1 once it
+// is known to exceed bound.
+func distance(a, b string, bound int) int {
+	ra, rb := []rune(a), []rune(b)
+	if abs(len(ra)-len(rb)) > bound {
+		return bound + 1
+	}
+
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		rowMin := cur[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			rowMin = min(rowMin, cur[j])
+		}
+		if rowMin > bound {
+			return bound + 1
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
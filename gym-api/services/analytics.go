@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gym-api/analytics"
+	"gym-api/apperror"
+
+	"gorm.io/gorm"
+)
+
+// Analytics runs the management reports over a period, and the
+// dashboard's summary.
+type Analytics interface {
+	// Summary returns today's dashboard figures.
+	Summary(ctx context.Context) (analytics.Summary, error)
+	Attendance(ctx context.Context, p analytics.Params) ([]analytics.SeriesPoint, error)
+	Heatmap(ctx context.Context, p analytics.Params) ([7][24]int64, error)
+	MemberFlows(ctx context.Context, p analytics.Params) ([]analytics.MemberFlow, error)
+	RetentionCohorts(ctx context.Context, p analytics.Params) ([]analytics.Cohort, error)
+	RevenueByPackage(ctx context.Context, p analytics.Params) ([]analytics.PackageRevenue, error)
+	AverageVisits(ctx context.Context, p analytics.Params) ([]analytics.VisitFrequency, error)
+}
+
+type analyticsService struct {
+	db *gorm.DB
+}
+
+// NewAnalytics runs the reports on db. They filter and join across
+// several tables, so they take the database rather than a repository.
+func NewAnalytics(db *gorm.DB) Analytics {
+	return &analyticsService{db: db}
+}
+
+// run runs report and turns its errors into client errors.
+func run[T any](ctx context.Context, db *gorm.DB, p analytics.Params, report func(*gorm.DB, analytics.Params) (T, error)) (T, error) {
+	data, err := report(db.WithContext(ctx), p)
+	if errors.Is(err, analytics.ErrTooManyBuckets) || errors.Is(err, analytics.ErrRangeTooLarge) {
+		return data, apperror.BadRequest(err.Error())
+	}
+	if err != nil {
+		return data, apperror.DB(err, "Failed to compute report")
+	}
+	return data, nil
+}
+
+func (s *analyticsService) Summary(ctx context.Context) (analytics.Summary, error) {
+	summary, err := analytics.Totals(s.db.WithContext(ctx), time.Now())
+	if err != nil {
+		return summary, apperror.DB(err, "Failed to load stats")
+	}
+	return summary, nil
+}
+
+func (s *analyticsService) Attendance(ctx context.Context, p analytics.Params) ([]analytics.SeriesPoint, error) {
+	return run(ctx, s.db, p, analytics.Attendance)
+}
+
+func (s *analyticsService) Heatmap(ctx context.Context, p analytics.Params) ([7][24]int64, error) {
+	return run(ctx, s.db, p, analytics.Heatmap)
+}
+
+func (s *analyticsService) MemberFlows(ctx context.Context, p analytics.Params) ([]analytics.MemberFlow, error) {
+	return run(ctx, s.db, p, analytics.MemberFlows)
+}
+
+func (s *analyticsService) RetentionCohorts(ctx context.Context, p analytics.Params) ([]analytics.Cohort, error) {
+	return run(ctx, s.db, p, analytics.RetentionCohorts)
+}
+
+func (s *analyticsService) RevenueByPackage(ctx context.Context, p analytics.Params) ([]analytics.PackageRevenue, error) {
+	return run(ctx, s.db, p, analytics.RevenueByPackage)
+}
+
+func (s *analyticsService) AverageVisits(ctx context.Context, p analytics.Params) ([]analytics.VisitFrequency, error) {
+	return run(ctx, s.db, p, analytics.AverageVisits)
+}
@@ -0,0 +1,445 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"gym-api/apperror"
+	"gym-api/config"
+	"gym-api/listing"
+	"gym-api/metrics"
+	"gym-api/models"
+	"gym-api/repository"
+
+	"gorm.io/gorm"
+)
+
+// Codes for refused scans, so scanner apps can react without parsing the
+// reason text.
+const (
+	CodeQRExpired           apperror.Code = "qr_expired"
+	CodeMemberNotFound      apperror.Code = "member_not_found"
+	CodeNotAMember          apperror.Code = "not_a_member"
+	CodeMembershipSuspended apperror.Code = "membership_suspended"
+	CodeSubscriptionExpired apperror.Code = "subscription_expired"
+	CodeAlreadyCheckedIn    apperror.Code = "already_checked_in"
+	CodeAtCapacity          apperror.Code = "at_capacity"
+)
+
+// Attendance covers check-ins, check-outs and the head count.
+type Attendance interface {
+	// StaffScanner is a staff member scanning with the app. Their home
+	// branch decides which feed the scan is broadcast on.
+	StaffScanner(ctx context.Context, userID uint) (Scanner, error)
+	// Admit applies the admission rules to a scanned QR code and records
+	// the attendance. A refusal is a *ScanDenied.
+	Admit(ctx context.Context, a Admission) (models.Attendance, models.User, error)
+	// CheckOut closes the member's open visit.
+	CheckOut(ctx context.Context, memberID uint) (models.Attendance, error)
+	// Reject removes a check-in whose holder didn't match the member's
+	// photo and opens an incident for review.
+	Reject(ctx context.Context, attendanceID uint, r Rejection) (models.Incident, error)
+	// Occupancy returns the head count and capacity of a branch, or of
+	// every branch when branchID is nil. A capacity of 0 is unlimited.
+	Occupancy(ctx context.Context, branchID *uint) (int64, int, error)
+	// OccupancyHistory returns hourly check-ins and peak head count for the
+	// local day of day, for one branch or every branch.
+	OccupancyHistory(ctx context.Context, branchID *uint, day time.Time) ([]OccupancyHour, error)
+
+	List(ctx context.Context, spec listing.Spec, p listing.Params) (*listing.Page[models.Attendance], error)
+	History(ctx context.Context, memberID uint, spec listing.Spec, p listing.Params) (*listing.Page[models.Attendance], error)
+
+	// SyncScan admits a scan a device queued while offline, as of its
+	// capture time, and stores the outcome under the device's client ID in
+	// the same transaction as the check-in. A client ID synced before gets
+	// its stored outcome back, with duplicate set.
+	SyncScan(ctx context.Context, clientID string, a Admission) (scan models.SyncedScan, duplicate bool, err error)
+}
+
+// OccupancyHour is an hour of a day's occupancy history.
+type OccupancyHour struct {
+	Hour     time.Time
+	CheckIns int64
+	Peak     int64 // Highest head count during the hour
+}
+
+// Offline scans older than this are refused rather than back-filled.
+const maxSyncAge = 7 * 24 * time.Hour
+
+// Scanner is whoever admits people: a staff member with the scanner app or
+// an unattended kiosk device.
+type Scanner struct {
+	UserID   *uint
+	DeviceID *uint
+	BranchID *uint
+}
+
+// Admission is a QR code presented to a scanner at time At. Live scans pass
+// time.Now(); offline scans pass their capture time so they are judged as
+// of that moment.
+type Admission struct {
+	MemberID  uint  // From the QR code
+	Timestamp int64 // When the QR code was generated (unix seconds)
+	Scanner   Scanner
+	At        time.Time
+}
+
+// Rejection is staff reporting a check-in whose holder didn't match the
+// member's photo.
+type Rejection struct {
+	ReportedBy uint
+	Reason     string
+	Notes      string
+	Suspend    bool // Suspend the member until an admin reviews the incident
+}
+
+// ScanDenied is returned by Admit when the admission rules refuse entry.
+type ScanDenied struct {
+	Status int
+	Code   apperror.Code
+	Reason string
+}
+
+func (d *ScanDenied) Error() string {
+	return d.Reason
+}
+
+type attendance struct {
+	repos repository.Repos
+	feed  Feed
+}
+
+func NewAttendance(repos repository.Repos, feed Feed) Attendance {
+	return &attendance{repos: repos, feed: feed}
+}
+
+func (s *attendance) StaffScanner(ctx context.Context, userID uint) (Scanner, error) {
+	staff, err := s.repos.Users.ByID(ctx, userID)
+	if err != nil {
+		return Scanner{}, apperror.FromDB(err, "Scanner account not found")
+	}
+	return Scanner{UserID: &staff.ID, BranchID: staff.BranchID}, nil
+}
+
+func (s *attendance) Admit(ctx context.Context, a Admission) (models.Attendance, models.User, error) {
+	return s.admit(ctx, a, nil)
+}
+
+// admit is Admit, running also in the transaction that records the
+// check-in, if it isn't nil.
+func (s *attendance) admit(ctx context.Context, a Admission, also func(tx repository.Repos, record *models.Attendance) error) (models.Attendance, models.User, error) {
+	scanner := a.Scanner
+	live := time.Since(a.At) < time.Minute
+	deny := func(status int, code apperror.Code, reason string) error {
+		metrics.Scans.WithLabelValues("denied", string(code)).Inc()
+		s.feed.Denied(scanner, a.MemberID, reason, live)
+		return &ScanDenied{Status: status, Code: code, Reason: reason}
+	}
+
+	// 1. Validate Timestamp (ensure it's not too old, e.g., < 1 minute)
+	now := a.At.Unix()
+	if now-a.Timestamp > 60 || now-a.Timestamp < -5 { // 60s leniency, 5s clock skew
+		return models.Attendance{}, models.User{}, deny(http.StatusBadRequest, CodeQRExpired, "QR code expired")
+	}
+
+	// 2. Verify the member exists
+	member, err := s.repos.Users.ByID(ctx, a.MemberID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.Attendance{}, member, deny(http.StatusNotFound, CodeMemberNotFound, "Member not found")
+		}
+		return models.Attendance{}, member, err
+	}
+	if member.Role != models.RoleTrainer && member.Role != models.RoleMember {
+		return models.Attendance{}, member, deny(http.StatusBadRequest, CodeNotAMember, "User is not a trainer or member")
+	}
+	if member.MembershipStatus == "pending" {
+		return models.Attendance{}, member, deny(http.StatusForbidden, CodeRegistrationPending, "Registration not approved yet")
+	}
+	if member.MembershipStatus == "suspended" {
+		return models.Attendance{}, member, deny(http.StatusForbidden, CodeMembershipSuspended, "Membership suspended pending review")
+	}
+
+	// 3. Reject members whose subscription has run out
+	if member.Role == models.RoleMember && member.SubEndDate != nil && a.At.After(*member.SubEndDate) {
+		metrics.Scans.WithLabelValues("denied", string(CodeSubscriptionExpired)).Inc()
+		s.feed.Expired(scanner, member, live)
+		return models.Attendance{}, member, &ScanDenied{Status: http.StatusForbidden, Code: CodeSubscriptionExpired, Reason: "Subscription expired"}
+	}
+
+	// 4. Check if already scanned today (optional business rule)
+	seen, err := s.repos.Attendance.CheckedInOn(ctx, member.ID, a.At)
+	if err != nil {
+		return models.Attendance{}, member, err
+	}
+	if seen {
+		return models.Attendance{}, member, deny(http.StatusConflict, CodeAlreadyCheckedIn, "Attendance already marked for today")
+	}
+
+	// 5. Enforce the branch capacity and record the check-in. The branch's
+	// occupancy row stays locked from the head count to the insert, so
+	// concurrent scans can't both take the last place. Late-synced scans
+	// are exempt: those people are already inside.
+	record := models.Attendance{
+		TrainerID: member.ID,
+		ScannedBy: scanner.UserID,
+		DeviceID:  scanner.DeviceID,
+		BranchID:  scanner.BranchID,
+		ScanTime:  a.At,
+		Date:      a.At,
+	}
+	capacity := s.capacity(ctx, scanner.BranchID)
+	full := false
+	err = s.repos.Transaction(ctx, func(tx repository.Repos) error {
+		if live && capacity > 0 {
+			if err := tx.Attendance.LockOccupancy(ctx, scanner.BranchID); err != nil {
+				return err
+			}
+			occupancy, err := tx.Attendance.CountOpen(ctx, scanner.BranchID, time.Now().Add(-config.OccupancyTimeout()))
+			if err != nil {
+				return err
+			}
+			if full = occupancy >= int64(capacity); full {
+				return nil
+			}
+		}
+		if err := tx.Attendance.Create(ctx, &record); err != nil {
+			return err
+		}
+		if also != nil {
+			return also(tx, &record)
+		}
+		return nil
+	})
+	if err != nil {
+		return models.Attendance{}, member, err
+	}
+	if full {
+		return models.Attendance{}, member, deny(http.StatusForbidden, CodeAtCapacity, "Gym is at full capacity")
+	}
+
+	// 6. Open the gate and broadcast to the live feed
+	metrics.Scans.WithLabelValues("admitted", "").Inc()
+	s.feed.Admitted(scanner, member, record, live)
+	s.publishOccupancy(ctx, scanner.BranchID)
+
+	return record, member, nil
+}
+
+func (s *attendance) CheckOut(ctx context.Context, memberID uint) (models.Attendance, error) {
+	// 1. Find the member's open visit
+	visit, err := s.repos.Attendance.LatestOpen(ctx, memberID, time.Now().Add(-config.OccupancyTimeout()))
+	if err != nil {
+		return visit, apperror.FromDB(err, "No open check-in found")
+	}
+
+	// 2. Close it
+	now := time.Now()
+	visit.CheckOutTime = &now
+	if err := s.repos.Attendance.Save(ctx, &visit); err != nil {
+		return visit, apperror.DB(err, "Could not check out")
+	}
+
+	s.feed.CheckedOut(visit)
+	s.publishOccupancy(ctx, visit.BranchID)
+	return visit, nil
+}
+
+func (s *attendance) Reject(ctx context.Context, attendanceID uint, r Rejection) (models.Incident, error) {
+	visit, err := s.repos.Attendance.ByID(ctx, attendanceID)
+	if err != nil {
+		return models.Incident{}, apperror.FromDB(err, "Attendance not found")
+	}
+
+	incident := models.Incident{
+		MemberID:     visit.TrainerID,
+		AttendanceID: visit.ID,
+		ScanTime:     visit.ScanTime,
+		ScannedBy:    visit.ScannedBy,
+		DeviceID:     visit.DeviceID,
+		ReportedBy:   r.ReportedBy,
+		Reason:       r.Reason,
+		Notes:        r.Notes,
+		Suspended:    r.Suspend,
+		Status:       "open",
+	}
+	err = s.repos.Transaction(ctx, func(tx repository.Repos) error {
+		if err := tx.Attendance.Delete(ctx, &visit); err != nil {
+			return err
+		}
+		if err := tx.Incidents.Create(ctx, &incident); err != nil {
+			return err
+		}
+		if r.Suspend {
+			return tx.Users.SetMembershipStatus(ctx, visit.TrainerID, "suspended")
+		}
+		return nil
+	})
+	if err != nil {
+		return incident, apperror.DB(err, "Could not reject attendance")
+	}
+
+	s.feed.Rejected(visit, incident)
+	s.publishOccupancy(ctx, visit.BranchID)
+	return incident, nil
+}
+
+func (s *attendance) Occupancy(ctx context.Context, branchID *uint) (int64, int, error) {
+	occupancy, err := s.count(ctx, branchID)
+	if err != nil {
+		return 0, 0, apperror.DB(err, "Failed to load occupancy")
+	}
+	return occupancy, s.capacity(ctx, branchID), nil
+}
+
+func (s *attendance) OccupancyHistory(ctx context.Context, branchID *uint, day time.Time) ([]OccupancyHour, error) {
+	dayStart := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.Local)
+	dayEnd := dayStart.AddDate(0, 0, 1)
+	timeout := config.OccupancyTimeout()
+
+	// 1. Load every visit that overlaps the day
+	visits, err := s.repos.Attendance.Visits(ctx, branchID, dayStart.Add(-timeout), dayEnd)
+	if err != nil {
+		return nil, apperror.DB(err, "Failed to load occupancy history")
+	}
+
+	// 2. Turn visits into +1/-1 changes and sweep through them in time order
+	type change struct {
+		at    time.Time
+		delta int64
+	}
+	now := time.Now()
+	changes := make([]change, 0, len(visits)*2)
+	for _, v := range visits {
+		out := v.ScanTime.Add(timeout)
+		if v.CheckOutTime != nil && v.CheckOutTime.Before(out) {
+			out = *v.CheckOutTime
+		}
+		if out.After(now) {
+			out = now
+		}
+		changes = append(changes, change{v.ScanTime, 1}, change{out, -1})
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].at.Equal(changes[j].at) {
+			return changes[i].delta < changes[j].delta // leave before entering
+		}
+		return changes[i].at.Before(changes[j].at)
+	})
+
+	hours := make([]OccupancyHour, 24)
+	for h := range hours {
+		hours[h].Hour = dayStart.Add(time.Duration(h) * time.Hour)
+	}
+
+	var present int64
+	i := 0
+	for ; i < len(changes) && changes[i].at.Before(dayStart); i++ {
+		present += changes[i].delta
+	}
+	for h := range hours {
+		hourEnd := hours[h].Hour.Add(time.Hour)
+		hours[h].Peak = present
+		for ; i < len(changes) && changes[i].at.Before(hourEnd); i++ {
+			present += changes[i].delta
+			if changes[i].delta > 0 {
+				hours[h].CheckIns++
+			}
+			hours[h].Peak = max(hours[h].Peak, present)
+		}
+	}
+	return hours, nil
+}
+
+// count is the number of people checked in and not yet checked out.
+// Check-ins older than config.OccupancyTimeout are assumed to have left.
+func (s *attendance) count(ctx context.Context, branchID *uint) (int64, error) {
+	return s.repos.Attendance.CountOpen(ctx, branchID, time.Now().Add(-config.OccupancyTimeout()))
+}
+
+// capacity returns the branch's capacity, falling back to MAX_CAPACITY.
+func (s *attendance) capacity(ctx context.Context, branchID *uint) int {
+	if branchID != nil {
+		if branch, err := s.repos.Branches.ByID(ctx, *branchID); err == nil && branch.Capacity > 0 {
+			return branch.Capacity
+		}
+	}
+	return config.MaxCapacity()
+}
+
+// publishOccupancy broadcasts the branch's current head count.
+func (s *attendance) publishOccupancy(ctx context.Context, branchID *uint) {
+	occupancy, err := s.count(ctx, branchID)
+	if err != nil {
+		return // The next check-in or check-out will publish it
+	}
+	s.feed.Occupancy(branchID, occupancy, s.capacity(ctx, branchID))
+}
+
+func (s *attendance) List(ctx context.Context, spec listing.Spec, p listing.Params) (*listing.Page[models.Attendance], error) {
+	page, err := s.repos.Attendance.List(ctx, spec, p)
+	if err != nil {
+		return nil, apperror.DB(err, "Failed to fetch attendance logs")
+	}
+	return page, nil
+}
+
+func (s *attendance) History(ctx context.Context, memberID uint, spec listing.Spec, p listing.Params) (*listing.Page[models.Attendance], error) {
+	page, err := s.repos.Attendance.ForMember(ctx, memberID, spec, p)
+	if err != nil {
+		return nil, apperror.DB(err, "Failed to fetch history")
+	}
+	return page, nil
+}
+
+func (s *attendance) SyncScan(ctx context.Context, clientID string, a Admission) (models.SyncedScan, bool, error) {
+	var deviceID uint
+	if a.Scanner.DeviceID != nil {
+		deviceID = *a.Scanner.DeviceID
+	}
+
+	// 1. Seen before: replay the stored outcome
+	previous, err := s.repos.Attendance.SyncedScan(ctx, deviceID, clientID)
+	if err == nil {
+		return previous, true, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return previous, false, apperror.DB(err, "Could not look up earlier syncs")
+	}
+
+	// 2. Judge the scan as of its capture time. A check-in is stored with
+	// its outcome; a refusal on its own.
+	scan := models.SyncedScan{
+		DeviceID:   deviceID,
+		ClientID:   clientID,
+		MemberID:   a.MemberID,
+		CapturedAt: a.At,
+		Status:     "admitted",
+	}
+	if a.At.After(time.Now().Add(5*time.Minute)) || time.Since(a.At) > maxSyncAge {
+		scan.Status, scan.Reason = "denied", "Capture time out of range"
+	} else {
+		_, _, err := s.admit(ctx, a, func(tx repository.Repos, record *models.Attendance) error {
+			scan.AttendanceID = &record.ID
+			return tx.Attendance.CreateSyncedScan(ctx, &scan)
+		})
+		var denied *ScanDenied
+		switch {
+		case errors.As(err, &denied):
+			scan.Status, scan.Reason = "denied", denied.Reason
+		case err != nil:
+			// Nothing is stored, so the device retries it on the next sync
+			return scan, false, apperror.DB(err, "Could not mark attendance")
+		default:
+			return scan, false, nil
+		}
+	}
+
+	if err := s.repos.Attendance.CreateSyncedScan(ctx, &scan); err != nil {
+		return scan, false, apperror.DB(err, "Could not record sync result")
+	}
+	return scan, false, nil
+}
@@ -0,0 +1,419 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"gym-api/apperror"
+	"gym-api/models"
+	"gym-api/repository"
+	"gym-api/repository/fake"
+)
+
+// feed records what the attendance service broadcast.
+type feed struct {
+	admitted, denied, expired, checkedOut, rejected int
+	occupancy                                       []int64
+}
+
+func (f *feed) Admitted(Scanner, models.User, models.Attendance, bool) { f.admitted++ }
+func (f *feed) Denied(Scanner, uint, string, bool)                     { f.denied++ }
+func (f *feed) Expired(Scanner, models.User, bool)                     { f.expired++ }
+func (f *feed) CheckedOut(models.Attendance)                           { f.checkedOut++ }
+func (f *feed) Rejected(models.Attendance, models.Incident)            { f.rejected++ }
+func (f *feed) Occupancy(_ *uint, occupancy int64, _ int) {
+	f.occupancy = append(f.occupancy, occupancy)
+}
+
+func newAttendance(t *testing.T) (*fake.Store, *feed, Attendance) {
+	t.Helper()
+	t.Setenv("MAX_CAPACITY", "0")
+	store, f := fake.New(), &feed{}
+	return store, f, NewAttendance(store.Repos(), f)
+}
+
+// scan is a live scan of a fresh QR code for memberID.
+func scan(memberID uint, scanner Scanner) Admission {
+	now := time.Now()
+	return Admission{MemberID: memberID, Timestamp: now.Unix(), Scanner: scanner, At: now}
+}
+
+// denied returns the code of a *ScanDenied, failing the test for any other
+// outcome.
+func denied(t *testing.T, err error, status int) string {
+	t.Helper()
+	var d *ScanDenied
+	if !errors.As(err, &d) {
+		t.Fatalf("err = %v, want a *ScanDenied", err)
+	}
+	if d.Status != status {
+		t.Errorf("status = %d, want %d", d.Status, status)
+	}
+	return string(d.Code)
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestAdmitRefuses(t *testing.T) {
+	yesterday := time.Now().AddDate(0, 0, -1)
+	tests := []struct {
+		name   string
+		member models.User
+		qrAge  time.Duration
+		status int
+		code   apperror.Code
+	}{
+		{"expired QR code", models.User{Role: models.RoleMember}, 2 * time.Minute, http.StatusBadRequest, CodeQRExpired},
+		{"QR code from the future", models.User{Role: models.RoleMember}, -time.Minute, http.StatusBadRequest, CodeQRExpired},
+		{"staff", models.User{Role: models.RoleStaff}, 0, http.StatusBadRequest, CodeNotAMember},
+		{"pending registration", models.User{Role: models.RoleMember, MembershipStatus: "pending"}, 0, http.StatusForbidden, CodeRegistrationPending},
+		{"suspended", models.User{Role: models.RoleMember, MembershipStatus: "suspended"}, 0, http.StatusForbidden, CodeMembershipSuspended},
+		{"expired subscription", models.User{Role: models.RoleMember, MembershipStatus: "active", SubEndDate: &yesterday}, 0, http.StatusForbidden, CodeSubscriptionExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, f, svc := newAttendance(t)
+			member := store.AddUser(tt.member)
+
+			a := scan(member.ID, Scanner{})
+			a.Timestamp -= int64(tt.qrAge.Seconds())
+			_, _, err := svc.Admit(context.Background(), a)
+
+			if code := denied(t, err, tt.status); code != string(tt.code) {
+				t.Errorf("code = %s, want %s", code, tt.code)
+			}
+			if len(store.Attendance) != 0 {
+				t.Errorf("recorded %d check-ins, want none", len(store.Attendance))
+			}
+			if f.admitted != 0 {
+				t.Error("broadcast an admission")
+			}
+		})
+	}
+}
+
+func TestAdmitUnknownMember(t *testing.T) {
+	_, f, svc := newAttendance(t)
+
+	_, _, err := svc.Admit(context.Background(), scan(42, Scanner{}))
+
+	if code := denied(t, err, http.StatusNotFound); code != string(CodeMemberNotFound) {
+		t.Errorf("code = %s, want %s", code, CodeMemberNotFound)
+	}
+	if f.denied != 1 {
+		t.Errorf("broadcast %d denials, want 1", f.denied)
+	}
+}
+
+func TestAdmitRecordsCheckIn(t *testing.T) {
+	store, f, svc := newAttendance(t)
+	branch := store.AddBranch(models.Branch{Capacity: 10})
+	end := time.Now().AddDate(0, 1, 0)
+	member := store.AddUser(models.User{Role: models.RoleMember, MembershipStatus: "active", SubEndDate: &end})
+	staff := store.AddUser(models.User{Role: models.RoleStaff, BranchID: &branch.ID})
+
+	scanner, err := svc.StaffScanner(context.Background(), staff.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record, got, err := svc.Admit(context.Background(), scan(member.ID, scanner))
+	if err != nil {
+		t.Fatalf("Admit: %v", err)
+	}
+
+	if got.ID != member.ID || record.TrainerID != member.ID {
+		t.Errorf("admitted member %d (record for %d), want %d", got.ID, record.TrainerID, member.ID)
+	}
+	if record.BranchID == nil || *record.BranchID != branch.ID || record.ScannedBy == nil || *record.ScannedBy != staff.ID {
+		t.Errorf("record = %+v, want the scanner's branch and ID", record)
+	}
+	if len(store.Attendance) != 1 {
+		t.Fatalf("stored %d check-ins, want 1", len(store.Attendance))
+	}
+	if f.admitted != 1 || len(f.occupancy) != 1 || f.occupancy[0] != 1 {
+		t.Errorf("feed = %+v, want one admission and an occupancy of 1", f)
+	}
+}
+
+func TestAdmitOncePerDay(t *testing.T) {
+	store, _, svc := newAttendance(t)
+	member := store.AddUser(models.User{Role: models.RoleMember, MembershipStatus: "active"})
+
+	if _, _, err := svc.Admit(context.Background(), scan(member.ID, Scanner{})); err != nil {
+		t.Fatalf("first scan: %v", err)
+	}
+	_, _, err := svc.Admit(context.Background(), scan(member.ID, Scanner{}))
+
+	if code := denied(t, err, http.StatusConflict); code != string(CodeAlreadyCheckedIn) {
+		t.Errorf("code = %s, want %s", code, CodeAlreadyCheckedIn)
+	}
+}
+
+func TestAdmitAtCapacity(t *testing.T) {
+	store, _, svc := newAttendance(t)
+	branch := store.AddBranch(models.Branch{Capacity: 1})
+	inside := store.AddUser(models.User{Role: models.RoleMember, MembershipStatus: "active"})
+	store.AddAttendance(models.Attendance{TrainerID: inside.ID, BranchID: &branch.ID, ScanTime: time.Now(), Date: time.Now()})
+	member := store.AddUser(models.User{Role: models.RoleMember, MembershipStatus: "active"})
+
+	_, _, err := svc.Admit(context.Background(), scan(member.ID, Scanner{BranchID: &branch.ID}))
+
+	if code := denied(t, err, http.StatusForbidden); code != string(CodeAtCapacity) {
+		t.Errorf("code = %s, want %s", code, CodeAtCapacity)
+	}
+	if len(store.Attendance) != 1 {
+		t.Errorf("stored %d check-ins, want only the one already inside", len(store.Attendance))
+	}
+}
+
+func TestAdmitCapacityCountsOnlyTheBranch(t *testing.T) {
+	store, _, svc := newAttendance(t)
+	full := store.AddBranch(models.Branch{Capacity: 1})
+	branch := store.AddBranch(models.Branch{Capacity: 1})
+	inside := store.AddUser(models.User{Role: models.RoleMember, MembershipStatus: "active"})
+	store.AddAttendance(models.Attendance{TrainerID: inside.ID, BranchID: &full.ID, ScanTime: time.Now(), Date: time.Now()})
+	member := store.AddUser(models.User{Role: models.RoleMember, MembershipStatus: "active"})
+
+	if _, _, err := svc.Admit(context.Background(), scan(member.ID, Scanner{BranchID: &branch.ID})); err != nil {
+		t.Fatalf("Admit: %v", err)
+	}
+}
+
+func TestAdmitOfflineScanSkipsCapacity(t *testing.T) {
+	store, f, svc := newAttendance(t)
+	branch := store.AddBranch(models.Branch{Capacity: 1})
+	inside := store.AddUser(models.User{Role: models.RoleMember, MembershipStatus: "active"})
+	store.AddAttendance(models.Attendance{TrainerID: inside.ID, BranchID: &branch.ID, ScanTime: time.Now(), Date: time.Now()})
+	member := store.AddUser(models.User{Role: models.RoleMember, MembershipStatus: "active"})
+
+	// Captured ten minutes ago by a kiosk that was offline: already inside
+	captured := time.Now().Add(-10 * time.Minute)
+	a := Admission{MemberID: member.ID, Timestamp: captured.Unix(), Scanner: Scanner{BranchID: &branch.ID}, At: captured}
+	record, _, err := svc.Admit(context.Background(), a)
+	if err != nil {
+		t.Fatalf("Admit: %v", err)
+	}
+
+	if !record.ScanTime.Equal(captured) {
+		t.Errorf("scan time = %v, want the capture time %v", record.ScanTime, captured)
+	}
+	if f.admitted != 1 {
+		t.Errorf("broadcast %d admissions, want 1", f.admitted)
+	}
+}
+
+func TestAdmitJudgesOfflineScanAtCaptureTime(t *testing.T) {
+	store, _, svc := newAttendance(t)
+	// The subscription ran out after the scan was captured
+	end := time.Now().Add(-time.Hour)
+	member := store.AddUser(models.User{Role: models.RoleMember, MembershipStatus: "active", SubEndDate: &end})
+
+	captured := end.Add(-time.Hour)
+	a := Admission{MemberID: member.ID, Timestamp: captured.Unix(), At: captured}
+	if _, _, err := svc.Admit(context.Background(), a); err != nil {
+		t.Fatalf("Admit: %v", err)
+	}
+}
+
+func TestSyncScanReplaysOutcome(t *testing.T) {
+	store, f, svc := newAttendance(t)
+	member := store.AddUser(models.User{Role: models.RoleMember, MembershipStatus: "active"})
+	captured := time.Now().Add(-time.Hour)
+	a := Admission{MemberID: member.ID, Timestamp: captured.Unix(), Scanner: Scanner{DeviceID: ptr(uint(3))}, At: captured}
+
+	first, duplicate, err := svc.SyncScan(context.Background(), "scan-1", a)
+	if err != nil || duplicate || first.Status != "admitted" || first.AttendanceID == nil {
+		t.Fatalf("first sync = %+v, %v, %v; want admitted", first, duplicate, err)
+	}
+	again, duplicate, err := svc.SyncScan(context.Background(), "scan-1", a)
+	if err != nil || !duplicate || again.AttendanceID == nil || *again.AttendanceID != *first.AttendanceID {
+		t.Errorf("second sync = %+v, %v, %v; want the first outcome", again, duplicate, err)
+	}
+	if len(store.Attendance) != 1 || f.admitted != 1 {
+		t.Errorf("%d check-ins, %d broadcast; want 1", len(store.Attendance), f.admitted)
+	}
+}
+
+func TestSyncScanStoresRefusals(t *testing.T) {
+	store, _, svc := newAttendance(t)
+	captured := time.Now().Add(-time.Hour)
+
+	scan, _, err := svc.SyncScan(context.Background(), "scan-1", Admission{MemberID: 999, Timestamp: captured.Unix(), At: captured})
+	if err != nil || scan.Status != "denied" || scan.Reason != "Member not found" {
+		t.Fatalf("sync = %+v, %v; want denied", scan, err)
+	}
+	old := time.Now().Add(-30 * 24 * time.Hour)
+	scan, _, err = svc.SyncScan(context.Background(), "scan-2", Admission{MemberID: 999, Timestamp: old.Unix(), At: old})
+	if err != nil || scan.Status != "denied" || scan.Reason != "Capture time out of range" {
+		t.Fatalf("sync = %+v, %v; want denied", scan, err)
+	}
+	if len(store.SyncedScans) != 2 {
+		t.Errorf("stored %d outcomes, want 2", len(store.SyncedScans))
+	}
+}
+
+// brokenSyncLookup fails every synced-scan lookup, as during an outage.
+type brokenSyncLookup struct{ repository.Attendance }
+
+func (brokenSyncLookup) SyncedScan(context.Context, uint, string) (models.SyncedScan, error) {
+	return models.SyncedScan{}, errors.New("connection refused")
+}
+
+func TestSyncScanLookupError(t *testing.T) {
+	store := fake.New()
+	repos := store.Repos()
+	repos.Attendance = brokenSyncLookup{repos.Attendance}
+	member := store.AddUser(models.User{Role: models.RoleMember, MembershipStatus: "active"})
+	captured := time.Now().Add(-time.Hour)
+
+	// Not knowing whether the scan was synced must not admit it again
+	_, _, err := NewAttendance(repos, &feed{}).SyncScan(context.Background(), "scan-1", Admission{MemberID: member.ID, Timestamp: captured.Unix(), At: captured})
+	if e, ok := apperror.As(err); !ok || e.Status < 500 {
+		t.Errorf("err = %v, want a server error", err)
+	}
+	if len(store.Attendance) != 0 {
+		t.Errorf("%d check-ins, want none", len(store.Attendance))
+	}
+}
+
+func TestCheckOut(t *testing.T) {
+	store, f, svc := newAttendance(t)
+	member := store.AddUser(models.User{Role: models.RoleMember, MembershipStatus: "active"})
+	visit := store.AddAttendance(models.Attendance{TrainerID: member.ID, ScanTime: time.Now().Add(-time.Hour), Date: time.Now()})
+
+	got, err := svc.CheckOut(context.Background(), member.ID)
+	if err != nil {
+		t.Fatalf("CheckOut: %v", err)
+	}
+
+	if got.ID != visit.ID || got.CheckOutTime == nil {
+		t.Errorf("closed %+v, want visit %d with a check-out time", got, visit.ID)
+	}
+	if store.Attendance[0].CheckOutTime == nil {
+		t.Error("check-out not stored")
+	}
+	if f.checkedOut != 1 {
+		t.Errorf("broadcast %d check-outs, want 1", f.checkedOut)
+	}
+
+	_, err = svc.CheckOut(context.Background(), member.ID)
+	if e, ok := apperror.As(err); !ok || e.Status != http.StatusNotFound {
+		t.Errorf("second check-out: err = %v, want 404", err)
+	}
+}
+
+// brokenAttendance fails every open-visit lookup, as during an outage.
+type brokenAttendance struct{ repository.Attendance }
+
+func (brokenAttendance) LatestOpen(context.Context, uint, time.Time) (models.Attendance, error) {
+	return models.Attendance{}, errors.New("connection refused")
+}
+
+func TestCheckOutDatabaseError(t *testing.T) {
+	store := fake.New()
+	repos := store.Repos()
+	repos.Attendance = brokenAttendance{repos.Attendance}
+	member := store.AddUser(models.User{Role: models.RoleMember, MembershipStatus: "active"})
+
+	_, err := NewAttendance(repos, &feed{}).CheckOut(context.Background(), member.ID)
+	if e, ok := apperror.As(err); !ok || e.Status == http.StatusNotFound || e.Status < 500 {
+		t.Errorf("err = %v, want a server error, not 404", err)
+	}
+}
+
+func TestCheckOutIgnoresStaleVisits(t *testing.T) {
+	store, _, svc := newAttendance(t)
+	member := store.AddUser(models.User{Role: models.RoleMember, MembershipStatus: "active"})
+	// Longer ago than OCCUPANCY_TIMEOUT_MINUTES: assumed to have left
+	old := time.Now().Add(-24 * time.Hour)
+	store.AddAttendance(models.Attendance{TrainerID: member.ID, ScanTime: old, Date: old})
+
+	_, err := svc.CheckOut(context.Background(), member.ID)
+	if e, ok := apperror.As(err); !ok || e.Status != http.StatusNotFound {
+		t.Errorf("err = %v, want 404", err)
+	}
+}
+
+func TestReject(t *testing.T) {
+	for _, suspend := range []bool{false, true} {
+		store, f, svc := newAttendance(t)
+		member := store.AddUser(models.User{Role: models.RoleMember, MembershipStatus: "active"})
+		staff := store.AddUser(models.User{Role: models.RoleStaff})
+		visit := store.AddAttendance(models.Attendance{TrainerID: member.ID, ScannedBy: &staff.ID, ScanTime: time.Now(), Date: time.Now()})
+
+		incident, err := svc.Reject(context.Background(), visit.ID, Rejection{ReportedBy: staff.ID, Reason: "photo mismatch", Suspend: suspend})
+		if err != nil {
+			t.Fatalf("Reject: %v", err)
+		}
+
+		if incident.MemberID != member.ID || incident.AttendanceID != visit.ID || incident.Status != "open" || incident.Suspended != suspend {
+			t.Errorf("incident = %+v", incident)
+		}
+		if len(store.Attendance) != 0 || len(store.Incidents) != 1 {
+			t.Errorf("%d check-ins and %d incidents left, want 0 and 1", len(store.Attendance), len(store.Incidents))
+		}
+		want := "active"
+		if suspend {
+			want = "suspended"
+		}
+		if status := store.User(member.ID).MembershipStatus; status != want {
+			t.Errorf("suspend=%t: status = %q, want %q", suspend, status, want)
+		}
+		if f.rejected != 1 {
+			t.Errorf("broadcast %d rejections, want 1", f.rejected)
+		}
+	}
+}
+
+func TestOccupancyFallsBackToMaxCapacity(t *testing.T) {
+	store, _, svc := newAttendance(t)
+	t.Setenv("MAX_CAPACITY", "50")
+	branch := store.AddBranch(models.Branch{})
+	store.AddAttendance(models.Attendance{BranchID: &branch.ID, ScanTime: time.Now()})
+	store.AddAttendance(models.Attendance{BranchID: &branch.ID, ScanTime: time.Now(), CheckOutTime: ptr(time.Now())})
+
+	occupancy, capacity, err := svc.Occupancy(context.Background(), &branch.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if occupancy != 1 || capacity != 50 {
+		t.Errorf("occupancy, capacity = %d, %d; want 1, 50", occupancy, capacity)
+	}
+}
+
+func TestOccupancyHistory(t *testing.T) {
+	store, _, svc := newAttendance(t)
+	t.Setenv("OCCUPANCY_TIMEOUT_MINUTES", "60")
+	branch, other := store.AddBranch(models.Branch{}), store.AddBranch(models.Branch{})
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, time.March, day, hour, minute, 0, 0, time.Local)
+	}
+	store.AddAttendance(models.Attendance{BranchID: &branch.ID, ScanTime: at(1, 23, 30)}) // Still in at midnight
+	store.AddAttendance(models.Attendance{BranchID: &branch.ID, ScanTime: at(2, 8, 10), CheckOutTime: ptr(at(2, 8, 50))})
+	store.AddAttendance(models.Attendance{BranchID: &branch.ID, ScanTime: at(2, 8, 40)}) // Times out at 9:40
+	store.AddAttendance(models.Attendance{BranchID: &other.ID, ScanTime: at(2, 8, 20)})
+
+	hours, err := svc.OccupancyHistory(context.Background(), &branch.ID, at(2, 15, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hours) != 24 || !hours[0].Hour.Equal(at(2, 0, 0)) {
+		t.Fatalf("got %d hours from %v, want 24 from midnight", len(hours), hours[0].Hour)
+	}
+	for _, want := range []OccupancyHour{
+		{Hour: at(2, 0, 0), Peak: 1},
+		{Hour: at(2, 1, 0)},
+		{Hour: at(2, 8, 0), CheckIns: 2, Peak: 2},
+		{Hour: at(2, 9, 0), Peak: 1},
+		{Hour: at(2, 10, 0)},
+	} {
+		if got := hours[want.Hour.Hour()]; got != want {
+			t.Errorf("%s: %+v, want %+v", want.Hour.Format("15:04"), got, want)
+		}
+	}
+}
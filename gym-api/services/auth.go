@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gym-api/apperror"
+	"gym-api/lockout"
+	"gym-api/models"
+	"gym-api/repository"
+	"gym-api/utils"
+
+	"gorm.io/gorm"
+)
+
+// Auth covers signing in with a password and the signed-in user's own
+// account.
+type Auth interface {
+	// Login checks an email address and password. Attempts count against
+	// the login limits; a lockout refusal is returned as the
+	// *lockout.Blocked. Accounts with 2FA still need a code after it.
+	Login(ctx context.Context, email, password, ip string) (models.User, error)
+	// Profile returns the signed-in user.
+	Profile(ctx context.Context, userID uint) (models.User, error)
+	ChangePassword(ctx context.Context, userID uint, oldPassword, newPassword string) error
+}
+
+type auth struct {
+	repos   repository.Repos
+	limiter *lockout.Limiter
+	feed    Feed
+}
+
+func NewAuth(repos repository.Repos, limiter *lockout.Limiter, feed Feed) Auth {
+	return &auth{repos: repos, limiter: limiter, feed: feed}
+}
+
+func (s *auth) Login(ctx context.Context, email, password, ip string) (models.User, error) {
+	// Refuse guessing before touching the password
+	if err := s.limiter.Check(ctx, email, ip); err != nil {
+		return models.User{}, err
+	}
+
+	// Emails are stored normalized (see package migrations), so this uses the index
+	user, err := s.repos.Users.ByEmail(ctx, email)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		s.limiter.Failed(ctx, email, ip, nil)
+		return user, apperror.Unauthorized("Invalid credentials")
+	}
+	if err != nil {
+		return user, apperror.DB(err, "Could not login")
+	}
+	if !utils.CheckPasswordHash(password, user.PasswordHash) {
+		s.limiter.Failed(ctx, email, ip, &user.ID)
+		return user, apperror.Unauthorized("Invalid credentials")
+	}
+
+	if !user.IsActive {
+		return user, apperror.Unauthorized("User is deactivated")
+	}
+	if user.MembershipStatus == "pending" {
+		return user, s.registrationPending(ctx, user.ID)
+	}
+	if user.Role == models.RoleMember && user.SubEndDate != nil && time.Now().After(*user.SubEndDate) {
+		// Shown on the live feed; no gate is waiting for this one
+		s.feed.Expired(Scanner{BranchID: user.BranchID}, user, false)
+		return user, apperror.Forbidden("Subscription expired")
+	}
+
+	// Failures of 2FA accounts are only forgotten once the code is right
+	// too, or every correct password would grant a fresh round of guesses
+	if user.TwoFactorEnabledAt == nil {
+		s.limiter.Succeeded(ctx, email)
+	}
+	return user, nil
+}
+
+// registrationPending explains to a pending member why they cannot sign in.
+func (s *auth) registrationPending(ctx context.Context, userID uint) error {
+	registration, err := s.repos.Registrations.ForUser(ctx, userID)
+	if err == nil && registration.EmailVerifiedAt == nil {
+		return apperror.Forbidden("Confirm your email address first").WithCode(CodeEmailNotVerified)
+	}
+	return apperror.Forbidden("Your registration is waiting for approval").WithCode(CodeRegistrationPending)
+}
+
+func (s *auth) Profile(ctx context.Context, userID uint) (models.User, error) {
+	user, err := s.repos.Users.ByID(ctx, userID)
+	if err != nil {
+		return user, apperror.FromDB(err, "User not found")
+	}
+	return user, nil
+}
+
+func (s *auth) ChangePassword(ctx context.Context, userID uint, oldPassword, newPassword string) error {
+	user, err := s.Profile(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !utils.CheckPasswordHash(oldPassword, user.PasswordHash) {
+		return apperror.Unauthorized("Incorrect old password")
+	}
+
+	hash, err := utils.HashPassword(newPassword)
+	if err != nil {
+		return apperror.Internal(err, "Could not hash new password")
+	}
+	user.PasswordHash = hash
+	if err := s.repos.Users.Save(ctx, &user); err != nil {
+		return apperror.DB(err, "Could not update password")
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"gym-api/config"
+	"gym-api/lockout"
+	"gym-api/models"
+	"gym-api/repository/fake"
+	"gym-api/utils"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// useAuditLog points config.DB, which the login limits audit failures to,
+// at an in-memory database.
+func useAuditLog(t *testing.T) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(&models.AuditLog{}); err != nil {
+		t.Fatal(err)
+	}
+	previous := config.DB
+	config.DB = db
+	t.Cleanup(func() {
+		config.DB = previous
+		sqlDB.Close()
+	})
+}
+
+func newAuth(t *testing.T) (*fake.Store, *feed, Auth) {
+	useAuditLog(t)
+	store, events := fake.New(), &feed{}
+	limiter := &lockout.Limiter{Store: lockout.NewMemoryStore(), Limits: config.LoginLimits{
+		MaxFailures:  3,
+		DelayAfter:   10,
+		Window:       time.Hour,
+		LockDuration: time.Hour,
+		IPAttempts:   100,
+	}}
+	return store, events, NewAuth(store.Repos(), limiter, events)
+}
+
+// secretHash hashes Secret123! once; bcrypt is slow on purpose.
+var secretHash = sync.OnceValues(func() (string, error) { return utils.HashPassword("Secret123!") })
+
+// addLogin stores an active account with password Secret123!.
+func addLogin(t *testing.T, store *fake.Store, user models.User) models.User {
+	t.Helper()
+	hash, err := secretHash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	user.PasswordHash, user.IsActive = hash, true
+	if user.Role == "" {
+		user.Role = models.RoleStaff
+	}
+	return store.AddUser(user)
+}
+
+func TestLogin(t *testing.T) {
+	store, _, svc := newAuth(t)
+	user := addLogin(t, store, models.User{Email: "sam@example.com"})
+
+	got, err := svc.Login(context.Background(), "sam@example.com", "Secret123!", "10.0.0.1")
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if got.ID != user.ID {
+		t.Errorf("signed in %d, want %d", got.ID, user.ID)
+	}
+}
+
+func TestLoginLocksAfterFailures(t *testing.T) {
+	store, _, svc := newAuth(t)
+	addLogin(t, store, models.User{Email: "sam@example.com"})
+	ctx := context.Background()
+
+	for _, email := range []string{"sam@example.com", "sam@example.com", "nobody@example.com", "sam@example.com"} {
+		_, err := svc.Login(ctx, email, "wrong", "10.0.0.1")
+		wantStatus(t, err, http.StatusUnauthorized)
+	}
+
+	_, err := svc.Login(ctx, "sam@example.com", "Secret123!", "10.0.0.1")
+	var blocked *lockout.Blocked
+	if !errors.As(err, &blocked) || !blocked.Locked {
+		t.Errorf("after 3 failures: err = %v, want the account locked", err)
+	}
+}
+
+func TestLoginRefusals(t *testing.T) {
+	store, events, svc := newAuth(t)
+	ctx := context.Background()
+	yesterday := time.Now().AddDate(0, 0, -1)
+	addLogin(t, store, models.User{Email: "off@example.com"})
+	store.Users[len(store.Users)-1].IsActive = false
+	addLogin(t, store, models.User{Email: "expired@example.com", Role: models.RoleMember, MembershipStatus: "active", SubEndDate: &yesterday})
+
+	_, err := svc.Login(ctx, "off@example.com", "Secret123!", "10.0.0.1")
+	wantStatus(t, err, http.StatusUnauthorized)
+
+	_, err = svc.Login(ctx, "expired@example.com", "Secret123!", "10.0.0.1")
+	wantStatus(t, err, http.StatusForbidden)
+	if events.expired != 1 {
+		t.Errorf("expired announced %d times, want 1", events.expired)
+	}
+}
+
+func TestLoginPendingRegistration(t *testing.T) {
+	store, notified, emailed, registrations := newRegistrations()
+	useAuditLog(t)
+	limiter := &lockout.Limiter{Store: lockout.NewMemoryStore(), Limits: config.Login()}
+	svc := NewAuth(store.Repos(), limiter, &feed{})
+	ctx := context.Background()
+	register(t, registrations)
+
+	_, err := svc.Login(ctx, "ana@example.com", "Secret123!", "10.0.0.1")
+	if e := wantStatus(t, err, http.StatusForbidden); e.Code != CodeEmailNotVerified {
+		t.Errorf("before verifying: code = %s, want %s", e.Code, CodeEmailNotVerified)
+	}
+
+	if err := registrations.Verify(ctx, emailed.token(t)); err != nil {
+		t.Fatal(err)
+	}
+	_, err = svc.Login(ctx, "ana@example.com", "Secret123!", "10.0.0.1")
+	if e := wantStatus(t, err, http.StatusForbidden); e.Code != CodeRegistrationPending {
+		t.Errorf("before approval: code = %s, want %s", e.Code, CodeRegistrationPending)
+	}
+
+	if _, err := registrations.Approve(ctx, store.Registrations[0].ID, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Login(ctx, "ana@example.com", "Secret123!", "10.0.0.1"); err != nil {
+		t.Errorf("after approval: %v", err)
+	}
+	if len(*notified) != 1 {
+		t.Errorf("notified %v, want the welcome only", *notified)
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	store, _, svc := newAuth(t)
+	user := addLogin(t, store, models.User{Email: "sam@example.com"})
+	ctx := context.Background()
+
+	err := svc.ChangePassword(ctx, user.ID, "wrong", "Better456!")
+	wantStatus(t, err, http.StatusUnauthorized)
+
+	if err := svc.ChangePassword(ctx, user.ID, "Secret123!", "Better456!"); err != nil {
+		t.Fatalf("ChangePassword: %v", err)
+	}
+	if !utils.CheckPasswordHash("Better456!", store.User(user.ID).PasswordHash) {
+		t.Error("new password not stored")
+	}
+	if _, err := svc.Login(ctx, "sam@example.com", "Better456!", "10.0.0.1"); err != nil {
+		t.Errorf("Login with the new password: %v", err)
+	}
+
+	err = svc.ChangePassword(ctx, 999, "Secret123!", "Better456!")
+	wantStatus(t, err, http.StatusNotFound)
+}
+
+func TestProfile(t *testing.T) {
+	store, _, svc := newAuth(t)
+	user := addLogin(t, store, models.User{Email: "sam@example.com"})
+
+	got, err := svc.Profile(context.Background(), user.ID)
+	if err != nil || got.Email != "sam@example.com" {
+		t.Errorf("Profile = %+v, %v; want sam", got, err)
+	}
+	_, err = svc.Profile(context.Background(), 999)
+	wantStatus(t, err, http.StatusNotFound)
+}
@@ -0,0 +1,38 @@
+package services
+
+import (
+	"context"
+
+	"gym-api/apperror"
+	"gym-api/models"
+	"gym-api/repository"
+)
+
+// Branches manages the gym locations.
+type Branches interface {
+	List(ctx context.Context) ([]models.Branch, error)
+	Create(ctx context.Context, branch models.Branch) (models.Branch, error)
+}
+
+type branches struct {
+	repos repository.Repos
+}
+
+func NewBranches(repos repository.Repos) Branches {
+	return &branches{repos: repos}
+}
+
+func (s *branches) List(ctx context.Context) ([]models.Branch, error) {
+	list, err := s.repos.Branches.List(ctx)
+	if err != nil {
+		return nil, apperror.DB(err, "Failed to fetch branches")
+	}
+	return list, nil
+}
+
+func (s *branches) Create(ctx context.Context, branch models.Branch) (models.Branch, error) {
+	if err := s.repos.Branches.Create(ctx, &branch); err != nil {
+		return branch, apperror.DB(err, "Could not create branch")
+	}
+	return branch, nil
+}
@@ -0,0 +1,43 @@
+package services
+
+import (
+	"context"
+
+	"gym-api/apperror"
+	"gym-api/churn"
+	"gym-api/models"
+	"gym-api/repository"
+)
+
+// Churn serves the churn scores the churn-scoring job computes.
+type Churn interface {
+	// AtRisk returns the members scored at any of levels, highest score
+	// first.
+	AtRisk(ctx context.Context, levels []string) ([]models.ChurnRisk, error)
+	// Recompute starts rescoring every member in the background, unless a
+	// run is already going.
+	Recompute() error
+}
+
+type churnService struct {
+	repos repository.Repos
+}
+
+func NewChurn(repos repository.Repos) Churn {
+	return &churnService{repos: repos}
+}
+
+func (s *churnService) AtRisk(ctx context.Context, levels []string) ([]models.ChurnRisk, error) {
+	risks, err := s.repos.ChurnRisks.AtLevels(ctx, levels)
+	if err != nil {
+		return nil, apperror.DB(err, "Failed to fetch at-risk members")
+	}
+	return risks, nil
+}
+
+func (s *churnService) Recompute() error {
+	if !churn.Start() {
+		return apperror.Conflict("Churn scores are already being recomputed")
+	}
+	return nil
+}
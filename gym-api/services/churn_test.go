@@ -0,0 +1,35 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"gym-api/churn"
+	"gym-api/models"
+	"gym-api/repository/fake"
+)
+
+func TestAtRisk(t *testing.T) {
+	store := fake.New()
+	svc := NewChurn(store.Repos())
+	pkg := store.AddPackage(models.Package{Name: "Monthly"})
+	ana := store.AddUser(models.User{Name: "Ana", Role: models.RoleMember, PackageID: &pkg.ID})
+	bea := store.AddUser(models.User{Name: "Bea", Role: models.RoleMember})
+	cal := store.AddUser(models.User{Name: "Cal", Role: models.RoleMember})
+	store.ChurnRisks = []models.ChurnRisk{
+		{MemberID: ana.ID, Score: 0.6, Level: churn.Medium},
+		{MemberID: bea.ID, Score: 0.9, Level: churn.High},
+		{MemberID: cal.ID, Score: 0.1, Level: churn.Low},
+	}
+
+	risks, err := svc.AtRisk(context.Background(), []string{churn.Medium, churn.High})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(risks) != 2 || risks[0].Member.Name != "Bea" || risks[1].Member.Name != "Ana" {
+		t.Fatalf("at risk: %+v, want Bea then Ana", risks)
+	}
+	if risks[1].Member.Package == nil || risks[1].Member.Package.Name != "Monthly" {
+		t.Errorf("Ana's package = %+v, want Monthly loaded", risks[1].Member.Package)
+	}
+}
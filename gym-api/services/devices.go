@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"gym-api/apperror"
+	"gym-api/models"
+	"gym-api/repository"
+	"gym-api/utils"
+)
+
+// Devices registers the kiosks and scanner apps and revokes their keys.
+type Devices interface {
+	List(ctx context.Context) ([]models.Device, error)
+	// Register creates a device and returns it with its API key. Only a
+	// hash of the key is kept.
+	Register(ctx context.Context, device NewDevice) (models.Device, string, error)
+	// Revoke disables a device's key immediately. Revoking twice keeps the
+	// first time.
+	Revoke(ctx context.Context, id uint) (models.Device, error)
+}
+
+type NewDevice struct {
+	Name      string
+	BranchID  *uint
+	CreatedBy uint
+}
+
+type devices struct {
+	repos repository.Repos
+}
+
+func NewDevices(repos repository.Repos) Devices {
+	return &devices{repos: repos}
+}
+
+func (s *devices) List(ctx context.Context) ([]models.Device, error) {
+	list, err := s.repos.Devices.List(ctx)
+	if err != nil {
+		return nil, apperror.DB(err, "Failed to fetch devices")
+	}
+	return list, nil
+}
+
+func (s *devices) Register(ctx context.Context, input NewDevice) (models.Device, string, error) {
+	key, err := utils.GenerateAPIKey()
+	if err != nil {
+		return models.Device{}, "", apperror.Internal(err, "Could not generate device key")
+	}
+
+	device := models.Device{
+		Name:      input.Name,
+		BranchID:  input.BranchID,
+		KeyPrefix: key[:12],
+		KeyHash:   utils.HashAPIKey(key),
+		CreatedBy: input.CreatedBy,
+	}
+	if err := s.repos.Devices.Create(ctx, &device); err != nil {
+		return device, "", apperror.DB(err, "Could not register device")
+	}
+	return device, key, nil
+}
+
+func (s *devices) Revoke(ctx context.Context, id uint) (models.Device, error) {
+	device, err := s.repos.Devices.ByID(ctx, id)
+	if err != nil {
+		return device, apperror.FromDB(err, "Device not found")
+	}
+	if device.RevokedAt != nil {
+		return device, nil
+	}
+
+	now := time.Now()
+	device.RevokedAt = &now
+	if err := s.repos.Devices.Save(ctx, &device); err != nil {
+		return device, apperror.DB(err, "Could not revoke device")
+	}
+	return device, nil
+}
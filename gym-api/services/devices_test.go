@@ -0,0 +1,54 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"gym-api/repository/fake"
+	"gym-api/utils"
+)
+
+func TestRegisterDevice(t *testing.T) {
+	store := fake.New()
+	svc := NewDevices(store.Repos())
+	branch := uint(2)
+
+	device, key, err := svc.Register(context.Background(), NewDevice{Name: "Lobby kiosk", BranchID: &branch, CreatedBy: 1})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if device.KeyHash != utils.HashAPIKey(key) || device.KeyPrefix != key[:12] {
+		t.Errorf("stored %q/%q for key %q, want its hash and prefix", device.KeyHash, device.KeyPrefix, key)
+	}
+	if len(store.Devices) != 1 || *store.Devices[0].BranchID != 2 || store.Devices[0].CreatedBy != 1 {
+		t.Errorf("stored %+v, want the lobby kiosk of branch 2", store.Devices)
+	}
+
+	_, other, err := svc.Register(context.Background(), NewDevice{Name: "Side door"})
+	if err != nil || other == key {
+		t.Errorf("second device: key %q, %v; want a new key", other, err)
+	}
+}
+
+func TestRevokeDevice(t *testing.T) {
+	store := fake.New()
+	svc := NewDevices(store.Repos())
+	ctx := context.Background()
+	device, _, err := svc.Register(ctx, NewDevice{Name: "Lobby kiosk"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	revoked, err := svc.Revoke(ctx, device.ID)
+	if err != nil || revoked.RevokedAt == nil {
+		t.Fatalf("Revoke = %+v, %v; want it revoked", revoked, err)
+	}
+	again, err := svc.Revoke(ctx, device.ID)
+	if err != nil || !again.RevokedAt.Equal(*revoked.RevokedAt) {
+		t.Errorf("revoking again: %v, %v; want the first time kept", again.RevokedAt, err)
+	}
+
+	_, err = svc.Revoke(ctx, 999)
+	wantStatus(t, err, http.StatusNotFound)
+}
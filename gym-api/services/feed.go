@@ -0,0 +1,92 @@
+package services
+
+import (
+	"time"
+
+	"gym-api/events"
+	"gym-api/gates"
+	"gym-api/models"
+)
+
+// Feed announces what happens at the door. live is false for synced
+// offline scans: the person is long gone, so no gate is opened for them.
+type Feed interface {
+	Admitted(scanner Scanner, member models.User, attendance models.Attendance, live bool)
+	Denied(scanner Scanner, memberID uint, reason string, live bool)
+	Expired(scanner Scanner, member models.User, live bool)
+	CheckedOut(attendance models.Attendance)
+	Rejected(attendance models.Attendance, incident models.Incident)
+	Occupancy(branchID *uint, occupancy int64, capacity int)
+}
+
+// LiveFeed publishes to the live event stream and drives the gates.
+type LiveFeed struct{}
+
+func (LiveFeed) Admitted(scanner Scanner, member models.User, attendance models.Attendance, live bool) {
+	signalGate(scanner, member.ID, true, "", live)
+	events.Publish(events.CheckIn, scanner.BranchID, map[string]any{
+		"attendance_id":   attendance.ID,
+		"member_id":       member.ID,
+		"name":            member.Name,
+		"role":            member.Role,
+		"profile_picture": member.ProfilePicture,
+		"device_id":       scanner.DeviceID,
+		"scan_time":       attendance.ScanTime,
+	})
+}
+
+func (LiveFeed) Denied(scanner Scanner, memberID uint, reason string, live bool) {
+	events.Publish(events.Denied, scanner.BranchID, map[string]any{
+		"member_id": memberID,
+		"device_id": scanner.DeviceID,
+		"reason":    reason,
+	})
+	signalGate(scanner, memberID, false, reason, live)
+}
+
+func (LiveFeed) Expired(scanner Scanner, member models.User, live bool) {
+	events.Publish(events.Expired, scanner.BranchID, map[string]any{
+		"member_id":    member.ID,
+		"name":         member.Name,
+		"sub_end_date": member.SubEndDate,
+	})
+	signalGate(scanner, member.ID, false, "Subscription expired", live)
+}
+
+func (LiveFeed) CheckedOut(attendance models.Attendance) {
+	events.Publish(events.CheckOut, attendance.BranchID, map[string]any{
+		"attendance_id":  attendance.ID,
+		"member_id":      attendance.TrainerID,
+		"check_out_time": attendance.CheckOutTime,
+	})
+}
+
+func (LiveFeed) Rejected(attendance models.Attendance, incident models.Incident) {
+	events.Publish(events.Rejected, attendance.BranchID, map[string]any{
+		"attendance_id": attendance.ID,
+		"member_id":     attendance.TrainerID,
+		"incident_id":   incident.ID,
+		"reason":        incident.Reason,
+		"suspended":     incident.Suspended,
+	})
+}
+
+func (LiveFeed) Occupancy(branchID *uint, occupancy int64, capacity int) {
+	events.Publish(events.Occupancy, branchID, map[string]any{
+		"occupancy": occupancy,
+		"capacity":  capacity,
+	})
+}
+
+// signalGate tells the scanner's gate whether to let the member through.
+func signalGate(scanner Scanner, memberID uint, granted bool, reason string, live bool) {
+	if !live {
+		return
+	}
+	gates.Default.Dispatch(scanner.DeviceID, scanner.BranchID, gates.Decision{
+		Granted:  granted,
+		MemberID: memberID,
+		Reason:   reason,
+		Time:     time.Now(),
+	})
+}
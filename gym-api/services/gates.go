@@ -0,0 +1,212 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"gym-api/apperror"
+	"gym-api/gates"
+	"gym-api/listing"
+	"gym-api/models"
+	"gym-api/repository"
+	"gym-api/utils"
+)
+
+// Gates configures turnstiles and door relays and takes their reports.
+type Gates interface {
+	List(ctx context.Context) ([]models.Gate, error)
+	// Create configures a gate and returns it with the secret that signs
+	// its relay requests and webhook. The secret is not kept readable.
+	Create(ctx context.Context, gate NewGate) (models.Gate, string, error)
+	Update(ctx context.Context, id uint, changes GateChanges) (models.Gate, error)
+	Delete(ctx context.Context, id uint) error
+	// Test sends a grant to a gate and waits for it to be accepted.
+	Test(ctx context.Context, id uint) error
+	// Simulate raises an event on a simulator gate.
+	Simulate(id uint, event gates.Event) error
+	// Authenticate checks a webhook request signed by gate id and returns
+	// the gate. Each request is accepted once.
+	Authenticate(ctx context.Context, id uint, req SignedRequest) (models.Gate, error)
+	// Report records an event a gate reported.
+	Report(gate models.Gate, event gates.Event)
+	// AuditLog pages through the audit log, where door events end up,
+	// keeping the entries whose action starts with prefix.
+	AuditLog(ctx context.Context, prefix string, spec listing.Spec, p listing.Params) (*listing.Page[models.AuditLog], error)
+}
+
+type NewGate struct {
+	Name     string
+	Kind     string
+	Address  string
+	Door     string
+	BranchID *uint
+	DeviceID *uint
+	Enabled  bool
+}
+
+// GateChanges is a partial update: nil fields are left unchanged. A
+// BranchID or DeviceID of 0 unbinds the gate.
+type GateChanges struct {
+	Name     *string
+	Kind     *string
+	Address  *string
+	Door     *string
+	BranchID *uint
+	DeviceID *uint
+	Enabled  *bool
+}
+
+// SignedRequest is a request a gate signed with its secret, as sent in
+// the gates.*Header headers.
+type SignedRequest struct {
+	Timestamp string
+	Nonce     string
+	Signature string
+	Body      []byte
+}
+
+type gateService struct {
+	repos    repository.Repos
+	registry *gates.Registry
+}
+
+func NewGates(repos repository.Repos, registry *gates.Registry) Gates {
+	return &gateService{repos: repos, registry: registry}
+}
+
+// reload brings the controllers in line with a change to the gates.
+func (s *gateService) reload() {
+	if err := s.registry.Reload(); err != nil {
+		slog.Error("Failed to reload gates", "error", err)
+	}
+}
+
+func (s *gateService) List(ctx context.Context) ([]models.Gate, error) {
+	list, err := s.repos.Gates.List(ctx)
+	if err != nil {
+		return nil, apperror.DB(err, "Failed to fetch gates")
+	}
+	return list, nil
+}
+
+func (s *gateService) Create(ctx context.Context, input NewGate) (models.Gate, string, error) {
+	secret, err := utils.GenerateAPIKey()
+	if err != nil {
+		return models.Gate{}, "", apperror.Internal(err, "Could not generate gate secret")
+	}
+
+	gate := models.Gate{
+		Name:     input.Name,
+		Kind:     input.Kind,
+		Address:  input.Address,
+		Secret:   secret,
+		Door:     input.Door,
+		BranchID: input.BranchID,
+		DeviceID: input.DeviceID,
+		Enabled:  input.Enabled,
+	}
+	if err := s.repos.Gates.Create(ctx, &gate); err != nil {
+		return gate, "", apperror.DB(err, "Could not create gate")
+	}
+	s.reload()
+	return gate, secret, nil
+}
+
+func (s *gateService) Update(ctx context.Context, id uint, changes GateChanges) (models.Gate, error) {
+	gate, err := s.repos.Gates.ByID(ctx, id)
+	if err != nil {
+		return gate, apperror.FromDB(err, "Gate not found")
+	}
+
+	if changes.Name != nil {
+		gate.Name = *changes.Name
+	}
+	if changes.Kind != nil {
+		gate.Kind = *changes.Kind
+	}
+	if changes.Address != nil {
+		gate.Address = *changes.Address
+	}
+	if changes.Door != nil {
+		gate.Door = *changes.Door
+	}
+	if changes.BranchID != nil {
+		gate.BranchID = changes.BranchID
+		if *changes.BranchID == 0 {
+			gate.BranchID = nil
+		}
+	}
+	if changes.DeviceID != nil {
+		gate.DeviceID = changes.DeviceID
+		if *changes.DeviceID == 0 {
+			gate.DeviceID = nil
+		}
+	}
+	if changes.Enabled != nil {
+		gate.Enabled = *changes.Enabled
+	}
+
+	if err := s.repos.Gates.Save(ctx, &gate); err != nil {
+		return gate, apperror.DB(err, "Could not update gate")
+	}
+	s.reload()
+	return gate, nil
+}
+
+func (s *gateService) Delete(ctx context.Context, id uint) error {
+	if err := s.repos.Gates.Delete(ctx, id); err != nil {
+		return apperror.FromDB(err, "Gate not found")
+	}
+	s.reload()
+	return nil
+}
+
+func (s *gateService) Test(ctx context.Context, id uint) error {
+	decision := gates.Decision{Granted: true, Reason: "test", Time: time.Now()}
+	if err := s.registry.Send(ctx, id, decision); err != nil {
+		return apperror.BadGateway(err, "Gate did not accept the test signal")
+	}
+	return nil
+}
+
+func (s *gateService) Simulate(id uint, event gates.Event) error {
+	if err := s.registry.Simulate(id, event); err != nil {
+		return apperror.BadRequest(err.Error())
+	}
+	return nil
+}
+
+func (s *gateService) Authenticate(ctx context.Context, id uint, req SignedRequest) (models.Gate, error) {
+	gate, err := s.repos.Gates.ByID(ctx, id)
+	if err != nil || !gate.Enabled {
+		return gate, apperror.NotFound("Gate not found")
+	}
+	err = gates.Verify(gate.Secret, req.Timestamp, req.Nonce, req.Body, req.Signature, time.Now())
+	if err != nil {
+		return gate, apperror.Unauthorized(err.Error())
+	}
+	fresh, err := s.repos.Gates.UseNonce(ctx, gate.ID, req.Nonce)
+	if err != nil {
+		return gate, apperror.DB(err, "Failed to record the nonce")
+	}
+	if !fresh {
+		return gate, apperror.Unauthorized("Nonce already used")
+	}
+	return gate, nil
+}
+
+func (s *gateService) Report(gate models.Gate, event gates.Event) {
+	if event.Door == "" {
+		event.Door = gate.Door
+	}
+	gates.Report(gate, event)
+}
+
+func (s *gateService) AuditLog(ctx context.Context, prefix string, spec listing.Spec, p listing.Params) (*listing.Page[models.AuditLog], error) {
+	page, err := s.repos.AuditLogs.List(ctx, prefix, spec, p)
+	if err != nil {
+		return nil, apperror.DB(err, "Failed to fetch audit logs")
+	}
+	return page, nil
+}
@@ -0,0 +1,40 @@
+package services
+
+import (
+	"context"
+	"sync/atomic"
+
+	"gym-api/apperror"
+	"gym-api/repository"
+)
+
+// Health answers the readiness probe.
+type Health interface {
+	// Ready checks that the database answers and its schema has been
+	// migrated.
+	Ready(ctx context.Context) error
+}
+
+type health struct {
+	repos repository.Repos
+	// migrated is set once every table has been seen; tables don't
+	// disappear.
+	migrated atomic.Bool
+}
+
+func NewHealth(repos repository.Repos) Health {
+	return &health{repos: repos}
+}
+
+func (s *health) Ready(ctx context.Context) error {
+	if err := s.repos.Database.Ping(ctx); err != nil {
+		return apperror.Unavailable(err, "Database unreachable")
+	}
+	if !s.migrated.Load() {
+		if !s.repos.Database.Migrated(ctx) {
+			return apperror.Unavailable(nil, "Database migrations have not run")
+		}
+		s.migrated.Store(true)
+	}
+	return nil
+}
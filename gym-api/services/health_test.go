@@ -0,0 +1,42 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"gym-api/repository"
+)
+
+// database is a database as the readiness probe sees it.
+type database struct {
+	down     error
+	migrated bool
+}
+
+func (d *database) Ping(context.Context) error    { return d.down }
+func (d *database) Migrated(context.Context) bool { return d.migrated }
+
+func TestReady(t *testing.T) {
+	db := &database{down: errors.New("connection refused")}
+	svc := NewHealth(repository.Repos{Database: db})
+	ctx := context.Background()
+
+	wantStatus(t, svc.Ready(ctx), http.StatusServiceUnavailable)
+
+	db.down = nil
+	if e := wantStatus(t, svc.Ready(ctx), http.StatusServiceUnavailable); e.Message != "Database migrations have not run" {
+		t.Errorf("before migrating: %s", e.Message)
+	}
+
+	db.migrated = true
+	if err := svc.Ready(ctx); err != nil {
+		t.Fatalf("migrated: %v", err)
+	}
+	// Tables don't disappear: the check isn't repeated
+	db.migrated = false
+	if err := svc.Ready(ctx); err != nil {
+		t.Errorf("after a successful check: %v", err)
+	}
+}
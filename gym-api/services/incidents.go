@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gym-api/apperror"
+	"gym-api/models"
+	"gym-api/repository"
+
+	"gorm.io/gorm"
+)
+
+// Incidents is the admin review of the check-ins staff rejected; the
+// incidents are opened by Attendance.Reject.
+type Incidents interface {
+	List(ctx context.Context, filter repository.IncidentFilter) ([]models.Incident, error)
+	// Resolve closes an open incident, optionally lifting the suspension
+	// of its member.
+	Resolve(ctx context.Context, id uint, r Resolution) (models.Incident, error)
+}
+
+type Resolution struct {
+	ResolvedBy uint
+	Resolution string
+	Reinstate  bool
+}
+
+type incidents struct {
+	repos repository.Repos
+}
+
+func NewIncidents(repos repository.Repos) Incidents {
+	return &incidents{repos: repos}
+}
+
+func (s *incidents) List(ctx context.Context, filter repository.IncidentFilter) ([]models.Incident, error) {
+	list, err := s.repos.Incidents.List(ctx, filter)
+	if err != nil {
+		return nil, apperror.DB(err, "Failed to fetch incidents")
+	}
+	return list, nil
+}
+
+func (s *incidents) Resolve(ctx context.Context, id uint, r Resolution) (models.Incident, error) {
+	incident, err := s.repos.Incidents.ByID(ctx, id)
+	if err != nil {
+		return incident, apperror.FromDB(err, "Incident not found")
+	}
+	if incident.Status == "resolved" {
+		return incident, apperror.Conflict("Incident already resolved")
+	}
+
+	now := time.Now()
+	incident.Status = "resolved"
+	incident.Resolution = r.Resolution
+	incident.ResolvedBy = &r.ResolvedBy
+	incident.ResolvedAt = &now
+
+	err = s.repos.Transaction(ctx, func(tx repository.Repos) error {
+		if err := tx.Incidents.Save(ctx, &incident); err != nil {
+			return err
+		}
+		if !r.Reinstate {
+			return nil
+		}
+		// Only a suspension is lifted; other statuses stand, and a deleted
+		// member has nothing to lift
+		member, err := tx.Users.ByID(ctx, incident.MemberID)
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return nil
+		case err != nil:
+			return err
+		case member.MembershipStatus != "suspended":
+			return nil
+		}
+		return tx.Users.SetMembershipStatus(ctx, member.ID, "active")
+	})
+	if err != nil {
+		return incident, apperror.DB(err, "Could not resolve incident")
+	}
+	return incident, nil
+}
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"gym-api/models"
+	"gym-api/repository"
+	"gym-api/repository/fake"
+)
+
+func TestListIncidents(t *testing.T) {
+	store := fake.New()
+	svc := NewIncidents(store.Repos())
+	ana := store.AddUser(models.User{Name: "Ana", Role: models.RoleMember})
+	bea := store.AddUser(models.User{Name: "Bea", Role: models.RoleMember})
+	repos, ctx := store.Repos(), context.Background()
+	for _, incident := range []models.Incident{
+		{MemberID: ana.ID, Status: "resolved"},
+		{MemberID: ana.ID},
+		{MemberID: bea.ID},
+	} {
+		if err := repos.Incidents.Create(ctx, &incident); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	open, err := svc.List(ctx, repository.IncidentFilter{Status: "open"})
+	if err != nil || len(open) != 2 || open[0].MemberID != bea.ID || open[0].Member.Name != "Bea" {
+		t.Errorf("open: %+v, %v; want Bea's then Ana's", open, err)
+	}
+	anas, err := svc.List(ctx, repository.IncidentFilter{MemberID: ana.ID})
+	if err != nil || len(anas) != 2 {
+		t.Errorf("Ana's: %d, %v; want 2", len(anas), err)
+	}
+}
+
+func TestResolveIncident(t *testing.T) {
+	store := fake.New()
+	svc := NewIncidents(store.Repos())
+	ctx := context.Background()
+	open := func(member models.User) models.Incident {
+		incident := models.Incident{MemberID: member.ID, Suspended: member.MembershipStatus == "suspended"}
+		if err := store.Repos().Incidents.Create(ctx, &incident); err != nil {
+			t.Fatal(err)
+		}
+		return incident
+	}
+	suspended := store.AddUser(models.User{Role: models.RoleMember, MembershipStatus: "suspended"})
+	expired := store.AddUser(models.User{Role: models.RoleMember, MembershipStatus: "expired"})
+
+	kept := open(suspended)
+	resolved, err := svc.Resolve(ctx, kept.ID, Resolution{ResolvedBy: 1, Resolution: "Not them"})
+	if err != nil || resolved.Status != "resolved" || *resolved.ResolvedBy != 1 || resolved.ResolvedAt == nil {
+		t.Fatalf("Resolve = %+v, %v; want it resolved by 1", resolved, err)
+	}
+	if status := store.User(suspended.ID).MembershipStatus; status != "suspended" {
+		t.Errorf("without reinstate: %s, want still suspended", status)
+	}
+	_, err = svc.Resolve(ctx, kept.ID, Resolution{ResolvedBy: 1})
+	wantStatus(t, err, http.StatusConflict)
+
+	if _, err := svc.Resolve(ctx, open(suspended).ID, Resolution{ResolvedBy: 1, Reinstate: true}); err != nil {
+		t.Fatal(err)
+	}
+	if status := store.User(suspended.ID).MembershipStatus; status != "active" {
+		t.Errorf("reinstated: %s, want active", status)
+	}
+
+	// Reinstating only lifts suspensions
+	if _, err := svc.Resolve(ctx, open(expired).ID, Resolution{ResolvedBy: 1, Reinstate: true}); err != nil {
+		t.Fatal(err)
+	}
+	if status := store.User(expired.ID).MembershipStatus; status != "expired" {
+		t.Errorf("expired member reinstated: %s", status)
+	}
+
+	_, err = svc.Resolve(ctx, 999, Resolution{ResolvedBy: 1})
+	wantStatus(t, err, http.StatusNotFound)
+}
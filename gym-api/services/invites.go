@@ -0,0 +1,203 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"gym-api/apperror"
+	"gym-api/config"
+	"gym-api/models"
+	"gym-api/notifications"
+	"gym-api/repository"
+	"gym-api/utils"
+
+	"gorm.io/gorm"
+)
+
+// CodeInviteExpired is returned for an invite link past its expiry.
+const CodeInviteExpired apperror.Code = "invite_expired"
+
+// Invites brings staff and trainers on board: an admin invites them and
+// they choose their own password from the emailed link.
+type Invites interface {
+	// Invite creates an inactive account and emails its owner a link.
+	// sent reports whether the email went out; if not, the admin can
+	// resend once mail works.
+	Invite(ctx context.Context, invitee NewInvite, by uint) (invite models.Invite, sent bool, err error)
+	// Pending lists invites not yet accepted, expired ones included.
+	Pending(ctx context.Context) ([]models.Invite, error)
+	// Resend emails a pending invite again with a new link and expiry.
+	// Earlier links stop working.
+	Resend(ctx context.Context, id uint) (invite models.Invite, sent bool, err error)
+	// Revoke cancels a pending invite and removes the account it was for,
+	// freeing the email address.
+	Revoke(ctx context.Context, id uint) (models.Invite, error)
+	// Lookup finds the pending invite for the token from a link.
+	Lookup(ctx context.Context, token string) (models.Invite, error)
+	// Accept sets the invitee's password and activates the account, once.
+	Accept(ctx context.Context, token, password string) (models.User, error)
+}
+
+type NewInvite struct {
+	Name     string
+	Email    string
+	Role     models.Role
+	BranchID *uint
+}
+
+type invites struct {
+	repos repository.Repos
+	email Mailer
+}
+
+func NewInvites(repos repository.Repos, email Mailer) Invites {
+	return &invites{repos: repos, email: email}
+}
+
+// issueToken gives invite a new token and expiry, replacing any earlier
+// ones, and returns the token for the link.
+func (s *invites) issueToken(invite *models.Invite) (string, error) {
+	token, err := utils.GenerateInviteToken()
+	if err != nil {
+		return "", apperror.Internal(err, "Could not generate invite token")
+	}
+	invite.TokenHash = utils.HashAPIKey(token)
+	invite.ExpiresAt = time.Now().Add(config.InviteTTL())
+	return token, nil
+}
+
+// send emails the invite link. A failed email is logged and reported as
+// not sent.
+func (s *invites) send(ctx context.Context, invite *models.Invite, token string) bool {
+	inviter := "An administrator"
+	if admin, err := s.repos.Users.ByID(ctx, invite.InvitedBy); err == nil {
+		inviter = admin.Name
+	}
+
+	emailCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	err := s.email(emailCtx, invite.User, notifications.StaffInvite, "", map[string]interface{}{
+		"InvitedBy": inviter,
+		"Role":      string(invite.User.Role),
+		"Link":      config.InviteURL() + "?token=" + url.QueryEscape(token),
+		"ExpiresAt": invite.ExpiresAt.Format("2 Jan 2006 15:04"),
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Invite email failed", "user_id", invite.UserID, "error", err)
+		return false
+	}
+
+	now := time.Now()
+	invite.SentAt = &now
+	if err := s.repos.Invites.MarkSent(ctx, invite, now); err != nil {
+		slog.ErrorContext(ctx, "Failed to record invite delivery", "error", err)
+	}
+	return true
+}
+
+func (s *invites) Invite(ctx context.Context, invitee NewInvite, by uint) (models.Invite, bool, error) {
+	// 1. The pending account and its invite
+	invite := models.Invite{
+		User: models.User{
+			Name:     invitee.Name,
+			Email:    invitee.Email,
+			Role:     invitee.Role,
+			BranchID: invitee.BranchID,
+		},
+		InvitedBy: by,
+	}
+	token, err := s.issueToken(&invite)
+	if err != nil {
+		return invite, false, err
+	}
+	if err := s.repos.Invites.Create(ctx, &invite); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return invite, false, apperror.Conflict("A user with this email already exists")
+		}
+		return invite, false, apperror.DB(err, "Could not create invite")
+	}
+	invite.User.IsActive = false
+
+	// 2. The link
+	return invite, s.send(ctx, &invite, token), nil
+}
+
+func (s *invites) Pending(ctx context.Context) ([]models.Invite, error) {
+	list, err := s.repos.Invites.Pending(ctx)
+	if err != nil {
+		return nil, apperror.DB(err, "Failed to fetch invites")
+	}
+	return list, nil
+}
+
+func (s *invites) pending(ctx context.Context, id uint) (models.Invite, error) {
+	invite, err := s.repos.Invites.PendingByID(ctx, id)
+	if err != nil {
+		return invite, apperror.FromDB(err, "Invite not found or already accepted")
+	}
+	return invite, nil
+}
+
+func (s *invites) Resend(ctx context.Context, id uint) (models.Invite, bool, error) {
+	invite, err := s.pending(ctx, id)
+	if err != nil {
+		return invite, false, err
+	}
+
+	token, err := s.issueToken(&invite)
+	if err != nil {
+		return invite, false, err
+	}
+	if err := s.repos.Invites.SetToken(ctx, &invite); err != nil {
+		return invite, false, apperror.DB(err, "Could not resend invite")
+	}
+	return invite, s.send(ctx, &invite, token), nil
+}
+
+func (s *invites) Revoke(ctx context.Context, id uint) (models.Invite, error) {
+	invite, err := s.pending(ctx, id)
+	if err != nil {
+		return invite, err
+	}
+	if err := s.repos.Invites.Delete(ctx, invite); err != nil {
+		return invite, apperror.DB(err, "Could not revoke invite")
+	}
+	return invite, nil
+}
+
+func (s *invites) Lookup(ctx context.Context, token string) (models.Invite, error) {
+	invite, err := s.repos.Invites.PendingByTokenHash(ctx, utils.HashAPIKey(token))
+	if err != nil {
+		return invite, apperror.FromDB(err, "Invite not found or already used")
+	}
+	if time.Now().After(invite.ExpiresAt) {
+		return invite, apperror.New(http.StatusGone, CodeInviteExpired, "This invite has expired, ask for a new one")
+	}
+	return invite, nil
+}
+
+func (s *invites) Accept(ctx context.Context, token, password string) (models.User, error) {
+	// 1. Find the invite
+	invite, err := s.Lookup(ctx, token)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	// 2. Hash the chosen password
+	hash, err := utils.HashPassword(password)
+	if err != nil {
+		return models.User{}, apperror.Internal(err, "Could not hash password")
+	}
+
+	// 3. Accept once, even if the link is opened twice
+	if err := s.repos.Invites.Accept(ctx, invite, hash, time.Now()); err != nil {
+		return models.User{}, apperror.FromDB(err, "Invite not found or already used")
+	}
+	user := invite.User
+	user.PasswordHash, user.IsActive = hash, true
+	return user, nil
+}
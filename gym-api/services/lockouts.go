@@ -0,0 +1,40 @@
+package services
+
+import (
+	"context"
+
+	"gym-api/apperror"
+	"gym-api/lockout"
+	"gym-api/models"
+)
+
+// Lockouts lets admins see and lift login lockouts.
+type Lockouts interface {
+	// Locked lists the accounts and addresses currently locked out.
+	Locked(ctx context.Context) ([]models.LoginThrottle, error)
+	// Clear lifts the lockout of key and resets its failure count.
+	Clear(ctx context.Context, key lockout.Key) error
+}
+
+type lockouts struct {
+	limiter *lockout.Limiter
+}
+
+func NewLockouts(limiter *lockout.Limiter) Lockouts {
+	return &lockouts{limiter: limiter}
+}
+
+func (s *lockouts) Locked(ctx context.Context) ([]models.LoginThrottle, error) {
+	locked, err := s.limiter.Locked(ctx)
+	if err != nil {
+		return nil, apperror.DB(err, "Failed to fetch lockouts")
+	}
+	return locked, nil
+}
+
+func (s *lockouts) Clear(ctx context.Context, key lockout.Key) error {
+	if err := s.limiter.Clear(ctx, key); err != nil {
+		return apperror.DB(err, "Could not clear lockout")
+	}
+	return nil
+}
@@ -0,0 +1,339 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gym-api/apperror"
+	"gym-api/listing"
+	"gym-api/metrics"
+	"gym-api/models"
+	"gym-api/notifications"
+	"gym-api/repository"
+	"gym-api/storage"
+	"gym-api/utils"
+
+	"gorm.io/gorm"
+)
+
+// CodeRegistrationPending refuses a member whose self-registration staff
+// have not approved yet.
+const CodeRegistrationPending apperror.Code = "registration_pending"
+
+// Membership covers members and the packages they buy.
+type Membership interface {
+	List(ctx context.Context, spec listing.Spec, p listing.Params) (*listing.Page[models.User], error)
+	Get(ctx context.Context, id uint) (models.User, error)
+	// Create signs a member up at the front desk, active straight away.
+	Create(ctx context.Context, member NewMember, soldBy uint) (models.User, error)
+	Update(ctx context.Context, id uint, changes MemberChanges, by uint) (models.User, error)
+	// SetPhoto replaces a member's profile picture. It returns the key of
+	// the photo replaced, for the caller to delete from storage.
+	SetPhoto(ctx context.Context, id uint, photo storage.StoredPhoto) (models.User, string, error)
+	// ToggleStatus switches a member between active and inactive.
+	ToggleStatus(ctx context.Context, id uint) (models.User, error)
+	AssignTrainer(ctx context.Context, memberID, trainerID uint) error
+	// Subscribe sells a package to a member, starting now.
+	Subscribe(ctx context.Context, memberID, packageID, soldBy uint) (models.User, models.Package, error)
+	// Delete removes a member and returns what was removed.
+	Delete(ctx context.Context, id uint) (models.User, error)
+}
+
+// NewMember is a member signed up by staff.
+type NewMember struct {
+	Name       string
+	Email      string
+	Phone      string
+	Password   string
+	Photo      *storage.StoredPhoto
+	PackageID  *uint
+	SubEndDate *time.Time // Replaces the package's end date
+}
+
+// MemberChanges is a partial update: nil fields are left unchanged.
+type MemberChanges struct {
+	Name             *string
+	Email            *string
+	Phone            *string
+	PackageID        *uint // A different package starts a new subscription
+	MembershipStatus *string
+}
+
+type membership struct {
+	repos  repository.Repos
+	notify Notifier
+}
+
+func NewMembership(repos repository.Repos, notify Notifier) Membership {
+	return &membership{repos: repos, notify: notify}
+}
+
+// Term is the period a package bought at start covers. Every sale goes
+// through it so sign-ups, edits and renewals agree on dates.
+func Term(pkg models.Package, start time.Time) (time.Time, time.Time) {
+	return start, start.AddDate(0, 0, pkg.DurationDays)
+}
+
+// awaitingApproval refuses to activate a member or sell them a package
+// before their registration is approved.
+func awaitingApproval(member models.User) error {
+	if member.MembershipStatus == "pending" {
+		return apperror.Conflict("Approve this member's registration first").WithCode(CodeRegistrationPending)
+	}
+	return nil
+}
+
+// applyPackage puts member on pkg from start.
+func applyPackage(member *models.User, pkg models.Package, start time.Time) {
+	start, end := Term(pkg, start)
+	member.PackageID = &pkg.ID
+	member.SubStartDate = &start
+	member.SubEndDate = &end
+	member.MembershipStatus = "active"
+}
+
+// recordSale adds a sale to the subscription history. A failure is logged:
+// the member has their package either way.
+func (s *membership) recordSale(ctx context.Context, member models.User, pkg models.Package, soldBy *uint) {
+	metrics.SubscriptionsSold.WithLabelValues(pkg.Name).Inc()
+	sub := models.Subscription{
+		MemberID:  member.ID,
+		PackageID: pkg.ID,
+		Price:     pkg.Price,
+		StartDate: *member.SubStartDate,
+		EndDate:   *member.SubEndDate,
+		SoldBy:    soldBy,
+	}
+	if err := s.repos.Subscriptions.Create(ctx, &sub); err != nil {
+		slog.ErrorContext(ctx, "Failed to record subscription", "member_id", member.ID, "error", err)
+	}
+}
+
+// receipt fills the renewal receipt template.
+func receipt(member models.User, pkg models.Package) map[string]interface{} {
+	return map[string]interface{}{
+		"Package":   pkg.Name,
+		"Price":     fmt.Sprintf("%.2f", pkg.Price),
+		"StartDate": member.SubStartDate.Format("2006-01-02"),
+		"EndDate":   member.SubEndDate.Format("2006-01-02"),
+	}
+}
+
+func (s *membership) List(ctx context.Context, spec listing.Spec, p listing.Params) (*listing.Page[models.User], error) {
+	page, err := s.repos.Users.List(ctx, models.RoleMember, spec, p)
+	if err != nil {
+		return nil, apperror.DB(err, "Failed to fetch members")
+	}
+	return page, nil
+}
+
+// member loads the member with id. Staff and trainers share the table but
+// are not members, so their IDs are not found here.
+func (s *membership) member(ctx context.Context, id uint) (models.User, error) {
+	member, err := s.repos.Users.ByID(ctx, id)
+	if err != nil {
+		return member, apperror.FromDB(err, "Member not found")
+	}
+	if member.Role != models.RoleMember {
+		return models.User{}, apperror.NotFound("Member not found")
+	}
+	return member, nil
+}
+
+func (s *membership) Get(ctx context.Context, id uint) (models.User, error) {
+	return s.member(ctx, id)
+}
+
+func (s *membership) Create(ctx context.Context, input NewMember, soldBy uint) (models.User, error) {
+	hash, err := utils.HashPassword(input.Password)
+	if err != nil {
+		return models.User{}, apperror.Internal(err, "Could not hash password")
+	}
+	member := models.User{
+		Name:         input.Name,
+		Email:        input.Email,
+		Phone:        input.Phone,
+		PasswordHash: hash,
+		Role:         models.RoleMember,
+	}
+	if input.Photo != nil {
+		member.ProfilePicture = input.Photo.URL
+		member.ProfileThumbnail = input.Photo.ThumbnailURL
+		member.PhotoKey = input.Photo.Key
+	}
+
+	// 1. The package, if one was sold
+	var pkg models.Package
+	now := time.Now()
+	if input.PackageID != nil {
+		if pkg, err = s.repos.Packages.ByID(ctx, *input.PackageID); err != nil {
+			return member, apperror.FromDB(err, "Package not found")
+		}
+		applyPackage(&member, pkg, now)
+	}
+	// Staff may set the end date by hand, e.g. for time already paid elsewhere
+	if input.SubEndDate != nil {
+		member.SubStartDate = &now
+		member.SubEndDate = input.SubEndDate
+		member.MembershipStatus = "active"
+	}
+
+	// 2. Save
+	if err := s.repos.Users.Create(ctx, &member); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return member, apperror.Conflict("A user with this email already exists")
+		}
+		return member, apperror.DB(err, "Could not create user")
+	}
+
+	if pkg.ID != 0 {
+		s.recordSale(ctx, member, pkg, &soldBy)
+	}
+	s.notify(member, notifications.Welcome, nil)
+	return member, nil
+}
+
+func (s *membership) SetPhoto(ctx context.Context, id uint, photo storage.StoredPhoto) (models.User, string, error) {
+	// Staff may only change members' photos, not other staff's or admins'
+	member, err := s.member(ctx, id)
+	if err != nil {
+		return member, "", err
+	}
+
+	previous := member.PhotoKey
+	member.ProfilePicture = photo.URL
+	member.ProfileThumbnail = photo.ThumbnailURL
+	member.PhotoKey = photo.Key
+	if err := s.repos.Users.Save(ctx, &member); err != nil {
+		return member, "", apperror.DB(err, "Failed to save profile picture")
+	}
+	return member, previous, nil
+}
+
+func (s *membership) Update(ctx context.Context, id uint, changes MemberChanges, by uint) (models.User, error) {
+	member, err := s.member(ctx, id)
+	if err != nil {
+		return member, err
+	}
+	if changes.MembershipStatus != nil || changes.PackageID != nil {
+		if err := awaitingApproval(member); err != nil {
+			return member, err
+		}
+	}
+
+	if changes.Name != nil {
+		member.Name = *changes.Name
+	}
+	if changes.Email != nil {
+		member.Email = *changes.Email
+	}
+	if changes.Phone != nil {
+		member.Phone = *changes.Phone
+	}
+	if changes.MembershipStatus != nil {
+		member.MembershipStatus = *changes.MembershipStatus
+	}
+
+	// Switching package starts a new subscription; the same one is a no-op
+	var renewed *models.Package
+	if changes.PackageID != nil && (member.PackageID == nil || *member.PackageID != *changes.PackageID) {
+		pkg, err := s.repos.Packages.ByID(ctx, *changes.PackageID)
+		if err != nil {
+			return member, apperror.FromDB(err, "Package not found")
+		}
+		applyPackage(&member, pkg, time.Now())
+		member.Package = &pkg
+		renewed = &pkg
+	}
+
+	if err := s.repos.Users.Save(ctx, &member); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return member, apperror.Conflict("A user with this email already exists")
+		}
+		return member, apperror.DB(err, "Could not update member")
+	}
+
+	if renewed != nil {
+		s.recordSale(ctx, member, *renewed, &by)
+		s.notify(member, notifications.RenewalReceipt, receipt(member, *renewed))
+	}
+	return member, nil
+}
+
+func (s *membership) ToggleStatus(ctx context.Context, id uint) (models.User, error) {
+	member, err := s.member(ctx, id)
+	if err != nil {
+		return member, err
+	}
+	if err := awaitingApproval(member); err != nil {
+		return member, err
+	}
+
+	if member.MembershipStatus == "active" {
+		member.MembershipStatus = "inactive"
+	} else {
+		member.MembershipStatus = "active"
+	}
+	if err := s.repos.Users.Save(ctx, &member); err != nil {
+		return member, apperror.DB(err, "Could not update member status")
+	}
+	return member, nil
+}
+
+func (s *membership) AssignTrainer(ctx context.Context, memberID, trainerID uint) error {
+	member, err := s.member(ctx, memberID)
+	if err != nil {
+		return err
+	}
+	trainer, err := s.repos.Users.ByID(ctx, trainerID)
+	if err != nil {
+		return apperror.FromDB(err, "Trainer not found")
+	}
+	if trainer.Role != models.RoleTrainer {
+		return apperror.BadRequest("Assigned user must be a trainer")
+	}
+
+	member.AssignedTrainerID = &trainer.ID
+	if err := s.repos.Users.Save(ctx, &member); err != nil {
+		return apperror.DB(err, "Could not assign trainer")
+	}
+	return nil
+}
+
+func (s *membership) Subscribe(ctx context.Context, memberID, packageID, soldBy uint) (models.User, models.Package, error) {
+	pkg, err := s.repos.Packages.ByID(ctx, packageID)
+	if err != nil {
+		return models.User{}, pkg, apperror.FromDB(err, "Package not found")
+	}
+	member, err := s.member(ctx, memberID)
+	if err != nil {
+		return member, pkg, err
+	}
+	if err := awaitingApproval(member); err != nil {
+		return member, pkg, err
+	}
+
+	applyPackage(&member, pkg, time.Now())
+	member.Package = &pkg
+	if err := s.repos.Users.Save(ctx, &member); err != nil {
+		return member, pkg, apperror.DB(err, "Could not subscribe member")
+	}
+
+	s.recordSale(ctx, member, pkg, &soldBy)
+	s.notify(member, notifications.RenewalReceipt, receipt(member, pkg))
+	return member, pkg, nil
+}
+
+func (s *membership) Delete(ctx context.Context, id uint) (models.User, error) {
+	member, err := s.member(ctx, id)
+	if err != nil {
+		return member, err
+	}
+	if err := s.repos.Users.Delete(ctx, &member); err != nil {
+		return member, apperror.DB(err, "Failed to delete member")
+	}
+	return member, nil
+}
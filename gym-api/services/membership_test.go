@@ -0,0 +1,264 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"gym-api/apperror"
+	"gym-api/models"
+	"gym-api/notifications"
+	"gym-api/repository/fake"
+	"gym-api/storage"
+)
+
+// sent records the notifications a service dispatched.
+type sent []notifications.Kind
+
+func (s *sent) notify(_ models.User, kind notifications.Kind, _ map[string]interface{}) {
+	*s = append(*s, kind)
+}
+
+func newMembership() (*fake.Store, *sent, Membership) {
+	store, notified := fake.New(), &sent{}
+	return store, notified, NewMembership(store.Repos(), notified.notify)
+}
+
+// wantStatus fails the test unless err is an *apperror.Error with status.
+func wantStatus(t *testing.T, err error, status int) *apperror.Error {
+	t.Helper()
+	e, ok := apperror.As(err)
+	if !ok {
+		t.Fatalf("err = %v, want an apperror with status %d", err, status)
+	}
+	if e.Status != status {
+		t.Fatalf("status = %d (%s), want %d", e.Status, e.Message, status)
+	}
+	return e
+}
+
+func TestCreateMemberSellsPackage(t *testing.T) {
+	store, notified, svc := newMembership()
+	pkg := store.AddPackage(models.Package{Name: "Monthly", DurationDays: 30, Price: 40})
+	staff := store.AddUser(models.User{Role: models.RoleStaff})
+
+	member, err := svc.Create(context.Background(), NewMember{
+		Name:      "Ana",
+		Email:     "ana@example.com",
+		Password:  "Secret123!",
+		PackageID: &pkg.ID,
+	}, staff.ID)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	if member.Role != models.RoleMember || member.MembershipStatus != "active" {
+		t.Errorf("role, status = %s, %s; want member, active", member.Role, member.MembershipStatus)
+	}
+	if member.SubStartDate == nil || member.SubEndDate == nil || member.SubEndDate.Sub(*member.SubStartDate) != 30*24*time.Hour {
+		t.Errorf("term = %v to %v, want 30 days", member.SubStartDate, member.SubEndDate)
+	}
+	if len(store.Subscriptions) != 1 || store.Subscriptions[0].Price != 40 || *store.Subscriptions[0].SoldBy != staff.ID {
+		t.Errorf("subscriptions = %+v, want one sale at 40 by %d", store.Subscriptions, staff.ID)
+	}
+	if len(*notified) != 1 || (*notified)[0] != notifications.Welcome {
+		t.Errorf("notified %v, want a welcome", *notified)
+	}
+}
+
+func TestCreateMemberEndDateByHand(t *testing.T) {
+	store, _, svc := newMembership()
+	end := time.Now().AddDate(0, 2, 0)
+
+	member, err := svc.Create(context.Background(), NewMember{Name: "Ana", Email: "ana@example.com", Password: "Secret123!", SubEndDate: &end}, 1)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	if !member.SubEndDate.Equal(end) || member.MembershipStatus != "active" {
+		t.Errorf("end, status = %v, %s; want %v, active", member.SubEndDate, member.MembershipStatus, end)
+	}
+	if len(store.Subscriptions) != 0 {
+		t.Error("recorded a sale without a package")
+	}
+}
+
+func TestCreateMemberDuplicateEmail(t *testing.T) {
+	store, _, svc := newMembership()
+	store.AddUser(models.User{Email: "ana@example.com"})
+
+	_, err := svc.Create(context.Background(), NewMember{Name: "Ana", Email: "ana@example.com", Password: "Secret123!"}, 1)
+	wantStatus(t, err, http.StatusConflict)
+}
+
+func TestCreateMemberUnknownPackage(t *testing.T) {
+	_, _, svc := newMembership()
+	id := uint(99)
+
+	_, err := svc.Create(context.Background(), NewMember{Name: "Ana", Email: "ana@example.com", Password: "Secret123!", PackageID: &id}, 1)
+	wantStatus(t, err, http.StatusNotFound)
+}
+
+func TestMemberEndpointsIgnoreOtherRoles(t *testing.T) {
+	store, _, svc := newMembership()
+	pkg := store.AddPackage(models.Package{DurationDays: 30})
+	staff := store.AddUser(models.User{Role: models.RoleStaff, MembershipStatus: "active", Email: "staff@example.com"})
+	ctx := context.Background()
+	name := "Renamed"
+
+	_, err := svc.Get(ctx, staff.ID)
+	wantStatus(t, err, http.StatusNotFound)
+	_, err = svc.Update(ctx, staff.ID, MemberChanges{Name: &name}, 1)
+	wantStatus(t, err, http.StatusNotFound)
+	_, err = svc.ToggleStatus(ctx, staff.ID)
+	wantStatus(t, err, http.StatusNotFound)
+	_, _, err = svc.Subscribe(ctx, staff.ID, pkg.ID, 1)
+	wantStatus(t, err, http.StatusNotFound)
+	wantStatus(t, svc.AssignTrainer(ctx, staff.ID, staff.ID), http.StatusNotFound)
+	_, err = svc.Delete(ctx, staff.ID)
+	wantStatus(t, err, http.StatusNotFound)
+
+	if got := store.User(staff.ID); got.Name == name || got.ID == 0 || got.PackageID != nil {
+		t.Errorf("staff account changed: %+v", got)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	store, notified, svc := newMembership()
+	pkg := store.AddPackage(models.Package{Name: "Quarterly", DurationDays: 90, Price: 100})
+	member := store.AddUser(models.User{Role: models.RoleMember, MembershipStatus: "inactive"})
+
+	got, gotPkg, err := svc.Subscribe(context.Background(), member.ID, pkg.ID, 7)
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	if gotPkg.ID != pkg.ID || got.PackageID == nil || *got.PackageID != pkg.ID || got.MembershipStatus != "active" {
+		t.Errorf("member = %+v, want active on package %d", got, pkg.ID)
+	}
+	if stored := store.User(member.ID); stored.SubEndDate == nil || !stored.SubEndDate.Equal(*got.SubEndDate) {
+		t.Errorf("stored end date = %v, want %v", stored.SubEndDate, got.SubEndDate)
+	}
+	if len(store.Subscriptions) != 1 {
+		t.Errorf("recorded %d sales, want 1", len(store.Subscriptions))
+	}
+	if len(*notified) != 1 || (*notified)[0] != notifications.RenewalReceipt {
+		t.Errorf("notified %v, want a renewal receipt", *notified)
+	}
+}
+
+func TestPendingMemberCannotBeActivated(t *testing.T) {
+	store, notified, svc := newMembership()
+	pkg := store.AddPackage(models.Package{DurationDays: 30})
+	member := store.AddUser(models.User{Role: models.RoleMember, MembershipStatus: "pending"})
+	ctx := context.Background()
+	active := "active"
+
+	_, _, err := svc.Subscribe(ctx, member.ID, pkg.ID, 1)
+	if e := wantStatus(t, err, http.StatusConflict); e.Code != CodeRegistrationPending {
+		t.Errorf("code = %s, want %s", e.Code, CodeRegistrationPending)
+	}
+	_, err = svc.ToggleStatus(ctx, member.ID)
+	wantStatus(t, err, http.StatusConflict)
+	_, err = svc.Update(ctx, member.ID, MemberChanges{MembershipStatus: &active}, 1)
+	wantStatus(t, err, http.StatusConflict)
+
+	if store.User(member.ID).MembershipStatus != "pending" || len(store.Subscriptions) != 0 || len(*notified) != 0 {
+		t.Error("pending member was changed")
+	}
+}
+
+func TestUpdateMemberPackage(t *testing.T) {
+	store, notified, svc := newMembership()
+	monthly := store.AddPackage(models.Package{DurationDays: 30})
+	yearly := store.AddPackage(models.Package{DurationDays: 365})
+	member := store.AddUser(models.User{Role: models.RoleMember, MembershipStatus: "active", PackageID: &monthly.ID})
+	ctx := context.Background()
+
+	// The same package is not a new sale
+	if _, err := svc.Update(ctx, member.ID, MemberChanges{PackageID: &monthly.ID}, 1); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.Subscriptions) != 0 {
+		t.Fatalf("recorded a sale for an unchanged package")
+	}
+
+	got, err := svc.Update(ctx, member.ID, MemberChanges{PackageID: &yearly.ID}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Package == nil || got.Package.ID != yearly.ID || len(store.Subscriptions) != 1 || len(*notified) != 1 {
+		t.Errorf("member = %+v, %d sales, notified %v; want the yearly package, one sale and a receipt", got, len(store.Subscriptions), *notified)
+	}
+}
+
+func TestUpdateMemberDuplicateEmail(t *testing.T) {
+	store, _, svc := newMembership()
+	store.AddUser(models.User{Role: models.RoleMember, Email: "taken@example.com"})
+	member := store.AddUser(models.User{Role: models.RoleMember, Email: "ana@example.com"})
+	email := "taken@example.com"
+
+	_, err := svc.Update(context.Background(), member.ID, MemberChanges{Email: &email}, 1)
+	wantStatus(t, err, http.StatusConflict)
+}
+
+func TestToggleStatus(t *testing.T) {
+	store, _, svc := newMembership()
+	member := store.AddUser(models.User{Role: models.RoleMember, MembershipStatus: "active"})
+
+	for _, want := range []string{"inactive", "active"} {
+		got, err := svc.ToggleStatus(context.Background(), member.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.MembershipStatus != want || store.User(member.ID).MembershipStatus != want {
+			t.Errorf("status = %s, want %s", got.MembershipStatus, want)
+		}
+	}
+}
+
+func TestAssignTrainer(t *testing.T) {
+	store, _, svc := newMembership()
+	member := store.AddUser(models.User{Role: models.RoleMember})
+	trainer := store.AddUser(models.User{Role: models.RoleTrainer})
+	staff := store.AddUser(models.User{Role: models.RoleStaff})
+	ctx := context.Background()
+
+	wantStatus(t, svc.AssignTrainer(ctx, member.ID, staff.ID), http.StatusBadRequest)
+	wantStatus(t, svc.AssignTrainer(ctx, member.ID, 99), http.StatusNotFound)
+
+	if err := svc.AssignTrainer(ctx, member.ID, trainer.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := store.User(member.ID).AssignedTrainerID; got == nil || *got != trainer.ID {
+		t.Errorf("assigned trainer = %v, want %d", got, trainer.ID)
+	}
+}
+
+func TestTerm(t *testing.T) {
+	start := time.Date(2024, 1, 31, 10, 0, 0, 0, time.UTC)
+	from, to := Term(models.Package{DurationDays: 30}, start)
+	if !from.Equal(start) || !to.Equal(time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("Term = %v to %v", from, to)
+	}
+}
+
+func TestSetPhoto(t *testing.T) {
+	store, _, svc := newMembership()
+	ctx := context.Background()
+	member := store.AddUser(models.User{Role: models.RoleMember, PhotoKey: "old"})
+	staff := store.AddUser(models.User{Role: models.RoleStaff})
+
+	updated, previous, err := svc.SetPhoto(ctx, member.ID, storage.StoredPhoto{Key: "new", URL: "/photos/new.jpg"})
+	if err != nil {
+		t.Fatalf("SetPhoto: %v", err)
+	}
+	if previous != "old" || updated.PhotoKey != "new" || store.User(member.ID).ProfilePicture != "/photos/new.jpg" {
+		t.Errorf("replaced %q with %+v, want old replaced by new", previous, updated)
+	}
+
+	// Staff photos are not changed through the member routes
+	_, _, err = svc.SetPhoto(ctx, staff.ID, storage.StoredPhoto{Key: "other"})
+	wantStatus(t, err, http.StatusNotFound)
+}
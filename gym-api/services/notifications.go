@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+
+	"gym-api/apperror"
+	"gym-api/listing"
+	"gym-api/models"
+	"gym-api/notifications"
+	"gym-api/repository"
+)
+
+// Notifications shows users what they were sent and what they want to
+// receive. Sending is package notifications' job.
+type Notifications interface {
+	// Recent returns the latest deliveries to a user, newest first.
+	Recent(ctx context.Context, userID uint) ([]models.NotificationLog, error)
+	// Logs pages through every delivery, for admins.
+	Logs(ctx context.Context, spec listing.Spec, p listing.Params) (*listing.Page[models.NotificationLog], error)
+	Preferences(ctx context.Context, userID uint) (Preferences, error)
+	// SetPreferences switches off the channels and kinds set to false and
+	// every other one back on, and returns the result.
+	SetPreferences(ctx context.Context, userID uint, prefs Preferences) (Preferences, error)
+	// SetPushToken registers the device push notifications go to. An
+	// empty token unregisters it.
+	SetPushToken(ctx context.Context, userID uint, token string) error
+}
+
+// Preferences maps each channel and kind to whether the user wants to
+// receive it.
+type Preferences struct {
+	Channels map[string]bool
+	Kinds    map[string]bool
+}
+
+// recentNotifications is how many deliveries Recent returns.
+const recentNotifications = 100
+
+type notificationService struct {
+	repos repository.Repos
+}
+
+func NewNotifications(repos repository.Repos) Notifications {
+	return &notificationService{repos: repos}
+}
+
+func (s *notificationService) Recent(ctx context.Context, userID uint) ([]models.NotificationLog, error) {
+	logs, err := s.repos.Notifications.Recent(ctx, userID, recentNotifications)
+	if err != nil {
+		return nil, apperror.DB(err, "Failed to fetch notifications")
+	}
+	return logs, nil
+}
+
+func (s *notificationService) Logs(ctx context.Context, spec listing.Spec, p listing.Params) (*listing.Page[models.NotificationLog], error) {
+	page, err := s.repos.Notifications.List(ctx, spec, p)
+	if err != nil {
+		return nil, apperror.DB(err, "Failed to fetch notification logs")
+	}
+	return page, nil
+}
+
+func (s *notificationService) Preferences(ctx context.Context, userID uint) (Preferences, error) {
+	prefs := Preferences{Channels: map[string]bool{}, Kinds: map[string]bool{}}
+	for _, ch := range notifications.AllChannels {
+		prefs.Channels[string(ch)] = true
+	}
+	for _, k := range notifications.AllKinds {
+		prefs.Kinds[string(k)] = true
+	}
+
+	optOuts, err := s.repos.Notifications.OptOuts(ctx, userID)
+	if err != nil {
+		return prefs, apperror.DB(err, "Failed to load preferences")
+	}
+	for _, o := range optOuts {
+		if o.Kind == "" {
+			prefs.Channels[o.Channel] = false
+		} else if o.Channel == "" {
+			prefs.Kinds[o.Kind] = false
+		}
+	}
+	return prefs, nil
+}
+
+func (s *notificationService) SetPreferences(ctx context.Context, userID uint, prefs Preferences) (Preferences, error) {
+	// 1. Build the new set of opt-outs from the switched-off entries
+	var optOuts []models.NotificationOptOut
+	for _, ch := range notifications.AllChannels {
+		if enabled, ok := prefs.Channels[string(ch)]; ok && !enabled {
+			optOuts = append(optOuts, models.NotificationOptOut{UserID: userID, Channel: string(ch)})
+		}
+	}
+	for _, k := range notifications.AllKinds {
+		if enabled, ok := prefs.Kinds[string(k)]; ok && !enabled {
+			optOuts = append(optOuts, models.NotificationOptOut{UserID: userID, Kind: string(k)})
+		}
+	}
+
+	// 2. Replace the stored ones
+	if err := s.repos.Notifications.SetOptOuts(ctx, userID, optOuts); err != nil {
+		return prefs, apperror.DB(err, "Could not save preferences")
+	}
+	return s.Preferences(ctx, userID)
+}
+
+func (s *notificationService) SetPushToken(ctx context.Context, userID uint, token string) error {
+	if err := s.repos.Users.SetPushToken(ctx, userID, token); err != nil {
+		return apperror.DB(err, "Could not save push token")
+	}
+	return nil
+}
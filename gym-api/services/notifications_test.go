@@ -0,0 +1,59 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"gym-api/models"
+	"gym-api/notifications"
+	"gym-api/repository/fake"
+)
+
+func TestNotificationPreferences(t *testing.T) {
+	store := fake.New()
+	svc := NewNotifications(store.Repos())
+	ctx := context.Background()
+	user := store.AddUser(models.User{Role: models.RoleMember})
+
+	prefs, err := svc.Preferences(ctx, user.ID)
+	if err != nil || !prefs.Channels[string(notifications.SMS)] || !prefs.Kinds[string(notifications.WeMissYou)] {
+		t.Fatalf("defaults: %+v, %v; want everything on", prefs, err)
+	}
+
+	prefs, err = svc.SetPreferences(ctx, user.ID, Preferences{
+		Channels: map[string]bool{"sms": false, "email": true, "fax": false},
+		Kinds:    map[string]bool{"we_miss_you": false},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prefs.Channels["sms"] || !prefs.Channels["email"] || prefs.Kinds["we_miss_you"] || !prefs.Kinds["welcome"] {
+		t.Errorf("after switching sms and we_miss_you off: %+v", prefs)
+	}
+	if len(store.OptOuts) != 2 {
+		t.Errorf("stored %+v, want the 2 known opt-outs", store.OptOuts)
+	}
+
+	// Left out entries are switched back on
+	prefs, err = svc.SetPreferences(ctx, user.ID, Preferences{Kinds: map[string]bool{"welcome": false}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !prefs.Channels["sms"] || !prefs.Kinds["we_miss_you"] || prefs.Kinds["welcome"] {
+		t.Errorf("after switching only welcome off: %+v", prefs)
+	}
+}
+
+func TestRecentNotifications(t *testing.T) {
+	store := fake.New()
+	svc := NewNotifications(store.Repos())
+	for i := range recentNotifications + 5 {
+		store.Notifications = append(store.Notifications, models.NotificationLog{ID: uint(i + 1), UserID: 1})
+	}
+	store.Notifications = append(store.Notifications, models.NotificationLog{ID: 999, UserID: 2})
+
+	logs, err := svc.Recent(context.Background(), 1)
+	if err != nil || len(logs) != recentNotifications || logs[0].ID != recentNotifications+5 {
+		t.Errorf("Recent = %d entries from %d, %v; want the latest %d", len(logs), logs[0].ID, err, recentNotifications)
+	}
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+
+	"gym-api/apperror"
+	"gym-api/listing"
+	"gym-api/models"
+	"gym-api/repository"
+)
+
+// Packages manages the membership packages on sale.
+type Packages interface {
+	List(ctx context.Context, spec listing.Spec, p listing.Params) (*listing.Page[models.Package], error)
+	Create(ctx context.Context, pkg models.Package) (models.Package, error)
+	Update(ctx context.Context, id uint, changes PackageChanges) (models.Package, error)
+	Delete(ctx context.Context, id uint) error
+}
+
+// PackageChanges is a partial update: nil fields are left unchanged.
+// Members keep the dates they bought.
+type PackageChanges struct {
+	Name         *string
+	DurationDays *int
+	Price        *float64
+	Description  *string
+}
+
+type packages struct {
+	repos repository.Repos
+}
+
+func NewPackages(repos repository.Repos) Packages {
+	return &packages{repos: repos}
+}
+
+func (s *packages) List(ctx context.Context, spec listing.Spec, p listing.Params) (*listing.Page[models.Package], error) {
+	page, err := s.repos.Packages.List(ctx, spec, p)
+	if err != nil {
+		return nil, apperror.DB(err, "Failed to fetch packages")
+	}
+	return page, nil
+}
+
+func (s *packages) Create(ctx context.Context, pkg models.Package) (models.Package, error) {
+	if err := s.repos.Packages.Create(ctx, &pkg); err != nil {
+		return pkg, apperror.DB(err, "Could not create package")
+	}
+	return pkg, nil
+}
+
+func (s *packages) Update(ctx context.Context, id uint, changes PackageChanges) (models.Package, error) {
+	pkg, err := s.repos.Packages.ByID(ctx, id)
+	if err != nil {
+		return pkg, apperror.FromDB(err, "Package not found")
+	}
+
+	if changes.Name != nil {
+		pkg.Name = *changes.Name
+	}
+	if changes.DurationDays != nil {
+		pkg.DurationDays = *changes.DurationDays
+	}
+	if changes.Price != nil {
+		pkg.Price = *changes.Price
+	}
+	if changes.Description != nil {
+		pkg.Description = *changes.Description
+	}
+
+	if err := s.repos.Packages.Save(ctx, &pkg); err != nil {
+		return pkg, apperror.DB(err, "Could not update package")
+	}
+	return pkg, nil
+}
+
+func (s *packages) Delete(ctx context.Context, id uint) error {
+	if err := s.repos.Packages.Delete(ctx, id); err != nil {
+		return apperror.DB(err, "Could not delete package")
+	}
+	return nil
+}
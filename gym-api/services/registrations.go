@@ -0,0 +1,230 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"gym-api/apperror"
+	"gym-api/config"
+	"gym-api/models"
+	"gym-api/notifications"
+	"gym-api/repository"
+	"gym-api/storage"
+	"gym-api/utils"
+
+	"gorm.io/gorm"
+)
+
+// Codes for self-registered members who cannot sign in yet. See also
+// CodeRegistrationPending.
+const (
+	CodeEmailNotVerified    apperror.Code = "email_not_verified"
+	CodeVerificationExpired apperror.Code = "verification_expired"
+)
+
+// verificationResendInterval keeps the resend endpoint from being used to
+// flood someone's inbox.
+const verificationResendInterval = time.Minute
+
+// Registrations covers members signing themselves up: they confirm their
+// email address, then staff approve them.
+type Registrations interface {
+	// Register creates a pending member and emails them a link to confirm
+	// their address. On error the returned user still carries the photo, for
+	// the caller to remove.
+	Register(ctx context.Context, member NewRegistration) (models.User, error)
+	// Verify confirms an email address. Opening the link again is harmless.
+	Verify(ctx context.Context, token string) error
+	// ResendVerification emails a new link; earlier links stop working.
+	// It returns nil whether or not a registration uses email, so the
+	// answer can't be used to find out.
+	ResendVerification(ctx context.Context, email string) error
+	// Pending lists the registrations waiting for approval, oldest first.
+	Pending(ctx context.Context) ([]models.Registration, error)
+	// Approve activates a member whose email address is confirmed.
+	Approve(ctx context.Context, id, staffID uint) (models.Registration, error)
+	// Reject removes a pending registration and its account, freeing the
+	// email address, and returns what was removed.
+	Reject(ctx context.Context, id uint) (models.Registration, error)
+}
+
+// NewRegistration is a member signing up through the app.
+type NewRegistration struct {
+	Name     string
+	Email    string
+	Phone    string
+	Password string
+	Photo    *storage.StoredPhoto
+}
+
+type registrations struct {
+	repos  repository.Repos
+	notify Notifier
+	email  Mailer
+}
+
+func NewRegistrations(repos repository.Repos, notify Notifier, email Mailer) Registrations {
+	return &registrations{repos: repos, notify: notify, email: email}
+}
+
+// issueToken gives registration a new verification token and expiry and
+// returns the token for the link.
+func (s *registrations) issueToken(registration *models.Registration) (string, error) {
+	token, err := utils.GenerateInviteToken()
+	if err != nil {
+		return "", apperror.Internal(err, "Could not generate verification token")
+	}
+	registration.TokenHash = utils.HashAPIKey(token)
+	registration.ExpiresAt = time.Now().Add(config.VerifyEmailTTL())
+	return token, nil
+}
+
+// send emails the link confirming the member's email address. A failed
+// email is logged; the member can ask for a new link.
+func (s *registrations) send(ctx context.Context, registration *models.Registration, token string) {
+	emailCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	err := s.email(emailCtx, registration.User, notifications.VerifyEmail, "", map[string]interface{}{
+		"Link":      config.VerifyEmailURL() + "?token=" + url.QueryEscape(token),
+		"ExpiresAt": registration.ExpiresAt.Format("2 Jan 2006 15:04"),
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Verification email failed", "user_id", registration.UserID, "error", err)
+		return
+	}
+
+	now := time.Now()
+	registration.SentAt = &now
+	if err := s.repos.Registrations.MarkSent(ctx, registration, now); err != nil {
+		slog.ErrorContext(ctx, "Failed to record verification email", "error", err)
+	}
+}
+
+func (s *registrations) Register(ctx context.Context, input NewRegistration) (models.User, error) {
+	registration := models.Registration{
+		User: models.User{
+			Name:             input.Name,
+			Email:            input.Email,
+			Phone:            input.Phone,
+			Role:             models.RoleMember,
+			MembershipStatus: "pending",
+		},
+	}
+	if input.Photo != nil {
+		registration.User.ProfilePicture = input.Photo.URL
+		registration.User.ProfileThumbnail = input.Photo.ThumbnailURL
+		registration.User.PhotoKey = input.Photo.Key
+	}
+
+	hash, err := utils.HashPassword(input.Password)
+	if err != nil {
+		return registration.User, apperror.Internal(err, "Could not hash password")
+	}
+	registration.User.PasswordHash = hash
+	token, err := s.issueToken(&registration)
+	if err != nil {
+		return registration.User, err
+	}
+
+	if err := s.repos.Registrations.Create(ctx, &registration); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return registration.User, apperror.Conflict("A user with this email already exists")
+		}
+		return registration.User, apperror.DB(err, "Could not create user")
+	}
+
+	s.send(ctx, &registration, token)
+	return registration.User, nil
+}
+
+func (s *registrations) Verify(ctx context.Context, token string) error {
+	registration, err := s.repos.Registrations.ByTokenHash(ctx, utils.HashAPIKey(token))
+	if err != nil {
+		return apperror.FromDB(err, "Verification link not found")
+	}
+	if registration.EmailVerifiedAt != nil {
+		return nil
+	}
+	if time.Now().After(registration.ExpiresAt) {
+		return apperror.New(http.StatusGone, CodeVerificationExpired, "This link has expired, ask for a new one")
+	}
+	if err := s.repos.Registrations.MarkVerified(ctx, registration.ID, time.Now()); err != nil {
+		return apperror.DB(err, "Could not confirm email address")
+	}
+	return nil
+}
+
+func (s *registrations) ResendVerification(ctx context.Context, email string) error {
+	registration, err := s.repos.Registrations.UnverifiedByEmail(ctx, email)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return apperror.DB(err, "Could not resend verification email")
+	}
+	if registration.SentAt != nil && time.Since(*registration.SentAt) < verificationResendInterval {
+		return nil
+	}
+
+	token, err := s.issueToken(&registration)
+	if err != nil {
+		return err
+	}
+	if err := s.repos.Registrations.SetToken(ctx, &registration); err != nil {
+		return apperror.DB(err, "Could not resend verification email")
+	}
+	s.send(ctx, &registration, token)
+	return nil
+}
+
+func (s *registrations) Pending(ctx context.Context) ([]models.Registration, error) {
+	list, err := s.repos.Registrations.Pending(ctx)
+	if err != nil {
+		return nil, apperror.DB(err, "Failed to fetch registrations")
+	}
+	return list, nil
+}
+
+func (s *registrations) pending(ctx context.Context, id uint) (models.Registration, error) {
+	registration, err := s.repos.Registrations.PendingByID(ctx, id)
+	if err != nil {
+		return registration, apperror.FromDB(err, "Registration not found or already approved")
+	}
+	return registration, nil
+}
+
+func (s *registrations) Approve(ctx context.Context, id, staffID uint) (models.Registration, error) {
+	registration, err := s.pending(ctx, id)
+	if err != nil {
+		return registration, err
+	}
+	if registration.EmailVerifiedAt == nil {
+		return registration, apperror.Conflict("The member has not confirmed their email address yet").WithCode(CodeEmailNotVerified)
+	}
+
+	// Approve once, even if two people click at the same time
+	now := time.Now()
+	if err := s.repos.Registrations.Approve(ctx, registration, staffID, now); err != nil {
+		return registration, apperror.FromDB(err, "Registration not found or already approved")
+	}
+	registration.ApprovedAt, registration.ApprovedBy = &now, &staffID
+	registration.User.MembershipStatus = "active"
+
+	s.notify(registration.User, notifications.Welcome, nil)
+	return registration, nil
+}
+
+func (s *registrations) Reject(ctx context.Context, id uint) (models.Registration, error) {
+	registration, err := s.pending(ctx, id)
+	if err != nil {
+		return registration, err
+	}
+	if err := s.repos.Registrations.Delete(ctx, registration); err != nil {
+		return registration, apperror.DB(err, "Could not reject registration")
+	}
+	return registration, nil
+}
@@ -0,0 +1,234 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"gym-api/models"
+	"gym-api/notifications"
+	"gym-api/repository/fake"
+)
+
+// inbox records the verification links a service emailed.
+type inbox []string
+
+func (i *inbox) email(_ context.Context, _ models.User, _ notifications.Kind, _ string, data map[string]interface{}) error {
+	*i = append(*i, data["Link"].(string))
+	return nil
+}
+
+// token returns the token in the last link emailed.
+func (i *inbox) token(t *testing.T) string {
+	t.Helper()
+	if len(*i) == 0 {
+		t.Fatal("no verification email sent")
+	}
+	link, err := url.Parse((*i)[len(*i)-1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return link.Query().Get("token")
+}
+
+func newRegistrations() (*fake.Store, *sent, *inbox, Registrations) {
+	store, notified, emailed := fake.New(), &sent{}, &inbox{}
+	return store, notified, emailed, NewRegistrations(store.Repos(), notified.notify, emailed.email)
+}
+
+func register(t *testing.T, svc Registrations) models.User {
+	t.Helper()
+	user, err := svc.Register(context.Background(), NewRegistration{Name: "Ana", Email: "ana@example.com", Password: "Secret123!"})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	return user
+}
+
+func TestRegisterCreatesPendingMemberWithoutPackage(t *testing.T) {
+	store, notified, emailed, svc := newRegistrations()
+
+	user := register(t, svc)
+
+	stored := store.User(user.ID)
+	if stored.Role != models.RoleMember || stored.MembershipStatus != "pending" {
+		t.Errorf("role, status = %s, %s; want member, pending", stored.Role, stored.MembershipStatus)
+	}
+	if stored.PackageID != nil || stored.SubStartDate != nil || stored.SubEndDate != nil {
+		t.Errorf("package, term = %v, %v to %v; want none", stored.PackageID, stored.SubStartDate, stored.SubEndDate)
+	}
+	if len(store.Subscriptions) != 0 {
+		t.Errorf("recorded %d sales, want none", len(store.Subscriptions))
+	}
+	if len(*emailed) != 1 || len(*notified) != 0 {
+		t.Errorf("emailed %d, notified %v; want one verification email only", len(*emailed), *notified)
+	}
+	if len(store.Registrations) != 1 || store.Registrations[0].SentAt == nil {
+		t.Errorf("registrations = %+v, want one with the email sent", store.Registrations)
+	}
+
+	_, err := svc.Register(context.Background(), NewRegistration{Name: "Ana", Email: "ana@example.com", Password: "Secret123!"})
+	wantStatus(t, err, http.StatusConflict)
+}
+
+func TestApproveBeforeVerifyIsRejected(t *testing.T) {
+	store, notified, _, svc := newRegistrations()
+	user := register(t, svc)
+
+	_, err := svc.Approve(context.Background(), store.Registrations[0].ID, 1)
+	if e := wantStatus(t, err, http.StatusConflict); e.Code != CodeEmailNotVerified {
+		t.Errorf("code = %s, want %s", e.Code, CodeEmailNotVerified)
+	}
+	if store.User(user.ID).MembershipStatus != "pending" || store.Registrations[0].ApprovedAt != nil || len(*notified) != 0 {
+		t.Error("unverified registration was approved")
+	}
+}
+
+func TestApproveRegistration(t *testing.T) {
+	store, notified, emailed, svc := newRegistrations()
+	ctx := context.Background()
+	user := register(t, svc)
+	if err := svc.Verify(ctx, emailed.token(t)); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	id := store.Registrations[0].ID
+
+	registration, err := svc.Approve(ctx, id, 7)
+	if err != nil {
+		t.Fatalf("Approve: %v", err)
+	}
+	if registration.User.MembershipStatus != "active" || *registration.ApprovedBy != 7 {
+		t.Errorf("status, approved by = %s, %d; want active, 7", registration.User.MembershipStatus, *registration.ApprovedBy)
+	}
+	if stored := store.User(user.ID); stored.MembershipStatus != "active" || stored.PackageID != nil {
+		t.Errorf("stored status, package = %s, %v; want active without a package", stored.MembershipStatus, stored.PackageID)
+	}
+	if len(*notified) != 1 || (*notified)[0] != notifications.Welcome {
+		t.Errorf("notified %v, want a welcome", *notified)
+	}
+
+	// A second click, or a second member of staff
+	_, err = svc.Approve(ctx, id, 8)
+	wantStatus(t, err, http.StatusNotFound)
+	if *store.Registrations[0].ApprovedBy != 7 || len(*notified) != 1 {
+		t.Error("registration approved twice")
+	}
+	if pending, _ := svc.Pending(ctx); len(pending) != 0 {
+		t.Errorf("pending = %+v, want none", pending)
+	}
+}
+
+func TestRejectRegistration(t *testing.T) {
+	store, notified, _, svc := newRegistrations()
+	ctx := context.Background()
+	user := register(t, svc)
+	id := store.Registrations[0].ID
+
+	registration, err := svc.Reject(ctx, id)
+	if err != nil {
+		t.Fatalf("Reject: %v", err)
+	}
+	if registration.User.Email != user.Email {
+		t.Errorf("rejected %q, want %q", registration.User.Email, user.Email)
+	}
+	if len(store.Registrations) != 0 || store.User(user.ID).ID != 0 || len(*notified) != 0 {
+		t.Error("registration or account left behind")
+	}
+
+	_, err = svc.Reject(ctx, id)
+	wantStatus(t, err, http.StatusNotFound)
+	_, err = svc.Approve(ctx, id, 1)
+	wantStatus(t, err, http.StatusNotFound)
+
+	// The email address is free again
+	register(t, svc)
+}
+
+func TestApprovedRegistrationCannotBeRejected(t *testing.T) {
+	store, _, emailed, svc := newRegistrations()
+	ctx := context.Background()
+	user := register(t, svc)
+	if err := svc.Verify(ctx, emailed.token(t)); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	id := store.Registrations[0].ID
+	if _, err := svc.Approve(ctx, id, 1); err != nil {
+		t.Fatalf("Approve: %v", err)
+	}
+
+	_, err := svc.Reject(ctx, id)
+	wantStatus(t, err, http.StatusNotFound)
+	if store.User(user.ID).ID == 0 {
+		t.Error("approved member was deleted")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	store, _, emailed, svc := newRegistrations()
+	ctx := context.Background()
+	register(t, svc)
+	token := emailed.token(t)
+
+	err := svc.Verify(ctx, "not-a-token")
+	wantStatus(t, err, http.StatusNotFound)
+
+	if err := svc.Verify(ctx, token); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	verifiedAt := *store.Registrations[0].EmailVerifiedAt
+	if err := svc.Verify(ctx, token); err != nil {
+		t.Errorf("Verify again: %v, want nil", err)
+	}
+	if !store.Registrations[0].EmailVerifiedAt.Equal(verifiedAt) {
+		t.Error("opening the link again moved the verification time")
+	}
+}
+
+func TestVerifyExpired(t *testing.T) {
+	store, _, emailed, svc := newRegistrations()
+	register(t, svc)
+	store.Registrations[0].ExpiresAt = time.Now().Add(-time.Minute)
+
+	err := svc.Verify(context.Background(), emailed.token(t))
+	if e := wantStatus(t, err, http.StatusGone); e.Code != CodeVerificationExpired {
+		t.Errorf("code = %s, want %s", e.Code, CodeVerificationExpired)
+	}
+	if store.Registrations[0].EmailVerifiedAt != nil {
+		t.Error("expired link confirmed the address")
+	}
+}
+
+func TestResendVerification(t *testing.T) {
+	store, _, emailed, svc := newRegistrations()
+	ctx := context.Background()
+	register(t, svc)
+	first := emailed.token(t)
+
+	// Too soon after the first email
+	if err := svc.ResendVerification(ctx, "ana@example.com"); err != nil || len(*emailed) != 1 {
+		t.Fatalf("resend = %v after %d emails, want nil and no new email", err, len(*emailed))
+	}
+
+	sent := time.Now().Add(-2 * verificationResendInterval)
+	store.Registrations[0].SentAt = &sent
+	if err := svc.ResendVerification(ctx, "ana@example.com"); err != nil {
+		t.Fatalf("ResendVerification: %v", err)
+	}
+	if len(*emailed) != 2 {
+		t.Fatalf("sent %d emails, want 2", len(*emailed))
+	}
+	wantStatus(t, svc.Verify(ctx, first), http.StatusNotFound)
+	if err := svc.Verify(ctx, emailed.token(t)); err != nil {
+		t.Errorf("Verify new link: %v", err)
+	}
+
+	// Unknown and confirmed addresses look the same as a resend
+	if err := svc.ResendVerification(ctx, "nobody@example.com"); err != nil {
+		t.Errorf("unknown address: %v, want nil", err)
+	}
+	if err := svc.ResendVerification(ctx, "ana@example.com"); err != nil || len(*emailed) != 2 {
+		t.Errorf("confirmed address: %v after %d emails, want nil and no new email", err, len(*emailed))
+	}
+}
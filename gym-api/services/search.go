@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"gym-api/apperror"
+	"gym-api/models"
+	"gym-api/repository"
+	"gym-api/search"
+)
+
+// Search is the front desk member lookup.
+type Search interface {
+	// Members matches partial names, emails, phone numbers, member numbers
+	// or a scanned QR code, best match first.
+	Members(ctx context.Context, query string, limit int) ([]MemberMatch, error)
+}
+
+// MemberMatch is a member found by Search.
+type MemberMatch struct {
+	User           models.User
+	CheckedInToday bool
+	Score          int
+}
+
+// MemberIndex finds up to limit members matching query.
+type MemberIndex func(query string, limit int) ([]search.Hit, error)
+
+type searchService struct {
+	repos repository.Repos
+	index MemberIndex
+}
+
+// NewSearch looks members up in index and loads them through repos.
+func NewSearch(repos repository.Repos, index MemberIndex) Search {
+	return &searchService{repos: repos, index: index}
+}
+
+func (s *searchService) Members(ctx context.Context, query string, limit int) ([]MemberMatch, error) {
+	hits, err := s.index(query, limit)
+	if err != nil {
+		return nil, apperror.DB(err, "Search failed")
+	}
+	if len(hits) == 0 {
+		return []MemberMatch{}, nil
+	}
+
+	ids := make([]uint, len(hits))
+	for i, h := range hits {
+		ids[i] = h.ID
+	}
+	users, err := s.repos.Users.ByIDs(ctx, ids)
+	if err != nil {
+		return nil, apperror.DB(err, "Search failed")
+	}
+	byID := make(map[uint]models.User, len(users))
+	for _, u := range users {
+		byID[u.ID] = u
+	}
+
+	// One query for the whole page instead of one per card
+	now := time.Now()
+	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	checkedIn, err := s.repos.Attendance.CheckedInSince(ctx, ids, startOfDay)
+	if err != nil {
+		return nil, apperror.DB(err, "Search failed")
+	}
+	today := make(map[uint]bool, len(checkedIn))
+	for _, id := range checkedIn {
+		today[id] = true
+	}
+
+	matches := make([]MemberMatch, 0, len(hits))
+	for _, h := range hits {
+		user, ok := byID[h.ID]
+		if !ok {
+			continue // Deleted since the index was built
+		}
+		matches = append(matches, MemberMatch{User: user, CheckedInToday: today[user.ID], Score: h.Score})
+	}
+	return matches, nil
+}
@@ -0,0 +1,95 @@
+// Package services holds the business rules for members, packages, staff
+// accounts, attendance, gates, sign-in and reports. Services read and
+// write through package repository and are built once in main; handlers
+// receive them through their constructors rather than reaching for
+// config.DB.
+//
+// Errors meant for the client are *apperror.Error values, so handlers can
+// return them as they are.
+package services
+
+import (
+	"context"
+
+	"gym-api/gates"
+	"gym-api/lockout"
+	"gym-api/models"
+	"gym-api/notifications"
+	"gym-api/oidc"
+	"gym-api/repository"
+	"gym-api/twofactor"
+
+	"gorm.io/gorm"
+)
+
+// Services is every service, as main wires them.
+type Services struct {
+	Auth          Auth
+	Membership    Membership
+	Packages      Packages
+	Users         Users
+	Attendance    Attendance
+	Gates         Gates
+	Branches      Branches
+	Devices       Devices
+	Incidents     Incidents
+	Notifications Notifications
+	Churn         Churn
+	Health        Health
+	Invites       Invites
+	Registrations Registrations
+	TwoFactor     TwoFactor
+	SSO           SSO
+	Lockouts      Lockouts
+	Analytics     Analytics
+	Search        Search
+}
+
+// Deps are what the services use besides the repositories: side effects
+// that leave the process and state other packages keep for it. main passes
+// the real ones; tests pass fakes, or leave out those they don't reach.
+type Deps struct {
+	Notify  Notifier          // notifications.Dispatch
+	Email   Mailer            // notifications.SendEmail
+	Feed    Feed              // LiveFeed
+	Gates   *gates.Registry   // gates.Default
+	Limiter *lockout.Limiter  // lockout.Default
+	Policy  *twofactor.Policy // twofactor.Default
+	SSO     *oidc.Provider    // oidc.Default
+	Index   MemberIndex       // search.Members
+	// Reports runs the analytics queries, which join across too many
+	// tables for a repository interface.
+	Reports *gorm.DB
+}
+
+// New builds the services on repos.
+func New(repos repository.Repos, deps Deps) Services {
+	return Services{
+		Auth:          NewAuth(repos, deps.Limiter, deps.Feed),
+		Membership:    NewMembership(repos, deps.Notify),
+		Packages:      NewPackages(repos),
+		Users:         NewUsers(repos, deps.Notify),
+		Attendance:    NewAttendance(repos, deps.Feed),
+		Gates:         NewGates(repos, deps.Gates),
+		Branches:      NewBranches(repos),
+		Devices:       NewDevices(repos),
+		Incidents:     NewIncidents(repos),
+		Notifications: NewNotifications(repos),
+		Churn:         NewChurn(repos),
+		Health:        NewHealth(repos),
+		Invites:       NewInvites(repos, deps.Email),
+		Registrations: NewRegistrations(repos, deps.Notify, deps.Email),
+		TwoFactor:     NewTwoFactor(repos, deps.Limiter, deps.Policy),
+		SSO:           NewSSO(repos, deps.SSO),
+		Lockouts:      NewLockouts(deps.Limiter),
+		Analytics:     NewAnalytics(deps.Reports),
+		Search:        NewSearch(repos, deps.Index),
+	}
+}
+
+// Notifier sends a notification without waiting for it to be delivered.
+type Notifier func(user models.User, kind notifications.Kind, data map[string]interface{})
+
+// Mailer sends a transactional email, such as an invite link, and waits
+// for the outcome.
+type Mailer func(ctx context.Context, user models.User, kind notifications.Kind, reference string, data map[string]interface{}) error
@@ -0,0 +1,218 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"unicode"
+
+	"gym-api/apperror"
+	"gym-api/audit"
+	"gym-api/config"
+	"gym-api/models"
+	"gym-api/oidc"
+	"gym-api/repository"
+	"gym-api/validation"
+
+	"gorm.io/gorm"
+)
+
+// SSO signs staff in through the configured OIDC provider.
+type SSO interface {
+	// Start returns the provider URL to send the browser to and the state
+	// the web app must see come back.
+	Start(ctx context.Context) (authURL, state string, err error)
+	// Finish redeems the code the provider returned. The verified email
+	// selects the account; with OIDC_ALLOWED_DOMAINS set, staff without one
+	// get an account on first sign-on. ip goes in the audit log.
+	Finish(ctx context.Context, code, state, ip string) (SSOLogin, error)
+}
+
+// SSOLogin is a finished sign-on.
+type SSOLogin struct {
+	User models.User
+	// MFA is set when the provider says it checked a second factor.
+	MFA bool
+}
+
+type sso struct {
+	repos    repository.Repos
+	provider *oidc.Provider
+}
+
+func NewSSO(repos repository.Repos, provider *oidc.Provider) SSO {
+	return &sso{repos: repos, provider: provider}
+}
+
+func (s *sso) Start(ctx context.Context) (string, string, error) {
+	authURL, state, err := s.provider.Start(ctx)
+	if errors.Is(err, oidc.ErrDisabled) {
+		return "", "", apperror.NotFound("Single sign-on is not configured")
+	}
+	if err != nil {
+		return "", "", apperror.BadGateway(err, "Could not reach the sign-on provider")
+	}
+	return authURL, state, nil
+}
+
+func (s *sso) Finish(ctx context.Context, code, state, ip string) (SSOLogin, error) {
+	settings := s.provider.Settings
+
+	// 1. Redeem the code
+	claims, err := s.provider.Finish(ctx, code, state)
+	switch {
+	case errors.Is(err, oidc.ErrDisabled):
+		return SSOLogin{}, apperror.NotFound("Single sign-on is not configured")
+	case errors.Is(err, oidc.ErrUnknownState):
+		return SSOLogin{}, apperror.BadRequest("Sign-on expired, start again")
+	case errors.Is(err, oidc.ErrRefused):
+		slog.WarnContext(ctx, "Sign-on refused", "error", err)
+		return SSOLogin{}, apperror.Unauthorized("The sign-on provider's answer could not be verified")
+	case err != nil:
+		return SSOLogin{}, apperror.BadGateway(err, "Could not reach the sign-on provider")
+	}
+
+	// 2. Only a verified email from an allowed domain identifies anyone
+	email := validation.NormalizeEmail(claims.Email)
+	if email == "" || !claims.EmailVerified {
+		return SSOLogin{}, apperror.Forbidden("The sign-on provider did not confirm your email address")
+	}
+	_, domain, _ := strings.Cut(email, "@")
+	if len(settings.AllowedDomains) > 0 && !slices.Contains(settings.AllowedDomains, domain) {
+		return SSOLogin{}, apperror.Forbidden("Single sign-on is not available for " + domain)
+	}
+	role := ssoRole(settings, claims)
+
+	// 3. Find the account, or create it just in time
+	user, err := s.repos.Users.ByEmail(ctx, email)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		if len(settings.AllowedDomains) == 0 {
+			return SSOLogin{}, apperror.Forbidden("No account uses this email address")
+		}
+		if role == "" {
+			role = models.Role(settings.DefaultRole)
+		}
+		if user, err = s.create(ctx, claims, email, role, ip); err != nil {
+			return SSOLogin{}, err
+		}
+	case err != nil:
+		return SSOLogin{}, apperror.DB(err, "Could not login")
+	default:
+		if user, err = s.update(ctx, user, claims, role, ip); err != nil {
+			return SSOLogin{}, err
+		}
+	}
+
+	return SSOLogin{User: user, MFA: slices.Contains(claims.AMR, "mfa")}, nil
+}
+
+// ssoRole maps the configured role claim to a role, or "" if no value is
+// mapped. The most privileged match wins.
+func ssoRole(settings config.OIDCSettings, claims *oidc.Claims) models.Role {
+	if settings.RoleClaim == "" {
+		return ""
+	}
+	var values []string
+	switch v := claims.Raw[settings.RoleClaim].(type) {
+	case string:
+		values = []string{v}
+	case []any:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+
+	var mapped []models.Role
+	for _, v := range values {
+		if role, ok := settings.RoleMap[v]; ok {
+			mapped = append(mapped, models.Role(role))
+		}
+	}
+	for _, role := range []models.Role{models.RoleAdmin, models.RoleStaff, models.RoleTrainer} {
+		if slices.Contains(mapped, role) {
+			return role
+		}
+	}
+	return ""
+}
+
+func (s *sso) create(ctx context.Context, claims *oidc.Claims, email string, role models.Role, ip string) (models.User, error) {
+	// Names end up in email headers; the provider's is not validated
+	name := strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, claims.Name))
+	if name == "" {
+		name, _, _ = strings.Cut(email, "@")
+	}
+	// No password: the account signs in through the provider only
+	user := models.User{
+		Name:       name,
+		Email:      email,
+		Role:       role,
+		IsActive:   true,
+		SSOSubject: claims.Subject,
+	}
+	if err := s.repos.Users.Create(ctx, &user); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return user, apperror.Conflict("A user with this email already exists")
+		}
+		return user, apperror.DB(err, "Could not create user")
+	}
+
+	audit.Record(models.AuditLog{
+		Action:  "auth.sso_user_created",
+		ActorID: &user.ID,
+		Subject: email,
+		Detail:  fmt.Sprintf("role=%s issuer=%s", role, s.provider.Settings.Issuer),
+		IP:      ip,
+	})
+	return user, nil
+}
+
+func (s *sso) update(ctx context.Context, user models.User, claims *oidc.Claims, role models.Role, ip string) (models.User, error) {
+	if !user.IsActive {
+		return user, apperror.Unauthorized("User is deactivated")
+	}
+	// Members sign in with a password, whatever the provider maps them to:
+	// a group claim must not turn a member's account into a staff one
+	if user.Role == models.RoleMember {
+		return user, apperror.Forbidden("Single sign-on is for staff accounts")
+	}
+	// The provider may reassign an email; the subject is stable
+	if user.SSOSubject != "" && user.SSOSubject != claims.Subject {
+		return user, apperror.Forbidden("This account is linked to a different sign-on identity")
+	}
+
+	roleChanged := role != "" && role != user.Role
+	if user.SSOSubject == claims.Subject && !roleChanged {
+		return user, nil
+	}
+	previous := user.Role
+	user.SSOSubject = claims.Subject
+	if roleChanged {
+		user.Role = role
+	}
+	if err := s.repos.Users.Save(ctx, &user); err != nil {
+		return user, apperror.DB(err, "Could not login")
+	}
+
+	if roleChanged {
+		audit.Record(models.AuditLog{
+			Action:  "auth.sso_role_changed",
+			ActorID: &user.ID,
+			Subject: user.Email,
+			Detail:  fmt.Sprintf("%s -> %s", previous, role),
+			IP:      ip,
+		})
+	}
+	return user, nil
+}
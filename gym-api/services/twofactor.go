@@ -0,0 +1,311 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gym-api/apperror"
+	"gym-api/audit"
+	"gym-api/config"
+	"gym-api/lockout"
+	"gym-api/models"
+	"gym-api/repository"
+	"gym-api/twofactor"
+	"gym-api/utils"
+)
+
+// CodeInvalidTwoFactorCode is returned for a wrong, expired or reused code.
+const CodeInvalidTwoFactorCode apperror.Code = "invalid_two_factor_code"
+
+// TwoFactor covers authenticator codes: enrolment, the second login step,
+// recovery codes and which roles must use them.
+type TwoFactor interface {
+	// VerifyLogin checks a code for the user a login challenge was issued
+	// to. Codes count against the same limits as passwords; a lockout
+	// refusal is returned as the *lockout.Blocked.
+	VerifyLogin(ctx context.Context, userID uint, code, ip string) (models.User, error)
+	// Setup generates a new authenticator secret, which takes effect once
+	// Enable confirms a code from it. It returns the secret and the
+	// otpauth URI to show as a QR code.
+	Setup(ctx context.Context, userID uint) (secret, uri string, err error)
+	// Enable turns 2FA on once a code from the new secret checks out, and
+	// returns a fresh set of recovery codes.
+	Enable(ctx context.Context, userID uint, code string) (models.User, []string, error)
+	// Disable turns 2FA off given both factors, unless the role requires it.
+	Disable(ctx context.Context, userID uint, password, code string) (models.User, error)
+	// RegenerateRecoveryCodes replaces the recovery codes, invalidating the
+	// old ones.
+	RegenerateRecoveryCodes(ctx context.Context, userID uint, code string) (models.User, []string, error)
+	// Reset turns 2FA off for a user who lost their authenticator and
+	// recovery codes.
+	Reset(ctx context.Context, userID uint) (models.User, error)
+	// Policies lists which roles must use 2FA.
+	Policies(ctx context.Context) ([]models.TwoFactorPolicy, error)
+	SetPolicy(ctx context.Context, role models.Role, required bool, by uint) (models.TwoFactorPolicy, error)
+}
+
+const (
+	methodTOTP     = "totp"
+	methodRecovery = "recovery"
+)
+
+type twoFactor struct {
+	repos   repository.Repos
+	limiter *lockout.Limiter
+	policy  *twofactor.Policy
+}
+
+func NewTwoFactor(repos repository.Repos, limiter *lockout.Limiter, policy *twofactor.Policy) TwoFactor {
+	return &twoFactor{repos: repos, limiter: limiter, policy: policy}
+}
+
+// check matches code against the user's authenticator and then their
+// unused recovery codes, and returns which one it matched, or "". Each
+// code is accepted once.
+func (s *twoFactor) check(ctx context.Context, user models.User, code string) (string, error) {
+	if step, ok := twofactor.Match(user.TOTPSecret, code, time.Now()); ok {
+		// Claiming the step refuses a replay of the same code, even a concurrent one
+		claimed, err := s.repos.TwoFactor.ClaimStep(ctx, user.ID, step)
+		if err != nil || !claimed {
+			return "", err
+		}
+		return methodTOTP, nil
+	}
+
+	used, err := s.repos.TwoFactor.UseRecoveryCode(ctx, user.ID, twofactor.HashRecoveryCode(code), time.Now())
+	if err != nil || !used {
+		return "", err
+	}
+	return methodRecovery, nil
+}
+
+// replaceRecoveryCodes stores a new set of recovery codes through tx and
+// returns them.
+func replaceRecoveryCodes(ctx context.Context, tx repository.Repos, userID uint) ([]string, error) {
+	codes, err := twofactor.NewRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = twofactor.HashRecoveryCode(code)
+	}
+	return codes, tx.TwoFactor.ReplaceRecoveryCodes(ctx, userID, hashes)
+}
+
+func (s *twoFactor) user(ctx context.Context, id uint) (models.User, error) {
+	user, err := s.repos.Users.ByID(ctx, id)
+	if err != nil {
+		return user, apperror.FromDB(err, "User not found")
+	}
+	return user, nil
+}
+
+func (s *twoFactor) VerifyLogin(ctx context.Context, userID uint, code, ip string) (models.User, error) {
+	// 1. The challenge proved the password was right
+	user, err := s.user(ctx, userID)
+	if err != nil {
+		return user, err
+	}
+	if !user.IsActive {
+		return user, apperror.Unauthorized("User is deactivated")
+	}
+	if user.TwoFactorEnabledAt == nil {
+		return user, apperror.Unauthorized("Login expired, sign in again")
+	}
+
+	// 2. Codes are guessable too; they count against the same limits as passwords
+	if err := s.limiter.Check(ctx, user.Email, ip); err != nil {
+		return user, err
+	}
+
+	// 3. Check the code
+	method, err := s.check(ctx, user, code)
+	if err != nil {
+		return user, apperror.DB(err, "Could not login")
+	}
+	if method == "" {
+		s.limiter.Failed(ctx, user.Email, ip, &user.ID)
+		return user, apperror.Unauthorized("Invalid or already used code").WithCode(CodeInvalidTwoFactorCode)
+	}
+	s.limiter.Succeeded(ctx, user.Email)
+	if method == methodRecovery {
+		detail := "Recovery code used"
+		if left, err := s.repos.TwoFactor.CountRecoveryCodes(ctx, user.ID); err != nil {
+			slog.ErrorContext(ctx, "Failed to count recovery codes", "user_id", user.ID, "error", err)
+		} else {
+			detail = fmt.Sprintf("%d recovery codes left", left)
+		}
+		audit.Record(models.AuditLog{
+			Action:  "auth.recovery_code_used",
+			ActorID: &user.ID,
+			Subject: user.Email,
+			Detail:  detail,
+			IP:      ip,
+		})
+	}
+	return user, nil
+}
+
+func (s *twoFactor) Setup(ctx context.Context, userID uint) (string, string, error) {
+	user, err := s.user(ctx, userID)
+	if err != nil {
+		return "", "", err
+	}
+	if user.TwoFactorEnabledAt != nil {
+		return "", "", apperror.Conflict("Two-factor authentication is already on")
+	}
+
+	secret, err := twofactor.NewSecret()
+	if err != nil {
+		return "", "", apperror.Internal(err, "Could not generate a secret")
+	}
+	if err := s.repos.TwoFactor.SetSecret(ctx, user.ID, secret); err != nil {
+		return "", "", apperror.DB(err, "Could not start two-factor setup")
+	}
+	return secret, twofactor.URI(config.TwoFactorIssuer(), user.Email, secret), nil
+}
+
+func (s *twoFactor) Enable(ctx context.Context, userID uint, code string) (models.User, []string, error) {
+	// 1. Find the pending secret
+	user, err := s.user(ctx, userID)
+	if err != nil {
+		return user, nil, err
+	}
+	if user.TwoFactorEnabledAt != nil {
+		return user, nil, apperror.Conflict("Two-factor authentication is already on")
+	}
+	if user.TOTPSecret == "" {
+		return user, nil, apperror.BadRequest("Start two-factor setup first")
+	}
+
+	// 2. Confirm a code from it
+	method, err := s.check(ctx, user, code)
+	if err != nil {
+		return user, nil, apperror.DB(err, "Could not enable two-factor authentication")
+	}
+	if method != methodTOTP {
+		return user, nil, apperror.BadRequest("Invalid code").WithCode(CodeInvalidTwoFactorCode)
+	}
+
+	// 3. Turn it on with a fresh set of recovery codes
+	now := time.Now()
+	var codes []string
+	err = s.repos.Transaction(ctx, func(tx repository.Repos) error {
+		if err := tx.TwoFactor.Enable(ctx, user.ID, now); err != nil {
+			return err
+		}
+		codes, err = replaceRecoveryCodes(ctx, tx, user.ID)
+		return err
+	})
+	if err != nil {
+		return user, nil, apperror.DB(err, "Could not enable two-factor authentication")
+	}
+	user.TwoFactorEnabledAt = &now
+	return user, codes, nil
+}
+
+func (s *twoFactor) Disable(ctx context.Context, userID uint, password, code string) (models.User, error) {
+	user, err := s.user(ctx, userID)
+	if err != nil {
+		return user, err
+	}
+	if user.TwoFactorEnabledAt == nil {
+		return user, apperror.BadRequest("Two-factor authentication is not on")
+	}
+	if s.policy.Required(ctx, user.Role) {
+		return user, apperror.Forbidden("Your role requires two-factor authentication")
+	}
+
+	// Both factors, so a stolen session cannot switch it off
+	if !utils.CheckPasswordHash(password, user.PasswordHash) {
+		return user, apperror.Unauthorized("Incorrect password")
+	}
+	method, err := s.check(ctx, user, code)
+	if err != nil {
+		return user, apperror.DB(err, "Could not disable two-factor authentication")
+	}
+	if method == "" {
+		return user, apperror.BadRequest("Invalid or already used code").WithCode(CodeInvalidTwoFactorCode)
+	}
+
+	err = s.repos.Transaction(ctx, func(tx repository.Repos) error { return tx.TwoFactor.Clear(ctx, user.ID) })
+	if err != nil {
+		return user, apperror.DB(err, "Could not disable two-factor authentication")
+	}
+	user.TwoFactorEnabledAt = nil
+	return user, nil
+}
+
+func (s *twoFactor) RegenerateRecoveryCodes(ctx context.Context, userID uint, code string) (models.User, []string, error) {
+	user, err := s.user(ctx, userID)
+	if err != nil {
+		return user, nil, err
+	}
+	if user.TwoFactorEnabledAt == nil {
+		return user, nil, apperror.BadRequest("Two-factor authentication is not on")
+	}
+	method, err := s.check(ctx, user, code)
+	if err != nil {
+		return user, nil, apperror.DB(err, "Could not replace recovery codes")
+	}
+	if method == "" {
+		return user, nil, apperror.BadRequest("Invalid or already used code").WithCode(CodeInvalidTwoFactorCode)
+	}
+
+	var codes []string
+	err = s.repos.Transaction(ctx, func(tx repository.Repos) error {
+		codes, err = replaceRecoveryCodes(ctx, tx, user.ID)
+		return err
+	})
+	if err != nil {
+		return user, nil, apperror.DB(err, "Could not replace recovery codes")
+	}
+	return user, codes, nil
+}
+
+func (s *twoFactor) Reset(ctx context.Context, userID uint) (models.User, error) {
+	user, err := s.user(ctx, userID)
+	if err != nil {
+		return user, err
+	}
+	err = s.repos.Transaction(ctx, func(tx repository.Repos) error { return tx.TwoFactor.Clear(ctx, user.ID) })
+	if err != nil {
+		return user, apperror.DB(err, "Could not reset two-factor authentication")
+	}
+	user.TwoFactorEnabledAt = nil
+	return user, nil
+}
+
+// Policies returns the policy for every role, including roles never set.
+func (s *twoFactor) Policies(ctx context.Context) ([]models.TwoFactorPolicy, error) {
+	rows, err := s.repos.TwoFactor.Policies(ctx)
+	if err != nil {
+		return nil, apperror.DB(err, "Failed to fetch the two-factor policy")
+	}
+	byRole := make(map[models.Role]models.TwoFactorPolicy, len(rows))
+	for _, row := range rows {
+		byRole[row.Role] = row
+	}
+
+	policies := make([]models.TwoFactorPolicy, len(twofactor.Roles))
+	for i, role := range twofactor.Roles {
+		p, ok := byRole[role]
+		if !ok {
+			p = models.TwoFactorPolicy{Role: role}
+		}
+		policies[i] = p
+	}
+	return policies, nil
+}
+
+func (s *twoFactor) SetPolicy(ctx context.Context, role models.Role, required bool, by uint) (models.TwoFactorPolicy, error) {
+	policy := models.TwoFactorPolicy{Role: role, Required: required, UpdatedBy: &by}
+	if err := s.repos.TwoFactor.SetPolicy(ctx, &policy); err != nil {
+		return policy, apperror.DB(err, "Could not update the two-factor policy")
+	}
+	s.policy.Forget()
+	return policy, nil
+}
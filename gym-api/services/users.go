@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"gym-api/apperror"
+	"gym-api/listing"
+	"gym-api/models"
+	"gym-api/notifications"
+	"gym-api/repository"
+	"gym-api/utils"
+
+	"gorm.io/gorm"
+)
+
+// Users manages staff and trainer accounts.
+type Users interface {
+	List(ctx context.Context, role models.Role, spec listing.Spec, p listing.Params) (*listing.Page[models.User], error)
+	// Create makes an account with a password chosen by the admin.
+	Create(ctx context.Context, user NewUser) (models.User, error)
+	Update(ctx context.Context, id uint, changes UserChanges) (models.User, error)
+	// ToggleActive locks an account out or lets it back in.
+	ToggleActive(ctx context.Context, id uint) (models.User, error)
+	// Delete removes an account and returns what was removed.
+	Delete(ctx context.Context, id uint) (models.User, error)
+}
+
+type NewUser struct {
+	Name     string
+	Email    string
+	Password string
+	Role     models.Role
+	BranchID *uint
+}
+
+// UserChanges is a partial update: nil fields are left unchanged.
+type UserChanges struct {
+	Name     *string
+	Email    *string
+	Role     *models.Role // Only between staff and trainer
+	BranchID *uint
+}
+
+type users struct {
+	repos  repository.Repos
+	notify Notifier
+}
+
+func NewUsers(repos repository.Repos, notify Notifier) Users {
+	return &users{repos: repos, notify: notify}
+}
+
+func (s *users) List(ctx context.Context, role models.Role, spec listing.Spec, p listing.Params) (*listing.Page[models.User], error) {
+	page, err := s.repos.Users.List(ctx, role, spec, p)
+	if err != nil {
+		return nil, apperror.DB(err, "Failed to fetch users")
+	}
+	return page, nil
+}
+
+func (s *users) Create(ctx context.Context, input NewUser) (models.User, error) {
+	hash, err := utils.HashPassword(input.Password)
+	if err != nil {
+		return models.User{}, apperror.Internal(err, "Could not hash password")
+	}
+
+	user := models.User{
+		Name:         input.Name,
+		Email:        input.Email,
+		PasswordHash: hash,
+		Role:         input.Role,
+		IsActive:     true,
+		BranchID:     input.BranchID,
+	}
+	if err := s.repos.Users.Create(ctx, &user); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return user, apperror.Conflict("A user with this email already exists")
+		}
+		return user, apperror.DB(err, "Could not create user")
+	}
+
+	s.notify(user, notifications.Welcome, nil)
+	return user, nil
+}
+
+func (s *users) Update(ctx context.Context, id uint, changes UserChanges) (models.User, error) {
+	user, err := s.repos.Users.ByID(ctx, id)
+	if err != nil {
+		return user, apperror.FromDB(err, "User not found")
+	}
+
+	if changes.Name != nil {
+		user.Name = *changes.Name
+	}
+	if changes.Email != nil {
+		user.Email = *changes.Email
+	}
+	if changes.Role != nil {
+		if user.Role != models.RoleStaff && user.Role != models.RoleTrainer {
+			return user, apperror.BadRequest("Only staff and trainers can change role")
+		}
+		user.Role = *changes.Role
+	}
+	if changes.BranchID != nil {
+		user.BranchID = changes.BranchID
+	}
+
+	if err := s.repos.Users.Save(ctx, &user); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return user, apperror.Conflict("A user with this email already exists")
+		}
+		return user, apperror.DB(err, "Could not update user")
+	}
+	return user, nil
+}
+
+func (s *users) ToggleActive(ctx context.Context, id uint) (models.User, error) {
+	user, err := s.repos.Users.ByID(ctx, id)
+	if err != nil {
+		return user, apperror.FromDB(err, "User not found")
+	}
+
+	// Invited accounts become active by accepting the invite
+	if !user.IsActive {
+		pending, err := s.repos.Users.PendingInvite(ctx, user.ID)
+		if err != nil {
+			return user, apperror.DB(err, "Could not update user status")
+		}
+		if pending {
+			return user, apperror.Conflict("This user has not accepted their invite yet")
+		}
+	}
+
+	user.IsActive = !user.IsActive
+	if err := s.repos.Users.Save(ctx, &user); err != nil {
+		return user, apperror.DB(err, "Could not update user status")
+	}
+	return user, nil
+}
+
+func (s *users) Delete(ctx context.Context, id uint) (models.User, error) {
+	user, err := s.repos.Users.ByID(ctx, id)
+	if err != nil {
+		return user, apperror.FromDB(err, "User not found")
+	}
+	if err := s.repos.Users.Delete(ctx, &user); err != nil {
+		return user, apperror.DB(err, "Failed to delete user")
+	}
+	return user, nil
+}
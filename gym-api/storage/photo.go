@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/jpeg"
+	"net/http"
+
+	_ "image/gif"
+	_ "image/png"
+
+	"golang.org/x/image/draw"
+)
+
+// MaxPhotoBytes caps profile picture uploads.
+const MaxPhotoBytes = 5 << 20
+
+// maxPhotoPixels guards against decompression bombs: small files that
+// decode into huge images.
+const maxPhotoPixels = 40_000_000
+
+// Photo sizes stored for every profile picture. Images are center-cropped
+// to a square first.
+const (
+	PhotoSize     = 512
+	ThumbnailSize = 128
+)
+
+var (
+	ErrPhotoTooLarge   = errors.New("photo must be 5MB or smaller")
+	ErrUnsupportedType = errors.New("photo must be a JPEG, PNG or GIF image")
+	ErrInvalidImage    = errors.New("photo could not be decoded")
+)
+
+var allowedPhotoTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+}
+
+// StoredPhoto is where a processed profile picture was saved.
+type StoredPhoto struct {
+	Key          string // Base key; the stored objects are Key+"_512.jpg" and Key+"_128.jpg"
+	URL          string
+	ThumbnailURL string
+}
+
+// SavePhoto validates an uploaded image by its content (not its filename),
+// re-encodes it as square JPEGs in the standard sizes and stores them under
+// a random key. Re-encoding also strips EXIF metadata such as GPS location.
+func SavePhoto(ctx context.Context, store Storage, data []byte) (*StoredPhoto, error) {
+	if len(data) > MaxPhotoBytes {
+		return nil, ErrPhotoTooLarge
+	}
+	if !allowedPhotoTypes[http.DetectContentType(data)] {
+		return nil, ErrUnsupportedType
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || cfg.Width*cfg.Height > maxPhotoPixels {
+		return nil, ErrInvalidImage
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+
+	key := NewKey("profiles")
+	for _, size := range []int{PhotoSize, ThumbnailSize} {
+		encoded, err := encodeSquare(src, size)
+		if err != nil {
+			return nil, err
+		}
+		if err := store.Put(ctx, sizedKey(key, size), encoded, "image/jpeg"); err != nil {
+			return nil, err
+		}
+	}
+
+	return &StoredPhoto{
+		Key:          key,
+		URL:          store.URL(sizedKey(key, PhotoSize)),
+		ThumbnailURL: store.URL(sizedKey(key, ThumbnailSize)),
+	}, nil
+}
+
+// DeletePhoto removes every stored size of a photo saved by SavePhoto.
+func DeletePhoto(ctx context.Context, store Storage, key string) error {
+	var errs []error
+	for _, size := range []int{PhotoSize, ThumbnailSize} {
+		errs = append(errs, store.Delete(ctx, sizedKey(key, size)))
+	}
+	return errors.Join(errs...)
+}
+
+func sizedKey(key string, size int) string {
+	switch size {
+	case ThumbnailSize:
+		return key + "_128.jpg"
+	default:
+		return key + "_512.jpg"
+	}
+}
+
+func encodeSquare(src image.Image, size int) ([]byte, error) {
+	b := src.Bounds()
+	side := min(b.Dx(), b.Dy())
+	crop := image.Rect(0, 0, side, side).Add(image.Pt(
+		b.Min.X+(b.Dx()-side)/2,
+		b.Min.Y+(b.Dy()-side)/2,
+	))
+
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, crop, draw.Src, nil)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"os"
+)
+
+// Storage keeps uploaded files. Keys are slash-separated object names
+// generated by the API (see NewKey), never user input.
+type Storage interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	Delete(ctx context.Context, key string) error
+	// URL is the address clients fetch the object from.
+	URL(key string) string
+}
+
+// Default is the backend selected by Setup.
+var Default Storage = NewLocal("./uploads", "/uploads")
+
+// Setup picks the backend from STORAGE_DRIVER ("local" or "s3").
+func Setup() {
+	switch os.Getenv("STORAGE_DRIVER") {
+	case "s3":
+		Default = &S3{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Region:    envOr("S3_REGION", "us-east-1"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+			PublicURL: os.Getenv("S3_PUBLIC_URL"),
+			PathStyle: os.Getenv("S3_PATH_STYLE") == "true",
+		}
+		slog.Info("Photo storage on S3", "bucket", os.Getenv("S3_BUCKET"))
+	default:
+		Default = NewLocal(envOr("UPLOAD_DIR", "./uploads"), "/uploads")
+	}
+}
+
+// NewKey returns a random object key under prefix, e.g. "profiles/3f9a...".
+func NewKey(prefix string) string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return prefix + "/" + hex.EncodeToString(b)
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
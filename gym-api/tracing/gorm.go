@@ -0,0 +1,73 @@
+package tracing
+
+import (
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// GORM is a GORM plugin that traces every query. Queries run with a
+// request's context (db.WithContext(ctx)) become children of its span;
+// the rest start their own trace.
+type GORM struct{}
+
+func (GORM) Name() string {
+	return "tracing"
+}
+
+const spanKey = "tracing:span"
+
+func (GORM) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	return errors.Join(
+		cb.Create().Before("gorm:create").Register("tracing:before_create", startQuery("create")),
+		cb.Create().After("gorm:create").Register("tracing:after_create", endQuery),
+		cb.Query().Before("gorm:query").Register("tracing:before_query", startQuery("query")),
+		cb.Query().After("gorm:query").Register("tracing:after_query", endQuery),
+		cb.Update().Before("gorm:update").Register("tracing:before_update", startQuery("update")),
+		cb.Update().After("gorm:update").Register("tracing:after_update", endQuery),
+		cb.Delete().Before("gorm:delete").Register("tracing:before_delete", startQuery("delete")),
+		cb.Delete().After("gorm:delete").Register("tracing:after_delete", endQuery),
+		cb.Row().Before("gorm:row").Register("tracing:before_row", startQuery("row")),
+		cb.Row().After("gorm:row").Register("tracing:after_row", endQuery),
+		cb.Raw().Before("gorm:raw").Register("tracing:before_raw", startQuery("raw")),
+		cb.Raw().After("gorm:raw").Register("tracing:after_raw", endQuery),
+	)
+}
+
+func startQuery(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		name := operation // e.g. "query users"; the model is parsed by now
+		if db.Statement.Table != "" {
+			name += " " + db.Statement.Table
+		}
+		ctx, span := Tracer.Start(db.Statement.Context, name, trace.WithSpanKind(trace.SpanKindClient))
+		db.Statement.Context = ctx
+		db.InstanceSet(spanKey, span)
+	}
+}
+
+func endQuery(db *gorm.DB) {
+	value, ok := db.InstanceGet(spanKey)
+	if !ok {
+		return
+	}
+	span := value.(trace.Span)
+	defer span.End()
+
+	// The SQL keeps its placeholders; values may be personal data
+	span.SetAttributes(
+		semconv.DBSystemNameKey.String(db.Dialector.Name()),
+		semconv.DBCollectionName(db.Statement.Table),
+		semconv.DBQueryText(db.Statement.SQL.String()),
+		attribute.Int64("db.rows_affected", db.Statement.RowsAffected),
+	)
+	if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		span.RecordError(db.Error)
+		span.SetStatus(codes.Error, db.Error.Error())
+	}
+}
@@ -0,0 +1,71 @@
+// Package tracing sends OpenTelemetry traces of HTTP requests and database
+// queries. config.TracesExporter picks where they go: an OTLP collector,
+// stdout for local debugging, or nowhere. Incoming W3C trace context is
+// honoured either way, so log lines keep an upstream trace ID.
+package tracing
+
+import (
+	"context"
+	"log/slog"
+
+	"gym-api/config"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+)
+
+// Tracer starts the API's spans. It follows the provider set by Setup.
+var Tracer = otel.Tracer("gym-api")
+
+var provider *sdktrace.TracerProvider
+
+// Setup installs the trace exporter. The standard OTEL_* variables apply,
+// e.g. OTEL_SERVICE_NAME (default gym-api) and OTEL_TRACES_SAMPLER.
+func Setup() {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	name := config.TracesExporter()
+	switch name {
+	case "none":
+		return
+	case "otlp":
+		exporter, err = otlptracehttp.New(context.Background())
+	case "console":
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	default:
+		slog.Warn("Unknown OTEL_TRACES_EXPORTER, tracing is off", "exporter", name)
+		return
+	}
+	if err != nil {
+		slog.Error("Could not create the trace exporter, tracing is off", "exporter", name, "error", err)
+		return
+	}
+
+	res, err := resource.New(context.Background(),
+		resource.WithAttributes(semconv.ServiceName("gym-api")),
+		resource.WithFromEnv(), // OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES win
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		slog.Warn("Incomplete trace resource", "error", err)
+	}
+
+	provider = sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	slog.Info("Tracing enabled", "exporter", name)
+}
+
+// Shutdown sends the spans still buffered. Call it before exiting.
+func Shutdown(ctx context.Context) error {
+	if provider == nil {
+		return nil
+	}
+	return provider.Shutdown(ctx)
+}
@@ -0,0 +1,73 @@
+package twofactor
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"gym-api/models"
+)
+
+// Roles the policy can be set for.
+var Roles = []models.Role{models.RoleAdmin, models.RoleStaff, models.RoleTrainer, models.RoleMember}
+
+// How long Required trusts its cached policy. Other replicas pick up a
+// change within this time.
+const policyTTL = 30 * time.Second
+
+// PolicyStore is where the per-role policy is kept, repository.TwoFactor
+// in main.
+type PolicyStore interface {
+	Policies(ctx context.Context) ([]models.TwoFactorPolicy, error)
+}
+
+// Policy says which roles must sign in with a second factor. Required is
+// checked on every authenticated request, so the policy is cached; if it
+// cannot be reloaded the last known policy stays in force.
+type Policy struct {
+	mu       sync.Mutex
+	store    PolicyStore
+	required map[models.Role]bool
+	loaded   time.Time
+}
+
+// Default is the policy the middleware and the login handlers enforce. It
+// requires nothing until Use gives it a store.
+var Default = &Policy{}
+
+// Use reads the policy from store from now on.
+func (p *Policy) Use(store PolicyStore) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.store, p.required, p.loaded = store, nil, time.Time{}
+}
+
+// Required reports whether users with role must sign in with a second
+// factor.
+func (p *Policy) Required(ctx context.Context, role models.Role) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.store != nil && time.Since(p.loaded) > policyTTL {
+		rows, err := p.store.Policies(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to load the 2FA policy, keeping the previous one", "error", err)
+		} else {
+			required := make(map[models.Role]bool, len(rows))
+			for _, row := range rows {
+				required[row.Role] = row.Required
+			}
+			p.required, p.loaded = required, time.Now()
+		}
+	}
+	return p.required[role]
+}
+
+// Forget drops the cached policy, so this replica applies a change at
+// once.
+func (p *Policy) Forget() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.loaded = time.Time{}
+}
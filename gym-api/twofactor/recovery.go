@@ -0,0 +1,61 @@
+package twofactor
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// RecoveryCodeCount is how many codes a user gets at a time.
+const RecoveryCodeCount = 10
+
+// Unambiguous lowercase letters and digits: no 0/o, 1/l/i
+const recoveryAlphabet = "23456789abcdefghjkmnpqrstuvwxyz"
+
+// NewRecoveryCodes returns RecoveryCodeCount one-time codes in the form
+// xxxxx-xxxxx. Only HashRecoveryCode(code) should be stored.
+func NewRecoveryCodes() ([]string, error) {
+	codes := make([]string, RecoveryCodeCount)
+	for i := range codes {
+		b, err := randomChars(10)
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = string(b[:5]) + "-" + string(b[5:])
+	}
+	return codes, nil
+}
+
+// randomChars draws n characters from recoveryAlphabet, skipping bytes
+// that would favour its first characters.
+func randomChars(n int) ([]byte, error) {
+	limit := 256 - 256%len(recoveryAlphabet)
+	out := make([]byte, 0, n)
+	buf := make([]byte, n)
+	for len(out) < n {
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		for _, c := range buf {
+			if int(c) < limit && len(out) < n {
+				out = append(out, recoveryAlphabet[int(c)%len(recoveryAlphabet)])
+			}
+		}
+	}
+	return out, nil
+}
+
+// HashRecoveryCode hashes a recovery code for storage and lookup. Case,
+// spaces and dashes are ignored so codes can be typed loosely. Codes are
+// random, so a fast unsalted hash is enough.
+func HashRecoveryCode(code string) string {
+	normalized := strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(strings.TrimSpace(code)))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,43 @@
+package twofactor
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestNewRecoveryCodes(t *testing.T) {
+	codes, err := NewRecoveryCodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != RecoveryCodeCount {
+		t.Fatalf("got %d codes, want %d", len(codes), RecoveryCodeCount)
+	}
+	format := regexp.MustCompile(`^[` + recoveryAlphabet + `]{5}-[` + recoveryAlphabet + `]{5}$`)
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if !format.MatchString(code) {
+			t.Errorf("code %q is not xxxxx-xxxxx from the alphabet", code)
+		}
+		if seen[code] {
+			t.Errorf("code %q issued twice", code)
+		}
+		seen[code] = true
+	}
+}
+
+func TestHashRecoveryCodeIgnoresFormatting(t *testing.T) {
+	want := HashRecoveryCode("abcde-fghjk")
+	for _, typed := range []string{"ABCDE-FGHJK", " abcdefghjk ", "abcde fghjk"} {
+		if got := HashRecoveryCode(typed); got != want {
+			t.Errorf("HashRecoveryCode(%q) differs", typed)
+		}
+	}
+	if HashRecoveryCode("abcde-fghjm") == want {
+		t.Error("different codes hash the same")
+	}
+	if strings.Contains(want, "abcde") {
+		t.Error("hash contains the code")
+	}
+}
@@ -0,0 +1,87 @@
+package twofactor
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The SHA1 test vectors from RFC 6238, appendix B. The RFC's codes have
+// eight digits; the last six are the six-digit code.
+func TestCodeRFC6238(t *testing.T) {
+	secret := encoding.EncodeToString([]byte("12345678901234567890"))
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+	for _, tt := range tests {
+		t.Run(strconv.FormatInt(tt.unix, 10), func(t *testing.T) {
+			got, err := Code(secret, Step(time.Unix(tt.unix, 0)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := tt.want[2:]; got != want {
+				t.Errorf("Code = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestCodeLowercaseSecret(t *testing.T) {
+	secret := encoding.EncodeToString([]byte("12345678901234567890"))
+	upper, _ := Code(secret, 1)
+	lower, err := Code(strings.ToLower(secret), 1)
+	if err != nil || lower != upper {
+		t.Errorf("lowercase secret: %s, %v; want %s", lower, err, upper)
+	}
+	if _, err := Code("not base32!", 1); err == nil {
+		t.Error("invalid secret accepted")
+	}
+}
+
+func TestMatchSkew(t *testing.T) {
+	secret, err := NewSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1700000000, 0)
+	current := Step(now)
+
+	for offset := int64(-2); offset <= 2; offset++ {
+		code, _ := Code(secret, current+offset)
+		step, ok := Match(secret, code, now)
+		want := offset >= -Skew && offset <= Skew
+		if ok != want {
+			t.Errorf("code from step %+d: matched = %v, want %v", offset, ok, want)
+		}
+		if ok && step != current+offset {
+			t.Errorf("code from step %+d: matched step %d, want %d", offset, step, current+offset)
+		}
+	}
+}
+
+func TestMatchRejects(t *testing.T) {
+	secret, _ := NewSecret()
+	now := time.Now()
+	code, _ := Code(secret, Step(now))
+
+	if _, ok := Match(secret, code[:3]+" "+code[3:], now); !ok {
+		t.Error("code with a space rejected")
+	}
+	for _, bad := range []string{"", code[:5], code + "0"} {
+		if _, ok := Match(secret, bad, now); ok {
+			t.Errorf("code %q accepted", bad)
+		}
+	}
+	if _, ok := Match("", code, now); ok {
+		t.Error("code accepted without a secret")
+	}
+}
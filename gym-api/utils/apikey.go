@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// GenerateAPIKey returns a new random device API key. Only HashAPIKey(key)
+// should be stored.
+func GenerateAPIKey() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "gymk_" + hex.EncodeToString(b), nil
+}
+
+// HashAPIKey hashes a device key for storage and lookup. Keys are random
+// and long, so a fast unsalted hash is enough.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateInviteToken returns a new random staff invite token. Like device
+// keys, only HashAPIKey(token) should be stored.
+func GenerateInviteToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
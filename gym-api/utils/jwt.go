@@ -10,26 +10,52 @@ import (
 var jwtSecret = []byte("SUPER_SECRET_KEY_CHANGE_IN_PROD")
 
 type Claims struct {
-	UserID uint   `json:"user_id"`
-	Role   string `json:"role"`
+	UserID    uint   `json:"user_id"`
+	Role      string `json:"role"`
+	TwoFactor bool   `json:"2fa,omitempty"`     // Signed in with a second factor
+	Purpose   string `json:"purpose,omitempty"` // Set on tokens that are not sessions, e.g. PurposeTwoFactor
 	jwt.RegisteredClaims
 }
 
-func GenerateToken(userID uint, role string) (string, error) {
-	claims := Claims{
-		UserID: userID,
-		Role:   role,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
-	}
+// PurposeTwoFactor marks the challenge token handed out between the
+// password and the second factor.
+const PurposeTwoFactor = "2fa"
+
+// ChallengeTTL is how long the user has to enter their second factor.
+const ChallengeTTL = 5 * time.Minute
+
+// GenerateToken issues a session token. twoFactor records that the user
+// also passed a second factor.
+func GenerateToken(userID uint, role string, twoFactor bool) (string, error) {
+	return sign(Claims{UserID: userID, Role: role, TwoFactor: twoFactor}, 24*time.Hour)
+}
+
+// GenerateChallengeToken proves the password was right, for the second
+// login step. It is not accepted as a session.
+func GenerateChallengeToken(userID uint, role string) (string, error) {
+	return sign(Claims{UserID: userID, Role: role, Purpose: PurposeTwoFactor}, ChallengeTTL)
+}
 
+func sign(claims Claims, ttl time.Duration) (string, error) {
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString(jwtSecret)
 }
 
+// ValidateToken checks a session token.
 func ValidateToken(tokenString string) (*Claims, error) {
+	return validate(tokenString, "")
+}
+
+// ValidateChallengeToken checks a token from GenerateChallengeToken.
+func ValidateChallengeToken(tokenString string) (*Claims, error) {
+	return validate(tokenString, PurposeTwoFactor)
+}
+
+func validate(tokenString, purpose string) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
 		return jwtSecret, nil
 	})
@@ -38,7 +64,7 @@ func ValidateToken(tokenString string) (*Claims, error) {
 		return nil, err
 	}
 
-	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
+	if claims, ok := token.Claims.(*Claims); ok && token.Valid && claims.Purpose == purpose {
 		return claims, nil
 	}
 
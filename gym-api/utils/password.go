@@ -1,6 +1,11 @@
 package utils
 
 import (
+	"errors"
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -13,3 +18,36 @@ func CheckPasswordHash(password, hash string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err == nil
 }
+
+// Password rules shared by every place that sets a password.
+const (
+	MinPasswordLength = 8
+	MaxPasswordLength = 72 // bcrypt ignores anything longer
+)
+
+var (
+	ErrPasswordTooShort = fmt.Errorf("must be at least %d characters", MinPasswordLength)
+	ErrPasswordTooLong  = fmt.Errorf("must be at most %d bytes", MaxPasswordLength)
+	ErrPasswordTooWeak  = errors.New("must contain at least one letter and one digit")
+)
+
+// CheckPasswordStrength reports why a new password is not acceptable, or
+// nil if it is.
+func CheckPasswordStrength(password string) error {
+	if utf8.RuneCountInString(password) < MinPasswordLength {
+		return ErrPasswordTooShort
+	}
+	if len(password) > MaxPasswordLength {
+		return ErrPasswordTooLong
+	}
+
+	var letter, digit bool
+	for _, r := range password {
+		letter = letter || unicode.IsLetter(r)
+		digit = digit || unicode.IsDigit(r)
+	}
+	if !letter || !digit {
+		return ErrPasswordTooWeak
+	}
+	return nil
+}
@@ -3,7 +3,7 @@ package utils
 import (
 	"gym-api/config"
 	"gym-api/models"
-	"log"
+	"log/slog"
 	"time"
 )
 
@@ -21,12 +21,12 @@ func SeedAdmin() {
 		}
 
 		if err := config.DB.Create(&admin).Error; err != nil {
-			log.Println("Failed to seed admin:", err)
+			slog.Error("Failed to seed admin", "error", err)
 		} else {
-			log.Println("Admin account seeded: admin@gmail.com / admin123")
+			slog.Info("Admin account seeded", "email", "admin@gmail.com", "password", "admin123")
 		}
 	} else {
-		log.Println("Admin seed skipped (already exists)")
+		slog.Debug("Admin seed skipped, already exists")
 	}
 
 	// Seed Staff
@@ -41,9 +41,9 @@ func SeedAdmin() {
 			Role:         models.RoleStaff,
 		}
 		if err := config.DB.Create(&staff).Error; err != nil {
-			log.Println("Failed to seed staff:", err)
+			slog.Error("Failed to seed staff", "error", err)
 		} else {
-			log.Println("Staff account seeded: staff@gmail.com / staff123")
+			slog.Info("Staff account seeded", "email", "staff@gmail.com", "password", "staff123")
 		}
 	}
 
@@ -75,9 +75,9 @@ func SeedAdmin() {
 		}
 
 		if err := config.DB.Create(&member).Error; err != nil {
-			log.Println("Failed to seed member:", err)
+			slog.Error("Failed to seed member", "error", err)
 		} else {
-			log.Println("Member seeded: john@example.com")
+			slog.Info("Member seeded", "email", "john@example.com")
 
 			// Seed Attendance for this member
 			var admin models.User
@@ -88,13 +88,13 @@ func SeedAdmin() {
 				scanTime := time.Now().AddDate(0, 0, -i)
 				log := models.Attendance{
 					TrainerID: member.ID, // User ID
-					ScannedBy: admin.ID,
+					ScannedBy: &admin.ID,
 					ScanTime:  scanTime,
 					Date:      scanTime, // GORM handles time.Time to date type usually
 				}
 				config.DB.Create(&log)
 			}
-			log.Println("Seeded 3 attendance logs for John Doe")
+			slog.Info("Seeded attendance logs", "member", "John Doe", "count", 3)
 		}
 	}
 }
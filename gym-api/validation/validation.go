@@ -0,0 +1,192 @@
+// Package validation checks request inputs against the `validate` struct
+// tags and reports every failing field at once.
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"gym-api/gates"
+	"gym-api/utils"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// FieldError is one failed rule, keyed by the JSON name of the field.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Errors is returned by Struct when any field fails.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	parts := make([]string, len(e))
+	for i, f := range e {
+		parts[i] = f.Field + " " + f.Message
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Normalizer is implemented by inputs that clean themselves up (trim,
+// lowercase emails, ...) before they are validated.
+type Normalizer interface {
+	Normalize()
+}
+
+var validate = newValidator()
+
+var phonePattern = regexp.MustCompile(`^\+?[0-9 ()\-]{7,20}$`)
+
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+
+	// Report fields by the name clients send
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		for _, tag := range []string{"json", "form"} {
+			if name, _, _ := strings.Cut(f.Tag.Get(tag), ","); name != "" && name != "-" {
+				return name
+			}
+		}
+		return f.Name
+	})
+
+	v.RegisterValidation("password", func(fl validator.FieldLevel) bool {
+		return utils.CheckPasswordStrength(fl.Field().String()) == nil
+	})
+	// Names go into email subjects and headers; line breaks would end them
+	v.RegisterValidation("name", func(fl validator.FieldLevel) bool {
+		return !strings.ContainsFunc(fl.Field().String(), unicode.IsControl)
+	})
+	// Empty is allowed; add "required" where a phone number is mandatory
+	v.RegisterValidation("phone", func(fl validator.FieldLevel) bool {
+		return fl.Field().String() == "" || phonePattern.MatchString(fl.Field().String())
+	})
+
+	// Doors go into gate commands; empty leaves the gate's default
+	v.RegisterValidation("door", func(fl validator.FieldLevel) bool {
+		return fl.Field().String() == "" || gates.ValidDoor(fl.Field().String())
+	})
+
+	return v
+}
+
+// Struct normalizes input if it implements Normalizer, then validates it.
+// It returns Errors when any rule fails.
+func Struct(input any) error {
+	if n, ok := input.(Normalizer); ok {
+		n.Normalize()
+	}
+
+	err := validate.Struct(input)
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return err
+	}
+
+	out := make(Errors, len(verrs))
+	for i, fe := range verrs {
+		out[i] = FieldError{Field: fieldPath(fe), Rule: fe.Tag(), Message: message(fe)}
+	}
+	return out
+}
+
+// fieldPath drops the struct name from the namespace: "scans[0].client_id".
+func fieldPath(fe validator.FieldError) string {
+	_, path, _ := strings.Cut(fe.Namespace(), ".")
+	return path
+}
+
+func message(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required", "required_if", "required_unless", "required_with":
+		return "is required"
+	case "required_without":
+		return "is required without " + snakeCase(fe.Param())
+	case "email":
+		return "must be a valid email address"
+	case "ip":
+		return "must be a valid IP address"
+	case "password":
+		if err := utils.CheckPasswordStrength(fe.Value().(string)); err != nil {
+			return err.Error()
+		}
+		return "is not strong enough"
+	case "name":
+		return "must not contain control characters"
+	case "phone":
+		return "must be a valid phone number"
+	case "door":
+		return "may only contain letters, digits, _ and -"
+	case "oneof":
+		return "must be one of " + strings.Join(strings.Fields(fe.Param()), ", ")
+	case "min":
+		if fe.Kind() == reflect.String {
+			return fmt.Sprintf("must be at least %s characters", fe.Param())
+		}
+		return "must be at least " + fe.Param()
+	case "max":
+		switch fe.Kind() {
+		case reflect.String:
+			return fmt.Sprintf("must be at most %s characters", fe.Param())
+		case reflect.Slice:
+			return fmt.Sprintf("must have at most %s items", fe.Param())
+		}
+		return "must be at most " + fe.Param()
+	case "gt":
+		return "must be greater than " + fe.Param()
+	case "gte":
+		return "must be " + fe.Param() + " or more"
+	case "lte":
+		return "must be " + fe.Param() + " or less"
+	case "url":
+		return "must be a valid URL"
+	case "hostname_port":
+		return "must be host:port"
+	case "nefield":
+		return "must differ from " + snakeCase(fe.Param())
+	case "number":
+		return "must be a number"
+	case "datetime":
+		return "must be a date in the format " + fe.Param()
+	}
+	return "is invalid"
+}
+
+// snakeCase turns a Go field name into its JSON name: OldPassword ->
+// old_password, UserID -> user_id. Used for rules that name another field.
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			// A new word starts after a lowercase letter, or at the last
+			// capital of an acronym followed by lowercase (HTTPServer)
+			if i > 0 && (!unicode.IsUpper(runes[i-1]) || i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// NormalizeEmail trims and lowercases an email address so lookups and the
+// unique index treat "Jane@Example.com " and "jane@example.com" alike.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// Trim trims surrounding whitespace from each string in place.
+func Trim(fields ...*string) {
+	for _, f := range fields {
+		*f = strings.TrimSpace(*f)
+	}
+}
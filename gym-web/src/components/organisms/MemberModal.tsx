@@ -1,9 +1,10 @@
-import React, { useState, useEffect } from 'react';
+import React, { useState, useEffect, useRef } from 'react';
 import axios from 'axios';
 import { Button } from '../atoms/Button';
 import { Input } from '../atoms/Input';
 import { Icons } from '../atoms/Icon';
 import { API_URL } from '../../config';
+import { newIdempotencyKey } from '../../idempotency';
 
 interface MemberModalProps {
   onClose: () => void;
@@ -17,6 +18,8 @@ export const MemberModal: React.FC<MemberModalProps> = ({ onClose, onSuccess, in
   });
   const [loading, setLoading] = useState(false);
   const [packages, setPackages] = useState<any[]>([]);
+  // Kept until the server answers, so a retry cannot create the member twice
+  const createKey = useRef<string | null>(null);
 
   // Fetch packages on mount
   useEffect(() => {
@@ -77,13 +80,16 @@ export const MemberModal: React.FC<MemberModalProps> = ({ onClose, onSuccess, in
         if (member.packageId) formData.append('package_id', member.packageId);
         if (member.file) formData.append('profile_picture', member.file);
 
-        await axios.post(`${API_URL}/auth/register`, formData, {
-          headers: { 'Content-Type': 'multipart/form-data' }
+        createKey.current ??= newIdempotencyKey();
+        await axios.post(`${API_URL}/management/members`, formData, {
+          headers: { 'Content-Type': 'multipart/form-data', 'Idempotency-Key': createKey.current }
         });
+        createKey.current = null;
       }
       onSuccess();
       onClose();
     } catch (error) {
+      if (axios.isAxiosError(error) && error.response) createKey.current = null;
       alert(`Failed to ${initialData ? 'update' : 'create'} member.`);
     } finally {
       setLoading(false);
@@ -1,8 +1,9 @@
-import React, { useState } from 'react';
+import React, { useRef, useState } from 'react';
 import { Card } from '../atoms/Card';
 import { Icons } from '../atoms/Icon';
 import axios from 'axios';
 import { API_URL } from '../../config';
+import { newIdempotencyKey } from '../../idempotency';
 
 interface Member {
   id: number;
@@ -27,6 +28,8 @@ export const MembersTable: React.FC<MembersTableProps> = ({ members, packages, o
   const [searchTerm, setSearchTerm] = useState('');
   const [filterStatus, setFilterStatus] = useState('all');
   const [viewImage, setViewImage] = useState<string | null>(null);
+  // Kept until the server answers, so a retry cannot subscribe twice
+  const subscribeKey = useRef<string | null>(null);
 
   const filteredMembers = members.filter(m => {
     const matchesSearch = m.name.toLowerCase().includes(searchTerm.toLowerCase()) || 
@@ -37,13 +40,18 @@ export const MembersTable: React.FC<MembersTableProps> = ({ members, packages, o
 
   const handleSubscribe = async () => {
     if (!selectedMember || !selectedPackage) return;
+    subscribeKey.current ??= newIdempotencyKey();
     try {
-      await axios.post(`${API_URL}/admin/members/subscribe`, {
+      await axios.post(`${API_URL}/management/members/subscribe`, {
         member_id: selectedMember, package_id: selectedPackage
-      });
+      }, { headers: { 'Idempotency-Key': subscribeKey.current } });
+      subscribeKey.current = null;
       setSelectedMember(null);
       onUpdate();
-    } catch (e) { alert('Error updating subscription'); }
+    } catch (e) {
+      if (axios.isAxiosError(e) && e.response) subscribeKey.current = null;
+      alert('Error updating subscription');
+    }
   };
 
   const handleDelete = async (id: number) => {
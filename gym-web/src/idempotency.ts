@@ -0,0 +1,5 @@
+// A fresh Idempotency-Key for one user action. Reuse it when retrying an
+// action that got no answer, so the server runs it only once.
+// crypto.randomUUID needs HTTPS; getRandomValues works everywhere.
+export const newIdempotencyKey = () =>
+  Array.from(crypto.getRandomValues(new Uint8Array(16)), b => b.toString(16).padStart(2, '0')).join('');
@@ -61,7 +61,6 @@ class _AdminDashboardState extends State<AdminDashboard> {
       final todaysLogs = await _apiService.getAttendanceLogs(
         startDate: todayStr,
         endDate: todayStr,
-        limit: 1000,
       );
       _todaysCheckIns = todaysLogs.length;
 
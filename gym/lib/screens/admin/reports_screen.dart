@@ -45,7 +45,6 @@ class _ReportsScreenState extends State<ReportsScreen>
       _attendanceLogs = await _apiService.getAttendanceLogs(
         startDate: DateFormat('yyyy-MM-dd').format(thirtyDaysAgo),
         endDate: DateFormat('yyyy-MM-dd').format(now),
-        limit: 2000,
       );
     } catch (e) {
       debugPrint("Error loading reports data: $e");
@@ -18,6 +18,10 @@ class AdminScannerScreen extends StatefulWidget {
 class _AdminScannerScreenState extends State<AdminScannerScreen> {
   bool _isProcessing = false;
   final ApiService _apiService = ApiService();
+  // A scan that got no answer keeps its key, so scanning the same code
+  // again cannot check the member in twice
+  String? _pendingScan;
+  String? _scanKey;
 
   void _onDetect(BarcodeCapture capture) async {
     if (_isProcessing) return;
@@ -272,8 +276,18 @@ class _AdminScannerScreenState extends State<AdminScannerScreen> {
   }
 
   Future<void> _submitAttendance(Map<String, dynamic> payload) async {
+    final scan = jsonEncode(payload);
+    if (scan != _pendingScan || _scanKey == null) {
+      _pendingScan = scan;
+      _scanKey = ApiService.newIdempotencyKey();
+    }
     try {
-      await _apiService.post('/management/scan', data: payload);
+      await _apiService.post(
+        '/management/scan',
+        data: payload,
+        idempotencyKey: _scanKey,
+      );
+      _scanKey = null;
       if (mounted) {
         ScaffoldMessenger.of(context).showSnackBar(
           const SnackBar(content: Text('Attendance Marked Successfully!')),
@@ -283,6 +297,7 @@ class _AdminScannerScreenState extends State<AdminScannerScreen> {
     } on DioException catch (e) {
       String errorMessage = 'Error marking attendance';
       if (e.response != null) {
+        _scanKey = null; // Answered, so a new scan is a new request
         if (e.response?.statusCode == 409) {
           errorMessage = 'Attendance already marked for today!';
         } else if (e.response?.data != null &&
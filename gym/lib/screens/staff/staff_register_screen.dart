@@ -21,6 +21,9 @@ class _StaffRegisterScreenState extends State<StaffRegisterScreen> {
   int? _selectedDuration;
   bool _isLoading = false;
   final ApiService _apiService = ApiService();
+  // Kept until the server answers, so retrying after a timeout cannot
+  // register the member twice
+  String? _registerKey;
   final ImagePicker _picker = ImagePicker();
 
   Future<void> _pickImage(ImageSource source) async {
@@ -54,7 +57,13 @@ class _StaffRegisterScreenState extends State<StaffRegisterScreen> {
           'sub_end_date': _selectedDate!.toIso8601String().split('T')[0],
       });
 
-      await _apiService.post('/auth/register', data: formData);
+      _registerKey ??= ApiService.newIdempotencyKey();
+      await _apiService.post(
+        '/management/members',
+        data: formData,
+        idempotencyKey: _registerKey,
+      );
+      _registerKey = null;
 
       if (mounted) {
         ScaffoldMessenger.of(context).showSnackBar(
@@ -66,6 +75,7 @@ class _StaffRegisterScreenState extends State<StaffRegisterScreen> {
         setState(() => _imageFile = null);
       }
     } catch (e) {
+      if (e is DioException && e.response != null) _registerKey = null;
       if (mounted) {
         ScaffoldMessenger.of(
           context,
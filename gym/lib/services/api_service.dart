@@ -1,3 +1,5 @@
+import 'dart:math';
+
 import 'package:dio/dio.dart';
 import 'package:shared_preferences/shared_preferences.dart';
 import '../config/constants.dart';
@@ -32,8 +34,29 @@ class ApiService {
     );
   }
 
-  Future<Response> post(String path, {dynamic data}) async {
-    return _dio.post(path, data: data);
+  /// Sends a POST. Pass the same [idempotencyKey] when retrying an action
+  /// whose first attempt got no answer, so the server runs it only once.
+  Future<Response> post(
+    String path, {
+    dynamic data,
+    String? idempotencyKey,
+  }) async {
+    return _dio.post(
+      path,
+      data: data,
+      options: idempotencyKey == null
+          ? null
+          : Options(headers: {'Idempotency-Key': idempotencyKey}),
+    );
+  }
+
+  /// A fresh Idempotency-Key for one user action.
+  static String newIdempotencyKey() {
+    final random = Random.secure();
+    return List.generate(
+      16,
+      (_) => random.nextInt(256).toRadixString(16).padLeft(2, '0'),
+    ).join();
   }
 
   Future<Response> get(
@@ -76,9 +99,11 @@ class ApiService {
     }
   }
 
+  /// Attendance logs, newest first. Without [page] or [limit] every log in
+  /// the date range is returned; with either, one page (20 by default).
   Future<List<Attendance>> getAttendanceLogs({
-    int page = 1,
-    int limit = 20,
+    int? page,
+    int? limit,
     String? startDate,
     String? endDate,
   }) async {
@@ -86,8 +111,8 @@ class ApiService {
       final response = await _dio.get(
         '/management/attendance',
         queryParameters: {
-          'page': page,
-          'limit': limit,
+          if (page != null) 'page': page,
+          if (limit != null) 'limit': limit,
           if (startDate != null) 'start_date': startDate,
           if (endDate != null) 'end_date': endDate,
         },